@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"comptes/internal/config"
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"fmt"
+	"strings"
+)
+
+// handleAccounts runs "comptes accounts [add|rename|close|reopen] ..."; without a
+// subcommand it lists the accounts, closed ones included
+func (c *CLI) handleAccounts(args []string) error {
+	if len(args) < 3 {
+		return c.showAccounts("text", service.LedgerView{})
+	}
+	action := args[2]
+
+	var positional []string
+	var account domain.Account
+	var transferTo string
+	rest := args[3:]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		switch arg {
+		case "--help", "-?":
+			ShowHelp("accounts")
+			return nil
+		case "--name", "-n", "--type", "--currency", "-C", "--initial-balance", "--transfer-to":
+			if i+1 >= len(rest) {
+				return errors.MissingArguments("accounts " + action + " " + arg)
+			}
+			value := rest[i+1]
+			i++
+			switch arg {
+			case "--name", "-n":
+				account.Name = value
+			case "--type":
+				account.Type = value
+			case "--currency", "-C":
+				account.Currency = value
+			case "--initial-balance":
+				balance, err := parseAmount(value)
+				if err != nil {
+					return errors.InvalidAmount(value, err)
+				}
+				account.InitialBalance = balance
+			default:
+				transferTo = strings.ToUpper(value)
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	want := 1
+	if action == "rename" {
+		want = 2
+	}
+	if len(positional) != want {
+		ShowHelp("accounts")
+		return errors.MissingArguments("accounts " + action)
+	}
+	accountID := strings.ToUpper(positional[0])
+
+	switch action {
+	case "add":
+		account.ID = accountID
+		if account.Type == "" {
+			account.Type = "checking"
+		}
+		if err := c.accountService.AddAccount(account); err != nil {
+			return err
+		}
+		fmt.Printf("Account %s added.\n", accountID)
+	case "rename":
+		if err := c.accountService.RenameAccount(accountID, positional[1]); err != nil {
+			return err
+		}
+		updateConfig(func(configPath string) error { return config.SetAccountName(configPath, accountID, positional[1]) })
+		fmt.Printf("Account %s renamed to %q.\n", accountID, positional[1])
+	case "close":
+		legs, err := c.accountService.CloseAccount(accountID, transferTo)
+		if err != nil {
+			return err
+		}
+		if len(legs) > 0 {
+			fmt.Printf("Closing transfer of %s recorded (%s).\n", legs[0].Amount.Abs(), legs[0].ID)
+		}
+		updateConfig(func(configPath string) error { return config.SetAccountActive(configPath, accountID, false) })
+		fmt.Printf("Account %s closed.\n", accountID)
+	case "reopen":
+		if err := c.accountService.ReopenAccount(accountID); err != nil {
+			return err
+		}
+		updateConfig(func(configPath string) error { return config.SetAccountActive(configPath, accountID, true) })
+		fmt.Printf("Account %s reopened.\n", accountID)
+	default:
+		ShowHelp("accounts")
+		return errors.InvalidCommand("accounts " + action)
+	}
+	return nil
+}
@@ -40,15 +40,41 @@ type TransactionInput struct {
 	ID          string       `json:"id"`
 	Account     string       `json:"account"`
 	Date        FlexibleDate `json:"date"`
-	Amount      float64      `json:"amount"`
+	Amount      domain.Money `json:"amount"`
 	Description string       `json:"description"`
 	Categories  []string     `json:"categories"`
 	Tags        []string     `json:"tags"`
+	Splits      []SplitInput `json:"splits"`
 	IsActive    bool         `json:"is_active"`
 	CreatedAt   FlexibleDate `json:"created_at"`
 	UpdatedAt   FlexibleDate `json:"updated_at"`
 }
 
+// SplitInput structure pour une ligne de ventilation dans le JSON
+type SplitInput struct {
+	Amount      domain.Money `json:"amount"`
+	Description string       `json:"description"`
+	Categories  []string     `json:"categories"`
+	Tags        []string     `json:"tags"`
+}
+
+// toDomainSplits converts split inputs, keeping nil (no change) distinct from empty (remove splits)
+func toDomainSplits(inputs []SplitInput) []domain.Split {
+	if inputs == nil {
+		return nil
+	}
+	splits := make([]domain.Split, 0, len(inputs))
+	for _, input := range inputs {
+		splits = append(splits, domain.Split{
+			Amount:      input.Amount,
+			Description: input.Description,
+			Categories:  input.Categories,
+			Tags:        input.Tags,
+		})
+	}
+	return splits
+}
+
 func (c *CLI) handleAdd(args []string) error {
 	if len(args) < 3 {
 		ShowHelp("add")
@@ -106,8 +132,9 @@ func (c *CLI) handleAdd(args []string) error {
 	} else {
 		// Flags mode (new behavior)
 		var account, description, date string
-		var amount float64
+		var amount domain.Money
 		var categories, tags []string
+		var splits []domain.Split
 		var hasAmount bool
 
 		// Parse flags
@@ -125,7 +152,7 @@ func (c *CLI) handleAdd(args []string) error {
 			case arg == "-m" || arg == "--amount":
 				if i+1 < len(args) {
 					var err error
-					amount, err = parseFloat(args[i+1])
+					amount, err = parseAmount(args[i+1])
 					if err != nil {
 						return fmt.Errorf("invalid amount: %w", err)
 					}
@@ -162,6 +189,17 @@ func (c *CLI) handleAdd(args []string) error {
 				} else {
 					return fmt.Errorf("--date requires a value")
 				}
+			case arg == "-s" || arg == "--split":
+				if i+1 < len(args) {
+					split, err := parseSplit(args[i+1])
+					if err != nil {
+						return fmt.Errorf("invalid split: %w", err)
+					}
+					splits = append(splits, split)
+					i++
+				} else {
+					return fmt.Errorf("--split requires a value")
+				}
 			case arg == "--immediate" || arg == "-i":
 				forceDirect = true
 			case strings.HasPrefix(arg, "-"):
@@ -205,8 +243,8 @@ func (c *CLI) handleAdd(args []string) error {
 			}
 			return fmt.Errorf("account is required (use -a/--account)")
 		}
-		if !hasAmount {
-			return fmt.Errorf("amount is required (use -m/--amount)")
+		if !hasAmount && len(splits) == 0 {
+			return fmt.Errorf("amount is required (use -m/--amount or -s/--split)")
 		}
 		if description == "" {
 			return fmt.Errorf("description is required (use --desc/--description)")
@@ -219,6 +257,7 @@ func (c *CLI) handleAdd(args []string) error {
 		transaction.Description = description
 		transaction.Categories = categories
 		transaction.Tags = tags
+		transaction.Splits = splits
 		transaction.IsActive = true
 		transaction.CreatedAt = time.Now()
 		transaction.UpdatedAt = time.Now()
@@ -271,6 +310,7 @@ func (c *CLI) buildTransactionFromInput(input TransactionInput) domain.Transacti
 		Description: input.Description,
 		Categories:  input.Categories,
 		Tags:        input.Tags,
+		Splits:      toDomainSplits(input.Splits),
 		IsActive:    input.IsActive,
 	}
 
@@ -303,11 +343,33 @@ func (c *CLI) buildTransactionFromInput(input TransactionInput) domain.Transacti
 	return transaction
 }
 
-// parseFloat parses a float64 string
-func parseFloat(s string) (float64, error) {
-	var result float64
-	_, err := fmt.Sscanf(s, "%f", &result)
-	return result, err
+// parseAmount parses an exact decimal amount (e.g. -25.50, -25,50 or "12 EUR")
+func parseAmount(s string) (domain.Money, error) {
+	return domain.ParseMoney(s, "")
+}
+
+// parseSplit parses a split line "<amount>:<categories>[:<tags>[:<description>]]",
+// e.g. "-90:ALM" or "-30:VET:URG:Pull" (categories and tags are comma-separated)
+func parseSplit(s string) (domain.Split, error) {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) < 2 {
+		return domain.Split{}, fmt.Errorf("expected <amount>:<categories>[:<tags>[:<description>]], got %q", s)
+	}
+	amount, err := parseAmount(parts[0])
+	if err != nil {
+		return domain.Split{}, err
+	}
+	split := domain.Split{
+		Amount:     amount,
+		Categories: parseList(parts[1]),
+	}
+	if len(parts) > 2 {
+		split.Tags = parseList(parts[2])
+	}
+	if len(parts) > 3 {
+		split.Description = parts[3]
+	}
+	return split, nil
 }
 
 // parseList parses a comma-separated list into a []string
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"comptes/internal/config"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+)
+
+func (c *CLI) handleBackup(args []string) error {
+	target := ""
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case "--help", "-?":
+			ShowHelp("backup")
+			return nil
+		case "-o", "--output":
+			if i+1 >= len(args) {
+				return errors.MissingArguments("backup --output")
+			}
+			target = args[i+1]
+			i++
+		}
+	}
+	if target == "" {
+		target = storage.BackupPath(c.dataDir, "")
+	}
+
+	manifest, err := storage.Backup(c.storage, c.dataDir, config.GetConfigPath(), target, "")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backup written to %s (%d files, %s)\n", target, len(manifest.Files), formatSize(manifest.Size()))
+	return nil
+}
+
+func (c *CLI) handleRestore(args []string) error {
+	if len(args) < 3 {
+		ShowHelp("restore")
+		return errors.MissingArguments("restore")
+	}
+	var archivePath string
+	var force bool
+	for _, arg := range args[2:] {
+		switch arg {
+		case "--help", "-?":
+			ShowHelp("restore")
+			return nil
+		case "-f", "--force":
+			force = true
+		default:
+			archivePath = arg
+		}
+	}
+	if archivePath == "" {
+		return errors.MissingArguments("restore")
+	}
+
+	archive, err := storage.ReadBackup(archivePath)
+	if err != nil {
+		return err
+	}
+	c.printBackupSummary(archivePath, archive.Manifest)
+
+	if !force {
+		fmt.Println("⚠️  WARNING: This will replace the data directory and the configuration with this backup.")
+		fmt.Print("Are you sure? (y/N): ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Operation cancelled.")
+			return nil
+		}
+	}
+
+	// The current data can be restored in turn
+	if err := c.autoBackup("restore"); err != nil {
+		return err
+	}
+	if err := storage.Restore(c.storage, c.dataDir, config.GetConfigPath(), archive); err != nil {
+		return err
+	}
+	fmt.Println("Backup restored.")
+	if archive.Manifest.SchemaVersion < storage.CurrentSchemaVersion {
+		fmt.Println("The backup uses an older schema version: run 'comptes migrate'.")
+	}
+	return nil
+}
+
+// printBackupSummary shows what an archive holds next to the current data
+func (c *CLI) printBackupSummary(archivePath string, manifest storage.BackupManifest) {
+	fmt.Printf("Backup %s (checksums verified)\n", archivePath)
+	fmt.Printf("  Created:        %s\n", manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+	if manifest.Reason != "" {
+		fmt.Printf("  Taken before:   %s\n", manifest.Reason)
+	}
+	fmt.Printf("  Schema version: %d\n", manifest.SchemaVersion)
+	fmt.Printf("  Files:          %d (%s)\n", len(manifest.Files), formatSize(manifest.Size()))
+	if manifest.Encrypted {
+		fmt.Println("  Encrypted:      yes (its passphrase will be needed)")
+		return
+	}
+
+	current := ""
+	if accounts, err := c.storage.GetAccounts(); err == nil {
+		if movements, err := c.transactionService.QueryTransactions(storage.TransactionQuery{Active: storage.ActiveOnly(true)}); err == nil {
+			current = fmt.Sprintf(" (currently %d accounts, %d movements)", len(accounts), len(movements))
+		}
+	}
+	fmt.Printf("  Content:        %d accounts, %d movements%s\n", manifest.Accounts, manifest.Movements, current)
+}
+
+// autoBackup archives the data directory before a destructive command, unless it is empty
+func (c *CLI) autoBackup(reason string) error {
+	if !storage.HasData(c.dataDir) {
+		return nil
+	}
+	target := storage.BackupPath(c.dataDir, reason)
+	if _, err := storage.Backup(c.storage, c.dataDir, config.GetConfigPath(), target, reason); err != nil {
+		return err
+	}
+	fmt.Printf("Backup written to %s\n", target)
+	return nil
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
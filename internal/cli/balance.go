@@ -1,32 +1,249 @@
 package cli
 
 import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/service"
 	"fmt"
+	"strings"
+	"time"
 )
 
-func (c *CLI) handleBalance() error {
-	if err := c.showBalances(); err != nil {
+func (c *CLI) handleBalance(args []string) error {
+	currency := ""
+	rateDate := service.RateAtToday
+	showClosed := false
+	showTrend := false
+	interval := service.TrendMonthly
+	format := "text"
+	var accountIDs []string
+	var from, to time.Time
+
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case "--help", "-?":
+			ShowHelp("balance")
+			return nil
+		case "--currency", "-C":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--currency requires a value")
+			}
+			currency = strings.ToUpper(args[i+1])
+			i++
+		case "--all", "-A":
+			showClosed = true
+		case "--rate-date":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--rate-date requires a value")
+			}
+			mode, err := service.ParseRateDate(args[i+1])
+			if err != nil {
+				return err
+			}
+			rateDate = mode
+			i++
+		case "--trend":
+			showTrend = true
+		case "--as-of", "--recorded-at":
+			i++ // Read by parseLedgerView
+		case "--interval", "--account", "--from", "--to", "--format", "-F":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			value := args[i+1]
+			i++
+			switch args[i-1] {
+			case "--interval":
+				parsed, err := service.ParseTrendInterval(value)
+				if err != nil {
+					return err
+				}
+				interval = parsed
+			case "--account":
+				accountIDs = append(accountIDs, strings.ToUpper(value))
+			case "--format", "-F":
+				format = value
+			default:
+				date, err := parseDate(value)
+				if err != nil {
+					return errors.InvalidDate(value, err)
+				}
+				if args[i-1] == "--from" {
+					from = date
+				} else {
+					to = date
+				}
+			}
+		default:
+			return fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	view, err := parseLedgerView(args)
+	if err != nil {
+		return err
+	}
+	if showTrend {
+		if !view.IsCurrent() {
+			return errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand, "--as-of and --recorded-at do not apply to --trend (use --to)")
+		}
+		return c.showBalanceTrend(accountIDs, showClosed, from, to, interval, format)
+	}
+
+	if currency == "" {
+		reporting, err := c.reportingCurrency()
+		if err != nil {
+			return fmt.Errorf("error showing balances: %w", err)
+		}
+		currency = reporting
+	}
+
+	if err := c.showBalances(currency, rateDate, showClosed, view); err != nil {
 		return fmt.Errorf("error showing balances: %w", err)
 	}
 	return nil
 }
 
-func (c *CLI) showBalances() error {
-	accounts, err := c.storage.GetAccounts()
+// showBalances prints each active account in its own currency, converted into
+// currency when it differs, followed by the total in currency. Closed accounts (always
+// at zero) are listed after them with showClosed. In a past view, a closed account that
+// still had a balance then counts like an open one.
+func (c *CLI) showBalances(currency string, rateDate service.RateDate, showClosed bool, view service.LedgerView) error {
+	stored, err := c.storage.GetAccounts()
 	if err != nil {
 		return err
 	}
+	var accounts []domain.Account
+	balances := make(map[string]domain.Money)
+	for _, account := range stored {
+		if !view.HasAccount(account) {
+			continue
+		}
+		balance, err := c.transactionService.AccountBalanceIn(account.ID, view)
+		if err != nil {
+			return err
+		}
+		if !account.IsActive && !balance.IsZero() {
+			account.IsActive = true
+		}
+		accounts = append(accounts, account)
+		balances[account.ID] = balance
+	}
 
-	fmt.Println("Account Balances:")
+	total := domain.NewMoney(0, currency)
+	var missingRates []string
+
+	fmt.Printf("Account Balances%s:\n", viewLabel(view))
 	for _, account := range accounts {
 		if account.IsActive {
-			balance, err := c.transactionService.GetAccountBalance(account.ID)
+			balance := balances[account.ID]
+			if balance.Currency == "" || balance.Currency == currency {
+				fmt.Printf("- %s: %s\n", account.Name, balance)
+				total = total.Add(balance.WithCurrency(currency))
+				continue
+			}
+
+			converted, err := c.exchangeService.ConvertedBalanceIn(account.ID, currency, rateDate, view)
 			if err != nil {
-				return err
+				fmt.Printf("- %s: %s (no exchange rate to %s)\n", account.Name, balance, currency)
+				missingRates = append(missingRates, account.ID)
+				continue
+			}
+			fmt.Printf("- %s: %s (≈ %s)\n", account.Name, balance, converted)
+			total = total.Add(converted)
+		}
+	}
+	if showClosed {
+		for _, account := range accounts {
+			if !account.IsActive {
+				fmt.Printf("- %s: %s (closed)\n", account.Name, balances[account.ID])
 			}
-			fmt.Printf("- %s: %.2f %s\n", account.Name, balance, account.Currency)
 		}
 	}
 
+	rateLabel := "today's rates"
+	if rateDate == service.RateAtTransactionDate {
+		rateLabel = "transaction-date rates"
+	}
+	fmt.Printf("Total: %s (%s)\n", total, rateLabel)
+	if len(missingRates) > 0 {
+		fmt.Printf("Warning: total excludes %s (add rates with 'comptes rates add' or 'comptes rates import')\n", strings.Join(missingRates, ", "))
+	}
+
+	return nil
+}
+
+// showBalanceTrend prints the balance of accounts at the end of each interval from from
+// to to (both included). The range defaults to the last 12 intervals up to today.
+func (c *CLI) showBalanceTrend(accountIDs []string, showClosed bool, from, to time.Time, interval service.TrendInterval, format string) error {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		switch interval {
+		case service.TrendDaily:
+			from = to.AddDate(0, 0, -11)
+		case service.TrendWeekly:
+			from = to.AddDate(0, 0, -7*11)
+		default:
+			from = time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, to.Location()).AddDate(0, -11, 0)
+		}
+	}
+	period, err := service.RangePeriod(from, to)
+	if err != nil {
+		return err
+	}
+	if len(accountIDs) == 0 && showClosed {
+		accounts, err := c.storage.GetAccounts()
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			accountIDs = append(accountIDs, account.ID)
+		}
+	}
+
+	trend, err := c.transactionService.BalanceTrend(accountIDs, period, interval)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "csv":
+		fmt.Println("date,account,balance,currency")
+		for i, date := range trend.Dates {
+			for _, account := range trend.Accounts {
+				fmt.Printf("%s,%s,%s,%s\n", date.Format("2006-01-02"), account.AccountID, account.Balances[i].Decimal(), account.Currency)
+			}
+		}
+	case "json":
+		return printJSON(trend)
+	default:
+		printBalanceTrendText(trend)
+	}
 	return nil
 }
+
+// printBalanceTrendText prints one line per date and one column per account
+func printBalanceTrendText(trend *service.BalanceTrend) {
+	fmt.Printf("Balance trend (%s):\n", trend.Interval)
+	width := 12
+	for _, account := range trend.Accounts {
+		width = max(width, len([]rune(account.AccountID)))
+		for _, balance := range account.Balances {
+			width = max(width, len(balance.String()))
+		}
+	}
+
+	fmt.Printf("%-10s", "Date")
+	for _, account := range trend.Accounts {
+		fmt.Printf(" %*s", width, account.AccountID)
+	}
+	fmt.Println()
+	for i, date := range trend.Dates {
+		fmt.Print(date.Format("2006-01-02"))
+		for _, account := range trend.Accounts {
+			fmt.Printf(" %*s", width, account.Balances[i])
+		}
+		fmt.Println()
+	}
+}
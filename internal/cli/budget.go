@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// handleBudget runs "comptes budget [show|list|set|remove] ..."
+func (c *CLI) handleBudget(args []string) error {
+	action := "show"
+	rest := args[2:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		action, rest = rest[0], rest[1:]
+	}
+
+	switch action {
+	case "show":
+		return c.handleBudgetShow(rest)
+	case "list":
+		return c.handleBudgetList(rest)
+	case "set":
+		return c.handleBudgetSet(rest)
+	case "remove":
+		return c.handleBudgetRemove(rest)
+	default:
+		ShowHelp("budget")
+		return errors.InvalidCommand("budget " + action)
+	}
+}
+
+func (c *CLI) handleBudgetShow(args []string) error {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-?" {
+			ShowHelp("budget")
+			return nil
+		}
+	}
+	options, _, err := c.parseReportOptions(args, func() (service.Period, error) {
+		return service.MonthPeriod(time.Now().Format("2006-01"))
+	})
+	if err != nil {
+		return err
+	}
+	report, err := c.reportService.BudgetReport(options.filter, options.period, options.currency, options.rateDate, time.Now())
+	if err != nil {
+		return err
+	}
+	switch options.format {
+	case "csv":
+		printBudgetCSV(report)
+	case "json":
+		return printJSON(report)
+	default:
+		printBudgetText(report)
+	}
+	return nil
+}
+
+func (c *CLI) handleBudgetList(args []string) error {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-?" {
+			ShowHelp("budget")
+			return nil
+		}
+	}
+	budgets, err := c.budgetService.Budgets()
+	if err != nil {
+		return err
+	}
+	if len(budgets) == 0 {
+		fmt.Println("No budgets. Add one with 'comptes budget set' or in config.yaml.")
+		return nil
+	}
+	for _, budget := range budgets {
+		validTo := "…"
+		if budget.ValidTo != nil {
+			validTo = budget.ValidTo.Format("2006-01-02")
+		}
+		line := fmt.Sprintf("%-8s %14s %-8s %s → %s", budget.Category, budget.Amount, budget.Period,
+			budget.ValidFrom.Format("2006-01-02"), validTo)
+		if budget.Description != "" {
+			line += "  " + budget.Description
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func (c *CLI) handleBudgetSet(args []string) error {
+	budget := domain.Budget{Period: domain.BudgetMonthly}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--help", "-?":
+			ShowHelp("budget")
+			return nil
+		case "--monthly":
+			budget.Period = domain.BudgetMonthly
+		case "--yearly":
+			budget.Period = domain.BudgetYearly
+		case "--once":
+			budget.Period = domain.BudgetOnce
+		case "--from", "--to", "--description", "-d":
+			if i+1 >= len(args) {
+				return errors.MissingArguments("budget set " + args[i])
+			}
+			value := args[i+1]
+			i++
+			if args[i-1] == "--description" || args[i-1] == "-d" {
+				budget.Description = value
+				continue
+			}
+			date, err := parseDate(value)
+			if err != nil {
+				return errors.InvalidDate(value, err)
+			}
+			date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+			if args[i-1] == "--from" {
+				budget.ValidFrom = date
+			} else {
+				budget.ValidTo = &date
+			}
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) != 2 {
+		ShowHelp("budget")
+		return errors.MissingArguments("budget set")
+	}
+
+	amount, err := parseAmount(positional[1])
+	if err != nil {
+		return errors.InvalidAmount(positional[1], err)
+	}
+	if amount.Currency == "" {
+		currency, err := c.reportingCurrency()
+		if err != nil {
+			return err
+		}
+		amount = amount.WithCurrency(currency)
+	}
+	budget.Category, budget.Amount = strings.ToUpper(positional[0]), amount
+	if budget.ValidFrom.IsZero() {
+		now := time.Now()
+		budget.ValidFrom = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	if err := c.budgetService.SetBudget(budget); err != nil {
+		return err
+	}
+	fmt.Printf("Budget %s set: %s %s from %s.\n", budget.Category, budget.Amount, budget.Period, budget.ValidFrom.Format("2006-01-02"))
+	return nil
+}
+
+func (c *CLI) handleBudgetRemove(args []string) error {
+	var category string
+	var validFrom time.Time
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--help", "-?":
+			ShowHelp("budget")
+			return nil
+		case "--from":
+			if i+1 >= len(args) {
+				return errors.MissingArguments("budget remove --from")
+			}
+			date, err := parseDate(args[i+1])
+			if err != nil {
+				return errors.InvalidDate(args[i+1], err)
+			}
+			validFrom = date
+			i++
+		default:
+			if category != "" {
+				ShowHelp("budget")
+				return errors.MissingArguments("budget remove")
+			}
+			category = args[i]
+		}
+	}
+	if category == "" {
+		ShowHelp("budget")
+		return errors.MissingArguments("budget remove")
+	}
+
+	removed, err := c.budgetService.RemoveBudget(category, validFrom)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Budget %s removed.\n", removed.Key())
+	return nil
+}
+
+func printBudgetText(report *service.BudgetReport) {
+	fmt.Printf("Budget %s (%s%s)\n", report.Period.Label, report.Currency, rateLabel(report.RateDate))
+	if len(report.Lines) == 0 {
+		fmt.Println("No budgets in this period.")
+		return
+	}
+
+	width := len("Category")
+	labels := make([]string, len(report.Lines))
+	for i, line := range report.Lines {
+		labels[i] = strings.Repeat("  ", line.Depth) + line.Name
+		if line.Code != line.Name {
+			labels[i] += " (" + line.Code + ")"
+		}
+		if line.RolledUp {
+			labels[i] += " *"
+		}
+		width = max(width, len([]rune(labels[i])))
+	}
+
+	fmt.Printf("%-*s %14s %14s %14s %14s\n", width, "Category", "Budgeted", "Spent", "Remaining", "Projected")
+	printLine := func(label string, line service.BudgetLine) {
+		fmt.Printf("%s%s %14s %14s %14s %14s\n", label, strings.Repeat(" ", width-len([]rune(label))),
+			line.Budgeted.Decimal(), line.Spent.Decimal(), line.Remaining.Decimal(), line.Projected.Decimal())
+	}
+	for i, line := range report.Lines {
+		printLine(labels[i], line)
+	}
+	printLine("Total", report.Total)
+	fmt.Printf("Unbudgeted spending: %s\n", report.Unbudgeted.Decimal())
+	for _, line := range report.Lines {
+		if line.RolledUp {
+			fmt.Println("* sum of the budgets of its subcategories")
+			break
+		}
+	}
+	if len(report.Excluded) > 0 {
+		fmt.Printf("Warning: %d movements excluded, no exchange rate to %s (add rates with 'comptes rates add')\n", len(report.Excluded), report.Currency)
+	}
+}
+
+func printBudgetCSV(report *service.BudgetReport) {
+	fmt.Println("category,name,parent,depth,budgeted,spent,remaining,projected,rolled_up,currency")
+	for _, line := range report.Lines {
+		fmt.Printf("%s,\"%s\",%s,%d,%s,%s,%s,%s,%t,%s\n", line.Code, line.Name, line.Parent, line.Depth,
+			line.Budgeted.Decimal(), line.Spent.Decimal(), line.Remaining.Decimal(), line.Projected.Decimal(), line.RolledUp, report.Currency)
+	}
+}
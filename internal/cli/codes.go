@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"comptes/internal/config"
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"fmt"
+	"strings"
+)
+
+// isCodeAction reports whether a category/tags argument is a management subcommand
+// rather than a code to put in the context (codes are upper case)
+func isCodeAction(arg string) bool {
+	switch arg {
+	case "add", "rename", "remove", "merge":
+		return true
+	}
+	return false
+}
+
+// handleCodeAction runs "comptes category|tags add|rename|remove|merge ..."
+func (c *CLI) handleCodeAction(kind string, args []string) error {
+	command := "category"
+	if kind == service.ConfigTag {
+		command = "tags"
+	}
+	action := args[2]
+
+	var codes []string
+	var name, description, parent string
+	rest := args[3:]
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case "--help", "-?":
+			ShowHelp(command)
+			return nil
+		case "--name", "-n", "--description", "-d", "--parent", "-p":
+			if i+1 >= len(rest) {
+				return errors.MissingArguments(command + " " + action + " " + rest[i])
+			}
+			switch rest[i] {
+			case "--name", "-n":
+				name = rest[i+1]
+			case "--description", "-d":
+				description = rest[i+1]
+			default:
+				parent = strings.ToUpper(rest[i+1])
+			}
+			i++
+		default:
+			codes = append(codes, strings.ToUpper(rest[i]))
+		}
+	}
+
+	want := 2
+	if action == "add" || action == "remove" {
+		want = 1
+	}
+	if len(codes) != want {
+		ShowHelp(command)
+		return errors.MissingArguments(command + " " + action)
+	}
+	label := strings.ToUpper(kind[:1]) + kind[1:]
+	list := "categories"
+	if kind == service.ConfigTag {
+		list = "tags"
+	}
+
+	switch action {
+	case "add":
+		entry := domain.Category{Code: codes[0], Name: name, Description: description}
+		if parent != "" {
+			entry.Parent = &parent
+		}
+		var err error
+		if kind == service.ConfigTag {
+			err = c.codeService.AddTag(domain.Tag(entry))
+		} else {
+			err = c.codeService.AddCategory(entry)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s added.\n", label, codes[0])
+	case "remove":
+		if err := c.codeService.Remove(kind, codes[0]); err != nil {
+			return err
+		}
+		updateConfig(func(configPath string) error { return config.RemoveCode(configPath, list, codes[0]) })
+		fmt.Printf("%s %s removed.\n", label, codes[0])
+	default:
+		var entry *domain.AuditEntry
+		var err error
+		if action == "rename" {
+			entry, err = c.codeService.Rename(kind, codes[0], codes[1])
+		} else {
+			entry, err = c.codeService.Merge(kind, codes[0], codes[1])
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.renameInContext(kind, codes[0], codes[1]); err != nil {
+			return err
+		}
+		updateConfig(func(configPath string) error {
+			if action == "rename" {
+				return config.RenameCode(configPath, list, codes[0], codes[1])
+			}
+			return config.MergeCode(configPath, list, codes[0], codes[1])
+		})
+		fmt.Printf("%s%s (%d movements updated).\n", strings.ToUpper(entry.Details[:1]), entry.Details[1:], len(entry.Transactions))
+	}
+	return nil
+}
+
+// renameInContext keeps the batch context pointing at existing codes
+func (c *CLI) renameInContext(kind, code, newCode string) error {
+	context, err := c.getCurrentContext()
+	if err != nil {
+		return err
+	}
+	codes := &context.Categories
+	if kind == service.ConfigTag {
+		codes = &context.Tags
+	}
+	var renamed []string
+	for _, existing := range *codes {
+		if existing == code {
+			existing = newCode
+		}
+		if !containsString(renamed, existing) {
+			renamed = append(renamed, existing)
+		}
+	}
+	*codes = renamed
+	return c.saveCurrentContext(context)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"comptes/internal/config"
 	"comptes/internal/errors"
 	"comptes/internal/service"
 	"comptes/internal/storage"
@@ -12,8 +13,17 @@ import (
 type CLI struct {
 	transactionService *service.TransactionService
 	batchService       *service.TransactionBatchService
+	exchangeService    *service.ExchangeRateService
+	codeService        *service.CodeService
+	accountService     *service.AccountService
+	reportService      *service.ReportService
+	budgetService      *service.BudgetService
+	envelopeService    *service.EnvelopeService
+	scheduleService    *service.ScheduleService
 	storage            storage.Storage
 	dataDir            string // Data directory path
+	schemaErr          error  // Why the data directory cannot be used as is (see storage.CheckSchema)
+	passphrase         string // Passphrase that unlocked an encrypted data directory
 }
 
 // NewCLI creates a new CLI instance
@@ -34,16 +44,54 @@ func NewCLI() (*CLI, error) {
 		return nil, errors.Wrap(errors.ErrorTypeSystem, "data_dir_failed", "Failed to create data directory", err)
 	}
 
-	// Initialize storage and services
-	storage := storage.NewJSONStorage(dataDir)
+	// Initialize storage (backend chosen in config.yaml) and services
+	backend := ""
+	configPath := config.GetConfigPath()
+	if _, err := os.Stat(configPath); err == nil {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return nil, errors.ConfigLoadFailed(err)
+		}
+		backend = cfg.Storage.Backend
+	}
+	schemaErr := storage.CheckSchema(dataDir)
+	var passphrase string
+	storage, err := storage.Open(backend, dataDir, func() (string, error) {
+		var err error
+		passphrase, err = readPassphrase(passphraseEnv, "Passphrase: ")
+		return passphrase, err
+	})
+	if err != nil {
+		if e, ok := err.(*errors.ComptesError); ok && e.Type == errors.ErrorTypeUserInput {
+			return nil, err
+		}
+		return nil, errors.Wrap(errors.ErrorTypeStorage, errors.CodeStorageInitFailed, "Failed to open storage", err)
+	}
 	transactionService := service.NewTransactionService(storage)
 	batchService := service.NewTransactionBatchService(storage, transactionService)
+	exchangeService := service.NewExchangeRateService(storage)
+
+	// Repair batches a crash left half-committed, on data this binary can read
+	if schemaErr == nil {
+		if _, err := batchService.RecoverBatches(); err != nil {
+			return nil, err
+		}
+	}
 
 	return &CLI{
 		transactionService: transactionService,
 		batchService:       batchService,
+		exchangeService:    exchangeService,
+		codeService:        service.NewCodeService(storage),
+		accountService:     service.NewAccountService(storage, transactionService),
+		reportService:      service.NewReportService(storage, transactionService, exchangeService),
+		budgetService:      service.NewBudgetService(storage),
+		envelopeService:    service.NewEnvelopeService(storage, transactionService, exchangeService),
+		scheduleService:    service.NewScheduleService(storage, transactionService),
 		storage:            storage,
 		dataDir:            dataDir,
+		schemaErr:          schemaErr,
+		passphrase:         passphrase,
 	}, nil
 }
 
@@ -56,11 +104,18 @@ func (c *CLI) Execute(args []string) error {
 
 	command := args[1]
 
+	// Only migrate and restore may run on a data directory of another schema version
+	if c.schemaErr != nil && command != "migrate" && command != "restore" {
+		return c.schemaErr
+	}
+
 	switch command {
 	case "init":
 		return c.handleInit()
 	case "add":
 		return c.handleAdd(args)
+	case "transfer":
+		return c.handleTransfer(args)
 	case "list":
 		return c.handleList(args)
 	case "edit":
@@ -70,7 +125,27 @@ func (c *CLI) Execute(args []string) error {
 	case "undo":
 		return c.handleUndo(args)
 	case "balance":
-		return c.handleBalance()
+		return c.handleBalance(args)
+	case "report":
+		return c.handleReport(args)
+	case "budget":
+		return c.handleBudget(args)
+	case "envelope":
+		return c.handleEnvelope(args)
+	case "schedule":
+		return c.handleSchedule(args)
+	case "rates":
+		return c.handleRates(args)
+	case "migrate":
+		return c.handleMigrate(args)
+	case "encryption":
+		return c.handleEncryption(args)
+	case "config":
+		return c.handleConfig(args)
+	case "backup":
+		return c.handleBackup(args)
+	case "restore":
+		return c.handleRestore(args)
 	case "begin":
 		return c.handleBegin(args)
 	case "commit":
@@ -79,6 +154,8 @@ func (c *CLI) Execute(args []string) error {
 		return c.handleRollback(args)
 	case "account":
 		return c.handleAccount(args)
+	case "accounts":
+		return c.handleAccounts(args)
 	case "category":
 		return c.handleCategory(args)
 	case "tags":
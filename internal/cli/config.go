@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"comptes/internal/config"
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"fmt"
+	"strings"
+)
+
+func (c *CLI) handleConfig(args []string) error {
+	action := "diff"
+	rest := args[2:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		action, rest = rest[0], rest[1:]
+	}
+
+	remaps := make(map[string]string)
+	dryRun := action == "diff"
+	prune := false
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case "--help", "-?":
+			ShowHelp("config")
+			return nil
+		case "-n", "--dry-run":
+			dryRun = true
+		case "--prune":
+			prune = true
+		case "--remap":
+			if i+1 >= len(rest) {
+				return errors.MissingArguments("config --remap")
+			}
+			from, to, ok := strings.Cut(rest[i+1], "=")
+			if !ok || from == "" || to == "" {
+				return errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand,
+					fmt.Sprintf("Invalid remap %q (expected OLD=NEW)", rest[i+1]))
+			}
+			remaps[strings.ToUpper(from)] = strings.ToUpper(to)
+			i++
+		}
+	}
+	if action != "diff" && action != "apply" {
+		ShowHelp("config")
+		return errors.InvalidCommand("config " + action)
+	}
+
+	configPath := config.GetConfigPath()
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return errors.ConfigLoadFailed(err)
+	}
+
+	// The plan must still hold when it is applied
+	if err := c.storage.Lock(); err != nil {
+		return err
+	}
+	defer c.storage.Unlock()
+
+	syncService := service.NewConfigSyncService(c.storage)
+	plan, err := syncService.Plan(cfg.Accounts, cfg.Categories, cfg.Tags, cfg.Budgets, remaps, prune)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Changes) == 0 {
+		fmt.Println("Data directory is in sync with the configuration.")
+		return nil
+	}
+	fmt.Printf("Changes from %s:\n", configPath)
+	for _, change := range plan.Changes {
+		fmt.Println("  " + formatConfigChange(change))
+	}
+	if dryRun {
+		command := "comptes config apply"
+		if prune {
+			command += " --prune"
+		}
+		fmt.Printf("Dry run: nothing was changed. Run '%s' to apply.\n", command)
+		return nil
+	}
+
+	// Remapping rewrites history: keep a way back
+	if plan.Remapped() > 0 {
+		if err := c.autoBackup("config-apply"); err != nil {
+			return err
+		}
+	}
+	if err := syncService.Apply(plan); err != nil {
+		return err
+	}
+	fmt.Println("Configuration applied.")
+	return nil
+}
+
+func formatConfigChange(change service.ConfigChange) string {
+	switch change.Action {
+	case service.ConfigAdded:
+		return fmt.Sprintf("+ %s %s", change.Kind, change.Code)
+	case service.ConfigChanged:
+		return fmt.Sprintf("~ %s %s (%s)", change.Kind, change.Code, strings.Join(change.Fields, ", "))
+	default:
+		if change.RemapTo != "" {
+			return fmt.Sprintf("- %s %s (%d movements remapped to %s)", change.Kind, change.Code, change.References, change.RemapTo)
+		}
+		return fmt.Sprintf("- %s %s", change.Kind, change.Code)
+	}
+}
+
+// updateConfig carries a change of the data directory over to config.yaml, so that
+// 'comptes config apply' does not undo it
+func updateConfig(update func(configPath string) error) {
+	if err := update(config.GetConfigPath()); err != nil {
+		fmt.Printf("Warning: config.yaml was not updated (%v): edit it before 'comptes config apply'\n", err)
+	}
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"comptes/internal/service"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -121,8 +122,16 @@ func (c *CLI) handleAccount(args []string) error {
 	return nil
 }
 
-// handleCategory sets the default categories in the context
+// handleCategory sets the default categories in the context, or manages categories
+// (add, rename, remove, merge)
 func (c *CLI) handleCategory(args []string) error {
+	if len(args) >= 3 && (args[2] == "--help" || args[2] == "-?") {
+		ShowHelp("category")
+		return nil
+	}
+	if len(args) >= 3 && isCodeAction(args[2]) {
+		return c.handleCodeAction(service.ConfigCategory, args)
+	}
 	if len(args) < 3 {
 		// Show current context
 		context, err := c.getCurrentContext()
@@ -182,8 +191,16 @@ func (c *CLI) handleCategory(args []string) error {
 	return nil
 }
 
-// handleTags sets the default tags in the context
+// handleTags sets the default tags in the context, or manages tags (add, rename,
+// remove, merge)
 func (c *CLI) handleTags(args []string) error {
+	if len(args) >= 3 && (args[2] == "--help" || args[2] == "-?") {
+		ShowHelp("tags")
+		return nil
+	}
+	if len(args) >= 3 && isCodeAction(args[2]) {
+		return c.handleCodeAction(service.ConfigTag, args)
+	}
 	if len(args) < 3 {
 		// Show current context
 		context, err := c.getCurrentContext()
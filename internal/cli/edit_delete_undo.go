@@ -86,6 +86,9 @@ func (c *CLI) handleDelete(args []string) error {
 	}
 
 	if hardDelete {
+		if err := c.autoBackup("delete-hard"); err != nil {
+			return err
+		}
 		if err := c.deleteTransactionHard(transactionID, message); err != nil {
 			return errors.Wrap(errors.ErrorTypeUserInput, "delete_hard_failed", "Failed to permanently delete transaction", err)
 		}
@@ -139,6 +142,9 @@ func (c *CLI) handleUndo(args []string) error {
 	}
 
 	if hardUndo {
+		if err := c.autoBackup("undo-hard"); err != nil {
+			return err
+		}
 		if err := c.undoTransactionHard(transactionID); err != nil {
 			return errors.Wrap(errors.ErrorTypeUserInput, "undo_hard_failed", "Failed to permanently undo transaction", err)
 		}
@@ -167,6 +173,7 @@ func (c *CLI) editTransaction(transactionID string, jsonData string, message str
 		Description: modifications.Description,
 		Categories:  modifications.Categories,
 		Tags:        modifications.Tags,
+		Splits:      toDomainSplits(modifications.Splits),
 	}
 
 	// Gérer les dates
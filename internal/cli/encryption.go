@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+)
+
+func (c *CLI) handleEncryption(args []string) error {
+	action := "status"
+	if len(args) >= 3 {
+		action = args[2]
+	}
+
+	switch action {
+	case "--help", "-?":
+		ShowHelp("encryption")
+		return nil
+	case "status":
+		if storage.IsEncrypted(c.dataDir) {
+			fmt.Printf("Data directory %s is encrypted.\n", c.dataDir)
+		} else {
+			fmt.Printf("Data directory %s is not encrypted.\n", c.dataDir)
+		}
+		return nil
+	case "enable":
+		if storage.IsEncrypted(c.dataDir) {
+			return errors.AlreadyEncrypted(c.dataDir)
+		}
+		passphrase, err := readNewPassphrase(newPassphraseEnv)
+		if err != nil {
+			return err
+		}
+		if err := storage.EnableEncryption(c.dataDir, passphrase); err != nil {
+			return err
+		}
+		fmt.Println("Data directory encrypted. Keep the passphrase safe: it cannot be recovered.")
+		return nil
+	case "disable":
+		if !storage.IsEncrypted(c.dataDir) {
+			return errors.NotEncrypted(c.dataDir)
+		}
+		if err := storage.DisableEncryption(c.dataDir, c.passphrase); err != nil {
+			return err
+		}
+		fmt.Println("Data directory decrypted.")
+		return nil
+	case "rotate":
+		if !storage.IsEncrypted(c.dataDir) {
+			return errors.NotEncrypted(c.dataDir)
+		}
+		passphrase, err := readNewPassphrase(newPassphraseEnv)
+		if err != nil {
+			return err
+		}
+		if err := storage.RotatePassphrase(c.dataDir, c.passphrase, passphrase); err != nil {
+			return err
+		}
+		fmt.Println("Passphrase changed.")
+		return nil
+	default:
+		ShowHelp("encryption")
+		return errors.InvalidCommand("encryption " + action)
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// envelopeOptions holds the flags shared by the envelope subcommands
+type envelopeOptions struct {
+	month       string
+	format      string
+	description string
+	positional  []string
+}
+
+func parseEnvelopeOptions(args []string) (options envelopeOptions, help bool, err error) {
+	options = envelopeOptions{month: time.Now().Format("2006-01"), format: "text"}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--help", "-?":
+			ShowHelp("envelope")
+			return options, true, nil
+		case "--month", "--format", "-F", "--description", "-d":
+			if i+1 >= len(args) {
+				return options, false, errors.New(errors.ErrorTypeUserInput, errors.CodeMissingArguments, fmt.Sprintf("%s requires a value", args[i]))
+			}
+			switch args[i] {
+			case "--month":
+				if _, err := service.MonthPeriod(args[i+1]); err != nil {
+					return options, false, err
+				}
+				options.month = args[i+1]
+			case "--format", "-F":
+				options.format = args[i+1]
+			default:
+				options.description = args[i+1]
+			}
+			i++
+		default:
+			options.positional = append(options.positional, args[i])
+		}
+	}
+	return options, false, nil
+}
+
+// handleEnvelope runs "comptes envelope [show|assign|move|list] ..."
+func (c *CLI) handleEnvelope(args []string) error {
+	action := "show"
+	rest := args[2:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		action, rest = rest[0], rest[1:]
+	}
+	options, help, err := parseEnvelopeOptions(rest)
+	if err != nil || help {
+		return err
+	}
+
+	want := map[string]int{"show": 0, "list": 0, "assign": 2, "move": 3}
+	count, known := want[action]
+	if !known {
+		ShowHelp("envelope")
+		return errors.InvalidCommand("envelope " + action)
+	}
+	if len(options.positional) != count {
+		ShowHelp("envelope")
+		return errors.MissingArguments("envelope " + action)
+	}
+	currency, err := c.reportingCurrency()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		return c.listEnvelopeAssignments(options)
+	case "assign", "move":
+		amountArg := options.positional[count-1]
+		amount, err := domain.ParseMoney(amountArg, currency)
+		if err != nil {
+			return errors.InvalidAmount(amountArg, err)
+		}
+		if action == "assign" {
+			if _, err := c.envelopeService.Assign(options.positional[0], options.month, amount, options.description); err != nil {
+				return err
+			}
+			fmt.Printf("Assigned %s to %s for %s.\n", amount, strings.ToUpper(options.positional[0]), options.month)
+		} else {
+			if _, err := c.envelopeService.Move(options.positional[0], options.positional[1], options.month, amount, options.description); err != nil {
+				return err
+			}
+			fmt.Printf("Moved %s from %s to %s for %s.\n", amount, strings.ToUpper(options.positional[0]), strings.ToUpper(options.positional[1]), options.month)
+		}
+		month, err := c.envelopeService.Month(options.month, currency)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("To be budgeted: %s\n", month.ToBeBudgeted)
+		if month.ToBeBudgeted.IsNegative() {
+			fmt.Println("Warning: more is assigned than there is to budget")
+		}
+		return nil
+	}
+
+	month, err := c.envelopeService.Month(options.month, currency)
+	if err != nil {
+		return err
+	}
+	switch options.format {
+	case "csv":
+		printEnvelopeCSV(month)
+	case "json":
+		return printJSON(month)
+	default:
+		printEnvelopeText(month)
+	}
+	return nil
+}
+
+func (c *CLI) listEnvelopeAssignments(options envelopeOptions) error {
+	assignments, err := c.envelopeService.Assignments(options.month)
+	if err != nil {
+		return err
+	}
+	if options.format == "json" {
+		return printJSON(assignments)
+	}
+	if len(assignments) == 0 {
+		fmt.Printf("No envelope assignments in %s.\n", options.month)
+		return nil
+	}
+	for _, assignment := range assignments {
+		line := fmt.Sprintf("%s %s %-8s %14s", assignment.ID[:8], assignment.CreatedAt.Local().Format("2006-01-02 15:04"),
+			assignment.Category, assignment.Amount)
+		if assignment.Description != "" {
+			line += "  " + assignment.Description
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func printEnvelopeText(month *service.EnvelopeMonth) {
+	fmt.Printf("Envelopes %s (%s)\n", month.Month.Label, month.Currency)
+	fmt.Printf("To be budgeted: %s (inflow this month: %s)\n", month.ToBeBudgeted.Decimal(), month.Inflow.Decimal())
+	if len(month.Lines) == 0 {
+		fmt.Println("No envelopes yet. Assign money with 'comptes envelope assign <category> <amount>'.")
+		return
+	}
+
+	width := len("Envelope")
+	labels := make([]string, len(month.Lines))
+	for i, line := range month.Lines {
+		labels[i] = strings.Repeat("  ", line.Depth) + line.Name
+		if line.Code != line.Name {
+			labels[i] += " (" + line.Code + ")"
+		}
+		width = max(width, len([]rune(labels[i])))
+	}
+
+	fmt.Printf("%-*s %14s %14s %14s %14s\n", width, "Envelope", "Carried", "Assigned", "Activity", "Available")
+	printLine := func(label string, line service.EnvelopeLine) {
+		fmt.Printf("%s%s %14s %14s %14s %14s\n", label, strings.Repeat(" ", width-len([]rune(label))),
+			line.Carried.Decimal(), line.Assigned.Decimal(), line.Activity.Decimal(), line.Available.Decimal())
+	}
+	for i, line := range month.Lines {
+		printLine(labels[i], line)
+	}
+	printLine("Total", month.Total)
+	if month.ToBeBudgeted.IsNegative() {
+		fmt.Println("Warning: more is assigned than there is to budget")
+	}
+	if len(month.Excluded) > 0 {
+		fmt.Printf("Warning: %d movements or accounts excluded, no exchange rate to %s (add rates with 'comptes rates add')\n", len(month.Excluded), month.Currency)
+	}
+}
+
+func printEnvelopeCSV(month *service.EnvelopeMonth) {
+	fmt.Println("category,name,parent,depth,carried,assigned,activity,available,currency")
+	for _, line := range month.Lines {
+		fmt.Printf("%s,\"%s\",%s,%d,%s,%s,%s,%s,%s\n", line.Code, line.Name, line.Parent, line.Depth,
+			line.Carried.Decimal(), line.Assigned.Decimal(), line.Activity.Decimal(), line.Available.Decimal(), month.Currency)
+	}
+	fmt.Printf(",\"To be budgeted\",,0,,,%s,%s,%s\n", month.Inflow.Decimal(), month.ToBeBudgeted.Decimal(), month.Currency)
+}
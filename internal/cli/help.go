@@ -9,17 +9,29 @@ const (
 Commands:
   init     - Initialize the project
   add      - Add transactions
+  transfer - Transfer money between two accounts
   list     - List transactions
   edit     - Edit a transaction (soft delete + new)
   delete   - Delete a transaction (soft delete)
   undo     - Undo the last operation on a transaction
   balance  - Show account balances
+  report   - Income, expenses and net per category over a period
+  budget   - Budgets per category, compared with the spending of a period
+  envelope - Assign each month's money to category envelopes, with rollover
+  schedule - Recurring movements (rent, salary, subscriptions) posted into a batch
+  rates    - Manage exchange rates
+  migrate  - Upgrade the data directory to the current schema version
+  encryption - Encrypt or decrypt the data directory, change its passphrase
+  config   - Sync accounts, categories, tags and budgets with config.yaml
+  backup   - Archive the data directory and configuration
+  restore  - Restore the data directory from an archive
   begin    - Begin a new transaction batch
   commit   - Commit a pending transaction batch
   rollback - Rollback a pending transaction batch
   account  - Set default account in context
-  category - Set default categories in context
-  tags     - Set default tags in context
+  accounts - List, add, rename, close or reopen accounts
+  category - Set default categories in context, or add/rename/remove/merge categories
+  tags     - Set default tags in context, or add/rename/remove/merge tags
   context  - Show or clear transaction context`
 
 	HelpAdd = `Usage: comptes add <json> [batch-id] [--immediate]
@@ -31,43 +43,101 @@ JSON format:
   comptes add '{"account":"BANQUE","amount":-25.50,"description":"Achat","categories":["ALM"]}'
   comptes add '{"account":"BANQUE","amount":-25.50,"description":"Achat","date":"today"}' [batch-id]
   comptes add '{"account":"BANQUE","amount":-25.50,"description":"Achat"}' --immediate
+  comptes add '{"account":"BANQUE","description":"Supermarché","splits":[{"amount":-90,"categories":["ALM"]},{"amount":-30,"categories":["VET"]}]}'
 
 Flags format:
   comptes add -a BANQUE -m -25.50 -d "Achat" -c ALM -t REC
   comptes add --account BANQUE --amount -25.50 --description "Achat" --categories "ALM,REC" --tags "URG"
   comptes add -a BANQUE -m -25.50 -d "Achat" -o today [batch-id]
   comptes add -a BANQUE -m -25.50 -d "Achat" --immediate
+  comptes add -a BANQUE -d "Supermarché" -s "-90:ALM" -s "-30:VET:URG:Pull"
 
 Flags:
   -a, --account <id>        Account ID (required)
-  -m, --amount <value>      Amount (required, negative for expense, positive for income)
+  -m, --amount <value>      Amount (required unless splits are given, negative for expense, positive for income)
   -d, --desc, --description <text>  Description (required)
   -c, --categories <codes>  Categories (comma-separated, e.g., "ALM,SLR")
   -t, --tags <codes>        Tags (comma-separated, e.g., "REC,URG")
   -o, --on, --date <date>   Date (optional, formats: today, yesterday, 2024-01-15)
+  -s, --split <line>        Split line "<amount>:<categories>[:<tags>[:<description>]]" (repeatable)
   -i, --immediate           Force immediate addition even if a batch is in progress
 
+Split lines must add up to the transaction amount. When --amount is omitted, it is
+computed from the split lines; the transaction categories are the union of the split categories.
+
 If batch-id is provided (or current batch is set), the transaction is added to the pending batch instead of directly.
 Use --immediate (or -i) flag to force immediate addition even if a batch is in progress.
 
 Date formats: 2024-01-15, 15/01/2024, yesterday, today, tomorrow`
 
+	HelpTransfer = `Usage: comptes transfer --from <account> --to <account> -m <amount> [flags] [batch-id] [--immediate]
+
+Moves money between two accounts. Two linked movements are created atomically
+(one debit, one credit) sharing the same transfer_id.
+
+Examples:
+  comptes transfer --from BANQUE --to LIVRET -m 100
+  comptes transfer --from BANQUE --to LIVRET -m 250 -d "Épargne mensuelle" -o 2024-01-31
+  comptes transfer --from LIVRET --to BANQUE -m 50 --immediate
+
+Flags:
+  --from <id>               Source account (required)
+  --to <id>                 Destination account (required)
+  -m, --amount <value>      Amount transferred, positive (required)
+  --received <value>        Amount credited when the accounts use different currencies
+                            (default: converted with the exchange-rate table)
+  -d, --desc, --description <text>  Description (default: "Transfer FROM -> TO")
+  -c, --categories <codes>  Categories (comma-separated)
+  -t, --tags <codes>        Tags (comma-separated)
+  -o, --on, --date <date>   Date (optional, formats: today, yesterday, 2024-01-15)
+  -i, --immediate           Force immediate addition even if a batch is in progress
+
+Editing, deleting or undoing one leg of a transfer applies to the other leg as well.
+Cross-currency transfers record the exchange rate used on both legs.`
+
 	HelpList = `Usage: comptes list [options]
 
 Options:
   --transactions, -T  List transactions (default)
-  --categories, -c   Show available categories
+  --categories, -c   Show the category tree with the total of each branch
   --tags, -t         Show available tags
   --accounts, -a     Show available accounts with balances
   --history, -h      Show all transactions (including deleted/edited)
   --format <fmt>, -F Output format: text (default), csv, json
   --codes, -k        Show category/tag codes instead of names
+  --no-transfers     Hide transfers between accounts
+  --running-balance, -r  Sort by date and show the account balance after each movement
+  --as-of <date>     Only movements dated on or before this day (also for --accounts)
+  --recorded-at <t>  The ledger as recorded at this time, e.g. "2024-03-01 18:00:00"
+                     (a date alone means the end of that day; also for --accounts)
+  --audit            Show the audit log (renames, merges, remaps)
   --help, -?         Show this help message
 
+Filters (movements only; they combine, in every format):
+  --account <id>     Movements of this account
+  --from <date>      On or after this date (YYYY-MM-DD, DD/MM/YYYY, today, yesterday...)
+  --to <date>        On or before this date
+  --category <code>  In this category or below it (repeatable: any of them)
+  --tag <code>       With this tag (repeatable: any of them)
+  --min <amount>     Signed amount at least this (e.g. --min -100 for expenses up to 100)
+  --max <amount>     Signed amount at most this; "50 USD" only matches USD movements
+  --search, -s <txt> Description contains this text (case-insensitive)
+  --batch <id>       Movements recorded by this committed batch (ID prefix accepted)
+  --where, -w <expr> Selection expression, e.g. "cat:ALM and amount<-50 and not tag:PRO"
+
+Expressions compare fields with : = != < <= > >= and combine with and, or, not, ( ):
+  account, cat (with subcategories), tag, amount, date (2024, 2024-01, 2024-01-15),
+  desc (':' searches the text), currency, id (':' is a prefix), is (transfer, split,
+  active, deleted). Quote values with spaces: desc:"le zinc".
+
 Examples:
   comptes list                           # Show active transactions with full names
   comptes list --transactions --format csv  # Export transactions as CSV
-  comptes list --categories              # Show available categories
+  comptes list --categories              # Show the category tree with totals
+  comptes list --category ALM            # Movements in ALM and its subcategories
+  comptes list --account BANQUE --from 2024-01-01 --to 2024-01-31 --format csv
+  comptes list --search pizza --max -20  # Pizza expenses of 20 or more
+  comptes list -w "account:BANQUE and date>=2024-01 and (cat:ALM or tag:RESTO)"
   comptes list --categories --format csv # Export categories as CSV
   comptes list --categories --format json # Export categories as JSON
   comptes list --tags --format json      # Export tags as JSON
@@ -75,8 +145,303 @@ Examples:
   comptes list --accounts --format csv   # Export accounts as CSV
   comptes list --accounts --format json # Export accounts as JSON
   comptes list --history                 # Show all transactions
+  comptes list --account BANQUE -r       # Account statement with running balance
+  comptes list --recorded-at 2024-03-01  # What the ledger said on March 1st
   comptes list --codes                   # Show codes instead of names`
 
+	HelpReport = `Usage: comptes report [period] [filters] [options]
+       comptes report pivot [--rows <dim>] [--columns <dim>] [period] [filters] [options]
+
+Shows the income, expenses and net of each category over a period, as a tree: a category
+includes its subcategories. Deleted or edited movements and transfers between accounts
+are left out. Amounts are converted into one currency at the rate of each movement's date
+(or today's rate with --rate-date today); movements without a rate are excluded and reported.
+
+Period (default: the current month):
+  --month <YYYY-MM>         A month
+  --quarter <YYYY-Qn>       A quarter (Q1 to Q4)
+  --year <YYYY>             A year
+  --from <date> [--to <date>]  A range of days, both included (--to defaults to today)
+
+Filters (same as 'comptes list'):
+  --account <id>, --category <code>, --tag <code>, --min/--max <amount>,
+  --search <text>, --batch <id>, --where <expression>
+  With --category, only the lines in that category (or below it) are counted.
+
+Options:
+  --currency, -C <code>     Currency of the report (default: reporting_currency)
+  --rate-date <when>        Rates used for conversion: transaction (default) or today
+  --format, -F <fmt>        Output format: text (default), csv, json
+  --help, -?                Show this help message
+
+Pivot: a matrix of net amounts with row and column totals and averages (default: the
+categories by month of the current year). Dimensions: category, tag, account, month, week.
+A category row includes its subcategories and a line counts in each of its tags, so
+totals count every line once. Averages divide a total by the number of columns (rows)
+or rows (columns), counting top-level categories only; month and week columns cover the
+whole period.
+  --rows <dim>              Dimension of the rows (default: category)
+  --columns <dim>           Dimension of the columns (default: month)
+
+Examples:
+  comptes report --month 2024-01
+  comptes report --category ALM --from 2024-01-01
+  comptes report --account BANQUE --year 2024 --format csv
+  comptes report --quarter 2024-Q2 -w "not tag:PRO" --format json
+  comptes report --year 2024 --currency USD --rate-date today
+  comptes report pivot --year 2024
+  comptes report pivot --rows tag --columns week --quarter 2024-Q1 --format csv`
+
+	HelpBudget = `Usage: comptes budget [show] [period] [filters] [options]
+       comptes budget list
+       comptes budget set <category> <amount> [--monthly|--yearly|--once] [--from <date>] [--to <date>] [-d <text>]
+       comptes budget remove <category> [--from <date>]
+
+Compares the budget of each budgeted category with its spending (expenses less refunds,
+subcategories included) over a period: budgeted, spent, remaining, and the spending
+projected to the end of the period at the pace so far. A category without a budget of its
+own shows the sum of the budgets of its subcategories (marked *). Spending outside the
+budgeted categories is shown as unbudgeted.
+
+A monthly or yearly budget gives its amount to each calendar month or year; a one-off
+budget gives it once to its whole validity range. A period that covers part of a month,
+year or range gets the matching share of days. Budgets are defined in config.yaml
+(applied with 'comptes config apply') or with 'comptes budget set'.
+
+Period, filters and options: as for 'comptes report' (default: the current month).
+
+Set:
+  <amount>                  Amount per period, e.g. 400 or "300 USD" (default currency: reporting_currency)
+  --monthly, --yearly, --once  How often the amount renews (default: monthly)
+  --from <date>             First day (default: the first day of the current month)
+  --to <date>               Last day, included (required with --once)
+  --description, -d <text>  Description
+  Setting a budget with the same category and first day replaces it.
+
+Remove:
+  --from <date>             First day of the budget, when the category has several
+
+Examples:
+  comptes budget
+  comptes budget --year 2024 --format csv
+  comptes budget set ALM 400
+  comptes budget set VAC 2000 --once --from 2024-07-01 --to 2024-08-31
+  comptes budget remove ALM --from 2024-01-01`
+
+	HelpEnvelope = `Usage: comptes envelope [show] [--month <YYYY-MM>] [--format <fmt>]
+       comptes envelope assign <category> <amount> [--month <YYYY-MM>] [-d <text>]
+       comptes envelope move <from> <to> <amount> [--month <YYYY-MM>] [-d <text>]
+       comptes envelope list [--month <YYYY-MM>] [--format json]
+
+Envelope budgeting: each month, money of the pool "to be budgeted" is assigned to the
+envelopes of categories. Movements of a category (subcategories included, unless they
+have an envelope of their own) draw its envelope down, and what is left, or overspent,
+rolls over to the next month. Income and movements outside the envelopes go to the pool,
+with the initial balances of the accounts; transfers between accounts are left out.
+
+Only assignments are stored (envelopes.json): balances are rebuilt from the movements,
+so edits, deletions and undos are always reflected. An envelope starts at the first
+assignment of its category. Amounts are in the reporting currency.
+
+Subcommands:
+  show      Carried over, assigned, activity and available per envelope (default)
+  assign    Move money from the pool to an envelope (a negative amount gives it back)
+  move      Move money from one envelope to another
+  list      Show the assignments of the month
+
+Options:
+  --month <YYYY-MM>         Month (default: the current month)
+  --description, -d <text>  Description of the assignment
+  --format, -F <fmt>        Output format: text (default), csv, json
+  --help, -?                Show this help message
+
+Examples:
+  comptes envelope assign ALM 400
+  comptes envelope assign VAC 150 --month 2024-07 -d "Épargne vacances"
+  comptes envelope move LOI ALM 50
+  comptes envelope --month 2024-06`
+
+	HelpSchedule = `Usage: comptes schedule [list]
+       comptes schedule add <id> --account <id> --amount <amount> [frequency] [options]
+       comptes schedule remove <id>
+       comptes schedule due [--until <date>]
+       comptes schedule run [--until <date>]
+
+Recurring rules describe movements that repeat (rent, salary, subscriptions). 'run' puts
+every occurrence due up to today (or --until) into a new pending batch: review it, then
+'comptes commit' or 'comptes rollback' it. Each movement keeps the key of its occurrence
+(rule and date), so an occurrence is never posted twice: not after an edit or a deletion
+of its movement, nor while it waits in a pending batch. Occurrences of a rolled back batch
+are due again.
+
+Subcommands:
+  list      Show the rules and their next occurrence (default)
+  add       Add a rule
+  remove    Remove a rule (the movements it posted are kept, and its ID cannot be reused)
+  due       Show the occurrences that 'run' would post
+  run       Post the due occurrences into a new pending batch
+
+Frequency (default: --monthly):
+  --daily, --weekly, --monthly, --yearly
+  --every <n>               Every n days, weeks, months or years (default: 1)
+  --day <1-31>              Monthly: day of the month, the last day for shorter months
+                            (default: the day of --start)
+
+Options:
+  --account, -a <id>        Account (required)
+  --amount, -m <amount>     Amount of each movement (required)
+  --description, -d <text>  Description (default: the rule ID)
+  --categories, -c <codes>  Comma-separated categories
+  --tags, -t <codes>        Comma-separated tags (default: REC when it exists)
+  --start <date>            First possible day (default: today)
+  --end <date>              Last possible day, included
+  --count <n>               Number of occurrences
+  --help, -?                Show this help message
+
+Examples:
+  comptes schedule add LOYER -a BANQUE -m -850 --day 5 -c LGT -d "Loyer"
+  comptes schedule add SALAIRE -a BANQUE -m 2500 --day 28 -c SLR --start 2024-01-01
+  comptes schedule add NETFLIX -a BANQUE -m -13.49 -c LOI --start 2024-01-12 --count 12
+  comptes schedule add ASSURANCE -a BANQUE -m -420 --yearly -c ASS --start 2024-03-01
+  comptes schedule due --until 2024-12-31
+  comptes schedule run`
+
+	HelpBalance = `Usage: comptes balance [options]
+
+Shows the balance of each active account in its own currency. Accounts in another
+currency are also converted to the reporting currency, and a converted total is shown.
+Closed accounts are hidden unless --all is given.
+
+Options:
+  --currency, -C <code>     Currency to convert to (default: reporting_currency from config.yaml)
+  --all, -A                 Also list closed accounts
+  --rate-date <when>        Rates used for conversion: today (default) or transaction
+                            (each movement converted at the rate of its own date)
+  --as-of <date>            Only count movements dated on or before this day
+  --recorded-at <time>      The balances as recorded at this time: later additions are
+                            left out and later edits and deletions undone (a date alone
+                            means the end of that day)
+  --help, -?                Show this help message
+
+Trend: the balance of each account at the end of each interval, in its own currency
+(default: the last 12 intervals up to today).
+  --trend                   Show the balance trend instead of the current balances
+  --interval <i>            daily, weekly (weeks end on Sunday) or monthly (default)
+  --account <id>            Only this account (repeatable)
+  --from <date>, --to <date>  Range of the trend (bounds included)
+  --format, -F <fmt>        Output format: text (default), csv, json
+
+Examples:
+  comptes balance
+  comptes balance --currency USD
+  comptes balance --rate-date transaction
+  comptes balance --as-of 2024-01-31      # End of January, future movements excluded
+  comptes balance --recorded-at 2024-02-01 --as-of 2024-01-31
+  comptes balance --trend
+  comptes balance --trend --interval weekly --account BANQUE --from 2024-01-01
+  comptes balance --trend --from 2024-01-01 --to 2024-12-31 --format csv`
+
+	HelpRates = `Usage: comptes rates [list]
+       comptes rates add <FROM> <TO> <rate> [date]
+       comptes rates import <file.csv>
+
+Manages the local exchange-rate table used to convert balances and cross-currency transfers.
+A rate gives the value of 1 FROM in TO. Conversions use the latest rate on or before
+the date (or the earliest later one), and inverse pairs are used automatically.
+
+CSV format (header optional):
+  date,from,to,rate
+  2024-01-31,USD,EUR,0.9215
+
+Examples:
+  comptes rates add USD EUR 0.9215 2024-01-31
+  comptes rates import rates.csv
+  comptes rates list`
+
+	HelpMigrate = `Usage: comptes migrate [--dry-run]
+
+Upgrades the data directory to the schema version of this binary. Other commands refuse
+to run until it is done, and refuse a data directory written by a newer comptes.
+
+Each pending migration is first checked on a copy of the data directory; then the
+directory is backed up to data/backups/<date>-schema-v<N>/ before the migration is applied.
+
+Flags:
+  -n, --dry-run   Only run the check, without changing anything
+
+Examples:
+  comptes migrate --dry-run
+  comptes migrate`
+
+	HelpEncryption = `Usage: comptes encryption [status|enable|disable|rotate]
+
+Encrypts the data files at rest (AES-256-GCM) with a key derived from a passphrase
+(PBKDF2-SHA256). Once enabled, every command asks for the passphrase, or reads it
+from COMPTES_PASSPHRASE. The passphrase cannot be recovered: without it, the data is lost.
+
+Subcommands:
+  status    Show whether the data directory is encrypted (default)
+  enable    Encrypt the data directory (asks for a new passphrase twice)
+  disable   Decrypt the data directory
+  rotate    Re-encrypt with a new passphrase (asks for it twice)
+
+The new passphrase of enable and rotate can be given in COMPTES_NEW_PASSPHRASE.
+Backups taken before this command (data/backups) are left as they were.
+
+Examples:
+  comptes encryption enable
+  COMPTES_PASSPHRASE=old COMPTES_NEW_PASSPHRASE=new comptes encryption rotate`
+
+	HelpConfig = `Usage: comptes config [diff|apply] [--prune] [--remap OLD=NEW ...] [--dry-run]
+
+Compares the accounts, categories, tags and budgets of config.yaml with the data directory
+and shows what would be added (+), changed (~) or removed (-). Items of the data directory
+missing from config.yaml, such as codes added with the CLI, are kept unless --prune is
+given. Movements are kept: a code still used by movements can only be pruned with a remap,
+which moves those movements to another code (a backup is taken first).
+
+Subcommands:
+  diff      Show the changes (default)
+  apply     Apply them
+
+Flags:
+  --prune           Remove the items missing from config.yaml
+  --remap OLD=NEW   Move the movements of removed OLD to NEW (repeatable)
+  -n, --dry-run     With apply, only show the changes
+
+Examples:
+  comptes config
+  comptes config apply
+  comptes config apply --prune --remap LOI=SRT --remap URG=IMP`
+
+	HelpBackup = `Usage: comptes backup [-o <archive>]
+
+Writes a compressed archive (tar.gz) of the data files, as stored (encrypted or not),
+and of config.yaml, with a manifest listing each file and its SHA-256 checksum.
+By default the archive goes to data/backups/comptes-<date>.tar.gz.
+
+A backup is also taken automatically before delete --hard, undo --hard, restore and
+a config apply that remaps movements (data/backups/auto-*.tar.gz, the latest 10 are kept).
+
+Flags:
+  -o, --output <archive>   Path of the archive to write
+
+Examples:
+  comptes backup
+  comptes backup -o ~/comptes-2024-01.tar.gz`
+
+	HelpRestore = `Usage: comptes restore <archive> [--force]
+
+Verifies an archive written by 'comptes backup' (manifest and checksums), shows what
+it holds next to the current data, then replaces the data directory and config.yaml
+after confirmation. The current data is backed up first.
+
+Flags:
+  -f, --force   Restore without confirmation
+
+Examples:
+  comptes restore data/backups/comptes-20240131-093000.tar.gz`
+
 	HelpEdit = `Usage: comptes edit <id> <json> -m <message>
 
 Example: comptes edit fd6647d8 '{"amount": -30.00}' -m "Correction montant"
@@ -88,7 +453,7 @@ Note: Message is mandatory for edit operations`
 
 Options:
   -m, --message     Message explaining the deletion (required)
-  --hard, -H        Permanently delete the transaction (cannot be undone)
+  --hard, -H        Permanently delete the transaction (a backup is taken first)
   -f, --force       Skip confirmation prompt for destructive operations
   --help, -?        Show this help message
 
@@ -104,7 +469,7 @@ Note: Message is mandatory for delete operations`
 	HelpUndo = `Usage: comptes undo <id> [options]
 
 Options:
-  --hard, -H        Permanently remove the transaction (cannot be undone)
+  --hard, -H        Permanently remove the transaction (a backup is taken first)
   -f, --force       Skip confirmation prompt for destructive operations
   --help, -?        Show this help message
 
@@ -162,7 +527,32 @@ Examples:
 When account is set, you can use simplified add commands:
   comptes add -m -25.50 --desc "Courses"  # Uses context account automatically`
 
+	HelpAccounts = `Usage: comptes accounts
+       comptes accounts add <id> --currency <code> [--name <name>] [--type <type>] [--initial-balance <amount>]
+       comptes accounts rename <id> <name>
+       comptes accounts close <id> [--transfer-to <id>]
+       comptes accounts reopen <id>
+
+Without a subcommand, lists every account with its balance (closed ones marked ❌).
+
+A closed account rejects new movements, and its movements can no longer be edited,
+deleted or undone; it is hidden from 'comptes balance' (see --all). An account can only
+be closed at a zero balance: --transfer-to records a closing transfer that moves the
+remaining balance to (or covers a negative balance from) another open account in the
+same currency. rename changes the display name; the ID used by movements stays.
+rename, close and reopen also update the account in config.yaml.
+
+Examples:
+  comptes accounts add PEA --currency EUR --name "Plan d'épargne" --type investment
+  comptes accounts rename BANQUE "Compte joint"
+  comptes accounts close LIVRET --transfer-to BANQUE
+  comptes accounts reopen LIVRET`
+
 	HelpCategory = `Usage: comptes category [category-code ...]
+       comptes category add <code> [--name <name>] [--parent <code>] [--description <text>]
+       comptes category rename <code> <new-code>
+       comptes category merge <code> <into-code>
+       comptes category remove <code>
 
 Sets the default categories in the transaction context. These categories will be used automatically
 when adding transactions if no categories are specified.
@@ -173,9 +563,23 @@ Examples:
   comptes category                    # Show current categories
 
 When categories are set, you can use simplified add commands:
-  comptes add -a BANQUE -m -25.50 --desc "Courses"  # Uses context categories automatically`
+  comptes add -a BANQUE -m -25.50 --desc "Courses"  # Uses context categories automatically
+
+Managing categories:
+  comptes category add RES --name Restaurants --parent ALM
+  comptes category rename LOI SRT     # Rewrites every movement using LOI
+  comptes category merge RES ALM      # Movements of RES move to ALM, RES is removed
+  comptes category remove RES         # Only when no movement uses it and it has no children
+
+Renames and merges apply to active and historical movements and to batches, and are
+recorded in the audit log (comptes list --audit). rename, merge and remove also update
+config.yaml, keeping its comments; added codes are kept by 'comptes config apply'.`
 
 	HelpTags = `Usage: comptes tags [tag-code ...]
+       comptes tags add <code> [--name <name>] [--parent <code>] [--description <text>]
+       comptes tags rename <code> <new-code>
+       comptes tags merge <code> <into-code>
+       comptes tags remove <code>
 
 Sets the default tags in the transaction context. These tags will be used automatically
 when adding transactions if no tags are specified.
@@ -186,7 +590,10 @@ Examples:
   comptes tags                        # Show current tags
 
 When tags are set, you can use simplified add commands:
-  comptes add -a BANQUE -m -25.50 --desc "Courses"  # Uses context tags automatically`
+  comptes add -a BANQUE -m -25.50 --desc "Courses"  # Uses context tags automatically
+
+Managing tags works like categories (see 'comptes category --help'): renames and merges
+rewrite every movement using the tag, are recorded in the audit log and update config.yaml.`
 
 	HelpContext = `Usage: comptes context [clear]
 
@@ -209,8 +616,32 @@ func ShowHelp(command string) {
 	switch command {
 	case "add":
 		fmt.Println(HelpAdd)
+	case "transfer":
+		fmt.Println(HelpTransfer)
 	case "list":
 		fmt.Println(HelpList)
+	case "balance":
+		fmt.Println(HelpBalance)
+	case "report":
+		fmt.Println(HelpReport)
+	case "budget":
+		fmt.Println(HelpBudget)
+	case "envelope":
+		fmt.Println(HelpEnvelope)
+	case "schedule":
+		fmt.Println(HelpSchedule)
+	case "rates":
+		fmt.Println(HelpRates)
+	case "migrate":
+		fmt.Println(HelpMigrate)
+	case "encryption":
+		fmt.Println(HelpEncryption)
+	case "config":
+		fmt.Println(HelpConfig)
+	case "backup":
+		fmt.Println(HelpBackup)
+	case "restore":
+		fmt.Println(HelpRestore)
 	case "edit":
 		fmt.Println(HelpEdit)
 	case "delete":
@@ -225,6 +656,8 @@ func ShowHelp(command string) {
 		fmt.Println(HelpRollback)
 	case "account":
 		fmt.Println(HelpAccount)
+	case "accounts":
+		fmt.Println(HelpAccounts)
 	case "category":
 		fmt.Println(HelpCategory)
 	case "tags":
@@ -3,11 +3,19 @@ package cli
 import (
 	"comptes/internal/config"
 	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
 	"fmt"
 	"os"
 )
 
 func (c *CLI) handleInit() error {
+	// Re-initializing would replace accounts, categories and tags under existing movements
+	if storage.HasData(c.dataDir) {
+		return errors.New(errors.ErrorTypeBusiness, errors.CodeAlreadyInitialized,
+			fmt.Sprintf("Data directory %s is already initialized: use 'comptes config apply' to sync config.yaml", c.dataDir))
+	}
+
 	fmt.Println("Initializing comptes project...")
 	if err := c.initProject(); err != nil {
 		return fmt.Errorf("error initializing project: %w", err)
@@ -17,6 +25,11 @@ func (c *CLI) handleInit() error {
 }
 
 func (c *CLI) initProject() error {
+	if err := c.storage.Lock(); err != nil {
+		return err
+	}
+	defer c.storage.Unlock()
+
 	// Load configuration from YAML file, or create default if not exists
 	configPath := config.GetConfigPath()
 	cfg, err := config.LoadConfig(configPath)
@@ -50,10 +63,15 @@ func (c *CLI) initProject() error {
 		return err
 	}
 
+	// Save budgets from config
+	if err := c.storage.SaveBudgets(cfg.Budgets); err != nil {
+		return err
+	}
+
 	// Create empty movements file
 	if err := c.storage.SaveTransactions([]domain.Transaction{}); err != nil {
 		return err
 	}
 
-	return nil
+	return storage.WriteSchemaVersion(c.dataDir, storage.CurrentSchemaVersion)
 }
@@ -2,15 +2,20 @@ package cli
 
 import (
 	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"comptes/internal/storage"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 )
 
 // AccountWithBalance represents an account with its current balance
 type AccountWithBalance struct {
 	domain.Account
-	CurrentBalance float64 `json:"current_balance"`
+	CurrentBalance domain.Money `json:"current_balance"`
 }
 
 func (c *CLI) handleList(args []string) error {
@@ -21,6 +26,8 @@ func (c *CLI) handleList(args []string) error {
 	showAccounts := false
 	showTransactions := true // Par défaut, on liste les transactions
 	showCodes := false
+	showAudit := false
+	showBalance := false
 
 	// Check for help flag first
 	for _, arg := range args {
@@ -56,6 +63,22 @@ func (c *CLI) handleList(args []string) error {
 		if arg == "--codes" || arg == "-k" {
 			showCodes = true
 		}
+		if arg == "--running-balance" || arg == "-r" {
+			showBalance = true
+		}
+		if arg == "--audit" {
+			showAudit = true
+			showTransactions = false
+		}
+	}
+
+	filter, err := parseListFilter(args)
+	if err != nil {
+		return err
+	}
+	filter.History = showHistory
+	if filter.View, err = parseLedgerView(args); err != nil {
+		return err
 	}
 
 	// Handle different list types
@@ -66,53 +89,164 @@ func (c *CLI) handleList(args []string) error {
 		return c.showTags(format)
 	}
 	if showAccounts {
-		return c.showAccounts(format)
+		return c.showAccounts(format, filter.View)
+	}
+	if showAudit {
+		return c.showAudit(format)
 	}
 	if showTransactions {
-		return c.listTransactions(format, showHistory, showCodes)
+		return c.listTransactions(format, showCodes, showBalance, filter)
 	}
 
 	// Fallback: liste les transactions par défaut
-	return c.listTransactions(format, showHistory, showCodes)
+	return c.listTransactions(format, showCodes, showBalance, filter)
 }
 
-func (c *CLI) listTransactions(format string, showHistory bool, showCodes bool) error {
-	transactions, err := c.transactionService.GetTransactions()
+// parseListFilter reads the filters of 'comptes list'; they combine with AND
+func parseListFilter(args []string) (service.TransactionFilter, error) {
+	var filter service.TransactionFilter
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--no-transfers" {
+			filter.NoTransfer = true
+			continue
+		}
+		switch arg {
+		case "--account", "--from", "--to", "--category", "--tag", "--min", "--max", "--search", "-s", "--batch", "--where", "-w":
+		default:
+			continue
+		}
+		if i+1 >= len(args) {
+			return filter, errors.New(errors.ErrorTypeUserInput, errors.CodeMissingArguments, fmt.Sprintf("%s requires a value", arg))
+		}
+		i++
+		value := args[i]
+
+		switch arg {
+		case "--account":
+			filter.Account = strings.ToUpper(value)
+		case "--from", "--to":
+			date, err := parseDate(value)
+			if err != nil {
+				return filter, errors.InvalidDate(value, err)
+			}
+			day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+			if arg == "--from" {
+				filter.From = day
+			} else {
+				filter.To = day.AddDate(0, 0, 1) // --to is inclusive
+			}
+		case "--category":
+			filter.Categories = append(filter.Categories, strings.ToUpper(value))
+		case "--tag":
+			filter.Tags = append(filter.Tags, strings.ToUpper(value))
+		case "--min", "--max":
+			amount, err := parseAmount(value)
+			if err != nil {
+				return filter, errors.InvalidAmount(value, err)
+			}
+			if arg == "--min" {
+				filter.Min = &amount
+			} else {
+				filter.Max = &amount
+			}
+		case "--search", "-s":
+			filter.Search = value
+		case "--batch":
+			filter.Batch = value
+		case "--where", "-w":
+			expression, err := service.ParseExpression(value)
+			if err != nil {
+				return filter, err
+			}
+			filter.Where = expression
+		}
+	}
+	return filter, nil
+}
+
+// parseLedgerView reads --as-of (movements dated on or before a day) and --recorded-at
+// (the ledger as recorded at an instant; a date without time means the end of that day)
+func parseLedgerView(args []string) (service.LedgerView, error) {
+	var view service.LedgerView
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg != "--as-of" && arg != "--recorded-at" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return view, errors.New(errors.ErrorTypeUserInput, errors.CodeMissingArguments, fmt.Sprintf("%s requires a value", arg))
+		}
+		i++
+		value := args[i]
+		date, err := parseDate(value)
+		if err != nil {
+			return view, errors.InvalidDate(value, err)
+		}
+		if arg == "--as-of" {
+			view.AsOf = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		// Recording times are local wall-clock times
+		if strings.Contains(value, ":") {
+			view.RecordedAt = time.Date(date.Year(), date.Month(), date.Day(), date.Hour(), date.Minute(), date.Second(), 0, time.Local)
+		} else {
+			view.RecordedAt = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	return view, nil
+}
+
+// viewLabel describes a ledger view for headers, empty for the current ledger
+func viewLabel(view service.LedgerView) string {
+	var parts []string
+	if !view.AsOf.IsZero() {
+		parts = append(parts, "as of "+view.AsOf.Format("2006-01-02"))
+	}
+	if !view.RecordedAt.IsZero() {
+		parts = append(parts, "as recorded at "+view.RecordedAt.Format("2006-01-02 15:04:05"))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+func (c *CLI) listTransactions(format string, showCodes bool, showBalance bool, filter service.TransactionFilter) error {
+	// Seulement les transactions actives, sauf avec --history ; une catégorie inclut ses sous-catégories
+	filteredTransactions, err := c.transactionService.FilterTransactions(filter)
 	if err != nil {
 		return err
 	}
 
-	if len(transactions) == 0 {
+	if len(filteredTransactions) == 0 {
 		fmt.Println("No transactions found.")
 		return nil
 	}
 
-	// Filtrer selon le flag --history
-	var filteredTransactions []domain.Transaction
-	if showHistory {
-		filteredTransactions = transactions // Toutes les transactions
-	} else {
-		// Seulement les transactions actives
-		for _, txn := range transactions {
-			if txn.IsActive {
-				filteredTransactions = append(filteredTransactions, txn)
-			}
+	// Le solde courant suit l'ordre des dates : la liste aussi
+	var balances map[string]domain.Money
+	if showBalance {
+		service.SortByDate(filteredTransactions)
+		if balances, err = c.transactionService.RunningBalances(filter.View); err != nil {
+			return err
 		}
 	}
 
+	showHistory := filter.History
 	switch format {
 	case "csv":
-		return c.listTransactionsCSV(filteredTransactions, showHistory, showCodes)
+		return c.listTransactionsCSV(filteredTransactions, showHistory, showCodes, balances)
 	case "json":
-		return c.listTransactionsJSON(filteredTransactions, showHistory, showCodes)
+		return c.listTransactionsJSON(filteredTransactions, showHistory, showCodes, balances)
 	case "text":
 		fallthrough
 	default:
-		return c.listTransactionsText(filteredTransactions, showHistory, showCodes)
+		return c.listTransactionsText(filteredTransactions, showHistory, showCodes, balances)
 	}
 }
 
-func (c *CLI) listTransactionsText(transactions []domain.Transaction, showHistory bool, showCodes bool) error {
+func (c *CLI) listTransactionsText(transactions []domain.Transaction, showHistory bool, showCodes bool, balances map[string]domain.Money) error {
 	if showHistory {
 		fmt.Println("Transactions (all):")
 	} else {
@@ -169,11 +303,11 @@ func (c *CLI) listTransactionsText(transactions []domain.Transaction, showHistor
 			if !txn.IsActive {
 				status = "❌"
 			}
-			line = fmt.Sprintf("- [%s] %s %s: %.2f EUR - %s (Categories: %v)",
+			line = fmt.Sprintf("- [%s] %s %s: %s - %s (Categories: %v)",
 				txn.ID, status, txn.Date.Format("2006-01-02"), txn.Amount, txn.Description, categoriesDisplay)
 		} else {
 			// Pas d'indicateur de statut pour list normal (toutes sont actives)
-			line = fmt.Sprintf("- [%s] %s: %.2f EUR - %s (Categories: %v)",
+			line = fmt.Sprintf("- [%s] %s: %s - %s (Categories: %v)",
 				txn.ID, txn.Date.Format("2006-01-02"), txn.Amount, txn.Description, categoriesDisplay)
 		}
 
@@ -182,23 +316,73 @@ func (c *CLI) listTransactionsText(transactions []domain.Transaction, showHistor
 			line += fmt.Sprintf(", Tags: %v", tagsDisplay)
 		}
 
+		// Signaler les virements entre comptes
+		if txn.IsTransfer() {
+			line += " [transfer]"
+		}
+
 		// Ajouter le commentaire d'edit si présent
 		if txn.EditComment != "" {
 			line += fmt.Sprintf(" | Edit: %s", txn.EditComment)
 		}
 
+		// Solde du compte après le mouvement avec --running-balance
+		if balance, exists := balances[txn.ID]; exists {
+			line += fmt.Sprintf(" | Balance: %s", balance)
+		}
+
 		fmt.Println(line)
+
+		// Lignes de ventilation
+		for _, split := range txn.Splits {
+			splitLine := fmt.Sprintf("    ↳ %s (Categories: %v)", split.Amount, displayCodes(split.Categories, categoryMap, showCodes))
+			if len(split.Tags) > 0 {
+				splitLine += fmt.Sprintf(", Tags: %v", displayCodes(split.Tags, tagMap, showCodes))
+			}
+			if split.Description != "" {
+				splitLine += " - " + split.Description
+			}
+			fmt.Println(splitLine)
+		}
 	}
 	return nil
 }
 
-func (c *CLI) listTransactionsCSV(transactions []domain.Transaction, showHistory bool, showCodes bool) error {
+// displayCodes converts codes to names unless showCodes is set (unknown codes are kept as is)
+func displayCodes(codes []string, names map[string]string, showCodes bool) []string {
+	if showCodes {
+		return codes
+	}
+	display := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if name, exists := names[code]; exists {
+			display = append(display, name)
+		} else {
+			display = append(display, code)
+		}
+	}
+	return display
+}
+
+// formatSplitsCSV formats split lines as "Cat1+Cat2=-90.00;Cat3=-30.00"
+func formatSplitsCSV(splits []domain.Split, categoryMap map[string]string, showCodes bool) string {
+	parts := make([]string, 0, len(splits))
+	for _, split := range splits {
+		parts = append(parts, strings.Join(displayCodes(split.Categories, categoryMap, showCodes), "+")+"="+split.Amount.Decimal())
+	}
+	return strings.Join(parts, ";")
+}
+
+func (c *CLI) listTransactionsCSV(transactions []domain.Transaction, showHistory bool, showCodes bool, balances map[string]domain.Money) error {
 	// En-tête CSV
+	header := "id,date,amount,currency,description,categories,tags,transfer_id,splits"
 	if showHistory {
-		fmt.Println("id,date,amount,description,categories,tags,is_active,edit_comment")
-	} else {
-		fmt.Println("id,date,amount,description,categories,tags")
+		header += ",is_active,edit_comment"
 	}
+	if balances != nil {
+		header += ",balance"
+	}
+	fmt.Println(header)
 
 	// Charger les catégories et tags pour la conversion des codes en noms
 	categories, _ := c.storage.GetCategories()
@@ -254,28 +438,42 @@ func (c *CLI) listTransactionsCSV(transactions []domain.Transaction, showHistory
 			tagsStr = strings.Join(tagsDisplay, ";")
 		}
 
+		// Convertir les lignes de ventilation en string
+		splitsStr := formatSplitsCSV(txn.Splits, categoryMap, showCodes)
+
+		row := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%s,%s,%s",
+			txn.ID, txn.Date.Format("2006-01-02"), txn.Amount.Decimal(), txn.Amount.Currency, txn.Description, categoriesStr, tagsStr, txn.TransferID, splitsStr)
 		if showHistory {
-			fmt.Printf("%s,%s,%.2f,%s,%s,%s,%t,%s\n",
-				txn.ID, txn.Date.Format("2006-01-02"), txn.Amount, txn.Description, categoriesStr, tagsStr, txn.IsActive, txn.EditComment)
-		} else {
-			fmt.Printf("%s,%s,%.2f,%s,%s,%s\n",
-				txn.ID, txn.Date.Format("2006-01-02"), txn.Amount, txn.Description, categoriesStr, tagsStr)
+			row += fmt.Sprintf(",%t,%s", txn.IsActive, txn.EditComment)
 		}
+		if balances != nil {
+			// Vide pour les mouvements supprimés ou édités
+			if balance, exists := balances[txn.ID]; exists {
+				row += "," + balance.Decimal()
+			} else {
+				row += ","
+			}
+		}
+		fmt.Println(row)
 	}
 	return nil
 }
 
-func (c *CLI) listTransactionsJSON(transactions []domain.Transaction, showHistory bool, showCodes bool) error {
+func (c *CLI) listTransactionsJSON(transactions []domain.Transaction, showHistory bool, showCodes bool, balances map[string]domain.Money) error {
 	// Créer une structure simplifiée pour le JSON
 	type TransactionOutput struct {
-		ID          string   `json:"id"`
-		Date        string   `json:"date"`
-		Amount      float64  `json:"amount"`
-		Description string   `json:"description"`
-		Categories  []string `json:"categories"`
-		Tags        []string `json:"tags"`
-		IsActive    *bool    `json:"is_active,omitempty"`
-		EditComment string   `json:"edit_comment,omitempty"`
+		ID          string         `json:"id"`
+		Date        string         `json:"date"`
+		Amount      domain.Money   `json:"amount"`
+		Description string         `json:"description"`
+		Categories  []string       `json:"categories"`
+		Tags        []string       `json:"tags"`
+		TransferID  string         `json:"transfer_id,omitempty"`
+		Splits      []domain.Split `json:"splits,omitempty"`
+		Rate        string         `json:"exchange_rate,omitempty"`
+		IsActive    *bool          `json:"is_active,omitempty"`
+		EditComment string         `json:"edit_comment,omitempty"`
+		Balance     *domain.Money  `json:"balance,omitempty"`
 	}
 
 	var output []TransactionOutput
@@ -297,6 +495,9 @@ func (c *CLI) listTransactionsJSON(transactions []domain.Transaction, showHistor
 			Description: txn.Description,
 			Categories:  categories,
 			Tags:        tags,
+			TransferID:  txn.TransferID,
+			Splits:      txn.Splits,
+			Rate:        txn.ExchangeRate,
 			EditComment: txn.EditComment,
 		}
 
@@ -305,6 +506,11 @@ func (c *CLI) listTransactionsJSON(transactions []domain.Transaction, showHistor
 			transactionOutput.IsActive = &txn.IsActive
 		}
 
+		// Solde du compte après le mouvement avec --running-balance
+		if balance, exists := balances[txn.ID]; exists {
+			transactionOutput.Balance = &balance
+		}
+
 		output = append(output, transactionOutput)
 	}
 
@@ -339,23 +545,56 @@ func (c *CLI) showCategories(format string) error {
 	}
 }
 
-// showCategoriesText displays categories in text format
+// showCategoriesText displays categories as a tree, with the total of the active
+// movements of each category including its subcategories
 func (c *CLI) showCategoriesText(categories []domain.Category) error {
+	transactions, err := c.transactionService.QueryTransactions(storage.TransactionQuery{Active: storage.ActiveOnly(true)})
+	if err != nil {
+		return err
+	}
+	tree := domain.NewCategoryTree(categories)
+	totals := tree.Totals(transactions)
+
 	fmt.Println("Available categories:")
 	fmt.Println("===================")
-	for _, cat := range categories {
-		fmt.Printf("• %s (%s) - %s\n", cat.Name, cat.Code, cat.Description)
-	}
+	tree.Walk(func(cat domain.Category, depth int) {
+		line := fmt.Sprintf("%s• %s (%s)", strings.Repeat("  ", depth), cat.Name, cat.Code)
+		if cat.Description != "" {
+			line += " - " + cat.Description
+		}
+		if total := formatTotals(totals[cat.Code]); total != "" {
+			line += " | " + total
+		}
+		fmt.Println(line)
+	})
 	return nil
 }
 
+// formatTotals formats amounts in several currencies, sorted by currency
+func formatTotals(amounts map[string]domain.Money) string {
+	currencies := make([]string, 0, len(amounts))
+	for currency := range amounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	parts := make([]string, len(currencies))
+	for i, currency := range currencies {
+		parts[i] = amounts[currency].String()
+	}
+	return strings.Join(parts, ", ")
+}
+
 // showCategoriesCSV displays categories in CSV format
 func (c *CLI) showCategoriesCSV(categories []domain.Category) error {
-	fmt.Println("code,name,description")
+	fmt.Println("code,name,description,parent")
 	for _, cat := range categories {
 		// Échapper les virgules dans la description en utilisant des guillemets
 		description := strings.ReplaceAll(cat.Description, "\"", "\"\"") // Échapper les guillemets existants
-		fmt.Printf("%s,%s,\"%s\"\n", cat.Code, cat.Name, description)
+		parent := ""
+		if cat.Parent != nil {
+			parent = *cat.Parent
+		}
+		fmt.Printf("%s,%s,\"%s\",%s\n", cat.Code, cat.Name, description, parent)
 	}
 	return nil
 }
@@ -423,12 +662,18 @@ func (c *CLI) showTagsJSON(tags []domain.Tag) error {
 	return nil
 }
 
-// showAccounts displays all available accounts
-func (c *CLI) showAccounts(format string) error {
-	accounts, err := c.storage.GetAccounts()
+// showAccounts displays all available accounts with their balance as seen by view
+func (c *CLI) showAccounts(format string, view service.LedgerView) error {
+	stored, err := c.storage.GetAccounts()
 	if err != nil {
 		return fmt.Errorf("error loading accounts: %w", err)
 	}
+	var accounts []domain.Account
+	for _, acc := range stored {
+		if view.HasAccount(acc) {
+			accounts = append(accounts, acc)
+		}
+	}
 
 	if len(accounts) == 0 {
 		fmt.Println("No accounts found.")
@@ -438,7 +683,7 @@ func (c *CLI) showAccounts(format string) error {
 	// Calculate current balances for each account
 	accountsWithBalance := make([]AccountWithBalance, len(accounts))
 	for i, acc := range accounts {
-		balance, err := c.transactionService.GetAccountBalance(acc.ID)
+		balance, err := c.transactionService.AccountBalanceIn(acc.ID, view)
 		if err != nil {
 			balance = acc.InitialBalance // Fallback to initial balance if calculation fails
 		}
@@ -467,9 +712,9 @@ func (c *CLI) showAccountsText(accounts []AccountWithBalance) error {
 		if !acc.IsActive {
 			status = "❌"
 		}
-		fmt.Printf("%s %s (%s) - %.2f %s", status, acc.Name, acc.ID, acc.CurrentBalance, acc.Currency)
+		fmt.Printf("%s %s (%s) - %s", status, acc.Name, acc.ID, acc.CurrentBalance)
 		if acc.InitialBalance != acc.CurrentBalance {
-			fmt.Printf(" (initial: %.2f %s)", acc.InitialBalance, acc.Currency)
+			fmt.Printf(" (initial: %s)", acc.InitialBalance)
 		}
 		fmt.Println()
 	}
@@ -481,8 +726,8 @@ func (c *CLI) showAccountsCSV(accounts []AccountWithBalance) error {
 	fmt.Println("id,name,type,currency,initial_balance,current_balance,is_active")
 	for _, acc := range accounts {
 		name := strings.ReplaceAll(acc.Name, "\"", "\"\"")
-		fmt.Printf("%s,\"%s\",%s,%s,%.2f,%.2f,%t\n",
-			acc.ID, name, acc.Type, acc.Currency, acc.InitialBalance, acc.CurrentBalance, acc.IsActive)
+		fmt.Printf("%s,\"%s\",%s,%s,%s,%s,%t\n",
+			acc.ID, name, acc.Type, acc.Currency, acc.InitialBalance.Decimal(), acc.CurrentBalance.Decimal(), acc.IsActive)
 	}
 	return nil
 }
@@ -496,3 +741,34 @@ func (c *CLI) showAccountsJSON(accounts []AccountWithBalance) error {
 	fmt.Println(string(jsonData))
 	return nil
 }
+
+// showAudit displays the audit log, oldest first
+func (c *CLI) showAudit(format string) error {
+	entries, err := c.storage.GetAuditLog()
+	if err != nil {
+		return fmt.Errorf("error loading audit log: %w", err)
+	}
+
+	if format == "json" {
+		if entries == nil {
+			entries = []domain.AuditEntry{}
+		}
+		jsonData, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Audit log is empty.")
+		return nil
+	}
+	fmt.Println("Audit log:")
+	for _, entry := range entries {
+		fmt.Printf("- %s [%s] %s (%d movements)\n",
+			entry.Time.Format("2006-01-02 15:04:05"), entry.Action, entry.Details, len(entry.Transactions))
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package cli
+
+import "testing"
+
+func TestParseListFilter_NormalisesCodes(t *testing.T) {
+	filter, err := parseListFilter([]string{"list", "--account", "banque", "--category", "alm", "--tag", "urg"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if filter.Account != "BANQUE" {
+		t.Errorf("Expected the account upper-cased, got %q", filter.Account)
+	}
+	if len(filter.Categories) != 1 || filter.Categories[0] != "ALM" || len(filter.Tags) != 1 || filter.Tags[0] != "URG" {
+		t.Errorf("Expected ALM and URG, got %v and %v", filter.Categories, filter.Tags)
+	}
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"comptes/internal/storage"
+	"fmt"
+)
+
+func (c *CLI) handleMigrate(args []string) error {
+	dryRun := false
+	for _, arg := range args[2:] {
+		switch arg {
+		case "--help", "-?":
+			ShowHelp("migrate")
+			return nil
+		case "--dry-run", "-n":
+			dryRun = true
+		}
+	}
+
+	version, err := storage.SchemaVersion(c.dataDir)
+	if err != nil {
+		return err
+	}
+
+	steps, err := storage.Migrate(c.dataDir, dryRun)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Printf("Data directory is up to date (schema version %d).\n", version)
+		return nil
+	}
+
+	fmt.Printf("Data directory schema version %d, current version %d.\n", version, storage.CurrentSchemaVersion)
+	for _, step := range steps {
+		if dryRun {
+			fmt.Printf("- v%d: %s (check passed)\n", step.Version, step.Description)
+		} else {
+			fmt.Printf("- v%d: %s (backup: %s)\n", step.Version, step.Description, step.Backup)
+		}
+	}
+	if dryRun {
+		fmt.Println("Dry run: nothing was changed. Run 'comptes migrate' to apply.")
+	} else {
+		fmt.Printf("Data directory migrated to schema version %d.\n", storage.CurrentSchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bufio"
+	"comptes/internal/errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Environment variables read instead of prompting, for scripts
+const (
+	passphraseEnv    = "COMPTES_PASSPHRASE"
+	newPassphraseEnv = "COMPTES_NEW_PASSPHRASE"
+)
+
+// stdin is shared by the prompts so that a piped input is not lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase returns the passphrase from env, or asks for it on the terminal
+func readPassphrase(env, prompt string) (string, error) {
+	if passphrase, ok := os.LookupEnv(env); ok {
+		return passphrase, nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	// Hide the typed passphrase when stdin is a terminal (best effort: stty is Unix only)
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		if setEcho(false) == nil {
+			defer func() {
+				setEcho(true)
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.PassphraseRequired()
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassphrase asks for a new passphrase twice, unless env provides it
+func readNewPassphrase(env string) (string, error) {
+	if passphrase, ok := os.LookupEnv(env); ok {
+		if passphrase == "" {
+			return "", errors.PassphraseRequired()
+		}
+		return passphrase, nil
+	}
+
+	passphrase, err := readPassphrase(env, "New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.PassphraseRequired()
+	}
+	confirmation, err := readPassphrase(env, "Confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != passphrase {
+		return "", errors.New(errors.ErrorTypeUserInput, errors.CodeWrongPassphrase, "Passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func setEcho(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"comptes/internal/config"
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+func (c *CLI) handleRates(args []string) error {
+	if len(args) < 3 {
+		return c.listRates()
+	}
+
+	switch args[2] {
+	case "--help", "-?":
+		ShowHelp("rates")
+		return nil
+	case "list":
+		return c.listRates()
+	case "add":
+		// comptes rates add <FROM> <TO> <rate> [date]
+		if len(args) < 6 {
+			ShowHelp("rates")
+			return errors.MissingArguments("rates add")
+		}
+		date := time.Now()
+		if len(args) >= 7 {
+			parsedDate, err := parseDate(args[6])
+			if err != nil {
+				return fmt.Errorf("invalid date format: %w", err)
+			}
+			date = parsedDate
+		}
+		rate := domain.ExchangeRate{Date: date, From: args[3], To: args[4], Rate: args[5]}
+		if err := c.exchangeService.AddRates(rate); err != nil {
+			return fmt.Errorf("error adding exchange rate: %w", err)
+		}
+		fmt.Printf("Exchange rate added: %s 1 %s = %s %s\n", date.Format("2006-01-02"), args[3], args[5], args[4])
+		return nil
+	case "import":
+		// comptes rates import <file.csv>
+		if len(args) < 4 {
+			ShowHelp("rates")
+			return errors.MissingArguments("rates import")
+		}
+		file, err := os.Open(args[3])
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", args[3], err)
+		}
+		defer file.Close()
+
+		count, err := c.exchangeService.ImportCSV(file)
+		if err != nil {
+			return fmt.Errorf("error importing exchange rates: %w", err)
+		}
+		fmt.Printf("Imported %d exchange rates from %s\n", count, args[3])
+		return nil
+	default:
+		ShowHelp("rates")
+		return errors.InvalidCommand("rates " + args[2])
+	}
+}
+
+func (c *CLI) listRates() error {
+	rates, err := c.exchangeService.GetRates()
+	if err != nil {
+		return err
+	}
+	if len(rates) == 0 {
+		fmt.Println("No exchange rates (use 'comptes rates add' or 'comptes rates import').")
+		return nil
+	}
+
+	fmt.Println("Exchange rates:")
+	for _, rate := range rates {
+		fmt.Printf("- %s: 1 %s = %s %s\n", rate.Date.Format("2006-01-02"), rate.From, rate.Rate, rate.To)
+	}
+	return nil
+}
+
+// reportingCurrency returns the currency configured for reports, or the currency of
+// the first account when the configuration cannot be read
+func (c *CLI) reportingCurrency() (string, error) {
+	if cfg, err := config.LoadConfig(config.GetConfigPath()); err == nil && cfg.ReportingCurrency != "" {
+		return cfg.ReportingCurrency, nil
+	}
+
+	accounts, err := c.storage.GetAccounts()
+	if err != nil {
+		return "", err
+	}
+	for _, account := range accounts {
+		if account.Currency != "" {
+			return account.Currency, nil
+		}
+	}
+	return "EUR", nil
+}
@@ -0,0 +1,315 @@
+package cli
+
+import (
+	"comptes/internal/errors"
+	"comptes/internal/service"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// reportOptions holds the arguments shared by the report commands
+type reportOptions struct {
+	format   string
+	currency string
+	rateDate service.RateDate
+	period   service.Period
+	filter   service.TransactionFilter
+	rows     service.PivotDimension
+	columns  service.PivotDimension
+}
+
+func (c *CLI) handleReport(args []string) error {
+	if len(args) > 2 && args[2] == "pivot" {
+		return c.handleReportPivot(args[3:])
+	}
+
+	options, help, err := c.parseReportOptions(args[2:], func() (service.Period, error) {
+		return service.MonthPeriod(time.Now().Format("2006-01"))
+	})
+	if err != nil || help {
+		return err
+	}
+	report, err := c.reportService.CategoryReport(options.filter, options.period, options.currency, options.rateDate)
+	if err != nil {
+		return err
+	}
+	switch options.format {
+	case "csv":
+		printReportCSV(report)
+	case "json":
+		return printJSON(report)
+	default:
+		printReportText(report)
+	}
+	return nil
+}
+
+func (c *CLI) handleReportPivot(args []string) error {
+	options, help, err := c.parseReportOptions(args, func() (service.Period, error) {
+		return service.YearPeriod(time.Now().Format("2006"))
+	})
+	if err != nil || help {
+		return err
+	}
+	table, err := c.reportService.Pivot(options.filter, options.period, options.currency, options.rateDate, options.rows, options.columns)
+	if err != nil {
+		return err
+	}
+	switch options.format {
+	case "csv":
+		printPivotCSV(table)
+	case "json":
+		return printJSON(table)
+	default:
+		printPivotText(table)
+	}
+	return nil
+}
+
+// parseReportOptions reads the period, filters and output options of a report command;
+// defaultPeriod applies when none is given. help is set when the help was shown.
+func (c *CLI) parseReportOptions(args []string, defaultPeriod func() (service.Period, error)) (options reportOptions, help bool, err error) {
+	options = reportOptions{format: "text", rateDate: service.RateAtTransactionDate, rows: service.PivotCategory, columns: service.PivotMonth}
+	var period *service.Period
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--help", "-?":
+			ShowHelp("report")
+			return options, true, nil
+		case "--format", "-F", "--currency", "-C", "--rate-date", "--month", "--quarter", "--year", "--rows", "--columns":
+		default:
+			continue
+		}
+		if i+1 >= len(args) {
+			return options, false, errors.New(errors.ErrorTypeUserInput, errors.CodeMissingArguments, fmt.Sprintf("%s requires a value", args[i]))
+		}
+		flag, value := args[i], args[i+1]
+		i++
+
+		var p service.Period
+		switch flag {
+		case "--format", "-F":
+			options.format = value
+			continue
+		case "--currency", "-C":
+			options.currency = strings.ToUpper(value)
+			continue
+		case "--rate-date":
+			if options.rateDate, err = service.ParseRateDate(value); err != nil {
+				return options, false, err
+			}
+			continue
+		case "--rows":
+			options.rows, err = service.ParsePivotDimension(value)
+		case "--columns":
+			options.columns, err = service.ParsePivotDimension(value)
+		case "--month":
+			p, err = service.MonthPeriod(value)
+		case "--quarter":
+			p, err = service.QuarterPeriod(value)
+		case "--year":
+			p, err = service.YearPeriod(value)
+		}
+		if err != nil {
+			return options, false, err
+		}
+		if flag == "--rows" || flag == "--columns" {
+			continue
+		}
+		if period != nil {
+			return options, false, errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand, "Use only one of --month, --quarter, --year or --from/--to")
+		}
+		period = &p
+	}
+
+	if options.filter, err = parseListFilter(args); err != nil {
+		return options, false, err
+	}
+	if options.period, err = reportPeriod(period, &options.filter, defaultPeriod); err != nil {
+		return options, false, err
+	}
+	if options.currency == "" {
+		if options.currency, err = c.reportingCurrency(); err != nil {
+			return options, false, err
+		}
+	}
+	return options, false, nil
+}
+
+// rateLabel notes in a report title that amounts are converted at today's rates
+func rateLabel(rateDate service.RateDate) string {
+	if rateDate == service.RateAtToday {
+		return ", today's rates"
+	}
+	return ""
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// reportPeriod returns the period chosen by --month, --quarter or --year, or else by
+// --from/--to (which it takes out of filter), or else the default period
+func reportPeriod(period *service.Period, filter *service.TransactionFilter, defaultPeriod func() (service.Period, error)) (service.Period, error) {
+	ranged := !filter.From.IsZero() || !filter.To.IsZero()
+	if period != nil {
+		if ranged {
+			return service.Period{}, errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand, "Use only one of --month, --quarter, --year or --from/--to")
+		}
+		return *period, nil
+	}
+	if !ranged {
+		return defaultPeriod()
+	}
+
+	from, to := filter.From, filter.To
+	filter.From, filter.To = time.Time{}, time.Time{}
+	if from.IsZero() {
+		return service.Period{}, errors.New(errors.ErrorTypeUserInput, errors.CodeMissingArguments, "--to requires --from")
+	}
+	last := time.Now()
+	if !to.IsZero() {
+		last = to.AddDate(0, 0, -1) // --to was made exclusive by parseListFilter
+	}
+	return service.RangePeriod(from, last)
+}
+
+func printReportText(report *service.CategoryReport) {
+	fmt.Printf("Report %s (%s%s)\n", report.Period.Label, report.Currency, rateLabel(report.RateDate))
+	if len(report.Lines) == 0 {
+		fmt.Println("No movements in this period.")
+		return
+	}
+
+	width := len("Category")
+	labels := make([]string, len(report.Lines))
+	for i, line := range report.Lines {
+		labels[i] = strings.Repeat("  ", line.Depth) + line.Name
+		if line.Code != "" && line.Code != line.Name {
+			labels[i] += " (" + line.Code + ")"
+		}
+		width = max(width, len([]rune(labels[i])))
+	}
+
+	fmt.Printf("%-*s %14s %14s %14s\n", width, "Category", "Income", "Expenses", "Net")
+	for i, line := range report.Lines {
+		fmt.Printf("%s%s %14s %14s %14s\n", labels[i], strings.Repeat(" ", width-len([]rune(labels[i]))),
+			line.Income.Decimal(), line.Expenses.Decimal(), line.Net.Decimal())
+	}
+	fmt.Printf("%-*s %14s %14s %14s\n", width, "Total", report.Total.Income.Decimal(), report.Total.Expenses.Decimal(), report.Total.Net.Decimal())
+	if len(report.Excluded) > 0 {
+		fmt.Printf("Warning: %d movements excluded, no exchange rate to %s (add rates with 'comptes rates add')\n", len(report.Excluded), report.Currency)
+	}
+}
+
+func printReportCSV(report *service.CategoryReport) {
+	fmt.Println("category,name,parent,depth,income,expenses,net,currency")
+	for _, line := range report.Lines {
+		fmt.Printf("%s,\"%s\",%s,%d,%s,%s,%s,%s\n", line.Code, line.Name, line.Parent, line.Depth,
+			line.Income.Decimal(), line.Expenses.Decimal(), line.Net.Decimal(), report.Currency)
+	}
+}
+
+// pivotLabel returns the label of a pivot row, with its code when it differs
+func pivotLabel(key service.PivotKey) string {
+	label := strings.Repeat("  ", key.Depth) + key.Label
+	if key.Key != "" && key.Key != key.Label {
+		label += " (" + key.Key + ")"
+	}
+	return label
+}
+
+// pivotHeader returns the header of a pivot column: its key, or its label for the
+// uncategorised and untagged column
+func pivotHeader(key service.PivotKey) string {
+	if key.Key == "" {
+		return key.Label
+	}
+	return key.Key
+}
+
+func printPivotText(table *service.PivotTable) {
+	fmt.Printf("Pivot %s by %s, %s (%s%s)\n", table.Rows, table.Columns, table.Period.Label, table.Currency, rateLabel(table.RateDate))
+	if len(table.RowKeys) == 0 {
+		fmt.Println("No movements in this period.")
+		return
+	}
+
+	width := len(table.Rows)
+	labels := make([]string, len(table.RowKeys))
+	for i, key := range table.RowKeys {
+		labels[i] = pivotLabel(key)
+		width = max(width, len([]rune(labels[i])), len("Average"))
+	}
+	columnWidth := 10
+	for _, key := range table.ColumnKeys {
+		columnWidth = max(columnWidth, len([]rune(pivotHeader(key))))
+	}
+	for _, total := range append(table.RowTotals, table.Total) {
+		columnWidth = max(columnWidth, len(total.Decimal()))
+	}
+
+	printRow := func(label string, cells []string) {
+		fmt.Print(label + strings.Repeat(" ", width-len([]rune(label))))
+		for _, cell := range cells {
+			fmt.Printf(" %*s", columnWidth, cell)
+		}
+		fmt.Println()
+	}
+
+	headers := make([]string, 0, len(table.ColumnKeys)+2)
+	for _, key := range table.ColumnKeys {
+		headers = append(headers, pivotHeader(key))
+	}
+	printRow(string(table.Rows), append(headers, "Total", "Average"))
+	for i := range table.RowKeys {
+		cells := make([]string, 0, len(table.ColumnKeys)+2)
+		for _, cell := range table.Cells[i] {
+			cells = append(cells, cell.Decimal())
+		}
+		printRow(labels[i], append(cells, table.RowTotals[i].Decimal(), table.RowAverages[i].Decimal()))
+	}
+
+	totals := make([]string, 0, len(table.ColumnKeys)+1)
+	averages := make([]string, 0, len(table.ColumnKeys))
+	for j := range table.ColumnKeys {
+		totals = append(totals, table.ColumnTotals[j].Decimal())
+		averages = append(averages, table.ColumnAverages[j].Decimal())
+	}
+	printRow("Total", append(totals, table.Total.Decimal()))
+	printRow("Average", averages)
+	if len(table.Excluded) > 0 {
+		fmt.Printf("Warning: %d movements excluded, no exchange rate to %s (add rates with 'comptes rates add')\n", len(table.Excluded), table.Currency)
+	}
+}
+
+func printPivotCSV(table *service.PivotTable) {
+	headers := []string{string(table.Rows), "label"}
+	for _, key := range table.ColumnKeys {
+		headers = append(headers, pivotHeader(key))
+	}
+	fmt.Println(strings.Join(append(headers, "total", "average"), ","))
+
+	for i, key := range table.RowKeys {
+		cells := []string{key.Key, "\"" + key.Label + "\""}
+		for _, cell := range table.Cells[i] {
+			cells = append(cells, cell.Decimal())
+		}
+		fmt.Println(strings.Join(append(cells, table.RowTotals[i].Decimal(), table.RowAverages[i].Decimal()), ","))
+	}
+
+	totals := []string{"", "Total"}
+	averages := []string{"", "Average"}
+	for j := range table.ColumnKeys {
+		totals = append(totals, table.ColumnTotals[j].Decimal())
+		averages = append(averages, table.ColumnAverages[j].Decimal())
+	}
+	fmt.Println(strings.Join(append(totals, table.Total.Decimal(), ""), ","))
+	fmt.Println(strings.Join(append(averages, "", ""), ","))
+}
@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handleSchedule runs "comptes schedule [list|add|remove|due|run] ..."
+func (c *CLI) handleSchedule(args []string) error {
+	action := "list"
+	rest := args[2:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		action, rest = rest[0], rest[1:]
+	}
+	for _, arg := range rest {
+		if arg == "--help" || arg == "-?" {
+			ShowHelp("schedule")
+			return nil
+		}
+	}
+
+	switch action {
+	case "list":
+		return c.listRecurringRules()
+	case "add":
+		return c.handleScheduleAdd(rest)
+	case "remove":
+		if len(rest) != 1 {
+			ShowHelp("schedule")
+			return errors.MissingArguments("schedule remove")
+		}
+		rule, err := c.scheduleService.RemoveRule(rest[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Recurring rule %s removed (its posted movements are kept).\n", rule.ID)
+		return nil
+	case "due", "run":
+		return c.handleScheduleRun(action, rest)
+	default:
+		ShowHelp("schedule")
+		return errors.InvalidCommand("schedule " + action)
+	}
+}
+
+func (c *CLI) listRecurringRules() error {
+	rules, err := c.scheduleService.Rules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Println("No recurring rules. Add one with 'comptes schedule add'.")
+		return nil
+	}
+	today := time.Now()
+	for _, rule := range rules {
+		next := "ended"
+		if date, ok := rule.Next(today.AddDate(0, 0, -1)); ok {
+			next = "next " + date.Format("2006-01-02")
+		}
+		fmt.Printf("%-10s %-8s %14s  %-28s %s (%s)\n", rule.ID, rule.Account, rule.Amount, describeRecurrence(rule), rule.Description, next)
+	}
+	return nil
+}
+
+// describeRecurrence returns how often a rule repeats and until when, e.g. "monthly on day 5, 12 times"
+func describeRecurrence(rule domain.RecurringRule) string {
+	text := string(rule.Frequency)
+	if rule.Interval > 1 {
+		units := map[domain.Frequency]string{domain.Daily: "days", domain.Weekly: "weeks", domain.Monthly: "months", domain.Yearly: "years"}
+		text = fmt.Sprintf("every %d %s", rule.Interval, units[rule.Frequency])
+	}
+	if rule.Day > 0 {
+		text += fmt.Sprintf(" on day %d", rule.Day)
+	}
+	if rule.Count > 0 {
+		text += fmt.Sprintf(", %d times", rule.Count)
+	}
+	if rule.End != nil {
+		text += ", until " + rule.End.Format("2006-01-02")
+	}
+	return text
+}
+
+func (c *CLI) handleScheduleAdd(args []string) error {
+	rule := domain.RecurringRule{Frequency: domain.Monthly}
+	var ids []string
+	var amount string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--daily":
+			rule.Frequency = domain.Daily
+			continue
+		case "--weekly":
+			rule.Frequency = domain.Weekly
+			continue
+		case "--monthly":
+			rule.Frequency = domain.Monthly
+			continue
+		case "--yearly":
+			rule.Frequency = domain.Yearly
+			continue
+		case "--account", "-a", "--amount", "-m", "--description", "-d", "--categories", "-c", "--tags", "-t",
+			"--every", "--day", "--start", "--end", "--count":
+		default:
+			ids = append(ids, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return errors.MissingArguments("schedule add " + args[i])
+		}
+		flag, value := args[i], args[i+1]
+		i++
+
+		switch flag {
+		case "--account", "-a":
+			rule.Account = strings.ToUpper(value)
+		case "--amount", "-m":
+			amount = value
+		case "--description", "-d":
+			rule.Description = value
+		case "--categories", "-c":
+			rule.Categories = upperList(parseList(value))
+		case "--tags", "-t":
+			rule.Tags = upperList(parseList(value))
+		case "--every", "--day", "--count":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand, fmt.Sprintf("%s expects a positive number, got %q", flag, value))
+			}
+			switch flag {
+			case "--every":
+				rule.Interval = n
+			case "--day":
+				rule.Day = n
+			default:
+				rule.Count = n
+			}
+		case "--start", "--end":
+			date, err := parseDate(value)
+			if err != nil {
+				return errors.InvalidDate(value, err)
+			}
+			date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+			if flag == "--start" {
+				rule.Start = date
+			} else {
+				rule.End = &date
+			}
+		}
+	}
+	if len(ids) != 1 || rule.Account == "" || amount == "" {
+		ShowHelp("schedule")
+		return errors.MissingArguments("schedule add <id> --account <id> --amount <amount>")
+	}
+	rule.ID = ids[0]
+
+	parsed, err := parseAmount(amount)
+	if err != nil {
+		return errors.InvalidAmount(amount, err)
+	}
+	rule.Amount = parsed
+	if rule.Start.IsZero() {
+		now := time.Now()
+		rule.Start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	if rule.Tags == nil {
+		rule.Tags = c.defaultRecurringTags()
+	}
+
+	if err := c.scheduleService.AddRule(rule); err != nil {
+		return err
+	}
+	fmt.Printf("Recurring rule %s added: %s %s\n", strings.ToUpper(rule.ID), rule.Amount, describeRecurrence(rule))
+	return nil
+}
+
+// defaultRecurringTags returns the REC (recurring) tag when it exists
+func (c *CLI) defaultRecurringTags() []string {
+	tags, err := c.storage.GetTags()
+	if err != nil {
+		return []string{}
+	}
+	for _, tag := range tags {
+		if tag.Code == "REC" {
+			return []string{"REC"}
+		}
+	}
+	return []string{}
+}
+
+func upperList(values []string) []string {
+	for i := range values {
+		values[i] = strings.ToUpper(values[i])
+	}
+	return values
+}
+
+func (c *CLI) handleScheduleRun(action string, args []string) error {
+	through := time.Now()
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--until" {
+			ShowHelp("schedule")
+			return errors.InvalidCommand("schedule " + action + " " + args[i])
+		}
+		if i+1 >= len(args) {
+			return errors.MissingArguments("schedule " + action + " --until")
+		}
+		date, err := parseDate(args[i+1])
+		if err != nil {
+			return errors.InvalidDate(args[i+1], err)
+		}
+		through = date
+		i++
+	}
+
+	if action == "due" {
+		due, err := c.scheduleService.Due(through)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			fmt.Printf("Nothing due up to %s.\n", through.Format("2006-01-02"))
+			return nil
+		}
+		printOccurrences(due)
+		return nil
+	}
+
+	batch, err := c.scheduleService.Run(through, "Recurring movements up to "+through.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	if batch == nil {
+		fmt.Printf("Nothing due up to %s.\n", through.Format("2006-01-02"))
+		return nil
+	}
+	printOccurrences(batch.Transactions)
+	fmt.Printf("%d movement(s) added to pending batch %s.\n", len(batch.Transactions), batch.ID[:8])
+	fmt.Printf("Review them, then 'comptes commit %s' or 'comptes rollback %s'.\n", batch.ID[:8], batch.ID[:8])
+	return nil
+}
+
+func printOccurrences(transactions []domain.Transaction) {
+	for _, txn := range transactions {
+		fmt.Printf("%s  %-8s %14s  %-20s %s\n", txn.Date.Format("2006-01-02"), txn.Account, txn.Amount,
+			strings.SplitN(txn.Occurrence, "@", 2)[0], txn.Description)
+	}
+}
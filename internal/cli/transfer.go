@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/service"
+	"fmt"
+	"strings"
+)
+
+func (c *CLI) handleTransfer(args []string) error {
+	if len(args) < 3 {
+		ShowHelp("transfer")
+		return fmt.Errorf("missing arguments")
+	}
+
+	var request service.TransferRequest
+	var providedBatchID, date string
+	var hasAmount bool
+	forceDirect := false
+
+	// Parse flags
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--help" || arg == "-?":
+			ShowHelp("transfer")
+			return nil
+		case arg == "--from":
+			if i+1 < len(args) {
+				request.From = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--from requires a value")
+			}
+		case arg == "--to":
+			if i+1 < len(args) {
+				request.To = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--to requires a value")
+			}
+		case arg == "-m" || arg == "--amount":
+			if i+1 < len(args) {
+				amount, err := parseAmount(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid amount: %w", err)
+				}
+				request.Amount = amount
+				hasAmount = true
+				i++
+			} else {
+				return fmt.Errorf("--amount requires a value")
+			}
+		case arg == "--received":
+			if i+1 < len(args) {
+				amount, err := parseAmount(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid received amount: %w", err)
+				}
+				request.Received = amount
+				i++
+			} else {
+				return fmt.Errorf("--received requires a value")
+			}
+		case arg == "--desc" || arg == "--description" || arg == "-d":
+			if i+1 < len(args) {
+				request.Description = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--description requires a value")
+			}
+		case arg == "-c" || arg == "--categories":
+			if i+1 < len(args) {
+				request.Categories = parseList(args[i+1])
+				i++
+			} else {
+				return fmt.Errorf("--categories requires a value")
+			}
+		case arg == "-t" || arg == "--tags":
+			if i+1 < len(args) {
+				request.Tags = parseList(args[i+1])
+				i++
+			} else {
+				return fmt.Errorf("--tags requires a value")
+			}
+		case arg == "--date" || arg == "--on" || arg == "-o":
+			if i+1 < len(args) {
+				date = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--date requires a value")
+			}
+		case arg == "--immediate" || arg == "-i":
+			forceDirect = true
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("unknown flag: %s", arg)
+		default:
+			// Treat as batch-id if not a flag
+			if providedBatchID == "" {
+				providedBatchID = arg
+			}
+		}
+	}
+
+	if request.From == "" {
+		return fmt.Errorf("source account is required (use --from)")
+	}
+	if request.To == "" {
+		return fmt.Errorf("destination account is required (use --to)")
+	}
+	if !hasAmount {
+		return fmt.Errorf("amount is required (use -m/--amount)")
+	}
+
+	if date != "" {
+		parsedDate, err := parseDate(date)
+		if err != nil {
+			return fmt.Errorf("invalid date format: %w", err)
+		}
+		request.Date = parsedDate
+	}
+
+	// Add to the current batch unless --immediate is set
+	if !forceDirect {
+		batchID, err := c.resolveBatchID(providedBatchID)
+		if err == nil && batchID != "" {
+			legs, err := c.transactionService.NewTransfer(request)
+			if err != nil {
+				return fmt.Errorf("error preparing transfer: %w", err)
+			}
+			if err := c.batchService.AddTransactionsToBatch(batchID, legs...); err != nil {
+				return fmt.Errorf("error adding transfer to batch: %w", err)
+			}
+			fmt.Printf("Transfer added to batch %s successfully!\n", batchID)
+			return nil
+		}
+	}
+
+	legs, err := c.transactionService.AddTransfer(request)
+	if err != nil {
+		return fmt.Errorf("error adding transfer: %w", err)
+	}
+	printTransferLegs(legs)
+	fmt.Println("Transfer added successfully!")
+	return nil
+}
+
+// printTransferLegs shows both movements created by a transfer
+func printTransferLegs(legs []domain.Transaction) {
+	for _, leg := range legs {
+		fmt.Printf("- [%s] %s: %s\n", leg.ID, leg.Account, leg.Amount)
+	}
+	if len(legs) > 0 && legs[0].ExchangeRate != "" {
+		fmt.Printf("Exchange rate: 1 %s = %s %s\n", legs[0].Amount.Currency, legs[0].ExchangeRate, legs[len(legs)-1].Amount.Currency)
+	}
+}
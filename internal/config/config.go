@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -12,21 +13,118 @@ import (
 
 // Config represents the configuration structure
 type Config struct {
-	Accounts   []domain.Account  `yaml:"accounts"`
-	Categories []domain.Category `yaml:"categories"`
-	Tags       []domain.Tag      `yaml:"tags"`
+	// ReportingCurrency is the currency balances and reports are converted to
+	ReportingCurrency string           `yaml:"reporting_currency,omitempty"`
+	Storage           StorageConfig    `yaml:"storage,omitempty"`
+	Accounts          []domain.Account `yaml:"accounts"`
+	Categories        CategoryList     `yaml:"categories"`
+	Tags              TagList          `yaml:"tags"`
+	Budgets           []domain.Budget  `yaml:"budgets,omitempty"`
+}
+
+// CategoryList is the list of categories of the configuration. In YAML, children may be
+// given as codes (children: [RES]) or as nested categories (children: [{code: RES, ...}]).
+type CategoryList []domain.Category
+
+// TagList is the list of tags of the configuration, nested like CategoryList
+type TagList []domain.Tag
+
+// UnmarshalYAML flattens nested categories, each child taking its enclosing category as parent
+func (l *CategoryList) UnmarshalYAML(node *yaml.Node) error {
+	categories, err := decodeCodeTree(node, nil)
+	if err != nil {
+		return err
+	}
+	*l = categories
+	return nil
+}
+
+// UnmarshalYAML flattens nested tags like CategoryList
+func (l *TagList) UnmarshalYAML(node *yaml.Node) error {
+	categories, err := decodeCodeTree(node, nil)
+	if err != nil {
+		return err
+	}
+	tags := make([]domain.Tag, len(categories))
+	for i, category := range categories {
+		tags[i] = domain.Tag(category)
+	}
+	*l = tags
+	return nil
+}
+
+// codeNode is a category or tag as written in YAML
+type codeNode struct {
+	Code        string      `yaml:"code"`
+	Name        string      `yaml:"name"`
+	Parent      *string     `yaml:"parent"`
+	Description string      `yaml:"description"`
+	Children    []yaml.Node `yaml:"children"`
+}
+
+func decodeCodeTree(node *yaml.Node, parent *string) ([]domain.Category, error) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: expected a list of codes", node.Line)
+	}
+
+	var categories []domain.Category
+	for _, item := range node.Content {
+		var entry codeNode
+		if err := item.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if entry.Code == "" {
+			return nil, fmt.Errorf("line %d: code is required", item.Line)
+		}
+		category := domain.Category{Code: entry.Code, Name: entry.Name, Parent: entry.Parent, Description: entry.Description}
+		if parent != nil {
+			if entry.Parent != nil && *entry.Parent != *parent {
+				return nil, fmt.Errorf("line %d: %s is nested under %s but its parent is %s", item.Line, entry.Code, *parent, *entry.Parent)
+			}
+			category.Parent = parent
+		}
+
+		// Codes name existing children; nested entries are children defined in place
+		var nested []domain.Category
+		for i := range entry.Children {
+			child := &entry.Children[i]
+			if child.Kind == yaml.ScalarNode {
+				category.Children = append(category.Children, child.Value)
+				continue
+			}
+			code := entry.Code
+			sub, err := decodeCodeTree(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{child}}, &code)
+			if err != nil {
+				return nil, err
+			}
+			nested = append(nested, sub...)
+		}
+		categories = append(categories, category)
+		categories = append(categories, nested...)
+	}
+	return categories, nil
+}
+
+// StorageConfig selects how the data directory is stored
+type StorageConfig struct {
+	// Backend is "json" (one JSON file per collection, default) or "journal" (append-only event journal)
+	Backend string `yaml:"backend,omitempty"`
 }
 
 // CreateDefaultConfig creates a default configuration
 func CreateDefaultConfig() *Config {
 	return &Config{
+		ReportingCurrency: "EUR",
 		Accounts: []domain.Account{
 			{
 				ID:             "BANQUE",
 				Name:           "Compte Courant Principal",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1500.00,
+				InitialBalance: domain.NewMoney(150000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      time.Now(),
 			},
@@ -35,7 +133,7 @@ func CreateDefaultConfig() *Config {
 				Name:           "Compte Épargne",
 				Type:           "savings",
 				Currency:       "EUR",
-				InitialBalance: 5000.00,
+				InitialBalance: domain.NewMoney(500000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      time.Now(),
 			},
@@ -72,12 +170,29 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return parseConfig(data)
+}
 
+// parseConfig decodes and validates the content of a configuration file
+func parseConfig(data []byte) (*Config, error) {
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Accounts are open unless is_active says otherwise
+	var activeFlags struct {
+		Accounts []struct {
+			IsActive *bool `yaml:"is_active"`
+		} `yaml:"accounts"`
+	}
+	if err := yaml.Unmarshal(data, &activeFlags); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	config.ReportingCurrency = strings.ToUpper(strings.TrimSpace(config.ReportingCurrency))
+	config.Storage.Backend = strings.ToLower(strings.TrimSpace(config.Storage.Backend))
+
 	// Set default values for accounts
 	for i := range config.Accounts {
 		if config.Accounts[i].ID == "" {
@@ -86,11 +201,64 @@ func LoadConfig(configPath string) (*Config, error) {
 		if config.Accounts[i].Currency == "" {
 			config.Accounts[i].Currency = "EUR"
 		}
+		// Bare numbers take the account currency; explicit currencies must match it
+		balance := config.Accounts[i].InitialBalance
+		if balance.Currency != "" && balance.Currency != config.Accounts[i].Currency {
+			return nil, fmt.Errorf("initial balance of account %s is in %s but the account uses %s",
+				config.Accounts[i].ID, balance.Currency, config.Accounts[i].Currency)
+		}
+		config.Accounts[i].InitialBalance = balance.WithCurrency(config.Accounts[i].Currency)
 		if config.Accounts[i].CreatedAt.IsZero() {
 			config.Accounts[i].CreatedAt = time.Now()
 		}
-		config.Accounts[i].IsActive = true
-		// Note: initial_balance is preserved from YAML
+		if activeFlags.Accounts[i].IsActive == nil {
+			config.Accounts[i].IsActive = true
+		}
+	}
+
+	// Parent and Children must describe the same hierarchy, without cycles
+	categories, err := domain.LinkCategories(config.Categories)
+	if err != nil {
+		return nil, fmt.Errorf("invalid categories: %w", err)
+	}
+	config.Categories = categories
+	tags := make([]domain.Category, len(config.Tags))
+	for i, tag := range config.Tags {
+		tags[i] = domain.Category(tag)
+	}
+	if tags, err = domain.LinkCategories(tags); err != nil {
+		return nil, fmt.Errorf("invalid tags: %w", err)
+	}
+	for i, tag := range tags {
+		config.Tags[i] = domain.Tag(tag)
+	}
+
+	// Without an explicit reporting currency, report in the currency of the first account
+	if config.ReportingCurrency == "" && len(config.Accounts) > 0 {
+		config.ReportingCurrency = config.Accounts[0].Currency
+	}
+
+	// Budgets are monthly by default; bare amounts are in the reporting currency
+	known := make(map[string]bool, len(config.Categories))
+	for _, category := range config.Categories {
+		known[category.Code] = true
+	}
+	for i := range config.Budgets {
+		budget := &config.Budgets[i]
+		budget.Category = strings.ToUpper(strings.TrimSpace(budget.Category))
+		if !known[budget.Category] {
+			return nil, fmt.Errorf("budget for unknown category %s", budget.Category)
+		}
+		if budget.Period == "" {
+			budget.Period = domain.BudgetMonthly
+		}
+		budget.Period = domain.BudgetPeriod(strings.ToLower(string(budget.Period)))
+		if budget.Amount.Currency == "" {
+			budget.Amount = budget.Amount.WithCurrency(config.ReportingCurrency)
+		}
+	}
+	if err := domain.ValidateBudgets(config.Budgets); err != nil {
+		return nil, fmt.Errorf("invalid budgets: %w", err)
 	}
 
 	return &config, nil
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"comptes/internal/domain"
 	"os"
 	"path/filepath"
 	"testing"
@@ -59,8 +60,8 @@ tags:
 		t.Errorf("Expected account name 'Test Account', got '%s'", account.Name)
 	}
 
-	if account.InitialBalance != 1500.0 {
-		t.Errorf("Expected initial balance 1500.0, got %.2f", account.InitialBalance)
+	if account.InitialBalance != domain.NewMoney(150000, "EUR") {
+		t.Errorf("Expected initial balance 1500.00 EUR, got %s", account.InitialBalance)
 	}
 
 	if account.Currency != "EUR" {
@@ -120,6 +121,147 @@ tags: []
 	if account.CreatedAt.IsZero() {
 		t.Error("Expected CreatedAt to be set")
 	}
+
+	if config.ReportingCurrency != "EUR" {
+		t.Errorf("Expected reporting currency to default to the first account currency, got '%s'", config.ReportingCurrency)
+	}
+}
+
+func TestLoadConfig_KeepsClosedAccounts(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+accounts:
+  - id: "OPEN"
+  - id: "CLOSED"
+    is_active: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !config.Accounts[0].IsActive {
+		t.Error("Expected an account without is_active to be active")
+	}
+	if config.Accounts[1].IsActive {
+		t.Error("Expected is_active: false to be kept")
+	}
+}
+
+func TestLoadConfig_NestedCategories(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+accounts: []
+categories:
+  - code: ALM
+    name: Alimentation
+    children:
+      - code: RES
+        name: Restaurants
+        children: [FST]
+      - code: SUP
+        name: Supermarché
+  - code: FST
+    name: Fast-food
+tags: []
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parents := map[string]string{}
+	for _, category := range config.Categories {
+		if category.Parent != nil {
+			parents[category.Code] = *category.Parent
+		}
+	}
+	if len(config.Categories) != 4 || parents["RES"] != "ALM" || parents["SUP"] != "ALM" || parents["FST"] != "RES" {
+		t.Errorf("Unexpected hierarchy %+v", config.Categories)
+	}
+	if children := config.Categories[0].Children; len(children) != 2 {
+		t.Errorf("Expected ALM children [RES SUP], got %v", children)
+	}
+
+	cycle := "categories:\n  - code: A\n    parent: B\n  - code: B\n    parent: A\n"
+	if err := os.WriteFile(configPath, []byte(cycle), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("Expected a cycle to be refused")
+	}
+}
+
+func TestLoadConfig_Budgets(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+reporting_currency: EUR
+accounts: []
+categories:
+  - code: ALM
+    name: Alimentation
+  - code: VAC
+    name: Vacances
+budgets:
+  - category: alm
+    amount: 400
+    valid_from: 2024-01-01
+    valid_to: 2024-06-30
+  - category: ALM
+    amount: "450 EUR"
+    valid_from: 2024-07-01
+  - category: VAC
+    amount: "2000 USD"
+    period: once
+    valid_from: 2024-07-01
+    valid_to: 2024-08-31
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(config.Budgets) != 3 {
+		t.Fatalf("Expected 3 budgets, got %+v", config.Budgets)
+	}
+	first := config.Budgets[0]
+	if first.Category != "ALM" || first.Period != domain.BudgetMonthly || first.Amount.String() != "400.00 EUR" ||
+		first.ValidTo == nil || first.ValidTo.Format("2006-01-02") != "2024-06-30" {
+		t.Errorf("Expected a monthly ALM budget of 400.00 EUR until 2024-06-30, got %+v", first)
+	}
+	if vacation := config.Budgets[2]; vacation.Period != domain.BudgetOnce || vacation.Amount.Currency != "USD" {
+		t.Errorf("Expected a one-off budget in USD, got %+v", vacation)
+	}
+
+	invalid := map[string]string{
+		"overlap":      "categories: [{code: ALM}]\nbudgets:\n  - {category: ALM, amount: 1, valid_from: 2024-01-01}\n  - {category: ALM, amount: 2, valid_from: 2024-03-01}\n",
+		"unknown":      "categories: [{code: ALM}]\nbudgets:\n  - {category: XXX, amount: 1, valid_from: 2024-01-01}\n",
+		"negative":     "categories: [{code: ALM}]\nbudgets:\n  - {category: ALM, amount: -1, valid_from: 2024-01-01}\n",
+		"open one-off": "categories: [{code: ALM}]\nbudgets:\n  - {category: ALM, amount: 1, period: once, valid_from: 2024-01-01}\n",
+	}
+	for name, content := range invalid {
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(configPath); err == nil {
+			t.Errorf("%s: expected the budgets to be refused", name)
+		}
+	}
 }
 
 func TestLoadConfig_InvalidFile(t *testing.T) {
@@ -0,0 +1,330 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetAccountName sets the name of an account in the configuration file
+func SetAccountName(configPath, accountID, name string) error {
+	return editConfig(configPath, func(root *yaml.Node) bool {
+		account := findEntry(mappingValue(root, "accounts"), "id", accountID)
+		return account != nil && setScalar(account, "name", "!!str", name)
+	})
+}
+
+// SetAccountActive opens or closes an account in the configuration file
+func SetAccountActive(configPath, accountID string, active bool) error {
+	return editConfig(configPath, func(root *yaml.Node) bool {
+		account := findEntry(mappingValue(root, "accounts"), "id", accountID)
+		if account == nil {
+			return false
+		}
+		// Without the key, the account is open
+		if active && mappingValue(account, "is_active") == nil {
+			return false
+		}
+		return setScalar(account, "is_active", "!!bool", fmt.Sprint(active))
+	})
+}
+
+// RenameCode renames a category or tag in the configuration file (list is "categories" or
+// "tags"): its code, the parents and children naming it and, for categories, the budgets
+func RenameCode(configPath, list, code, newCode string) error {
+	return editConfig(configPath, func(root *yaml.Node) bool {
+		changed := renameCodes(mappingValue(root, list), code, newCode)
+		if list == "categories" && renameBudgets(root, code, newCode) {
+			changed = true
+		}
+		return changed
+	})
+}
+
+// MergeCode removes a category or tag from the configuration file the way
+// CodeService.Merge removes it from the data directory: its children move under into,
+// into takes its place when it was below it, and its budgets go to into
+func MergeCode(configPath, list, code, into string) error {
+	return editConfig(configPath, func(root *yaml.Node) bool {
+		changed := false
+		if list == "categories" {
+			changed = renameBudgets(root, code, into)
+		}
+		top := mappingValue(root, list)
+		entries, parents, codes := codeLocations(top)
+		removed, exists := entries[code]
+		if !exists {
+			return changed
+		}
+
+		below := false
+		for parent, seen := parents[into], map[string]bool{}; parent != "" && !seen[parent]; parent = parents[parent] {
+			seen[parent] = true
+			if parent == code {
+				below = true
+				break
+			}
+		}
+		removeNode(removed.list, removed.node)
+		removeChildCode(top, code)
+		for _, child := range codes {
+			if parents[child] == code && child != into {
+				setParent(top, entries[child], into)
+			}
+		}
+		if target, exists := entries[into]; exists && below {
+			removeChildCode(top, into)
+			setParent(top, target, parents[code])
+		}
+		return true
+	})
+}
+
+// RemoveCode removes a category or tag from the configuration file
+func RemoveCode(configPath, list, code string) error {
+	return editConfig(configPath, func(root *yaml.Node) bool {
+		top := mappingValue(root, list)
+		entries, parents, codes := codeLocations(top)
+		removed, exists := entries[code]
+		if !exists {
+			return false
+		}
+		removeNode(removed.list, removed.node)
+		removeChildCode(top, code)
+		// Children only in the file keep code as parent, which makes the edited file invalid
+		for _, child := range codes {
+			if parents[child] == code {
+				setParent(top, entries[child], code)
+			}
+		}
+		return true
+	})
+}
+
+// editConfig rewrites the configuration file through edit, which reports whether it changed
+// anything. Comments are kept. Without a configuration file, there is nothing to edit.
+func editConfig(configPath string, edit func(root *yaml.Node) bool) error {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	if !edit(document.Content[0]) {
+		return nil
+	}
+
+	data, err = yaml.Marshal(&document)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if _, err := parseConfig(data); err != nil {
+		return fmt.Errorf("edited config file is invalid: %w", err)
+	}
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// findEntry returns the mapping of a YAML list whose key has the given value, or nil
+func findEntry(list *yaml.Node, key, value string) *yaml.Node {
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, entry := range list.Content {
+		if v := mappingValue(entry, key); v != nil && v.Value == value {
+			return entry
+		}
+	}
+	return nil
+}
+
+// setScalar sets key of a YAML mapping, adding it when missing, and reports whether it changed
+func setScalar(node *yaml.Node, key, tag, value string) bool {
+	if existing := mappingValue(node, key); existing != nil {
+		if existing.Kind == yaml.ScalarNode && existing.Value == value {
+			return false
+		}
+		*existing = yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, LineComment: existing.LineComment}
+		return true
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value})
+	return true
+}
+
+// codeLocation is where a category or tag is written in the configuration file
+type codeLocation struct {
+	node   *yaml.Node // Mapping of the entry
+	list   *yaml.Node // Sequence holding it: the top-level list or the children of its parent
+	nested string     // Code of the entry it is nested in, if any
+}
+
+// codeLocations finds every entry of a list of codes, nested ones included, and the parent
+// of each: given by parent, by nesting or by a list of children. codes lists the entries in
+// the order of the file.
+func codeLocations(list *yaml.Node) (entries map[string]codeLocation, parents map[string]string, codes []string) {
+	entries = make(map[string]codeLocation)
+	parents = make(map[string]string)
+	listed := make(map[string]string)
+	var walk func(list *yaml.Node, nested string)
+	walk = func(list *yaml.Node, nested string) {
+		if list == nil || list.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, item := range list.Content {
+			code := mappingValue(item, "code")
+			if code == nil {
+				continue
+			}
+			entries[code.Value] = codeLocation{node: item, list: list, nested: nested}
+			codes = append(codes, code.Value)
+			if parent := mappingValue(item, "parent"); parent != nil && parent.Tag != "!!null" && parent.Value != "" {
+				parents[code.Value] = parent.Value
+			} else if nested != "" {
+				parents[code.Value] = nested
+			}
+			children := mappingValue(item, "children")
+			if children == nil || children.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, child := range children.Content {
+				if child.Kind == yaml.ScalarNode {
+					listed[child.Value] = code.Value
+				}
+			}
+			walk(children, code.Value)
+		}
+	}
+	walk(list, "")
+	for child, parent := range listed {
+		if _, exists := parents[child]; !exists {
+			parents[child] = parent
+		}
+	}
+	return entries, parents, codes
+}
+
+// setParent moves an entry under parent ("" for the top level), out of the entry it was
+// nested in if that is not its parent any more
+func setParent(top *yaml.Node, entry codeLocation, parent string) {
+	if entry.node == nil {
+		return
+	}
+	if entry.nested != "" && entry.nested != parent {
+		removeNode(entry.list, entry.node)
+		top.Content = append(top.Content, entry.node)
+	}
+	if parent == "" {
+		removeKey(entry.node, "parent")
+		return
+	}
+	setScalar(entry.node, "parent", "!!str", parent)
+}
+
+// renameCodes renames code in the codes, parents and children of a list, nested entries included
+func renameCodes(list *yaml.Node, code, newCode string) bool {
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return false
+	}
+	changed := false
+	for _, item := range list.Content {
+		if item.Kind == yaml.ScalarNode {
+			if item.Value == code {
+				item.Value = newCode
+				changed = true
+			}
+			continue
+		}
+		for _, key := range []string{"code", "parent"} {
+			if value := mappingValue(item, key); value != nil && value.Kind == yaml.ScalarNode && value.Value == code {
+				value.Value = newCode
+				changed = true
+			}
+		}
+		if renameCodes(mappingValue(item, "children"), code, newCode) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// renameBudgets moves the budgets of a category to another one
+func renameBudgets(root *yaml.Node, code, newCode string) bool {
+	budgets := mappingValue(root, "budgets")
+	if budgets == nil || budgets.Kind != yaml.SequenceNode {
+		return false
+	}
+	changed := false
+	for _, budget := range budgets.Content {
+		if category := mappingValue(budget, "category"); category != nil && strings.ToUpper(strings.TrimSpace(category.Value)) == code {
+			category.Value = newCode
+			changed = true
+		}
+	}
+	return changed
+}
+
+// removeChildCode removes code from the lists of children naming it
+func removeChildCode(list *yaml.Node, code string) {
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range list.Content {
+		children := mappingValue(item, "children")
+		if children == nil || children.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i := 0; i < len(children.Content); i++ {
+			if child := children.Content[i]; child.Kind == yaml.ScalarNode && child.Value == code {
+				children.Content = append(children.Content[:i], children.Content[i+1:]...)
+				i--
+			}
+		}
+		removeChildCode(children, code)
+	}
+}
+
+func removeNode(list, node *yaml.Node) {
+	for i, item := range list.Content {
+		if item == node {
+			list.Content = append(list.Content[:i], list.Content[i+1:]...)
+			return
+		}
+	}
+}
+
+func removeKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
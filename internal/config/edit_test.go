@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+	return configPath
+}
+
+func TestEditConfig_Accounts(t *testing.T) {
+	configPath := writeConfig(t, `
+# Mes comptes
+accounts:
+  - id: BANQUE
+    name: Banque # affiché dans les rapports
+    currency: EUR
+  - id: LIVRET
+    name: Livret
+    currency: EUR
+    is_active: false
+`)
+
+	if err := SetAccountName(configPath, "BANQUE", "Compte joint"); err != nil {
+		t.Fatalf("Failed to rename account: %v", err)
+	}
+	if err := SetAccountActive(configPath, "BANQUE", false); err != nil {
+		t.Fatalf("Failed to close account: %v", err)
+	}
+	if err := SetAccountActive(configPath, "LIVRET", true); err != nil {
+		t.Fatalf("Failed to reopen account: %v", err)
+	}
+	if err := SetAccountName(configPath, "PEA", "Plan d'épargne"); err != nil {
+		t.Errorf("Expected an account missing from the file to be left alone, got %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load edited config: %v", err)
+	}
+	if len(config.Accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %+v", config.Accounts)
+	}
+	if banque := config.Accounts[0]; banque.Name != "Compte joint" || banque.IsActive {
+		t.Errorf("Expected BANQUE renamed and closed, got %+v", banque)
+	}
+	if !config.Accounts[1].IsActive {
+		t.Errorf("Expected LIVRET reopened, got %+v", config.Accounts[1])
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range []string{"# Mes comptes", "# affiché dans les rapports"} {
+		if !strings.Contains(string(data), comment) {
+			t.Errorf("Expected comment %q kept, got:\n%s", comment, data)
+		}
+	}
+}
+
+func TestEditConfig_MissingFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := SetAccountActive(configPath, "BANQUE", false); err != nil {
+		t.Errorf("Expected no error without a config file, got %v", err)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no config file to be created, got %v", err)
+	}
+}
+
+const codesConfig = `
+categories:
+  # Dépenses courantes
+  - code: ALM
+    name: Alimentation
+    children:
+      - code: RES
+        name: Restaurants
+        children:
+          - code: FST
+            name: Fast-food
+      - code: MAR
+        name: Marché
+  - code: LOI
+    name: Loisirs
+    children: [SRT]
+  - code: SRT
+    name: Sorties
+tags:
+  - code: VAC
+    name: Vacances
+budgets:
+  - category: RES
+    amount: 100
+    valid_from: 2024-01-01
+`
+
+func categoryParents(t *testing.T, configPath string) map[string]string {
+	t.Helper()
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load edited config: %v", err)
+	}
+	parents := make(map[string]string)
+	for _, category := range config.Categories {
+		parent := ""
+		if category.Parent != nil {
+			parent = *category.Parent
+		}
+		parents[category.Code] = parent
+	}
+	return parents
+}
+
+func TestEditConfig_RenameCode(t *testing.T) {
+	configPath := writeConfig(t, codesConfig)
+	if err := RenameCode(configPath, "categories", "RES", "RESTO"); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	if err := RenameCode(configPath, "categories", "SRT", "SOR"); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	if err := RenameCode(configPath, "tags", "VAC", "CONGES"); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+
+	parents := categoryParents(t, configPath)
+	want := map[string]string{"ALM": "", "RESTO": "ALM", "FST": "RESTO", "MAR": "ALM", "LOI": "", "SOR": "LOI"}
+	if len(parents) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, parents)
+	}
+	for code, parent := range want {
+		if got, exists := parents[code]; !exists || got != parent {
+			t.Errorf("Expected %s under %q, got %q (%v)", code, parent, got, exists)
+		}
+	}
+	config, _ := LoadConfig(configPath)
+	if config.Budgets[0].Category != "RESTO" || config.Tags[0].Code != "CONGES" {
+		t.Errorf("Expected the budget and the tag renamed, got %+v %+v", config.Budgets, config.Tags)
+	}
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "# Dépenses courantes") {
+		t.Errorf("Expected comments kept, got:\n%s", data)
+	}
+}
+
+func TestEditConfig_MergeCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		code, into  string
+		wantParents map[string]string
+		wantBudget  string
+	}{
+		{"children move under into", "RES", "LOI",
+			map[string]string{"ALM": "", "FST": "LOI", "MAR": "ALM", "LOI": "", "SRT": "LOI"}, "LOI"},
+		{"into takes the place of a parent", "ALM", "RES",
+			map[string]string{"RES": "", "FST": "RES", "MAR": "RES", "LOI": "", "SRT": "LOI"}, "RES"},
+		{"listed children move too", "LOI", "ALM",
+			map[string]string{"ALM": "", "RES": "ALM", "FST": "RES", "MAR": "ALM", "SRT": "ALM"}, "RES"},
+		{"into deeper below", "ALM", "FST",
+			map[string]string{"FST": "", "RES": "FST", "MAR": "FST", "LOI": "", "SRT": "LOI"}, "RES"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configPath := writeConfig(t, codesConfig)
+			if err := MergeCode(configPath, "categories", test.code, test.into); err != nil {
+				t.Fatalf("Failed to merge: %v", err)
+			}
+			parents := categoryParents(t, configPath)
+			if len(parents) != len(test.wantParents) {
+				t.Fatalf("Expected %v, got %v", test.wantParents, parents)
+			}
+			for code, parent := range test.wantParents {
+				if got, exists := parents[code]; !exists || got != parent {
+					t.Errorf("Expected %s under %q, got %q (%v)", code, parent, got, exists)
+				}
+			}
+			if config, _ := LoadConfig(configPath); config.Budgets[0].Category != test.wantBudget {
+				t.Errorf("Expected the budget on %s, got %s", test.wantBudget, config.Budgets[0].Category)
+			}
+		})
+	}
+}
+
+func TestEditConfig_RemoveCode(t *testing.T) {
+	configPath := writeConfig(t, codesConfig)
+	if err := RemoveCode(configPath, "categories", "SRT"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	parents := categoryParents(t, configPath)
+	if _, exists := parents["SRT"]; exists || len(parents) != 5 {
+		t.Errorf("Expected SRT removed, got %v", parents)
+	}
+	if err := RemoveCode(configPath, "tags", "GONE"); err != nil {
+		t.Errorf("Expected a code missing from the file to be left alone, got %v", err)
+	}
+
+	// Removing a code with children in the file would leave them without a parent
+	before, _ := os.ReadFile(configPath)
+	if err := RemoveCode(configPath, "categories", "RES"); err == nil {
+		t.Error("Expected the removal of a code with children in the file to be refused")
+	}
+	if after, _ := os.ReadFile(configPath); string(after) != string(before) {
+		t.Errorf("Expected the file unchanged, got:\n%s", after)
+	}
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// BudgetPeriod is how often the amount of a budget renews
+type BudgetPeriod string
+
+const (
+	BudgetMonthly BudgetPeriod = "monthly"
+	BudgetYearly  BudgetPeriod = "yearly"
+	BudgetOnce    BudgetPeriod = "once" // One-off: the amount covers the whole validity range
+)
+
+// Budget is the amount planned for the expenses of a category (subcategories included),
+// renewed each calendar month or year, or once, from ValidFrom to ValidTo
+type Budget struct {
+	Category    string       `json:"category" yaml:"category"`
+	Amount      Money        `json:"amount" yaml:"amount"` // Positive
+	Period      BudgetPeriod `json:"period" yaml:"period"`
+	ValidFrom   time.Time    `json:"valid_from" yaml:"valid_from"`
+	ValidTo     *time.Time   `json:"valid_to,omitempty" yaml:"valid_to,omitempty"` // Inclusive; nil for no end
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Key identifies a budget by its category and first day
+func (b Budget) Key() string {
+	return b.Category + "@" + b.ValidFrom.Format("2006-01-02")
+}
+
+// end returns the day after ValidTo, zero without ValidTo
+func (b Budget) end() time.Time {
+	if b.ValidTo == nil {
+		return time.Time{}
+	}
+	return day(*b.ValidTo).AddDate(0, 0, 1)
+}
+
+// Allocated returns the part of the amount planned for the days from from to to
+// (exclusive): each month, year or validity range gets the whole amount, shared evenly
+// between its days, so a month half covered gets half of a monthly amount
+func (b Budget) Allocated(from, to time.Time) Money {
+	total := new(big.Rat)
+	if b.Period == BudgetOnce && b.ValidTo == nil {
+		return MoneyFromRat(total, b.Amount.Currency) // Refused by ValidateBudgets
+	}
+	start, end := day(from), day(to)
+	if validFrom := day(b.ValidFrom); start.Before(validFrom) {
+		start = validFrom
+	}
+	if validEnd := b.end(); !validEnd.IsZero() && validEnd.Before(end) {
+		end = validEnd
+	}
+
+	for cursor := start; cursor.Before(end); {
+		windowStart, windowEnd := b.window(cursor)
+		overlapEnd := windowEnd
+		if end.Before(overlapEnd) {
+			overlapEnd = end
+		}
+		share := big.NewRat(int64(days(cursor, overlapEnd)), int64(days(windowStart, windowEnd)))
+		total.Add(total, share)
+		cursor = overlapEnd
+	}
+	return MoneyFromRat(total.Mul(total, b.Amount.Rat()), b.Amount.Currency)
+}
+
+// window returns the month, year or validity range containing date
+func (b Budget) window(date time.Time) (time.Time, time.Time) {
+	switch b.Period {
+	case BudgetMonthly:
+		start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	case BudgetYearly:
+		start := time.Date(date.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	default:
+		return day(b.ValidFrom), b.end()
+	}
+}
+
+// ValidateBudgets checks amounts, periods and validity ranges, and that the budgets of a
+// category do not overlap
+func ValidateBudgets(budgets []Budget) error {
+	byCategory := make(map[string][]Budget)
+	for _, budget := range budgets {
+		if budget.Category == "" {
+			return fmt.Errorf("budget without category")
+		}
+		if !budget.Amount.IsPositive() {
+			return fmt.Errorf("budget %s: amount must be positive", budget.Key())
+		}
+		switch budget.Period {
+		case BudgetMonthly, BudgetYearly:
+		case BudgetOnce:
+			if budget.ValidTo == nil {
+				return fmt.Errorf("budget %s: a one-off budget needs valid_to", budget.Key())
+			}
+		default:
+			return fmt.Errorf("budget %s: unknown period %q (monthly, yearly or once)", budget.Key(), budget.Period)
+		}
+		if budget.ValidFrom.IsZero() {
+			return fmt.Errorf("budget of %s: valid_from is required", budget.Category)
+		}
+		if budget.ValidTo != nil && day(*budget.ValidTo).Before(day(budget.ValidFrom)) {
+			return fmt.Errorf("budget %s: valid_to is before valid_from", budget.Key())
+		}
+		byCategory[budget.Category] = append(byCategory[budget.Category], budget)
+	}
+
+	for _, list := range byCategory {
+		sort.Slice(list, func(i, j int) bool { return list[i].ValidFrom.Before(list[j].ValidFrom) })
+		for i := 1; i < len(list); i++ {
+			previousEnd := list[i-1].end()
+			if previousEnd.IsZero() || day(list[i].ValidFrom).Before(previousEnd) {
+				return fmt.Errorf("budgets %s and %s overlap", list[i-1].Key(), list[i].Key())
+			}
+		}
+	}
+	return nil
+}
+
+// day returns the calendar day of t at midnight UTC
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// days returns the number of days from one midnight to another
+func days(from, to time.Time) int {
+	return int(to.Sub(from).Hours()/24 + 0.5)
+}
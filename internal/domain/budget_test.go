@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget_AllocatedProratesDays(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	end := date(2024, 8, 31)
+	monthly := Budget{Category: "ALM", Amount: NewMoney(31000, "EUR"), Period: BudgetMonthly, ValidFrom: date(2024, 1, 16)}
+	yearly := Budget{Category: "ALM", Amount: NewMoney(36600, "EUR"), Period: BudgetYearly, ValidFrom: date(2024, 1, 1)}
+	once := Budget{Category: "VAC", Amount: NewMoney(62000, "EUR"), Period: BudgetOnce, ValidFrom: date(2024, 7, 1), ValidTo: &end}
+
+	tests := []struct {
+		name     string
+		budget   Budget
+		from, to time.Time
+		expected string
+	}{
+		{"month started mid-way", monthly, date(2024, 1, 1), date(2024, 2, 1), "160.00 EUR"},
+		{"whole months", monthly, date(2024, 2, 1), date(2024, 4, 1), "620.00 EUR"},
+		{"before validity", monthly, date(2023, 1, 1), date(2024, 1, 1), "0.00 EUR"},
+		{"month of a yearly budget", yearly, date(2024, 2, 1), date(2024, 3, 1), "29.00 EUR"},
+		{"whole one-off range", once, date(2024, 1, 1), date(2025, 1, 1), "620.00 EUR"},
+		{"half of a one-off range", once, date(2024, 7, 1), date(2024, 8, 1), "310.00 EUR"},
+	}
+	for _, test := range tests {
+		if got := test.budget.Allocated(test.from, test.to).String(); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestValidateBudgets(t *testing.T) {
+	date := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC) }
+	june := date(6, 30)
+	valid := []Budget{
+		{Category: "ALM", Amount: NewMoney(100, "EUR"), Period: BudgetMonthly, ValidFrom: date(1, 1), ValidTo: &june},
+		{Category: "ALM", Amount: NewMoney(200, "EUR"), Period: BudgetMonthly, ValidFrom: date(7, 1)},
+	}
+	if err := ValidateBudgets(valid); err != nil {
+		t.Errorf("Expected successive budgets to be valid, got %v", err)
+	}
+
+	overlapping := append(valid, Budget{Category: "ALM", Amount: NewMoney(300, "EUR"), Period: BudgetYearly, ValidFrom: date(9, 1)})
+	if err := ValidateBudgets(overlapping); err == nil {
+		t.Error("Expected overlapping budgets to be refused")
+	}
+	if err := ValidateBudgets([]Budget{{Category: "ALM", Amount: NewMoney(100, "EUR"), Period: "weekly", ValidFrom: date(1, 1)}}); err == nil {
+		t.Error("Expected an unknown period to be refused")
+	}
+	if err := ValidateBudgets([]Budget{{Category: "ALM", Amount: NewMoney(100, "EUR"), Period: BudgetMonthly, ValidFrom: date(7, 1), ValidTo: &june}}); err == nil {
+		t.Error("Expected valid_to before valid_from to be refused")
+	}
+}
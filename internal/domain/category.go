@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LinkCategories checks the hierarchy of a list of categories (unique codes, known
+// parents, no cycles) and returns a copy where Parent and Children agree: a category
+// listed as a child of another takes it as parent, and Children are rebuilt from the
+// parents, in list order.
+func LinkCategories(categories []Category) ([]Category, error) {
+	linked := make([]Category, len(categories))
+	index := make(map[string]int, len(categories))
+	for i, category := range categories {
+		if _, exists := index[category.Code]; exists {
+			return nil, fmt.Errorf("duplicate code %s", category.Code)
+		}
+		index[category.Code] = i
+		linked[i] = category
+		if category.Parent != nil {
+			parent := *category.Parent
+			linked[i].Parent = &parent
+		}
+	}
+
+	for _, category := range categories {
+		for _, child := range category.Children {
+			i, exists := index[child]
+			if !exists {
+				return nil, fmt.Errorf("%s lists unknown child %s", category.Code, child)
+			}
+			if parent := linked[i].Parent; parent != nil && *parent != category.Code {
+				return nil, fmt.Errorf("%s is listed as a child of %s but its parent is %s", child, category.Code, *parent)
+			}
+			code := category.Code
+			linked[i].Parent = &code
+		}
+	}
+
+	for i := range linked {
+		linked[i].Children = []string{}
+	}
+	for _, category := range linked {
+		if category.Parent == nil {
+			continue
+		}
+		p, exists := index[*category.Parent]
+		if !exists {
+			return nil, fmt.Errorf("%s has unknown parent %s", category.Code, *category.Parent)
+		}
+		linked[p].Children = append(linked[p].Children, category.Code)
+	}
+
+	// Walking up from any category must reach the top within len(categories) steps
+	for _, category := range linked {
+		path := []string{category.Code}
+		for current := category; current.Parent != nil; current = linked[index[*current.Parent]] {
+			path = append(path, *current.Parent)
+			if *current.Parent == category.Code || len(path) > len(linked)+1 {
+				return nil, fmt.Errorf("cycle in the hierarchy: %s", strings.Join(path, " -> "))
+			}
+		}
+	}
+	return linked, nil
+}
+
+// CategoryTree walks a hierarchy of categories linked by LinkCategories (or kept
+// consistent by the services)
+type CategoryTree struct {
+	categories []Category
+	index      map[string]int
+}
+
+// NewCategoryTree indexes categories by code
+func NewCategoryTree(categories []Category) *CategoryTree {
+	tree := &CategoryTree{categories: categories, index: make(map[string]int, len(categories))}
+	for i, category := range categories {
+		tree.index[category.Code] = i
+	}
+	return tree
+}
+
+// Descendants returns code followed by every category below it
+func (t *CategoryTree) Descendants(code string) []string {
+	codes := []string{code}
+	seen := map[string]bool{code: true}
+	for i := 0; i < len(codes); i++ {
+		j, exists := t.index[codes[i]]
+		if !exists {
+			continue
+		}
+		for _, child := range t.categories[j].Children {
+			if !seen[child] {
+				seen[child] = true
+				codes = append(codes, child)
+			}
+		}
+	}
+	return codes
+}
+
+// Ancestors returns the categories above code, nearest first
+func (t *CategoryTree) Ancestors(code string) []string {
+	var codes []string
+	seen := map[string]bool{code: true}
+	for i, exists := t.index[code]; exists && t.categories[i].Parent != nil; i, exists = t.index[*t.categories[i].Parent] {
+		parent := *t.categories[i].Parent
+		if seen[parent] {
+			break
+		}
+		seen[parent] = true
+		codes = append(codes, parent)
+	}
+	return codes
+}
+
+// Walk calls fn for every category, depth first from the top-level ones, with its depth
+// (0 at the top). Categories whose parent is unknown are treated as top-level.
+func (t *CategoryTree) Walk(fn func(category Category, depth int)) {
+	visited := make(map[string]bool, len(t.categories))
+	var visit func(i, depth int)
+	visit = func(i, depth int) {
+		category := t.categories[i]
+		if visited[category.Code] {
+			return
+		}
+		visited[category.Code] = true
+		fn(category, depth)
+		for _, child := range category.Children {
+			if j, exists := t.index[child]; exists {
+				visit(j, depth+1)
+			}
+		}
+	}
+	for i, category := range t.categories {
+		if category.Parent == nil {
+			visit(i, 0)
+		} else if _, exists := t.index[*category.Parent]; !exists {
+			visit(i, 0)
+		}
+	}
+	// Categories left out by an inconsistent hierarchy are still shown
+	for i := range t.categories {
+		visit(i, 0)
+	}
+}
+
+// Totals returns, for each category, the total of the active lines in it or below it,
+// by currency. A line counts once in a category even if it names several categories
+// of the same branch.
+func (t *CategoryTree) Totals(transactions []Transaction) map[string]map[string]Money {
+	totals := make(map[string]map[string]Money)
+	for _, txn := range transactions {
+		if !txn.IsActive {
+			continue
+		}
+		for _, line := range txn.Lines() {
+			counted := make(map[string]bool)
+			for _, code := range line.Categories {
+				for _, rolled := range append([]string{code}, t.Ancestors(code)...) {
+					if counted[rolled] {
+						continue
+					}
+					counted[rolled] = true
+					if totals[rolled] == nil {
+						totals[rolled] = make(map[string]Money)
+					}
+					currency := line.Amount.Currency
+					totals[rolled][currency] = totals[rolled][currency].Add(line.Amount)
+				}
+			}
+		}
+	}
+	return totals
+}
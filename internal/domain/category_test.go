@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func code(s string) *string {
+	return &s
+}
+
+func TestLinkCategories_SyncsParentsAndChildren(t *testing.T) {
+	linked, err := LinkCategories([]Category{
+		{Code: "ALM", Children: []string{"RES"}},
+		{Code: "RES"},
+		{Code: "FST", Parent: code("RES"), Children: []string{}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if linked[1].Parent == nil || *linked[1].Parent != "ALM" {
+		t.Errorf("Expected RES to take ALM as parent, got %v", linked[1].Parent)
+	}
+	if len(linked[1].Children) != 1 || linked[1].Children[0] != "FST" {
+		t.Errorf("Expected RES children rebuilt as [FST], got %v", linked[1].Children)
+	}
+}
+
+func TestLinkCategories_RejectsInvalidHierarchies(t *testing.T) {
+	cases := []struct {
+		name       string
+		categories []Category
+		want       string
+	}{
+		{"dangling parent", []Category{{Code: "RES", Parent: code("ALM")}}, "unknown parent"},
+		{"unknown child", []Category{{Code: "ALM", Children: []string{"RES"}}}, "unknown child"},
+		{"two parents", []Category{{Code: "ALM"}, {Code: "LOI", Children: []string{"RES"}}, {Code: "RES", Parent: code("ALM")}}, "its parent is ALM"},
+		{"cycle", []Category{{Code: "A", Parent: code("C")}, {Code: "B", Parent: code("A")}, {Code: "C", Parent: code("B")}}, "cycle"},
+		{"self parent", []Category{{Code: "A", Parent: code("A")}}, "cycle"},
+		{"duplicate", []Category{{Code: "A"}, {Code: "A"}}, "duplicate"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LinkCategories(tc.categories)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestCategoryTree_TotalsRollUp(t *testing.T) {
+	categories, err := LinkCategories([]Category{
+		{Code: "ALM"},
+		{Code: "RES", Parent: code("ALM")},
+		{Code: "FST", Parent: code("RES")},
+		{Code: "LOI"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewCategoryTree(categories)
+
+	transactions := []Transaction{
+		{Amount: NewMoney(-1000, "EUR"), Categories: []string{"ALM"}, IsActive: true},
+		{Amount: NewMoney(-500, "EUR"), Categories: []string{"FST"}, IsActive: true},
+		{Amount: NewMoney(-300, "EUR"), IsActive: true, Splits: []Split{
+			{Amount: NewMoney(-200, "EUR"), Categories: []string{"RES", "LOI"}},
+			{Amount: NewMoney(-100, "EUR"), Categories: []string{"FST"}},
+		}},
+		{Amount: NewMoney(-9900, "EUR"), Categories: []string{"ALM"}, IsActive: false},
+	}
+	totals := tree.Totals(transactions)
+
+	want := map[string]int64{"ALM": -1800, "RES": -800, "FST": -600, "LOI": -200}
+	for code, minor := range want {
+		if got := totals[code]["EUR"]; got != NewMoney(minor, "EUR") {
+			t.Errorf("Expected %s total %d, got %s", code, minor, got)
+		}
+	}
+
+	if descendants := tree.Descendants("ALM"); strings.Join(descendants, ",") != "ALM,RES,FST" {
+		t.Errorf("Expected ALM,RES,FST, got %v", descendants)
+	}
+}
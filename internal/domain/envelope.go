@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// EnvelopeAssignment moves money of a month from the pool to be budgeted into the envelope
+// of a category; a negative amount gives it back. Envelope balances are not stored: they
+// are rebuilt from the assignments and the movements.
+type EnvelopeAssignment struct {
+	ID          string    `json:"id"`
+	Month       string    `json:"month"` // YYYY-MM
+	Category    string    `json:"category"`
+	Amount      Money     `json:"amount"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ExchangeRate gives the value of one unit of From expressed in To on a given date
+type ExchangeRate struct {
+	Date time.Time `json:"date"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Rate string    `json:"rate"` // Exact decimal, e.g. "1.0832"
+}
+
+// Value returns the rate as an exact rational number
+func (r ExchangeRate) Value() (*big.Rat, error) {
+	return ParseRate(r.Rate)
+}
+
+// ParseRate parses a strictly positive decimal rate such as "1.0832" or "0,92"
+func ParseRate(s string) (*big.Rat, error) {
+	value := strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid exchange rate: %q", s)
+	}
+	if rate.Sign() <= 0 {
+		return nil, fmt.Errorf("exchange rate must be positive: %q", s)
+	}
+	return rate, nil
+}
+
+// FormatRate formats a rate as a decimal string with up to 10 significant decimals
+func FormatRate(rate *big.Rat) string {
+	text := rate.FloatString(10)
+	text = strings.TrimRight(text, "0")
+	return strings.TrimSuffix(text, ".")
+}
+
+// Convert returns the amount converted into currency at the given rate,
+// rounded half away from zero to the target currency's minor unit
+func (m Money) Convert(rate *big.Rat, currency string) Money {
+	return MoneyFromRat(new(big.Rat).Mul(m.Rat(), rate), currency)
+}
@@ -10,7 +10,7 @@ type Account struct {
 	Name           string    `json:"name" yaml:"name"`
 	Type           string    `json:"type" yaml:"type"`
 	Currency       string    `json:"currency" yaml:"currency"`
-	InitialBalance float64   `json:"initial_balance" yaml:"initial_balance"`
+	InitialBalance Money     `json:"initial_balance" yaml:"initial_balance"`
 	IsActive       bool      `json:"is_active" yaml:"is_active"`
 	CreatedAt      time.Time `json:"created_at" yaml:"created_at"`
 }
@@ -20,15 +20,89 @@ type Transaction struct {
 	ID          string    `json:"id"`
 	Account     string    `json:"account"`
 	Date        time.Time `json:"date"`
-	Amount      float64   `json:"amount"`
+	Amount      Money     `json:"amount"`
 	Description string    `json:"description"`
 	Categories  []string  `json:"categories"`
 	Tags        []string  `json:"tags"`
+	Splits      []Split   `json:"splits,omitempty"`
 	IsActive    bool      `json:"is_active"`
 	EditComment string    `json:"edit_comment,omitempty"`
 	ParentID    string    `json:"parent_id,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	TransferID  string    `json:"transfer_id,omitempty"`
+	// ExchangeRate is the rate used by a cross-currency transfer, in units of the
+	// credited account currency per unit of the debited account currency
+	ExchangeRate string `json:"exchange_rate,omitempty"`
+	// Occurrence is the key of the recurring rule occurrence the movement was posted for
+	// (see RecurringRule.OccurrenceKey); edits keep it
+	Occurrence string    `json:"occurrence,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Split is one line of a transaction spread over several categories
+// (e.g. a supermarket receipt that is partly food and partly clothing)
+type Split struct {
+	Amount      Money    `json:"amount"`
+	Description string   `json:"description,omitempty"`
+	Categories  []string `json:"categories"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Lines returns the split lines of a transaction, or a single line covering the whole
+// amount when it is not split. Tags of the transaction apply to every line.
+func (t Transaction) Lines() []Split {
+	if len(t.Splits) == 0 {
+		return []Split{{
+			Amount:      t.Amount,
+			Description: t.Description,
+			Categories:  t.Categories,
+			Tags:        t.Tags,
+		}}
+	}
+
+	lines := make([]Split, len(t.Splits))
+	for i, split := range t.Splits {
+		lines[i] = split
+		lines[i].Tags = mergeCodes(t.Tags, split.Tags)
+		if lines[i].Description == "" {
+			lines[i].Description = t.Description
+		}
+	}
+	return lines
+}
+
+// CategoryAmounts returns the amount attributed to each category code. A line with several
+// categories counts fully in each of them; uncategorised amounts are keyed by "".
+func (t Transaction) CategoryAmounts() map[string]Money {
+	amounts := make(map[string]Money)
+	for _, line := range t.Lines() {
+		codes := line.Categories
+		if len(codes) == 0 {
+			codes = []string{""}
+		}
+		for _, code := range codes {
+			amounts[code] = amounts[code].Add(line.Amount)
+		}
+	}
+	return amounts
+}
+
+// mergeCodes returns the union of two code lists, keeping the first occurrence order
+func mergeCodes(a, b []string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, code := range append(append([]string{}, a...), b...) {
+		if !seen[code] {
+			seen[code] = true
+			merged = append(merged, code)
+		}
+	}
+	return merged
+}
+
+// IsTransfer reports whether the transaction is one leg of a transfer between two accounts
+func (t Transaction) IsTransfer() bool {
+	return t.TransferID != ""
 }
 
 // Category represents a transaction category
@@ -58,3 +132,22 @@ type TransactionBatch struct {
 	RolledBackAt *time.Time    `json:"rolled_back_at,omitempty"`
 	Transactions []Transaction `json:"transactions"`
 }
+
+// BalanceSnapshot records the balance of an account including every active movement
+// dated before Date, so that balances only replay the movements after it
+type BalanceSnapshot struct {
+	AccountID string    `json:"account_id"`
+	Balance   Money     `json:"balance"`
+	Date      time.Time `json:"date"`
+	LastTxnID string    `json:"last_transaction_id,omitempty"` // Latest movement included, checked on read
+}
+
+// AuditEntry records a change that rewrote stored data in place (e.g. renaming a
+// category rewrites the movements using it), so that history keeps a trace of it
+type AuditEntry struct {
+	ID           string    `json:"id"`
+	Time         time.Time `json:"time"`
+	Action       string    `json:"action"` // e.g. "category.rename"
+	Details      string    `json:"details"`
+	Transactions []string  `json:"transactions,omitempty"` // Movements rewritten
+}
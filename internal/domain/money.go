@@ -0,0 +1,353 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCurrencyExponent is the number of decimals used when a currency is unknown
+const DefaultCurrencyExponent = 2
+
+// currencyExponents lists currencies whose minor unit is not the cent
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"ISK": 0,
+	"XOF": 0,
+	"XAF": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// CurrencyExponent returns the number of decimals of a currency's minor unit
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return DefaultCurrencyExponent
+}
+
+// Money represents an exact monetary amount as an integer number of minor units
+// (e.g. cents) in a given currency. An empty currency means "not yet known" and
+// uses DefaultCurrencyExponent; it is filled from the account when a transaction is saved.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// NewMoney creates an amount from minor units
+func NewMoney(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: strings.ToUpper(currency)}
+}
+
+// ParseMoney parses a decimal amount such as "-25.50", "-25,50" or "12 EUR".
+// If the string carries a currency, it must match the given one (when not empty).
+// Amounts with more decimals than the currency allows are rejected.
+func ParseMoney(s string, currency string) (Money, error) {
+	value, parsedCurrency := splitAmountAndCurrency(s)
+	currency = strings.ToUpper(currency)
+	if parsedCurrency != "" {
+		if currency != "" && parsedCurrency != currency {
+			return Money{}, fmt.Errorf("currency mismatch: got %s, expected %s", parsedCurrency, currency)
+		}
+		currency = parsedCurrency
+	}
+
+	minor, exact, err := decimalToMinor(value, CurrencyExponent(currency))
+	if err != nil {
+		return Money{}, err
+	}
+	if !exact {
+		return Money{}, fmt.Errorf("too many decimals in amount %q for currency %s", s, displayCurrency(currency))
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on error (for constants and tests)
+func MustParseMoney(s string, currency string) Money {
+	m, err := ParseMoney(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// IsNegative reports whether the amount is strictly negative
+func (m Money) IsNegative() bool {
+	return m.Minor < 0
+}
+
+// IsPositive reports whether the amount is strictly positive
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Neg returns the opposite amount
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+// Abs returns the absolute amount
+func (m Money) Abs() Money {
+	if m.Minor < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// SameCurrency reports whether two amounts can be added together.
+// An amount without currency is compatible with any currency.
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == "" || o.Currency == "" || m.Currency == o.Currency
+}
+
+// Add returns m + o. Both amounts must share the same currency (see SameCurrency);
+// mixing currencies is a programming error and panics.
+func (m Money) Add(o Money) Money {
+	if !m.SameCurrency(o) {
+		panic(fmt.Sprintf("cannot add %s and %s amounts", m.Currency, o.Currency))
+	}
+	currency := m.Currency
+	if currency == "" {
+		currency = o.Currency
+		m = m.WithCurrency(currency)
+	}
+	o = o.WithCurrency(currency)
+	return Money{Minor: m.Minor + o.Minor, Currency: currency}
+}
+
+// Sub returns m - o (see Add for currency rules)
+func (m Money) Sub(o Money) Money {
+	return m.Add(o.Neg())
+}
+
+// Cmp compares two amounts of the same currency and returns -1, 0 or +1
+func (m Money) Cmp(o Money) int {
+	diff := m.Sub(o)
+	switch {
+	case diff.Minor < 0:
+		return -1
+	case diff.Minor > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// WithCurrency returns the amount denominated in the given currency.
+// Only amounts without currency are rescaled; an amount that already has
+// a currency is returned unchanged if it matches, otherwise the caller must convert it.
+func (m Money) WithCurrency(currency string) Money {
+	currency = strings.ToUpper(currency)
+	if m.Currency == currency || currency == "" {
+		return m
+	}
+	if m.Currency != "" {
+		return m
+	}
+	from := CurrencyExponent("")
+	to := CurrencyExponent(currency)
+	r := new(big.Rat).SetFrac64(m.Minor, 1)
+	r.Mul(r, pow10Rat(to-from))
+	return Money{Minor: roundRat(r), Currency: currency}
+}
+
+// Rat returns the amount in major units as an exact rational number
+func (m Money) Rat() *big.Rat {
+	r := new(big.Rat).SetFrac64(m.Minor, 1)
+	return r.Mul(r, pow10Rat(-CurrencyExponent(m.Currency)))
+}
+
+// MoneyFromRat builds an amount from a rational number of major units,
+// rounding half away from zero to the currency's minor unit
+func MoneyFromRat(r *big.Rat, currency string) Money {
+	scaled := new(big.Rat).Mul(r, pow10Rat(CurrencyExponent(currency)))
+	return Money{Minor: roundRat(scaled), Currency: strings.ToUpper(currency)}
+}
+
+// Decimal returns the amount as a plain decimal string, e.g. "-25.50"
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+	sign := ""
+	minor := m.Minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	digits := fmt.Sprintf("%0*d", exp+1, minor)
+	if exp == 0 {
+		return sign + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String returns the amount with its currency, e.g. "-25.50 EUR"
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// MarshalJSON encodes the amount as a string such as "-25.50 EUR" so that it round-trips exactly
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts either the string form written by MarshalJSON or a
+// plain JSON number (legacy float64 files and CLI input). Numbers are read
+// from their literal text, never through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		*m = Money{}
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(s, "")
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+	parsed, err := legacyMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalYAML encodes the amount as "1500.00 EUR", or as a bare number when no currency is known
+func (m Money) MarshalYAML() (interface{}, error) {
+	if m.Currency == "" {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: m.Decimal()}, nil
+	}
+	return m.String(), nil
+}
+
+// UnmarshalYAML accepts numbers (initial_balance: 1500.0) and strings ("1500.00 EUR")
+func (m *Money) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: amount must be a scalar", node.Line)
+	}
+	if node.Tag == "!!float" || node.Tag == "!!int" {
+		parsed, err := legacyMoney(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		*m = parsed
+		return nil
+	}
+	parsed, err := ParseMoney(node.Value, "")
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*m = parsed
+	return nil
+}
+
+// legacyMoney parses a bare number written before amounts were exact.
+// Extra decimals (float noise such as 0.30000000000000004) are rounded.
+func legacyMoney(text string) (Money, error) {
+	minor, _, err := decimalToMinor(text, DefaultCurrencyExponent)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: minor}, nil
+}
+
+// splitAmountAndCurrency separates "12.50 EUR" into "12.50" and "EUR"
+func splitAmountAndCurrency(s string) (string, string) {
+	fields := strings.Fields(strings.TrimSpace(s))
+	if len(fields) == 2 {
+		if isCurrencyCode(fields[1]) {
+			return fields[0], strings.ToUpper(fields[1])
+		}
+		if isCurrencyCode(fields[0]) {
+			return fields[1], strings.ToUpper(fields[0])
+		}
+	}
+	return strings.TrimSpace(s), ""
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// decimalToMinor converts a decimal string to minor units. exact is false when rounding was needed.
+func decimalToMinor(value string, exponent int) (int64, bool, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "+")
+	value = strings.ReplaceAll(value, ",", ".")
+	if value == "" {
+		return 0, false, fmt.Errorf("empty amount")
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid amount: %q", value)
+	}
+	r.Mul(r, pow10Rat(exponent))
+	rounded := roundRatBig(r)
+	if !rounded.IsInt64() {
+		return 0, false, fmt.Errorf("amount out of range: %q", value)
+	}
+	return rounded.Int64(), r.IsInt(), nil
+}
+
+// roundRat rounds half away from zero
+func roundRat(r *big.Rat) int64 {
+	return roundRatBig(r).Int64()
+}
+
+func roundRatBig(r *big.Rat) *big.Int {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return q
+}
+
+func pow10Rat(n int) *big.Rat {
+	if n >= 0 {
+		return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+	}
+	return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-n)), nil))
+}
+
+func displayCurrency(currency string) string {
+	if currency == "" {
+		return "(default)"
+	}
+	return currency
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		expected Money
+	}{
+		{"-25.50", "EUR", NewMoney(-2550, "EUR")},
+		{"-25,5", "EUR", NewMoney(-2550, "EUR")},
+		{"+100", "", NewMoney(10000, "")},
+		{"12.34 USD", "", NewMoney(1234, "USD")},
+		{"1500", "JPY", NewMoney(1500, "JPY")},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input, tt.currency)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseMoney(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseMoney_Invalid(t *testing.T) {
+	invalid := []struct {
+		input    string
+		currency string
+	}{
+		{"abc", "EUR"},
+		{"", "EUR"},
+		{"1.234", "EUR"},  // too many decimals
+		{"10.5", "JPY"},   // JPY has no minor unit
+		{"10 USD", "EUR"}, // currency mismatch
+	}
+
+	for _, tt := range invalid {
+		if _, err := ParseMoney(tt.input, tt.currency); err == nil {
+			t.Errorf("Expected error for ParseMoney(%q, %q), got nil", tt.input, tt.currency)
+		}
+	}
+}
+
+func TestMoney_SumIsExact(t *testing.T) {
+	// 0.10 added 1000 times drifts with float64
+	total := NewMoney(0, "EUR")
+	for i := 0; i < 1000; i++ {
+		total = total.Add(MustParseMoney("0.10", "EUR"))
+	}
+	if total != NewMoney(10000, "EUR") {
+		t.Errorf("Expected 100.00 EUR, got %s", total)
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	tests := []struct {
+		money    Money
+		expected string
+	}{
+		{NewMoney(-2550, "EUR"), "-25.50 EUR"},
+		{NewMoney(5, "EUR"), "0.05 EUR"},
+		{NewMoney(-5, ""), "-0.05"},
+		{NewMoney(1500, "JPY"), "1500 JPY"},
+		{NewMoney(1234, "KWD"), "1.234 KWD"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+func TestMoney_AddMismatchedCurrenciesPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when adding EUR and USD")
+		}
+	}()
+	NewMoney(100, "EUR").Add(NewMoney(100, "USD"))
+}
+
+func TestMoney_WithCurrency(t *testing.T) {
+	legacy := NewMoney(150000, "") // 1500.00 without currency
+	if got := legacy.WithCurrency("JPY"); got != NewMoney(1500, "JPY") {
+		t.Errorf("Expected 1500 JPY, got %s", got)
+	}
+	if got := legacy.WithCurrency("EUR"); got != NewMoney(150000, "EUR") {
+		t.Errorf("Expected 1500.00 EUR, got %s", got)
+	}
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	original := Transaction{ID: "txn1", Amount: NewMoney(-1999, "EUR")}
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	var decoded Transaction
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	if decoded.Amount != original.Amount {
+		t.Errorf("Expected %s after round-trip, got %s", original.Amount, decoded.Amount)
+	}
+}
+
+func TestMoney_UnmarshalLegacyJSONNumber(t *testing.T) {
+	var txn Transaction
+	if err := json.Unmarshal([]byte(`{"amount": -25.5}`), &txn); err != nil {
+		t.Fatalf("Failed to unmarshal legacy amount: %v", err)
+	}
+	if txn.Amount != NewMoney(-2550, "") {
+		t.Errorf("Expected -25.50 without currency, got %v", txn.Amount)
+	}
+
+	// Float noise from old files is rounded to the cent
+	if err := json.Unmarshal([]byte(`{"amount": 0.30000000000000004}`), &txn); err != nil {
+		t.Fatalf("Failed to unmarshal legacy amount: %v", err)
+	}
+	if txn.Amount != NewMoney(30, "") {
+		t.Errorf("Expected 0.30, got %v", txn.Amount)
+	}
+}
+
+func TestMoney_YAMLRoundTrip(t *testing.T) {
+	var account Account
+	if err := yaml.Unmarshal([]byte("initial_balance: 1500.5\n"), &account); err != nil {
+		t.Fatalf("Failed to unmarshal YAML number: %v", err)
+	}
+	if account.InitialBalance != NewMoney(150050, "") {
+		t.Errorf("Expected 1500.50, got %v", account.InitialBalance)
+	}
+
+	account.InitialBalance = NewMoney(150050, "EUR")
+	data, err := yaml.Marshal(account)
+	if err != nil {
+		t.Fatalf("Failed to marshal YAML: %v", err)
+	}
+
+	var decoded Account
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal YAML: %v", err)
+	}
+	if decoded.InitialBalance != account.InitialBalance {
+		t.Errorf("Expected %s after YAML round-trip, got %s", account.InitialBalance, decoded.InitialBalance)
+	}
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Frequency is how often a recurring rule repeats
+type Frequency string
+
+const (
+	Daily   Frequency = "daily"
+	Weekly  Frequency = "weekly"
+	Monthly Frequency = "monthly"
+	Yearly  Frequency = "yearly"
+)
+
+// RecurringRule describes a movement that repeats (rent, salary, subscriptions). Its
+// occurrences are posted by 'comptes schedule run', each movement keeping the key of its
+// occurrence (see OccurrenceKey) so that it is never posted twice.
+type RecurringRule struct {
+	ID          string     `json:"id"` // Code chosen by the user, e.g. LOYER
+	Account     string     `json:"account"`
+	Amount      Money      `json:"amount"`
+	Description string     `json:"description,omitempty"`
+	Categories  []string   `json:"categories"`
+	Tags        []string   `json:"tags"`
+	Frequency   Frequency  `json:"frequency"`
+	Interval    int        `json:"interval,omitempty"` // Every Interval days, weeks, months or years; 1 when 0
+	Day         int        `json:"day,omitempty"`      // Monthly: day of the month (clamped to short months); the day of Start when 0
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"`   // Last possible day, included
+	Count       int        `json:"count,omitempty"` // Number of occurrences; unlimited when 0
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// OccurrenceKey identifies the occurrence of the rule on date
+func (r RecurringRule) OccurrenceKey(date time.Time) string {
+	return r.ID + "@" + date.Format("2006-01-02")
+}
+
+// Validate checks the frequency, interval, day, end and count of the rule
+func (r RecurringRule) Validate() error {
+	switch r.Frequency {
+	case Daily, Weekly, Monthly, Yearly:
+	default:
+		return fmt.Errorf("unknown frequency %q (daily, weekly, monthly or yearly)", r.Frequency)
+	}
+	if r.Start.IsZero() {
+		return fmt.Errorf("a start date is required")
+	}
+	if r.Interval < 0 || r.Count < 0 {
+		return fmt.Errorf("interval and count cannot be negative")
+	}
+	if r.Day < 0 || r.Day > 31 || (r.Day != 0 && r.Frequency != Monthly) {
+		return fmt.Errorf("day must be from 1 to 31, and only for monthly rules")
+	}
+	if r.End != nil && day(*r.End).Before(day(r.Start)) {
+		return fmt.Errorf("end %s is before start %s", r.End.Format("2006-01-02"), r.Start.Format("2006-01-02"))
+	}
+	if r.Amount.IsZero() {
+		return fmt.Errorf("amount cannot be zero")
+	}
+	return nil
+}
+
+// Occurrences returns the days the rule falls on, from Start up to through (included),
+// within End and Count
+func (r RecurringRule) Occurrences(through time.Time) []time.Time {
+	var dates []time.Time
+	last := day(through)
+	r.each(func(date time.Time) bool {
+		if date.After(last) {
+			return false
+		}
+		dates = append(dates, date)
+		return true
+	})
+	return dates
+}
+
+// Next returns the first occurrence after the day of after; ok is false when the rule
+// has ended
+func (r RecurringRule) Next(after time.Time) (next time.Time, ok bool) {
+	after = day(after)
+	r.each(func(date time.Time) bool {
+		if date.After(after) {
+			next, ok = date, true
+			return false
+		}
+		return true
+	})
+	return next, ok
+}
+
+// each calls fn with the occurrences in order, within End and Count, until fn returns false
+func (r RecurringRule) each(fn func(date time.Time) bool) {
+	if r.Validate() != nil {
+		return
+	}
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	start := day(r.Start)
+	count := 0
+	for n := 0; r.Count == 0 || count < r.Count; n++ {
+		date := r.nth(start, n*interval)
+		if r.End != nil && date.After(day(*r.End)) {
+			return
+		}
+		if date.Before(start) {
+			continue // Monthly day earlier in the month than Start
+		}
+		count++
+		if !fn(date) {
+			return
+		}
+	}
+}
+
+// nth returns the day steps days, weeks, months or years after start
+func (r RecurringRule) nth(start time.Time, steps int) time.Time {
+	switch r.Frequency {
+	case Daily:
+		return start.AddDate(0, 0, steps)
+	case Weekly:
+		return start.AddDate(0, 0, 7*steps)
+	case Monthly:
+		dayOfMonth := r.Day
+		if dayOfMonth == 0 {
+			dayOfMonth = start.Day()
+		}
+		return clampedDate(start.Year(), start.Month()+time.Month(steps), dayOfMonth)
+	default:
+		return clampedDate(start.Year()+steps, start.Month(), start.Day())
+	}
+}
+
+// clampedDate returns the day of the month, or the last day of a shorter month
+// (month may overflow into the following years)
+func clampedDate(year int, month time.Month, dayOfMonth int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if lastDay := first.AddDate(0, 1, -1).Day(); dayOfMonth > lastDay {
+		dayOfMonth = lastDay
+	}
+	return first.AddDate(0, 0, dayOfMonth-1)
+}
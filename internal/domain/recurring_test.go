@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecurringRule_Occurrences(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	end := date(2024, 5, 31)
+	rent := RecurringRule{ID: "LOYER", Amount: NewMoney(-85000, "EUR"), Frequency: Monthly, Day: 31, Start: date(2024, 1, 1)}
+	late := RecurringRule{ID: "SALAIRE", Amount: NewMoney(250000, "EUR"), Frequency: Monthly, Day: 5, Start: date(2024, 1, 10), End: &end}
+	weekly := RecurringRule{ID: "CANTINE", Amount: NewMoney(-1200, "EUR"), Frequency: Weekly, Interval: 2, Start: date(2024, 1, 3), Count: 3}
+	leap := RecurringRule{ID: "ASSURANCE", Amount: NewMoney(-42000, "EUR"), Frequency: Yearly, Start: date(2024, 2, 29)}
+
+	tests := []struct {
+		name     string
+		rule     RecurringRule
+		through  time.Time
+		expected string
+	}{
+		{"day clamped to short months", rent, date(2024, 4, 30), "2024-01-31 2024-02-29 2024-03-31 2024-04-30"},
+		{"day before start skips the first month, end included", late, date(2024, 12, 31), "2024-02-05 2024-03-05 2024-04-05 2024-05-05"},
+		{"every two weeks, count", weekly, date(2024, 12, 31), "2024-01-03 2024-01-17 2024-01-31"},
+		{"yearly from a leap day", leap, date(2028, 3, 1), "2024-02-29 2025-02-28 2026-02-28 2027-02-28 2028-02-29"},
+		{"through before start", rent, date(2023, 12, 31), ""},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, occurrence := range test.rule.Occurrences(test.through) {
+			got = append(got, occurrence.Format("2006-01-02"))
+		}
+		if strings.Join(got, " ") != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, strings.Join(got, " "))
+		}
+	}
+
+	if next, ok := weekly.Next(date(2024, 1, 17)); !ok || !next.Equal(date(2024, 1, 31)) {
+		t.Errorf("Expected the next occurrence on 2024-01-31, got %v (%v)", next, ok)
+	}
+	if _, ok := weekly.Next(date(2024, 1, 31)); ok {
+		t.Error("Expected no occurrence after the last one")
+	}
+	if key := rent.OccurrenceKey(date(2024, 2, 29)); key != "LOYER@2024-02-29" {
+		t.Errorf("Expected the key LOYER@2024-02-29, got %s", key)
+	}
+}
+
+func TestRecurringRule_Validate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	before := start.AddDate(0, 0, -1)
+	valid := RecurringRule{ID: "LOYER", Amount: NewMoney(-85000, "EUR"), Frequency: Monthly, Day: 5, Start: start}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected a valid rule, got %v", err)
+	}
+
+	invalid := map[string]func(r *RecurringRule){
+		"unknown frequency": func(r *RecurringRule) { r.Frequency = "hourly" },
+		"no start":          func(r *RecurringRule) { r.Start = time.Time{} },
+		"negative count":    func(r *RecurringRule) { r.Count = -1 },
+		"day out of range":  func(r *RecurringRule) { r.Day = 32 },
+		"day of a weekly":   func(r *RecurringRule) { r.Frequency = Weekly },
+		"end before start":  func(r *RecurringRule) { r.End = &before },
+		"zero amount":       func(r *RecurringRule) { r.Amount = NewMoney(0, "EUR") },
+	}
+	for name, change := range invalid {
+		rule := valid
+		change(&rule)
+		if err := rule.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if len(rule.Occurrences(start.AddDate(1, 0, 0))) != 0 {
+			t.Errorf("%s: expected no occurrences for an invalid rule", name)
+		}
+	}
+}
@@ -30,6 +30,7 @@ type ComptesError struct {
 	Code    string
 	Message string
 	Cause   error
+	Column  int // Position (from 1) in the parsed input, for syntax errors; 0 otherwise
 }
 
 // Error implements the error interface
@@ -74,23 +75,50 @@ const (
 	CodeInvalidAmount       = "invalid_amount"
 	CodeInvalidDate         = "invalid_date"
 	CodeMissingField        = "missing_field"
+	CodeCurrencyMismatch    = "currency_mismatch"
+	CodeInvalidTransfer     = "invalid_transfer"
+	CodeInvalidSplit        = "invalid_split"
+	CodeInvalidExchangeRate = "invalid_exchange_rate"
+	CodeRateNotFound        = "exchange_rate_not_found"
+	CodeInvalidCode         = "invalid_code"
+	CodeDuplicateCode       = "duplicate_code"
+	CodeInvalidCategory     = "invalid_category"
+	CodeInvalidBudget       = "invalid_budget"
+	CodeBudgetNotFound      = "budget_not_found"
+	CodeInvalidRule         = "invalid_recurring_rule"
+	CodeRuleNotFound        = "recurring_rule_not_found"
 
 	// Storage error codes
 	CodeStorageReadFailed  = "storage_read_failed"
 	CodeStorageWriteFailed = "storage_write_failed"
 	CodeStorageInitFailed  = "storage_init_failed"
+	CodeStorageLocked      = "storage_locked"
+	CodeSchemaTooNew       = "schema_too_new"
+	CodeSchemaOutdated     = "schema_outdated"
+	CodeMigrationFailed    = "migration_failed"
+	CodeWrongPassphrase    = "wrong_passphrase"
+	CodePassphraseRequired = "passphrase_required"
+	CodeAlreadyEncrypted   = "already_encrypted"
+	CodeNotEncrypted       = "not_encrypted"
+	CodeBackupFailed       = "backup_failed"
+	CodeInvalidBackup      = "invalid_backup"
+	CodeAlreadyInitialized = "already_initialized"
 
 	// Business logic error codes
 	CodeTransactionAlreadyDeleted = "transaction_already_deleted"
 	CodeInvalidOperation          = "invalid_operation"
 	CodeAmbiguousID               = "ambiguous_id"
 	CodeParentNotFound            = "parent_not_found"
+	CodeCodeInUse                 = "code_in_use"
+	CodeAccountClosed             = "account_closed"
+	CodeAccountNotEmpty           = "account_not_empty"
 
 	// User input error codes
-	CodeMissingArguments = "missing_arguments"
-	CodeMissingMessage   = "missing_message"
-	CodeInvalidJSON      = "invalid_json"
-	CodeInvalidCommand   = "invalid_command"
+	CodeMissingArguments  = "missing_arguments"
+	CodeMissingMessage    = "missing_message"
+	CodeInvalidJSON       = "invalid_json"
+	CodeInvalidCommand    = "invalid_command"
+	CodeInvalidExpression = "invalid_expression"
 
 	// System error codes
 	CodeConfigLoadFailed = "config_load_failed"
@@ -120,6 +148,58 @@ func AmbiguousID(partialID string) *ComptesError {
 	return New(ErrorTypeValidation, CodeAmbiguousID, fmt.Sprintf("Multiple transactions found with ID starting with: %s (be more specific)", partialID))
 }
 
+func InvalidCode(code string) *ComptesError {
+	return New(ErrorTypeValidation, CodeInvalidCode, fmt.Sprintf("Invalid code %q (letters, digits, '_' and '-' only)", code))
+}
+
+func DuplicateCode(kind, code string) *ComptesError {
+	return New(ErrorTypeValidation, CodeDuplicateCode, fmt.Sprintf("A %s with code %s already exists", kind, code))
+}
+
+func CurrencyMismatch(accountID, expected, got string) *ComptesError {
+	return New(ErrorTypeValidation, CodeCurrencyMismatch, fmt.Sprintf("Account %s uses %s, amount is in %s", accountID, expected, got))
+}
+
+func InvalidAmount(amount string, cause error) *ComptesError {
+	return Wrap(ErrorTypeValidation, CodeInvalidAmount, fmt.Sprintf("Invalid amount: %s", amount), cause)
+}
+
+func InvalidDate(date string, cause error) *ComptesError {
+	return Wrap(ErrorTypeValidation, CodeInvalidDate, fmt.Sprintf("Invalid date: %s", date), cause)
+}
+
+func InvalidBudget(cause error) *ComptesError {
+	return Wrap(ErrorTypeValidation, CodeInvalidBudget, "Invalid budget", cause)
+}
+
+func BudgetNotFound(key string) *ComptesError {
+	return New(ErrorTypeValidation, CodeBudgetNotFound, fmt.Sprintf("Budget not found: %s", key))
+}
+
+func InvalidRule(id string, cause error) *ComptesError {
+	return Wrap(ErrorTypeValidation, CodeInvalidRule, fmt.Sprintf("Invalid recurring rule %s", id), cause)
+}
+
+func RuleNotFound(id string) *ComptesError {
+	return New(ErrorTypeValidation, CodeRuleNotFound, fmt.Sprintf("Recurring rule not found: %s", id))
+}
+
+func RuleIDUsed(id string) *ComptesError {
+	return New(ErrorTypeValidation, CodeDuplicateCode, fmt.Sprintf("Movements were already posted by a removed recurring rule %s, choose another ID", id))
+}
+
+func SplitMismatch(amount, total string) *ComptesError {
+	return New(ErrorTypeValidation, CodeInvalidSplit, fmt.Sprintf("Split lines add up to %s but the transaction amount is %s", total, amount))
+}
+
+func InvalidExchangeRate(rate string, cause error) *ComptesError {
+	return Wrap(ErrorTypeValidation, CodeInvalidExchangeRate, fmt.Sprintf("Invalid exchange rate: %s", rate), cause)
+}
+
+func RateNotFound(from, to string) *ComptesError {
+	return New(ErrorTypeValidation, CodeRateNotFound, fmt.Sprintf("No exchange rate from %s to %s", from, to))
+}
+
 // Storage errors
 func StorageReadFailed(resource string, cause error) *ComptesError {
 	return Wrap(ErrorTypeStorage, CodeStorageReadFailed, fmt.Sprintf("Failed to read %s", resource), cause)
@@ -129,6 +209,46 @@ func StorageWriteFailed(resource string, cause error) *ComptesError {
 	return Wrap(ErrorTypeStorage, CodeStorageWriteFailed, fmt.Sprintf("Failed to write %s", resource), cause)
 }
 
+func StorageLocked(dataDir string) *ComptesError {
+	return New(ErrorTypeStorage, CodeStorageLocked, fmt.Sprintf("Data directory %s is locked by another comptes process, try again once it has finished", dataDir))
+}
+
+func SchemaTooNew(dataDir string, version, supported int) *ComptesError {
+	return New(ErrorTypeStorage, CodeSchemaTooNew, fmt.Sprintf("Data directory %s uses schema version %d, this version of comptes only supports up to %d: upgrade comptes", dataDir, version, supported))
+}
+
+func SchemaOutdated(dataDir string, version, current int) *ComptesError {
+	return New(ErrorTypeStorage, CodeSchemaOutdated, fmt.Sprintf("Data directory %s uses schema version %d, this version of comptes needs %d: run 'comptes migrate'", dataDir, version, current))
+}
+
+func MigrationFailed(version int, description string, cause error) *ComptesError {
+	return Wrap(ErrorTypeStorage, CodeMigrationFailed, fmt.Sprintf("Migration to schema version %d (%s) failed", version, description), cause)
+}
+
+func WrongPassphrase() *ComptesError {
+	return New(ErrorTypeUserInput, CodeWrongPassphrase, "Wrong passphrase for the encrypted data directory")
+}
+
+func PassphraseRequired() *ComptesError {
+	return New(ErrorTypeUserInput, CodePassphraseRequired, "A passphrase is required for an encrypted data directory (prompt or COMPTES_PASSPHRASE)")
+}
+
+func AlreadyEncrypted(dataDir string) *ComptesError {
+	return New(ErrorTypeBusiness, CodeAlreadyEncrypted, fmt.Sprintf("Data directory %s is already encrypted", dataDir))
+}
+
+func NotEncrypted(dataDir string) *ComptesError {
+	return New(ErrorTypeBusiness, CodeNotEncrypted, fmt.Sprintf("Data directory %s is not encrypted", dataDir))
+}
+
+func BackupFailed(cause error) *ComptesError {
+	return Wrap(ErrorTypeStorage, CodeBackupFailed, "Failed to back up the data directory", cause)
+}
+
+func InvalidBackup(archive string, cause error) *ComptesError {
+	return Wrap(ErrorTypeStorage, CodeInvalidBackup, fmt.Sprintf("Invalid backup archive %s", archive), cause)
+}
+
 // Business logic errors
 func TransactionAlreadyDeleted(transactionID string) *ComptesError {
 	return New(ErrorTypeBusiness, CodeTransactionAlreadyDeleted, fmt.Sprintf("Transaction %s is already deleted", transactionID))
@@ -142,6 +262,18 @@ func ParentNotFound(parentID string) *ComptesError {
 	return New(ErrorTypeBusiness, CodeParentNotFound, fmt.Sprintf("Parent transaction %s not found", parentID))
 }
 
+func CodeInUse(kind, code, usedBy string) *ComptesError {
+	return New(ErrorTypeBusiness, CodeCodeInUse, fmt.Sprintf("Cannot remove %s %s: it is used by %s", kind, code, usedBy))
+}
+
+func AccountClosed(accountID string) *ComptesError {
+	return New(ErrorTypeBusiness, CodeAccountClosed, fmt.Sprintf("Account %s is closed (reopen it with 'comptes accounts reopen %s')", accountID, accountID))
+}
+
+func AccountNotEmpty(accountID, balance string) *ComptesError {
+	return New(ErrorTypeBusiness, CodeAccountNotEmpty, fmt.Sprintf("Cannot close account %s: its balance is %s (move it with --transfer-to <account>)", accountID, balance))
+}
+
 // User input errors
 func MissingArguments(command string) *ComptesError {
 	return New(ErrorTypeUserInput, CodeMissingArguments, fmt.Sprintf("Missing arguments for command: %s", command))
@@ -159,6 +291,12 @@ func InvalidCommand(command string) *ComptesError {
 	return New(ErrorTypeUserInput, CodeInvalidCommand, fmt.Sprintf("Unknown command: %s", command))
 }
 
+func InvalidExpression(column int, message string) *ComptesError {
+	err := New(ErrorTypeUserInput, CodeInvalidExpression, fmt.Sprintf("Invalid expression at column %d: %s", column, message))
+	err.Column = column
+	return err
+}
+
 // System errors
 func ConfigLoadFailed(cause error) *ComptesError {
 	return Wrap(ErrorTypeSystem, CodeConfigLoadFailed, "Failed to load configuration", cause)
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AccountService handles the lifecycle of accounts: creation, renaming, closing and reopening
+type AccountService struct {
+	storage            storage.Storage
+	transactionService *TransactionService
+}
+
+// NewAccountService creates a new account service
+func NewAccountService(storage storage.Storage, transactionService *TransactionService) *AccountService {
+	return &AccountService{
+		storage:            storage,
+		transactionService: transactionService,
+	}
+}
+
+// AddAccount creates an open account. The ID follows the rules of category codes, the
+// currency is required and the initial balance, if any, must be in that currency.
+func (s *AccountService) AddAccount(account domain.Account) error {
+	if !codePattern.MatchString(account.ID) {
+		return errors.InvalidCode(account.ID)
+	}
+	account.Currency = strings.ToUpper(strings.TrimSpace(account.Currency))
+	if account.Currency == "" {
+		return errors.New(errors.ErrorTypeValidation, errors.CodeMissingField, "Account currency is required")
+	}
+	if account.InitialBalance.Currency != "" && account.InitialBalance.Currency != account.Currency {
+		return errors.CurrencyMismatch(account.ID, account.Currency, account.InitialBalance.Currency)
+	}
+	account.InitialBalance = account.InitialBalance.WithCurrency(account.Currency)
+	if account.Name == "" {
+		account.Name = account.ID
+	}
+	account.IsActive = true
+	account.CreatedAt = time.Now()
+
+	return s.storage.Atomically(func() error {
+		accounts, err := s.storage.GetAccounts()
+		if err != nil {
+			return errors.StorageReadFailed("accounts", err)
+		}
+		if _, err := findAccount(accounts, account.ID); err == nil {
+			return errors.New(errors.ErrorTypeValidation, errors.CodeDuplicateCode, fmt.Sprintf("Account %s already exists", account.ID))
+		}
+		if err := s.storage.SaveAccounts(append(accounts, account)); err != nil {
+			return errors.StorageWriteFailed("accounts", err)
+		}
+		return nil
+	})
+}
+
+// RenameAccount changes the display name of an account (its ID, used by movements, stays)
+func (s *AccountService) RenameAccount(accountID, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New(errors.ErrorTypeValidation, errors.CodeMissingField, "Account name is required")
+	}
+	return s.updateAccount(accountID, func(account *domain.Account) error {
+		account.Name = name
+		return nil
+	})
+}
+
+// ReopenAccount lets a closed account receive movements again
+func (s *AccountService) ReopenAccount(accountID string) error {
+	return s.updateAccount(accountID, func(account *domain.Account) error {
+		if account.IsActive {
+			return errors.New(errors.ErrorTypeBusiness, errors.CodeInvalidOperation, fmt.Sprintf("Account %s is not closed", accountID))
+		}
+		account.IsActive = true
+		return nil
+	})
+}
+
+// CloseAccount closes an account whose balance is zero. With transferTo, a remaining
+// balance is first moved to (or, when negative, covered from) that account, which must
+// be open and use the same currency; the closing transfer legs are returned.
+func (s *AccountService) CloseAccount(accountID, transferTo string) ([]domain.Transaction, error) {
+	var legs []domain.Transaction
+	err := s.storage.Atomically(func() error {
+		accounts, err := s.storage.GetAccounts()
+		if err != nil {
+			return errors.StorageReadFailed("accounts", err)
+		}
+		account, err := findAccount(accounts, accountID)
+		if err != nil {
+			return err
+		}
+		if !account.IsActive {
+			return errors.AccountClosed(accountID)
+		}
+
+		balance, err := s.storage.GetAccountBalance(accountID)
+		if err != nil {
+			return errors.StorageReadFailed("accounts", err)
+		}
+		if !balance.IsZero() {
+			if transferTo == "" {
+				return errors.AccountNotEmpty(accountID, balance.String())
+			}
+			legs, err = s.closingTransfer(accounts, *account, balance, transferTo)
+			if err != nil {
+				return err
+			}
+			transactions, err := s.storage.GetTransactions()
+			if err != nil {
+				return errors.StorageReadFailed("transactions", err)
+			}
+			if err := s.storage.SaveTransactions(append(transactions, legs...)); err != nil {
+				return errors.StorageWriteFailed("transactions", err)
+			}
+		}
+
+		account.IsActive = false
+		if err := s.storage.SaveAccounts(accounts); err != nil {
+			return errors.StorageWriteFailed("accounts", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return legs, nil
+}
+
+// closingTransfer builds the transfer bringing the balance of account to zero
+func (s *AccountService) closingTransfer(accounts []domain.Account, account domain.Account, balance domain.Money, transferTo string) ([]domain.Transaction, error) {
+	target, err := findAccount(accounts, transferTo)
+	if err != nil {
+		return nil, err
+	}
+	if target.Currency != account.Currency {
+		return nil, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidTransfer,
+			fmt.Sprintf("The closing transfer must go to an account in %s (%s uses %s)", account.Currency, target.ID, target.Currency))
+	}
+
+	request := TransferRequest{
+		From:        account.ID,
+		To:          target.ID,
+		Amount:      balance,
+		Description: fmt.Sprintf("Closing of account %s", account.ID),
+	}
+	if balance.IsNegative() {
+		request.From, request.To, request.Amount = target.ID, account.ID, balance.Neg()
+	}
+	return s.transactionService.NewTransfer(request)
+}
+
+func (s *AccountService) updateAccount(accountID string, update func(*domain.Account) error) error {
+	return s.storage.Atomically(func() error {
+		accounts, err := s.storage.GetAccounts()
+		if err != nil {
+			return errors.StorageReadFailed("accounts", err)
+		}
+		account, err := findAccount(accounts, accountID)
+		if err != nil {
+			return err
+		}
+		if err := update(account); err != nil {
+			return err
+		}
+		if err := s.storage.SaveAccounts(accounts); err != nil {
+			return errors.StorageWriteFailed("accounts", err)
+		}
+		return nil
+	})
+}
+
+// findAccount returns a pointer into accounts
+func findAccount(accounts []domain.Account, accountID string) (*domain.Account, error) {
+	for i := range accounts {
+		if accounts[i].ID == accountID {
+			return &accounts[i], nil
+		}
+	}
+	return nil, errors.AccountNotFound(accountID)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+)
+
+func TestAccountService_AddAccount(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}},
+	}
+	service := NewAccountService(mockStorage, NewTransactionService(mockStorage))
+
+	if err := service.AddAccount(domain.Account{ID: "PEA", Currency: "eur", InitialBalance: domain.MustParseMoney("50", "")}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	added := mockStorage.accounts[1]
+	if !added.IsActive || added.Currency != "EUR" || added.InitialBalance != domain.NewMoney(5000, "EUR") || added.Name != "PEA" {
+		t.Errorf("Unexpected account %+v", added)
+	}
+
+	if err := service.AddAccount(domain.Account{ID: "PEA", Currency: "EUR"}); !hasErrorCode(err, errors.CodeDuplicateCode) {
+		t.Errorf("Expected duplicate_code, got %v", err)
+	}
+	if err := service.AddAccount(domain.Account{ID: "CTO"}); !hasErrorCode(err, errors.CodeMissingField) {
+		t.Errorf("Expected missing_field without currency, got %v", err)
+	}
+}
+
+func TestAccountService_CloseRequiresZeroBalance(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", InitialBalance: domain.NewMoney(10000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: true},
+			{ID: "USD", Name: "Dollars", Currency: "USD", IsActive: true},
+		},
+	}
+	transactionService := NewTransactionService(mockStorage)
+	service := NewAccountService(mockStorage, transactionService)
+
+	if _, err := service.CloseAccount("BANQUE", ""); !hasErrorCode(err, errors.CodeAccountNotEmpty) {
+		t.Fatalf("Expected account_not_empty, got %v", err)
+	}
+	if _, err := service.CloseAccount("BANQUE", "USD"); err == nil {
+		t.Error("Expected a closing transfer to another currency to be refused")
+	}
+
+	legs, err := service.CloseAccount("BANQUE", "LIVRET")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(legs) != 2 || legs[0].Account != "BANQUE" || legs[0].Amount != domain.NewMoney(-10000, "EUR") {
+		t.Errorf("Expected a closing transfer of 100.00 EUR from BANQUE, got %+v", legs)
+	}
+	if balance, _ := mockStorage.GetAccountBalance("BANQUE"); !balance.IsZero() {
+		t.Errorf("Expected BANQUE at zero, got %s", balance)
+	}
+	if mockStorage.accounts[0].IsActive {
+		t.Error("Expected BANQUE to be closed")
+	}
+
+	// Closed accounts reject movements until reopened
+	err = transactionService.AddTransaction(domain.Transaction{ID: "txn", Account: "BANQUE", Amount: domain.NewMoney(-100, "EUR"), IsActive: true})
+	if !hasErrorCode(err, errors.CodeAccountClosed) {
+		t.Errorf("Expected account_closed, got %v", err)
+	}
+	if err := transactionService.DeleteTransaction(legs[0].ID, "oops"); !hasErrorCode(err, errors.CodeAccountClosed) {
+		t.Errorf("Expected deleting the closing transfer to be refused, got %v", err)
+	}
+	if err := service.ReopenAccount("BANQUE"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := transactionService.AddTransaction(domain.Transaction{ID: "txn", Account: "BANQUE", Amount: domain.NewMoney(-100, "EUR"), IsActive: true}); err != nil {
+		t.Errorf("Expected a reopened account to accept movements, got %v", err)
+	}
+}
+
+func TestAccountService_CloseCoversNegativeBalance(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true},
+			{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: true},
+		},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Account: "LIVRET", Amount: domain.NewMoney(-2500, "EUR"), IsActive: true},
+		},
+	}
+	service := NewAccountService(mockStorage, NewTransactionService(mockStorage))
+
+	legs, err := service.CloseAccount("LIVRET", "BANQUE")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if legs[0].Account != "BANQUE" || legs[1].Account != "LIVRET" || legs[1].Amount != domain.NewMoney(2500, "EUR") {
+		t.Errorf("Expected BANQUE to cover 25.00 EUR, got %+v", legs)
+	}
+	if balance, _ := mockStorage.GetAccountBalance("LIVRET"); !balance.IsZero() {
+		t.Errorf("Expected LIVRET at zero, got %s", balance)
+	}
+}
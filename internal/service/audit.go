@@ -0,0 +1,30 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// recordAudit appends an entry to the audit log (call it inside the unit of work
+// that makes the change)
+func recordAudit(s storage.Storage, action, details string, transactions []string) (*domain.AuditEntry, error) {
+	entries, err := s.GetAuditLog()
+	if err != nil {
+		return nil, errors.StorageReadFailed("audit", err)
+	}
+	entry := domain.AuditEntry{
+		ID:           uuid.New().String(),
+		Time:         time.Now(),
+		Action:       action,
+		Details:      details,
+		Transactions: transactions,
+	}
+	if err := s.SaveAuditLog(append(entries, entry)); err != nil {
+		return nil, errors.StorageWriteFailed("audit", err)
+	}
+	return &entry, nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrendInterval is the spacing of the points of a balance trend
+type TrendInterval string
+
+const (
+	TrendDaily   TrendInterval = "daily"
+	TrendWeekly  TrendInterval = "weekly"
+	TrendMonthly TrendInterval = "monthly"
+)
+
+// ParseTrendInterval validates an interval given on the command line
+func ParseTrendInterval(value string) (TrendInterval, error) {
+	switch i := TrendInterval(strings.ToLower(value)); i {
+	case TrendDaily, TrendWeekly, TrendMonthly:
+		return i, nil
+	}
+	return "", errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand,
+		fmt.Sprintf("Unknown interval %q (daily, weekly or monthly)", value))
+}
+
+// BalanceTrend holds the balances of accounts at the end of regular intervals
+type BalanceTrend struct {
+	Interval TrendInterval  `json:"interval"`
+	Dates    []time.Time    `json:"dates"` // Last day of each interval, the last one cut at the end of the period
+	Accounts []AccountTrend `json:"accounts"`
+}
+
+// AccountTrend holds the balances of an account, one per date of the trend
+type AccountTrend struct {
+	AccountID string         `json:"account_id"`
+	Name      string         `json:"name"`
+	Currency  string         `json:"currency"`
+	Balances  []domain.Money `json:"balances"`
+}
+
+// BalanceTrend returns the balance of accounts at the end of each interval of the period:
+// the initial balance plus the active movements dated up to that day. Without accountIDs,
+// every open account is included.
+func (s *TransactionService) BalanceTrend(accountIDs []string, period Period, interval TrendInterval) (*BalanceTrend, error) {
+	accounts, err := s.trendAccounts(accountIDs)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.datedMovements(LedgerView{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each interval ends before the boundary following it
+	var boundaries []time.Time
+	trend := &BalanceTrend{Interval: interval}
+	for start := period.From; start.Before(period.To); {
+		end := nextBoundary(start, interval)
+		if end.After(period.To) {
+			end = period.To
+		}
+		boundaries = append(boundaries, end)
+		trend.Dates = append(trend.Dates, end.AddDate(0, 0, -1))
+		start = end
+	}
+
+	for _, account := range accounts {
+		line := AccountTrend{AccountID: account.ID, Name: account.Name, Currency: account.Currency}
+		balance := account.InitialBalance.WithCurrency(account.Currency)
+		next := 0
+		for _, boundary := range boundaries {
+			for ; next < len(transactions) && movementDay(transactions[next]).Before(boundary); next++ {
+				if transactions[next].Account == account.ID {
+					balance = balance.Add(transactions[next].Amount)
+				}
+			}
+			line.Balances = append(line.Balances, balance)
+		}
+		trend.Accounts = append(trend.Accounts, line)
+	}
+	return trend, nil
+}
+
+// RunningBalances returns, for each active movement of view, the balance of its account
+// just after it: the initial balance plus the active movements of the account in date
+// order (movements of the same date keep their recording order)
+func (s *TransactionService) RunningBalances(view LedgerView) (map[string]domain.Money, error) {
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return nil, errors.StorageReadFailed("accounts", err)
+	}
+	transactions, err := s.datedMovements(view)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]domain.Money, len(accounts))
+	for _, account := range accounts {
+		balances[account.ID] = account.InitialBalance.WithCurrency(account.Currency)
+	}
+	running := make(map[string]domain.Money, len(transactions))
+	for _, txn := range transactions {
+		balances[txn.Account] = balances[txn.Account].Add(txn.Amount)
+		running[txn.ID] = balances[txn.Account]
+	}
+	return running, nil
+}
+
+// SortByDate orders movements by date, keeping the recording order for the same date
+func SortByDate(transactions []domain.Transaction) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return movementDay(transactions[i]).Before(movementDay(transactions[j]))
+	})
+}
+
+// datedMovements returns the active movements of view sorted by date
+func (s *TransactionService) datedMovements(view LedgerView) ([]domain.Transaction, error) {
+	transactions, err := viewMovements(s.storage, storage.TransactionQuery{}, view)
+	if err != nil {
+		return nil, err
+	}
+	SortByDate(transactions)
+	return transactions, nil
+}
+
+func (s *TransactionService) trendAccounts(accountIDs []string) ([]domain.Account, error) {
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return nil, errors.StorageReadFailed("accounts", err)
+	}
+	if len(accountIDs) == 0 {
+		var open []domain.Account
+		for _, account := range accounts {
+			if account.IsActive {
+				open = append(open, account)
+			}
+		}
+		return open, nil
+	}
+
+	selected := make([]domain.Account, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		account, err := findAccount(accounts, id)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, *account)
+	}
+	return selected, nil
+}
+
+// movementDay returns the calendar day of a movement, comparable with Period bounds
+func movementDay(t domain.Transaction) time.Time {
+	return storage.Day(t.Date)
+}
+
+// nextBoundary returns the first day of the interval following the one containing day
+func nextBoundary(day time.Time, interval TrendInterval) time.Time {
+	switch interval {
+	case TrendDaily:
+		return day.AddDate(0, 0, 1)
+	case TrendWeekly:
+		// Weeks start on Monday
+		return day.AddDate(0, 0, 7-(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	}
+}
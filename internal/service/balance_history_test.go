@@ -0,0 +1,105 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestTransactionService_BalanceTrend(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 10, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: true},
+			{ID: "OLD", Name: "Ancien", Currency: "EUR", IsActive: false},
+		},
+		// Recorded out of date order
+		transactions: []domain.Transaction{
+			{ID: "feb", Account: "BANQUE", Date: day(2, 10), Amount: domain.NewMoney(-20000, "EUR"), IsActive: true},
+			{ID: "jan", Account: "BANQUE", Date: day(1, 15), Amount: domain.NewMoney(-5000, "EUR"), IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: day(1, 20), Amount: domain.NewMoney(-99900, "EUR"), IsActive: false},
+			{ID: "save", Account: "LIVRET", Date: day(2, 10), Amount: domain.NewMoney(20000, "EUR"), IsActive: true},
+			{ID: "apr", Account: "BANQUE", Date: day(4, 1), Amount: domain.NewMoney(1000, "EUR"), IsActive: true},
+		},
+	}
+	service := NewTransactionService(mockStorage)
+	period, _ := RangePeriod(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+
+	trend, err := service.BalanceTrend(nil, period, TrendMonthly)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	dates := []string{"2024-01-31", "2024-02-29", "2024-03-15"}
+	if len(trend.Dates) != len(dates) {
+		t.Fatalf("Expected dates %v, got %v", dates, trend.Dates)
+	}
+	for i, date := range dates {
+		if trend.Dates[i].Format("2006-01-02") != date {
+			t.Errorf("Expected date %d to be %s, got %s", i, date, trend.Dates[i].Format("2006-01-02"))
+		}
+	}
+	if len(trend.Accounts) != 2 {
+		t.Fatalf("Expected the two open accounts, got %+v", trend.Accounts)
+	}
+	expected := []string{"950.00 EUR", "750.00 EUR", "750.00 EUR"}
+	for i, balance := range trend.Accounts[0].Balances {
+		if balance.String() != expected[i] {
+			t.Errorf("Expected BANQUE %s on %s, got %s", expected[i], dates[i], balance)
+		}
+	}
+	if trend.Accounts[1].Balances[1].String() != "200.00 EUR" {
+		t.Errorf("Expected LIVRET 200.00 EUR at the end of February, got %s", trend.Accounts[1].Balances[1])
+	}
+
+	// 2024-02-05 is a Monday: weeks end on Sunday, the last one is cut at the period end
+	period, _ = RangePeriod(time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC))
+	trend, err = service.BalanceTrend([]string{"BANQUE", "OLD"}, period, TrendWeekly)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(trend.Dates) != 2 || trend.Dates[0].Format("2006-01-02") != "2024-02-11" || trend.Dates[1].Format("2006-01-02") != "2024-02-14" {
+		t.Errorf("Expected 2024-02-11 and 2024-02-14, got %v", trend.Dates)
+	}
+	if len(trend.Accounts) != 2 || trend.Accounts[0].Balances[0].String() != "750.00 EUR" {
+		t.Errorf("Expected BANQUE at 750.00 EUR and the closed account listed, got %+v", trend.Accounts)
+	}
+
+	if _, err := service.BalanceTrend([]string{"NOPE"}, period, TrendDaily); err == nil {
+		t.Error("Expected an unknown account to be refused")
+	}
+}
+
+func TestTransactionService_RunningBalances(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 10, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: true},
+			{ID: "OLD", Name: "Ancien", Currency: "EUR", IsActive: false},
+		},
+		// Recorded out of date order
+		transactions: []domain.Transaction{
+			{ID: "feb", Account: "BANQUE", Date: day(2, 10), Amount: domain.NewMoney(-20000, "EUR"), IsActive: true},
+			{ID: "jan", Account: "BANQUE", Date: day(1, 15), Amount: domain.NewMoney(-5000, "EUR"), IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: day(1, 20), Amount: domain.NewMoney(-99900, "EUR"), IsActive: false},
+			{ID: "save", Account: "LIVRET", Date: day(2, 10), Amount: domain.NewMoney(20000, "EUR"), IsActive: true},
+			{ID: "apr", Account: "BANQUE", Date: day(4, 1), Amount: domain.NewMoney(1000, "EUR"), IsActive: true},
+		},
+	}
+	service := NewTransactionService(mockStorage)
+
+	balances, err := service.RunningBalances(LedgerView{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]string{"jan": "950.00 EUR", "feb": "750.00 EUR", "apr": "760.00 EUR", "save": "200.00 EUR"}
+	for id, balance := range expected {
+		if balances[id].String() != balance {
+			t.Errorf("Expected %s after %s, got %s", balance, id, balances[id])
+		}
+	}
+	if _, exists := balances["deleted"]; exists {
+		t.Error("Expected no running balance for a deleted movement")
+	}
+}
@@ -26,6 +26,12 @@ func NewTransactionBatchService(storage storage.Storage, transactionService *Tra
 
 // BeginTransaction creates a new pending transaction batch
 func (s *TransactionBatchService) BeginTransaction(description string) (*domain.TransactionBatch, error) {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return nil, err
+	}
+	defer s.storage.Unlock()
+
 	batch := domain.TransactionBatch{
 		ID:           uuid.New().String(),
 		Description:  description,
@@ -82,6 +88,17 @@ func (s *TransactionBatchService) GetPendingBatchByID(id string) (*domain.Transa
 
 // AddTransactionToBatch adds a transaction to a pending batch
 func (s *TransactionBatchService) AddTransactionToBatch(batchID string, transaction domain.Transaction) error {
+	return s.AddTransactionsToBatch(batchID, transaction)
+}
+
+// AddTransactionsToBatch adds several transactions (e.g. both legs of a transfer) to a pending batch in one save
+func (s *TransactionBatchService) AddTransactionsToBatch(batchID string, transactions ...domain.Transaction) error {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return err
+	}
+	defer s.storage.Unlock()
+
 	// Get pending batches
 	batches, err := s.storage.GetPendingBatches()
 	if err != nil {
@@ -92,26 +109,28 @@ func (s *TransactionBatchService) AddTransactionToBatch(batchID string, transact
 	var batchFound bool
 	for i, batch := range batches {
 		if strings.HasPrefix(batch.ID, batchID) {
-			// Generate ID if not provided
-			if transaction.ID == "" {
-				transaction.ID = uuid.New().String()
-			}
-
-			// Set timestamps if not provided
-			if transaction.CreatedAt.IsZero() {
-				transaction.CreatedAt = time.Now()
-			}
-			if transaction.UpdatedAt.IsZero() {
-				transaction.UpdatedAt = time.Now()
+			for _, transaction := range transactions {
+				// Generate ID if not provided
+				if transaction.ID == "" {
+					transaction.ID = uuid.New().String()
+				}
+
+				// Set timestamps if not provided
+				if transaction.CreatedAt.IsZero() {
+					transaction.CreatedAt = time.Now()
+				}
+				if transaction.UpdatedAt.IsZero() {
+					transaction.UpdatedAt = time.Now()
+				}
+				if transaction.Date.IsZero() {
+					transaction.Date = time.Now()
+				}
+
+				transaction.IsActive = true
+
+				// Add transaction to batch
+				batches[i].Transactions = append(batches[i].Transactions, transaction)
 			}
-			if transaction.Date.IsZero() {
-				transaction.Date = time.Now()
-			}
-
-			transaction.IsActive = true
-
-			// Add transaction to batch
-			batches[i].Transactions = append(batches[i].Transactions, transaction)
 			batchFound = true
 			break
 		}
@@ -129,8 +148,15 @@ func (s *TransactionBatchService) AddTransactionToBatch(batchID string, transact
 	return nil
 }
 
-// CommitBatch commits a pending batch by adding all transactions to the main transactions file
+// CommitBatch commits a pending batch by adding all transactions to the main transactions file.
+// Movements, pending and committed batches are written as one unit of work: all or nothing.
 func (s *TransactionBatchService) CommitBatch(batchID string) error {
+	return s.storage.Atomically(func() error {
+		return s.commitBatch(batchID)
+	})
+}
+
+func (s *TransactionBatchService) commitBatch(batchID string) error {
 	// Get pending batches
 	batches, err := s.storage.GetPendingBatches()
 	if err != nil {
@@ -155,10 +181,12 @@ func (s *TransactionBatchService) CommitBatch(batchID string) error {
 	}
 
 	// Validate all transactions in the batch
-	for _, transaction := range batch.Transactions {
-		if err := s.transactionService.ValidateTransaction(transaction); err != nil {
+	for i, transaction := range batch.Transactions {
+		prepared, err := s.transactionService.PrepareTransaction(transaction)
+		if err != nil {
 			return errors.Wrap(errors.ErrorTypeValidation, "validation_failed", "Transaction validation failed in batch", err)
 		}
+		batch.Transactions[i] = prepared
 	}
 
 	// Get existing transactions
@@ -208,8 +236,14 @@ func (s *TransactionBatchService) CommitBatch(batchID string) error {
 	return nil
 }
 
-// RollbackBatch rolls back a pending batch
+// RollbackBatch rolls back a pending batch (pending and rolled back batches are written as one unit of work)
 func (s *TransactionBatchService) RollbackBatch(batchID string) error {
+	return s.storage.Atomically(func() error {
+		return s.rollbackBatch(batchID)
+	})
+}
+
+func (s *TransactionBatchService) rollbackBatch(batchID string) error {
 	// Get pending batches
 	batches, err := s.storage.GetPendingBatches()
 	if err != nil {
@@ -264,6 +298,96 @@ func (s *TransactionBatchService) RollbackBatch(batchID string) error {
 	return nil
 }
 
+// RecoverBatches repairs batches left inconsistent by a commit interrupted before units
+// of work existed: a pending batch already in committed batches is dropped, and a pending
+// batch whose movements were all saved is moved to committed batches. It returns the
+// number of batches repaired. It runs before every command, so the directory is only
+// locked when there is something to repair.
+func (s *TransactionBatchService) RecoverBatches() (int, error) {
+	// Cheap check first, without the lock: most runs have nothing to repair
+	if _, _, repaired, err := s.repairBatches(); err != nil || repaired == 0 {
+		return 0, err
+	}
+
+	repaired := 0
+	err := s.storage.Atomically(func() error {
+		pending, committed, count, err := s.repairBatches()
+		if err != nil || count == 0 {
+			return err
+		}
+		repaired = count
+
+		if err := s.storage.SavePendingBatches(pending); err != nil {
+			return errors.StorageWriteFailed("pending_transactions", err)
+		}
+		if err := s.storage.SaveCommittedBatches(committed); err != nil {
+			return errors.StorageWriteFailed("committed_transactions", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return repaired, nil
+}
+
+// repairBatches returns the pending and committed batches as RecoverBatches would leave
+// them, and the number of batches repaired
+func (s *TransactionBatchService) repairBatches() ([]domain.TransactionBatch, []domain.TransactionBatch, int, error) {
+	pending, err := s.storage.GetPendingBatches()
+	if err != nil {
+		return nil, nil, 0, errors.StorageReadFailed("pending_transactions", err)
+	}
+	if len(pending) == 0 {
+		return nil, nil, 0, nil
+	}
+
+	committed, err := s.storage.GetCommittedBatches()
+	if err != nil {
+		return nil, nil, 0, errors.StorageReadFailed("committed_transactions", err)
+	}
+	transactions, err := s.storage.GetTransactions()
+	if err != nil {
+		return nil, nil, 0, errors.StorageReadFailed("transactions", err)
+	}
+
+	committedIDs := make(map[string]bool, len(committed))
+	for _, batch := range committed {
+		committedIDs[batch.ID] = true
+	}
+	saved := make(map[string]bool, len(transactions))
+	for _, transaction := range transactions {
+		saved[transaction.ID] = true
+	}
+
+	repaired := 0
+	var stillPending []domain.TransactionBatch
+	for _, batch := range pending {
+		switch {
+		case committedIDs[batch.ID]:
+			repaired++
+		case len(batch.Transactions) > 0 && allSaved(batch.Transactions, saved):
+			now := time.Now()
+			batch.CommittedAt = &now
+			committed = append(committed, batch)
+			repaired++
+		default:
+			stillPending = append(stillPending, batch)
+		}
+	}
+	return stillPending, committed, repaired, nil
+}
+
+// allSaved reports whether every transaction of a batch is already among the movements
+func allSaved(transactions []domain.Transaction, saved map[string]bool) bool {
+	for _, transaction := range transactions {
+		if !saved[transaction.ID] {
+			return false
+		}
+	}
+	return true
+}
+
 // GetCommittedBatches returns all committed transaction batches
 func (s *TransactionBatchService) GetCommittedBatches() ([]domain.TransactionBatch, error) {
 	return s.storage.GetCommittedBatches()
@@ -273,3 +397,34 @@ func (s *TransactionBatchService) GetCommittedBatches() ([]domain.TransactionBat
 func (s *TransactionBatchService) GetRolledBackBatches() ([]domain.TransactionBatch, error) {
 	return s.storage.GetRolledBackBatches()
 }
+
+// rewriteBatches moves the references to code over to target in the movements of the
+// pending, committed and rolled back batches
+func rewriteBatches(store storage.Storage, kind, code, target string, now time.Time) error {
+	batchStores := []struct {
+		name string
+		get  func() ([]domain.TransactionBatch, error)
+		save func([]domain.TransactionBatch) error
+	}{
+		{"pending_transactions", store.GetPendingBatches, store.SavePendingBatches},
+		{"committed_batches", store.GetCommittedBatches, store.SaveCommittedBatches},
+		{"rolled_back_batches", store.GetRolledBackBatches, store.SaveRolledBackBatches},
+	}
+	for _, batchStore := range batchStores {
+		batches, err := batchStore.get()
+		if err != nil {
+			return errors.StorageReadFailed(batchStore.name, err)
+		}
+		var rewritten int
+		for i := range batches {
+			rewritten += len(remapReferences(batches[i].Transactions, kind, code, target, now))
+		}
+		if rewritten == 0 {
+			continue
+		}
+		if err := batchStore.save(batches); err != nil {
+			return errors.StorageWriteFailed(batchStore.name, err)
+		}
+	}
+	return nil
+}
@@ -25,7 +25,7 @@ func NewMockStorageForBatch() *MockStorageForBatch {
 					Name:           "Test Account",
 					Type:           "checking",
 					Currency:       "EUR",
-					InitialBalance: 1000.0,
+					InitialBalance: domain.NewMoney(100000, "EUR"),
 					IsActive:       true,
 					CreatedAt:      time.Now(),
 				},
@@ -246,11 +246,15 @@ func TestBatchService_GetPendingBatchByID_Ambiguous(t *testing.T) {
 	batchService := NewTransactionBatchService(mockStorage, transactionService)
 
 	// Create two batches with similar IDs
-	batch1, _ := batchService.BeginTransaction("Batch 1")
+	batchService.BeginTransaction("Batch 1")
 	batchService.BeginTransaction("Batch 2")
 
-	// Try to find with ambiguous partial ID (first character only)
-	partialID := batch1.ID[:1]
+	// Force a shared prefix: random UUIDs rarely share their first character
+	mockStorage.pendingBatches[0].ID = "abc11111-0000-0000-0000-000000000000"
+	mockStorage.pendingBatches[1].ID = "abc22222-0000-0000-0000-000000000000"
+
+	// Try to find with ambiguous partial ID
+	partialID := "abc"
 	_, err := batchService.GetPendingBatchByID(partialID)
 	if err == nil {
 		t.Error("Expected error for ambiguous ID, got nil")
@@ -314,7 +318,7 @@ func TestBatchService_AddTransactionToBatch(t *testing.T) {
 	// Create a transaction
 	transaction := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test transaction",
 		Categories:  []string{"ALM"},
 		Tags:        []string{"REC"},
@@ -364,7 +368,7 @@ func TestBatchService_AddTransactionToBatch_WithExistingID(t *testing.T) {
 	transaction := domain.Transaction{
 		ID:          existingID,
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test transaction",
 		IsActive:    true,
 	}
@@ -393,13 +397,13 @@ func TestBatchService_AddTransactionToBatch_MultipleTransactions(t *testing.T) {
 	// Add multiple transactions
 	transaction1 := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Transaction 1",
 		IsActive:    true,
 	}
 	transaction2 := domain.Transaction{
 		Account:     "account1",
-		Amount:      -25.0,
+		Amount:      domain.NewMoney(-2500, "EUR"),
 		Description: "Transaction 2",
 		IsActive:    true,
 	}
@@ -421,7 +425,7 @@ func TestBatchService_AddTransactionToBatch_NotFound(t *testing.T) {
 
 	transaction := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test transaction",
 		IsActive:    true,
 	}
@@ -443,7 +447,7 @@ func TestBatchService_AddTransactionToBatch_PartialID(t *testing.T) {
 
 	transaction := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test transaction",
 		IsActive:    true,
 	}
@@ -508,14 +512,14 @@ func TestBatchService_CommitBatch_WithTransactions(t *testing.T) {
 	// Add transactions
 	transaction1 := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Transaction 1",
 		Categories:  []string{"ALM"},
 		IsActive:    true,
 	}
 	transaction2 := domain.Transaction{
 		Account:     "account1",
-		Amount:      -25.0,
+		Amount:      domain.NewMoney(-2500, "EUR"),
 		Description: "Transaction 2",
 		Tags:        []string{"REC"},
 		IsActive:    true,
@@ -558,7 +562,7 @@ func TestBatchService_CommitBatch_ValidationFailure(t *testing.T) {
 	// Add invalid transaction (non-existent account)
 	invalidTransaction := domain.Transaction{
 		Account:     "nonexistent",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Invalid transaction",
 		IsActive:    true,
 	}
@@ -680,7 +684,7 @@ func TestBatchService_RollbackBatch(t *testing.T) {
 	// Add transactions
 	transaction := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test transaction",
 		IsActive:    true,
 	}
@@ -811,7 +815,7 @@ func TestBatchService_CommitBatch_Atomicity(t *testing.T) {
 	// Add valid and invalid transactions
 	validTransaction := domain.Transaction{
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Valid transaction",
 		Categories:  []string{"ALM"},
 		IsActive:    true,
@@ -819,7 +823,7 @@ func TestBatchService_CommitBatch_Atomicity(t *testing.T) {
 
 	invalidTransaction := domain.Transaction{
 		Account:     "nonexistent",
-		Amount:      -25.0,
+		Amount:      domain.NewMoney(-2500, "EUR"),
 		Description: "Invalid transaction",
 		IsActive:    true,
 	}
@@ -845,3 +849,58 @@ func TestBatchService_CommitBatch_Atomicity(t *testing.T) {
 		t.Errorf("Expected 1 pending batch, got %d", len(pendingBatches))
 	}
 }
+
+func TestBatchService_RecoverBatches(t *testing.T) {
+	mockStorage := NewMockStorageForBatch()
+	batchService := NewTransactionBatchService(mockStorage, NewTransactionService(mockStorage))
+
+	saved := domain.Transaction{ID: "t1", Account: "account1", Amount: domain.NewMoney(-5000, "EUR"), IsActive: true}
+	unsaved := domain.Transaction{ID: "t2", Account: "account1", Amount: domain.NewMoney(-2500, "EUR"), IsActive: true}
+	mockStorage.transactions = []domain.Transaction{saved}
+	mockStorage.pendingBatches = []domain.TransactionBatch{
+		{ID: "half-committed", Transactions: []domain.Transaction{saved}},
+		{ID: "already-committed", Transactions: []domain.Transaction{saved}},
+		{ID: "still-pending", Transactions: []domain.Transaction{saved, unsaved}},
+	}
+	mockStorage.committedBatches = []domain.TransactionBatch{{ID: "already-committed"}}
+
+	repaired, err := batchService.RecoverBatches()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if repaired != 2 {
+		t.Errorf("Expected 2 batches repaired, got %d", repaired)
+	}
+	if len(mockStorage.pendingBatches) != 1 || mockStorage.pendingBatches[0].ID != "still-pending" {
+		t.Errorf("Expected only still-pending to remain pending, got %+v", mockStorage.pendingBatches)
+	}
+	if len(mockStorage.committedBatches) != 2 || mockStorage.committedBatches[1].ID != "half-committed" || mockStorage.committedBatches[1].CommittedAt == nil {
+		t.Errorf("Expected half-committed to be moved to committed batches, got %+v", mockStorage.committedBatches)
+	}
+	if len(mockStorage.transactions) != 1 {
+		t.Errorf("Expected movements to be left untouched, got %d", len(mockStorage.transactions))
+	}
+
+	// Nothing left to repair: no lock is needed, so another process holding it is no error
+	locked := NewTransactionBatchService(lockedStorage{mockStorage}, NewTransactionService(mockStorage))
+	if repaired, err := locked.RecoverBatches(); err != nil || repaired != 0 {
+		t.Errorf("Expected nothing to repair without the lock, got %d (%v)", repaired, err)
+	}
+	mockStorage.pendingBatches = append(mockStorage.pendingBatches, domain.TransactionBatch{ID: "interrupted", Transactions: []domain.Transaction{saved}})
+	if _, err := locked.RecoverBatches(); !hasErrorCode(err, errors.CodeStorageLocked) {
+		t.Errorf("Expected a repair to need the lock, got %v", err)
+	}
+}
+
+// lockedStorage is a storage whose directory lock is held by another process
+type lockedStorage struct {
+	*MockStorageForBatch
+}
+
+func (lockedStorage) Lock() error {
+	return errors.StorageLocked("data")
+}
+
+func (lockedStorage) Atomically(fn func() error) error {
+	return errors.StorageLocked("data")
+}
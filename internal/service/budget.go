@@ -0,0 +1,152 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BudgetService manages the budgets of the data directory (see domain.Budget)
+type BudgetService struct {
+	storage storage.Storage
+}
+
+// NewBudgetService creates a new budget service
+func NewBudgetService(storage storage.Storage) *BudgetService {
+	return &BudgetService{
+		storage: storage,
+	}
+}
+
+// Budgets returns the budgets sorted by category and first day
+func (s *BudgetService) Budgets() ([]domain.Budget, error) {
+	budgets, err := s.storage.GetBudgets()
+	if err != nil {
+		return nil, errors.StorageReadFailed("budgets", err)
+	}
+	sortBudgets(budgets)
+	return budgets, nil
+}
+
+// SetBudget adds a budget, or replaces the budget of the same category starting the same day
+func (s *BudgetService) SetBudget(budget domain.Budget) error {
+	budget.Category = strings.ToUpper(budget.Category)
+	if budget.Period == "" {
+		budget.Period = domain.BudgetMonthly
+	}
+
+	return s.storage.Atomically(func() error {
+		categories, err := s.storage.GetCategories()
+		if err != nil {
+			return errors.StorageReadFailed("categories", err)
+		}
+		if findCode(categories, budget.Category) < 0 {
+			return errors.CategoryNotFound(budget.Category)
+		}
+
+		budgets, err := s.storage.GetBudgets()
+		if err != nil {
+			return errors.StorageReadFailed("budgets", err)
+		}
+		replaced := false
+		for i := range budgets {
+			if budgets[i].Key() == budget.Key() {
+				budgets[i] = budget
+				replaced = true
+			}
+		}
+		if !replaced {
+			budgets = append(budgets, budget)
+		}
+		if err := domain.ValidateBudgets(budgets); err != nil {
+			return errors.InvalidBudget(err)
+		}
+		sortBudgets(budgets)
+		if err := s.storage.SaveBudgets(budgets); err != nil {
+			return errors.StorageWriteFailed("budgets", err)
+		}
+		return nil
+	})
+}
+
+// RemoveBudget removes the budget of a category starting on validFrom. With a zero
+// validFrom, the category must have a single budget.
+func (s *BudgetService) RemoveBudget(category string, validFrom time.Time) (*domain.Budget, error) {
+	category = strings.ToUpper(category)
+	var removed *domain.Budget
+	err := s.storage.Atomically(func() error {
+		budgets, err := s.storage.GetBudgets()
+		if err != nil {
+			return errors.StorageReadFailed("budgets", err)
+		}
+		var matches []int
+		for i, budget := range budgets {
+			if budget.Category == category && (validFrom.IsZero() || sameDay(budget.ValidFrom, validFrom)) {
+				matches = append(matches, i)
+			}
+		}
+		if len(matches) == 0 {
+			key := category
+			if !validFrom.IsZero() {
+				key = domain.Budget{Category: category, ValidFrom: validFrom}.Key()
+			}
+			return errors.BudgetNotFound(key)
+		}
+		if len(matches) > 1 {
+			return errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidOperation,
+				fmt.Sprintf("%s has %d budgets: give the first day of the one to remove with --from", category, len(matches)))
+		}
+
+		budget := budgets[matches[0]]
+		removed = &budget
+		budgets = append(budgets[:matches[0]], budgets[matches[0]+1:]...)
+		if err := s.storage.SaveBudgets(budgets); err != nil {
+			return errors.StorageWriteFailed("budgets", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// rewriteBudgets moves the budgets of category code over to target, refusing the change
+// when target already has a budget over the same days
+func rewriteBudgets(store storage.Storage, code, target string) error {
+	budgets, err := store.GetBudgets()
+	if err != nil {
+		return errors.StorageReadFailed("budgets", err)
+	}
+	changed := false
+	for i := range budgets {
+		if budgets[i].Category == code {
+			budgets[i].Category = target
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := domain.ValidateBudgets(budgets); err != nil {
+		return errors.InvalidBudget(err)
+	}
+	sortBudgets(budgets)
+	if err := store.SaveBudgets(budgets); err != nil {
+		return errors.StorageWriteFailed("budgets", err)
+	}
+	return nil
+}
+
+func sortBudgets(budgets []domain.Budget) {
+	sort.SliceStable(budgets, func(i, j int) bool {
+		if budgets[i].Category != budgets[j].Category {
+			return budgets[i].Category < budgets[j].Category
+		}
+		return budgets[i].ValidFrom.Before(budgets[j].ValidFrom)
+	})
+}
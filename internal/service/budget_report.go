@@ -0,0 +1,177 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"math/big"
+	"time"
+)
+
+// BudgetLine compares the budget of a category with its spending, subcategories included
+type BudgetLine struct {
+	Code      string       `json:"code"`
+	Name      string       `json:"name"`
+	Parent    string       `json:"parent,omitempty"`
+	Depth     int          `json:"depth"`
+	Budgeted  domain.Money `json:"budgeted"`
+	Spent     domain.Money `json:"spent"`     // Expenses less refunds
+	Remaining domain.Money `json:"remaining"` // Negative when overspent
+	Projected domain.Money `json:"projected"` // Spending expected by the end of the period at the current pace
+	RolledUp  bool         `json:"rolled_up,omitempty"`
+}
+
+// BudgetReport is the budget-vs-actual of the budgeted categories over a period. A category
+// without a budget of its own whose subcategories have one shows the sum of their budgets
+// (RolledUp). Total counts the top-most budgeted categories once.
+type BudgetReport struct {
+	Period     Period       `json:"period"`
+	Currency   string       `json:"currency"`
+	RateDate   RateDate     `json:"rate_date"`
+	Lines      []BudgetLine `json:"categories"`
+	Total      BudgetLine   `json:"total"`
+	Unbudgeted domain.Money `json:"unbudgeted"`         // Spending outside the budgeted categories
+	Excluded   []string     `json:"excluded,omitempty"` // Movements without an exchange rate to Currency
+}
+
+// BudgetReport compares the budgets allocated over period (see domain.Budget.Allocated)
+// with the movements selected by filter, as CategoryReport counts and converts them. Budget
+// amounts are converted at the rate of the first day of the period, or at today's rate
+// with RateAtToday. Spending is projected to the end of the period from the pace up to
+// today when today falls in it.
+func (s *ReportService) BudgetReport(filter TransactionFilter, period Period, currency string, rateDate RateDate, today time.Time) (*BudgetReport, error) {
+	budgets, err := s.storage.GetBudgets()
+	if err != nil {
+		return nil, errors.StorageReadFailed("budgets", err)
+	}
+	categories, transactions, rates, err := s.load(filter, period)
+	if err != nil {
+		return nil, err
+	}
+
+	zero := domain.NewMoney(0, currency)
+	own := make(map[string]domain.Money)
+	for _, budget := range budgets {
+		allocated := budget.Allocated(period.From, period.To)
+		if allocated.IsZero() {
+			continue
+		}
+		converted, err := convert(rates, allocated, currency, rateDate.at(period.From))
+		if err != nil {
+			return nil, err
+		}
+		if current, exists := own[budget.Category]; exists {
+			converted = converted.Add(current)
+		}
+		own[budget.Category] = converted
+	}
+
+	tree := domain.NewCategoryTree(categories)
+	byCode := make(map[string]domain.Category, len(categories))
+	for _, category := range categories {
+		byCode[category.Code] = category
+	}
+	budgeted := make(map[string]domain.Money)
+	var allocate func(code string) (domain.Money, bool)
+	allocate = func(code string) (domain.Money, bool) {
+		if amount, exists := own[code]; exists {
+			budgeted[code] = amount
+			return amount, true
+		}
+		sum, found := zero, false
+		for _, child := range byCode[code].Children {
+			if amount, ok := allocate(child); ok {
+				sum, found = sum.Add(amount), true
+			}
+		}
+		if found {
+			budgeted[code] = sum
+		}
+		return sum, found
+	}
+
+	report := &BudgetReport{Period: period, Currency: currency, RateDate: rateDate, Unbudgeted: zero}
+	total := BudgetLine{Name: "Total", Budgeted: zero, Spent: zero}
+	for _, category := range categories {
+		if category.Parent == nil {
+			if amount, ok := allocate(category.Code); ok {
+				total.Budgeted = total.Budgeted.Add(amount)
+			}
+		}
+	}
+
+	r := newRollup(tree, currency, rates, rateDate, filter.Categories)
+	for _, txn := range transactions {
+		r.add(txn)
+	}
+	spent := func(code string) domain.Money {
+		if a, exists := r.byCategory[code]; exists {
+			return a.income.Add(a.expenses).Neg()
+		}
+		return zero
+	}
+
+	tree.Walk(func(category domain.Category, depth int) {
+		amount, exists := budgeted[category.Code]
+		if !exists {
+			return
+		}
+		_, ownBudget := own[category.Code]
+		report.Lines = append(report.Lines, budgetLine(BudgetLine{
+			Code: category.Code, Name: category.Name, Parent: stringValue(category.Parent), Depth: depth,
+			Budgeted: amount, Spent: spent(category.Code), RolledUp: !ownBudget,
+		}, period, today))
+	})
+
+	// Each line counts once in Total or Unbudgeted, even with several categories
+	for _, txn := range transactions {
+		if containsCode(r.excluded, txn.ID) {
+			continue
+		}
+		lines, err := convertedLines(rates, txn, currency, rateDate)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			if r.within != nil && !anyCodeIn(line.Categories, r.within) {
+				continue
+			}
+			if anyBudgeted(rolledCategories(tree, line.Categories), budgeted) {
+				total.Spent = total.Spent.Sub(line.Amount)
+			} else if line.Amount.IsNegative() {
+				report.Unbudgeted = report.Unbudgeted.Sub(line.Amount)
+			}
+		}
+	}
+	report.Total = budgetLine(total, period, today)
+	report.Excluded = r.excluded
+	return report, nil
+}
+
+// budgetLine completes a line with its remaining amount and projection
+func budgetLine(line BudgetLine, period Period, today time.Time) BudgetLine {
+	line.Remaining = line.Budgeted.Sub(line.Spent)
+	line.Projected = line.Spent
+	if period.Contains(today) {
+		elapsed := int64(daysBetween(period.From, today) + 1)
+		length := int64(daysBetween(period.From, period.To))
+		pace := new(big.Rat).Mul(line.Spent.Rat(), big.NewRat(length, elapsed))
+		line.Projected = domain.MoneyFromRat(pace, line.Spent.Currency)
+	}
+	return line
+}
+
+// daysBetween returns the number of calendar days from one day to another
+func daysBetween(from, to time.Time) int {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours()/24 + 0.5)
+}
+
+func anyBudgeted(codes []string, budgeted map[string]domain.Money) bool {
+	for _, code := range codes {
+		if _, exists := budgeted[code]; exists {
+			return true
+		}
+	}
+	return false
+}
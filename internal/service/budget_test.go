@@ -0,0 +1,128 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestBudgetService_SetAndRemove(t *testing.T) {
+	mockStorage := &MockStorage{categories: []domain.Category{{Code: "ALM"}}}
+	service := NewBudgetService(mockStorage)
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	if err := service.SetBudget(domain.Budget{Category: "alm", Amount: domain.NewMoney(40000, "EUR"), ValidFrom: january, ValidTo: &june}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.SetBudget(domain.Budget{Category: "ALM", Amount: domain.NewMoney(45000, "EUR"), ValidFrom: january, ValidTo: &june}); err != nil {
+		t.Fatalf("Expected the budget to be replaced, got %v", err)
+	}
+	if len(mockStorage.budgets) != 1 || mockStorage.budgets[0].Amount.String() != "450.00 EUR" || mockStorage.budgets[0].Period != domain.BudgetMonthly {
+		t.Fatalf("Expected a single monthly budget of 450.00 EUR, got %+v", mockStorage.budgets)
+	}
+
+	err := service.SetBudget(domain.Budget{Category: "ALM", Amount: domain.NewMoney(100, "EUR"), ValidFrom: june})
+	if !hasErrorCode(err, errors.CodeInvalidBudget) {
+		t.Errorf("Expected an overlapping budget to be refused, got %v", err)
+	}
+	if err := service.SetBudget(domain.Budget{Category: "XXX", Amount: domain.NewMoney(100, "EUR"), ValidFrom: january}); err == nil {
+		t.Error("Expected a budget on an unknown category to be refused")
+	}
+
+	july := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	if err := service.SetBudget(domain.Budget{Category: "ALM", Amount: domain.NewMoney(50000, "EUR"), ValidFrom: july}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.RemoveBudget("ALM", time.Time{}); err == nil {
+		t.Error("Expected --from to be required when the category has several budgets")
+	}
+	removed, err := service.RemoveBudget("alm", july)
+	if err != nil || removed.Key() != "ALM@2024-07-01" || len(mockStorage.budgets) != 1 {
+		t.Errorf("Expected ALM@2024-07-01 removed, got %v, %v (%+v)", removed, err, mockStorage.budgets)
+	}
+	_, err = service.RemoveBudget("ALM", july)
+	if !hasErrorCode(err, errors.CodeBudgetNotFound) {
+		t.Errorf("Expected budget_not_found, got %v", err)
+	}
+}
+
+func TestReportService_BudgetReport(t *testing.T) {
+	parent := "ALM"
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		budgets: []domain.Budget{
+			{Category: "RES", Amount: domain.NewMoney(5000, "EUR"), Period: domain.BudgetMonthly, ValidFrom: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Category: "VET", Amount: domain.NewMoney(36600, "EUR"), Period: domain.BudgetYearly, ValidFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+			{Code: "SLR", Name: "Salaire"},
+			{Code: "VET", Name: "Vêtements"},
+		},
+		rates: []domain.ExchangeRate{{Date: day(1), From: "USD", To: "EUR", Rate: "0.5"}},
+		transactions: []domain.Transaction{
+			{ID: "salary", Account: "BANQUE", Date: day(2), Amount: domain.NewMoney(200000, "EUR"), Categories: []string{"SLR"}, IsActive: true},
+			{ID: "lunch", Account: "BANQUE", Date: day(3), Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "refund", Account: "BANQUE", Date: day(4), Amount: domain.NewMoney(1000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "shop", Account: "BANQUE", Date: day(5), Amount: domain.NewMoney(-12000, "EUR"), IsActive: true, Splits: []domain.Split{
+				{Amount: domain.NewMoney(-9000, "EUR"), Categories: []string{"ALM"}},
+				{Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"VET"}},
+			}},
+			{ID: "nyc", Account: "USDACC", Date: day(6), Amount: domain.NewMoney(-4000, "USD"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "misc", Account: "BANQUE", Date: day(7), Amount: domain.NewMoney(-500, "EUR"), IsActive: true},
+			{ID: "gbp", Account: "GBPACC", Date: day(8), Amount: domain.NewMoney(-700, "GBP"), Categories: []string{"VET"}, IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-99900, "EUR"), Categories: []string{"ALM"}, IsActive: false},
+			{ID: "transfer", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-50000, "EUR"), TransferID: "t1", IsActive: true},
+			{ID: "february", Account: "BANQUE", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-100, "EUR"), Categories: []string{"ALM"}, IsActive: true},
+		},
+	}
+	service := NewReportService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+	period, err := MonthPeriod("2024-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := service.BudgetReport(TransactionFilter{}, period, "EUR", RateAtTransactionDate, time.Date(2024, 1, 16, 18, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []struct {
+		code                                  string
+		rolledUp                              bool
+		budgeted, spent, remaining, projected string
+	}{
+		{"ALM", true, "50.00 EUR", "130.00 EUR", "-80.00 EUR", "251.88 EUR"}, // RES budget, spending of ALM and RES
+		{"RES", false, "50.00 EUR", "40.00 EUR", "10.00 EUR", "77.50 EUR"},
+		{"VET", false, "31.00 EUR", "30.00 EUR", "1.00 EUR", "58.13 EUR"}, // 31 days of a 366-day year
+	}
+	if len(report.Lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %+v", len(expected), report.Lines)
+	}
+	for i, want := range expected {
+		line := report.Lines[i]
+		if line.Code != want.code || line.RolledUp != want.rolledUp || line.Budgeted.String() != want.budgeted ||
+			line.Spent.String() != want.spent || line.Remaining.String() != want.remaining || line.Projected.String() != want.projected {
+			t.Errorf("Line %d: expected %+v, got %+v", i, want, line)
+		}
+	}
+
+	// ALM and VET are the top-most budgeted categories; the uncategorised line is unbudgeted
+	if report.Total.Budgeted.String() != "81.00 EUR" || report.Total.Spent.String() != "160.00 EUR" || report.Unbudgeted.String() != "5.00 EUR" {
+		t.Errorf("Expected a total of 81.00 budgeted, 160.00 spent and 5.00 unbudgeted, got %+v, %s", report.Total, report.Unbudgeted)
+	}
+	if len(report.Excluded) != 1 || report.Excluded[0] != "gbp" {
+		t.Errorf("Expected the GBP movement excluded, got %v", report.Excluded)
+	}
+
+	past, err := service.BudgetReport(TransactionFilter{}, period, "EUR", RateAtTransactionDate, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if past.Lines[1].Projected != past.Lines[1].Spent {
+		t.Errorf("Expected the projection of a past period to be its spending, got %+v", past.Lines[1])
+	}
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var codePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CodeService manages categories and tags: it keeps Parent and Children consistent and
+// rewrites the movements referencing a code that is renamed or merged into another one
+type CodeService struct {
+	storage storage.Storage
+}
+
+// NewCodeService creates a new category and tag service
+func NewCodeService(storage storage.Storage) *CodeService {
+	return &CodeService{
+		storage: storage,
+	}
+}
+
+// AddCategory adds a category, under its parent if it has one
+func (s *CodeService) AddCategory(category domain.Category) error {
+	return s.add(ConfigCategory, category)
+}
+
+// AddTag adds a tag, under its parent if it has one
+func (s *CodeService) AddTag(tag domain.Tag) error {
+	return s.add(ConfigTag, domain.Category(tag))
+}
+
+func (s *CodeService) add(kind string, entry domain.Category) error {
+	if !codePattern.MatchString(entry.Code) {
+		return errors.InvalidCode(entry.Code)
+	}
+	if entry.Name == "" {
+		entry.Name = entry.Code
+	}
+	entry.Children = []string{}
+
+	return s.storage.Atomically(func() error {
+		entries, err := s.load(kind)
+		if err != nil {
+			return err
+		}
+		if findCode(entries, entry.Code) >= 0 {
+			return errors.DuplicateCode(kind, entry.Code)
+		}
+		if entry.Parent != nil {
+			parent := findCode(entries, *entry.Parent)
+			if parent < 0 {
+				return codeNotFound(kind, *entry.Parent)
+			}
+			entries[parent].Children = append(entries[parent].Children, entry.Code)
+		}
+		return s.save(kind, append(entries, entry))
+	})
+}
+
+// Rename changes the code of a category or tag (kind is ConfigCategory or ConfigTag)
+// everywhere: in the hierarchy, in every movement, active or not, and in the batches
+func (s *CodeService) Rename(kind, code, newCode string) (*domain.AuditEntry, error) {
+	if !codePattern.MatchString(newCode) {
+		return nil, errors.InvalidCode(newCode)
+	}
+
+	var entry *domain.AuditEntry
+	err := s.storage.Atomically(func() error {
+		entries, err := s.load(kind)
+		if err != nil {
+			return err
+		}
+		i := findCode(entries, code)
+		if i < 0 {
+			return codeNotFound(kind, code)
+		}
+		if findCode(entries, newCode) >= 0 {
+			return errors.New(errors.ErrorTypeValidation, errors.CodeDuplicateCode,
+				fmt.Sprintf("A %s with code %s already exists (use merge to combine them)", kind, newCode))
+		}
+
+		entries[i].Code = newCode
+		for j := range entries {
+			if entries[j].Parent != nil && *entries[j].Parent == code {
+				entries[j].Parent = &newCode
+			}
+			entries[j].Children = replaceCode(entries[j].Children, code, newCode)
+		}
+		if err := s.save(kind, entries); err != nil {
+			return err
+		}
+
+		changed, err := s.rewriteReferences(kind, code, newCode)
+		if err != nil {
+			return err
+		}
+		entry, err = recordAudit(s.storage, kind+".rename", fmt.Sprintf("%s %s renamed to %s", kind, code, newCode), changed)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Merge moves every reference to code over to into and removes code. Its children move
+// under into; when into was below code in the hierarchy, into takes its place.
+func (s *CodeService) Merge(kind, code, into string) (*domain.AuditEntry, error) {
+	if code == into {
+		return nil, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidOperation,
+			fmt.Sprintf("Cannot merge %s %s into itself", kind, code))
+	}
+
+	var entry *domain.AuditEntry
+	err := s.storage.Atomically(func() error {
+		entries, err := s.load(kind)
+		if err != nil {
+			return err
+		}
+		from := findCode(entries, code)
+		if from < 0 {
+			return codeNotFound(kind, code)
+		}
+		target := findCode(entries, into)
+		if target < 0 {
+			return codeNotFound(kind, into)
+		}
+
+		removed := entries[from]
+		if isDescendant(entries, into, code) {
+			setParent(entries, target, removed.Parent)
+		}
+		for _, child := range removed.Children {
+			if child != into {
+				setParent(entries, findCode(entries, child), &into)
+			}
+		}
+		setParent(entries, from, nil)
+		entries = append(entries[:from], entries[from+1:]...)
+		if err := s.save(kind, entries); err != nil {
+			return err
+		}
+
+		changed, err := s.rewriteReferences(kind, code, into)
+		if err != nil {
+			return err
+		}
+		entry, err = recordAudit(s.storage, kind+".merge", fmt.Sprintf("%s %s merged into %s", kind, code, into), changed)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Remove deletes a category or tag that no movement or batch uses and that has no children
+func (s *CodeService) Remove(kind, code string) error {
+	return s.storage.Atomically(func() error {
+		entries, err := s.load(kind)
+		if err != nil {
+			return err
+		}
+		i := findCode(entries, code)
+		if i < 0 {
+			return codeNotFound(kind, code)
+		}
+		if len(entries[i].Children) > 0 {
+			return errors.CodeInUse(kind, code, "its children "+strings.Join(entries[i].Children, ", "))
+		}
+
+		transactions, err := s.storage.GetTransactions()
+		if err != nil {
+			return errors.StorageReadFailed("transactions", err)
+		}
+		if count := countReferences(transactions, kind, code); count > 0 {
+			return errors.CodeInUse(kind, code, fmt.Sprintf("%d movements (merge it into another %s instead)", count, kind))
+		}
+		pending, err := s.storage.GetPendingBatches()
+		if err != nil {
+			return errors.StorageReadFailed("pending_transactions", err)
+		}
+		for _, batch := range pending {
+			if countReferences(batch.Transactions, kind, code) > 0 {
+				return errors.CodeInUse(kind, code, "pending batch "+batch.ID)
+			}
+		}
+		if kind == ConfigCategory {
+			budgets, err := s.storage.GetBudgets()
+			if err != nil {
+				return errors.StorageReadFailed("budgets", err)
+			}
+			for _, budget := range budgets {
+				if budget.Category == code {
+					return errors.CodeInUse(kind, code, "budget "+budget.Key())
+				}
+			}
+			assignments, err := s.storage.GetEnvelopeAssignments()
+			if err != nil {
+				return errors.StorageReadFailed("envelopes", err)
+			}
+			if count := countEnvelopeAssignments(assignments, code); count > 0 {
+				return errors.CodeInUse(kind, code, fmt.Sprintf("%d envelope assignments (merge it into another %s instead)", count, kind))
+			}
+		}
+		rules, err := s.storage.GetRecurringRules()
+		if err != nil {
+			return errors.StorageReadFailed("recurring", err)
+		}
+		if rule := ruleReferencing(rules, kind, code); rule != "" {
+			return errors.CodeInUse(kind, code, "recurring rule "+rule)
+		}
+
+		setParent(entries, i, nil)
+		return s.save(kind, append(entries[:i], entries[i+1:]...))
+	})
+}
+
+// rewriteReferences moves the references to code over to target in the movements, in
+// the copies kept by batches, in budgets, envelope assignments and recurring rules; it
+// returns the IDs of the movements changed
+func (s *CodeService) rewriteReferences(kind, code, target string) ([]string, error) {
+	if err := rewriteRules(s.storage, kind, code, target); err != nil {
+		return nil, err
+	}
+	if kind == ConfigCategory {
+		if err := rewriteBudgets(s.storage, code, target); err != nil {
+			return nil, err
+		}
+		if err := rewriteEnvelopes(s.storage, code, target); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	transactions, err := s.storage.GetTransactions()
+	if err != nil {
+		return nil, errors.StorageReadFailed("transactions", err)
+	}
+	changed := remapReferences(transactions, kind, code, target, now)
+	if len(changed) > 0 {
+		if err := s.storage.SaveTransactions(transactions); err != nil {
+			return nil, errors.StorageWriteFailed("transactions", err)
+		}
+	}
+
+	if err := rewriteBatches(s.storage, kind, code, target, now); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// load returns the categories or the tags; tags share the layout of categories
+func (s *CodeService) load(kind string) ([]domain.Category, error) {
+	if kind == ConfigTag {
+		tags, err := s.storage.GetTags()
+		if err != nil {
+			return nil, errors.StorageReadFailed("tags", err)
+		}
+		entries := make([]domain.Category, len(tags))
+		for i, tag := range tags {
+			entries[i] = domain.Category(tag)
+		}
+		return entries, nil
+	}
+	categories, err := s.storage.GetCategories()
+	if err != nil {
+		return nil, errors.StorageReadFailed("categories", err)
+	}
+	return append([]domain.Category(nil), categories...), nil
+}
+
+func (s *CodeService) save(kind string, entries []domain.Category) error {
+	if kind == ConfigTag {
+		tags := make([]domain.Tag, len(entries))
+		for i, entry := range entries {
+			tags[i] = domain.Tag(entry)
+		}
+		if err := s.storage.SaveTags(tags); err != nil {
+			return errors.StorageWriteFailed("tags", err)
+		}
+		return nil
+	}
+	if err := s.storage.SaveCategories(entries); err != nil {
+		return errors.StorageWriteFailed("categories", err)
+	}
+	return nil
+}
+
+func codeNotFound(kind, code string) error {
+	if kind == ConfigTag {
+		return errors.TagNotFound(code)
+	}
+	return errors.CategoryNotFound(code)
+}
+
+func findCode(entries []domain.Category, code string) int {
+	for i, entry := range entries {
+		if entry.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// setParent moves entries[i] under parent (nil for the top level), updating the
+// Children of its old and new parents
+func setParent(entries []domain.Category, i int, parent *string) {
+	if old := entries[i].Parent; old != nil {
+		if p := findCode(entries, *old); p >= 0 {
+			entries[p].Children = removeCode(entries[p].Children, entries[i].Code)
+		}
+	}
+	entries[i].Parent = nil
+	if parent == nil {
+		return
+	}
+	if p := findCode(entries, *parent); p >= 0 {
+		code := *parent
+		entries[i].Parent = &code
+		entries[p].Children = append(entries[p].Children, entries[i].Code)
+	}
+}
+
+// isDescendant reports whether code is below ancestor in the hierarchy
+func isDescendant(entries []domain.Category, code, ancestor string) bool {
+	seen := make(map[string]bool)
+	for i := findCode(entries, code); i >= 0 && entries[i].Parent != nil && !seen[entries[i].Code]; i = findCode(entries, *entries[i].Parent) {
+		seen[entries[i].Code] = true
+		if *entries[i].Parent == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func removeCode(codes []string, code string) []string {
+	result := make([]string, 0, len(codes))
+	for _, c := range codes {
+		if c != code {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func categoryParent(categories []domain.Category, code string) string {
+	for _, category := range categories {
+		if category.Code == code {
+			return stringValue(category.Parent)
+		}
+	}
+	return "<missing>"
+}
+
+func categoryChildren(categories []domain.Category, code string) []string {
+	for _, category := range categories {
+		if category.Code == code {
+			return category.Children
+		}
+	}
+	return nil
+}
+
+func TestCodeService_AddKeepsHierarchy(t *testing.T) {
+	mockStorage := &MockStorage{categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}}}
+	service := NewCodeService(mockStorage)
+
+	parent := "ALM"
+	if err := service.AddCategory(domain.Category{Code: "RES", Name: "Restaurants", Parent: &parent}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if children := categoryChildren(mockStorage.categories, "ALM"); len(children) != 1 || children[0] != "RES" {
+		t.Errorf("Expected ALM children [RES], got %v", children)
+	}
+
+	err := service.AddCategory(domain.Category{Code: "ALM"})
+	if !hasErrorCode(err, errors.CodeDuplicateCode) {
+		t.Errorf("Expected duplicate_code, got %v", err)
+	}
+	missing := "XXX"
+	if err := service.AddCategory(domain.Category{Code: "NEW", Parent: &missing}); err == nil {
+		t.Error("Expected an unknown parent to be refused")
+	}
+	if err := service.AddTag(domain.Tag{Code: "A B"}); err == nil {
+		t.Error("Expected a code with a space to be refused")
+	}
+}
+
+func TestCodeService_RenameRewritesHistoryAndBatches(t *testing.T) {
+	parent := "LOI"
+	mockStorage := &MockStorage{
+		categories: []domain.Category{
+			{Code: "LOI", Name: "Loisirs", Children: []string{"CIN"}},
+			{Code: "CIN", Name: "Cinéma", Parent: &parent},
+		},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Categories: []string{"LOI"}, IsActive: false},
+			{ID: "txn2", Categories: []string{"ALM"}, IsActive: true,
+				Splits: []domain.Split{{Categories: []string{"ALM"}}, {Categories: []string{"LOI"}}}},
+			{ID: "txn3", Categories: []string{"ALM"}, IsActive: true},
+		},
+		budgets:   []domain.Budget{{Category: "LOI", Amount: domain.NewMoney(100, "EUR"), Period: domain.BudgetMonthly, ValidFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		envelopes: []domain.EnvelopeAssignment{{ID: "a1", Month: "2024-01", Category: "LOI", Amount: domain.NewMoney(100, "EUR")}},
+	}
+	service := NewCodeService(mockStorage)
+
+	entry, err := service.Rename(ConfigCategory, "LOI", "SRT")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entry.Transactions) != 2 || len(mockStorage.audit) != 1 || mockStorage.audit[0].Action != "category.rename" {
+		t.Errorf("Expected an audit entry for 2 movements, got %+v", mockStorage.audit)
+	}
+	if mockStorage.transactions[0].Categories[0] != "SRT" || mockStorage.transactions[1].Splits[1].Categories[0] != "SRT" {
+		t.Errorf("Expected historical movements and split lines to be renamed, got %+v", mockStorage.transactions)
+	}
+	if !mockStorage.transactions[0].UpdatedAt.IsZero() || mockStorage.transactions[1].UpdatedAt.IsZero() {
+		t.Error("Expected the deletion time of historical movements to be kept")
+	}
+	if mockStorage.budgets[0].Category != "SRT" || mockStorage.envelopes[0].Category != "SRT" {
+		t.Errorf("Expected the budget and the envelope to follow their renamed category, got %+v, %+v", mockStorage.budgets, mockStorage.envelopes)
+	}
+	if categoryParent(mockStorage.categories, "CIN") != "SRT" {
+		t.Errorf("Expected CIN to follow its renamed parent, got %q", categoryParent(mockStorage.categories, "CIN"))
+	}
+
+	if _, err := service.Rename(ConfigCategory, "SRT", "CIN"); err == nil {
+		t.Error("Expected a rename onto an existing code to be refused")
+	}
+}
+
+func TestCodeService_MergeMovesChildrenAndReferences(t *testing.T) {
+	top, mid := "ALM", "RES"
+	mockStorage := &MockStorage{
+		tags: []domain.Tag{
+			{Code: "ALM", Children: []string{"RES"}},
+			{Code: "RES", Parent: &top, Children: []string{"FST"}},
+			{Code: "FST", Parent: &mid},
+		},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Tags: []string{"ALM", "FST"}, IsActive: true},
+		},
+	}
+	service := NewCodeService(mockStorage)
+
+	// FST is below ALM: it takes its place and inherits RES
+	entry, err := service.Merge(ConfigTag, "ALM", "FST")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mockStorage.tags) != 2 {
+		t.Fatalf("Expected ALM to be removed, got %+v", mockStorage.tags)
+	}
+	tags := make([]domain.Category, len(mockStorage.tags))
+	for i, tag := range mockStorage.tags {
+		tags[i] = domain.Category(tag)
+	}
+	if categoryParent(tags, "FST") != "" || categoryParent(tags, "RES") != "FST" {
+		t.Errorf("Expected FST at the top with RES below it, got %+v", mockStorage.tags)
+	}
+	if children := categoryChildren(tags, "RES"); len(children) != 0 {
+		t.Errorf("Expected RES to lose FST as a child, got %v", children)
+	}
+	if tags := mockStorage.transactions[0].Tags; len(tags) != 1 || tags[0] != "FST" {
+		t.Errorf("Expected txn1 tags merged to [FST], got %v", tags)
+	}
+	if entry.Action != "tag.merge" {
+		t.Errorf("Expected a tag.merge audit entry, got %s", entry.Action)
+	}
+}
+
+func TestCodeService_RemoveRefusesUsedCodes(t *testing.T) {
+	parent := "ALM"
+	mockStorage := &MockStorage{
+		categories: []domain.Category{
+			{Code: "ALM", Children: []string{"RES"}},
+			{Code: "RES", Parent: &parent},
+			{Code: "LOI"},
+			{Code: "VAC"},
+			{Code: "EPA"},
+		},
+		transactions: []domain.Transaction{{ID: "txn1", Categories: []string{"LOI"}}},
+		budgets:      []domain.Budget{{Category: "VAC", Amount: domain.NewMoney(100, "EUR"), Period: domain.BudgetMonthly, ValidFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		envelopes:    []domain.EnvelopeAssignment{{ID: "a1", Month: "2024-01", Category: "EPA", Amount: domain.NewMoney(100, "EUR")}},
+	}
+	service := NewCodeService(mockStorage)
+
+	for _, code := range []string{"ALM", "LOI", "VAC", "EPA"} {
+		err := service.Remove(ConfigCategory, code)
+		if !hasErrorCode(err, errors.CodeCodeInUse) {
+			t.Errorf("Expected code_in_use removing %s, got %v", code, err)
+		}
+	}
+
+	if err := service.Remove(ConfigCategory, "RES"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mockStorage.categories) != 4 || len(categoryChildren(mockStorage.categories, "ALM")) != 0 {
+		t.Errorf("Expected RES removed from the list and from ALM children, got %+v", mockStorage.categories)
+	}
+}
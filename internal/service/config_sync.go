@@ -0,0 +1,578 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of configured items
+const (
+	ConfigAccount  = "account"
+	ConfigCategory = "category"
+	ConfigTag      = "tag"
+	ConfigBudget   = "budget"
+)
+
+// Config change actions
+const (
+	ConfigAdded   = "add"
+	ConfigChanged = "change"
+	ConfigRemoved = "remove"
+)
+
+// ConfigChange is a difference between the configuration and the data directory
+type ConfigChange struct {
+	Kind       string
+	Code       string
+	Action     string
+	Fields     []string // Fields changed, for ConfigChanged
+	References int      // Movements using a removed code, pending batches included
+	RemapTo    string   // Code the references of a removed code move to
+}
+
+// ConfigPlan is what applying a configuration would change
+type ConfigPlan struct {
+	Changes []ConfigChange
+
+	accounts   []domain.Account
+	categories []domain.Category
+	tags       []domain.Tag
+	budgets    []domain.Budget
+}
+
+// Remapped returns the number of movements the plan rewrites
+func (p *ConfigPlan) Remapped() int {
+	var total int
+	for _, change := range p.Changes {
+		if change.RemapTo != "" {
+			total += change.References
+		}
+	}
+	return total
+}
+
+// ConfigSyncService brings accounts, categories, tags and budgets of the data directory in line
+// with config.yaml without touching movements, except to remap removed codes
+type ConfigSyncService struct {
+	storage storage.Storage
+}
+
+// NewConfigSyncService creates a new config sync service
+func NewConfigSyncService(storage storage.Storage) *ConfigSyncService {
+	return &ConfigSyncService{
+		storage: storage,
+	}
+}
+
+// Plan compares the configured items with the stored ones. Stored items missing from the
+// configuration, such as codes added with the CLI, are kept unless prune is set. remaps
+// gives, for removed codes, the code their movements move to; a removed code still used
+// by movements without a remap is an error.
+func (s *ConfigSyncService) Plan(accounts []domain.Account, categories []domain.Category, tags []domain.Tag, budgets []domain.Budget, remaps map[string]string, prune bool) (*ConfigPlan, error) {
+	storedAccounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return nil, errors.StorageReadFailed("accounts", err)
+	}
+	storedCategories, err := s.storage.GetCategories()
+	if err != nil {
+		return nil, errors.StorageReadFailed("categories", err)
+	}
+	storedTags, err := s.storage.GetTags()
+	if err != nil {
+		return nil, errors.StorageReadFailed("tags", err)
+	}
+	storedBudgets, err := s.storage.GetBudgets()
+	if err != nil {
+		return nil, errors.StorageReadFailed("budgets", err)
+	}
+	assignments, err := s.storage.GetEnvelopeAssignments()
+	if err != nil {
+		return nil, errors.StorageReadFailed("envelopes", err)
+	}
+	rules, err := s.storage.GetRecurringRules()
+	if err != nil {
+		return nil, errors.StorageReadFailed("recurring", err)
+	}
+	transactions, err := s.storage.GetTransactions()
+	if err != nil {
+		return nil, errors.StorageReadFailed("transactions", err)
+	}
+	pending, err := s.storage.GetPendingBatches()
+	if err != nil {
+		return nil, errors.StorageReadFailed("pending_transactions", err)
+	}
+
+	plan := &ConfigPlan{accounts: accounts, categories: categories, tags: tags, budgets: budgets}
+
+	// Accounts keep their creation date; a currency cannot change under existing movements
+	storedByID := make(map[string]domain.Account, len(storedAccounts))
+	for _, account := range storedAccounts {
+		storedByID[account.ID] = account
+	}
+	plan.accounts = make([]domain.Account, len(accounts))
+	for i, account := range accounts {
+		stored, exists := storedByID[account.ID]
+		delete(storedByID, account.ID)
+		plan.accounts[i] = account
+		if !exists {
+			plan.Changes = append(plan.Changes, ConfigChange{Kind: ConfigAccount, Code: account.ID, Action: ConfigAdded})
+			continue
+		}
+		plan.accounts[i].CreatedAt = stored.CreatedAt
+		fields := accountChanges(stored, account)
+		if len(fields) == 0 {
+			continue
+		}
+		if stored.Currency != account.Currency && countReferences(transactions, ConfigAccount, account.ID) > 0 {
+			return nil, errors.New(errors.ErrorTypeValidation, errors.CodeCurrencyMismatch,
+				fmt.Sprintf("Account %s has movements in %s: its currency cannot change to %s", account.ID, stored.Currency, account.Currency))
+		}
+		if stored.IsActive && !account.IsActive {
+			if err := s.checkClosable(stored, account); err != nil {
+				return nil, err
+			}
+		}
+		plan.Changes = append(plan.Changes, ConfigChange{Kind: ConfigAccount, Code: account.ID, Action: ConfigChanged, Fields: fields})
+	}
+	for _, stored := range storedAccounts {
+		if _, removed := storedByID[stored.ID]; removed {
+			plan.Changes = append(plan.Changes, ConfigChange{Kind: ConfigAccount, Code: stored.ID, Action: ConfigRemoved})
+		}
+	}
+
+	plan.Changes = append(plan.Changes, diffCodes(ConfigCategory, categoryEntries(storedCategories), categoryEntries(categories))...)
+	plan.Changes = append(plan.Changes, diffCodes(ConfigTag, tagEntries(storedTags), tagEntries(tags))...)
+	plan.Changes = append(plan.Changes, diffCodes(ConfigBudget, budgetEntries(storedBudgets), budgetEntries(budgets))...)
+	if !prune {
+		if err := plan.keepRemoved(storedAccounts, storedCategories, storedTags, storedBudgets); err != nil {
+			return nil, err
+		}
+	}
+
+	// Removed codes still used need a remap to a code that stays
+	for i, change := range plan.Changes {
+		if change.Action != ConfigRemoved {
+			continue
+		}
+		movements := countReferences(transactions, change.Kind, change.Code)
+		usedBy := fmt.Sprintf("%d movements", movements)
+		batched := 0
+		for _, batch := range pending {
+			batched += countReferences(batch.Transactions, change.Kind, change.Code)
+		}
+		if batched > 0 {
+			usedBy += fmt.Sprintf(" and %d movements of pending batches", batched)
+		}
+		plan.Changes[i].References = movements + batched
+		envelopes := 0
+		if change.Kind == ConfigCategory {
+			envelopes = countEnvelopeAssignments(assignments, change.Code)
+		}
+		if envelopes > 0 {
+			usedBy += fmt.Sprintf(" and %d envelope assignments", envelopes)
+		}
+		rule := ruleReferencing(rules, change.Kind, change.Code)
+		if rule != "" {
+			usedBy += " and recurring rule " + rule
+		}
+		if plan.Changes[i].References+envelopes == 0 && rule == "" {
+			continue
+		}
+		target, ok := remaps[change.Code]
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidOperation,
+				fmt.Sprintf("Cannot remove %s %s: %s use it (remap it with --remap %s=<code>)",
+					change.Kind, change.Code, usedBy, change.Code))
+		}
+		if err := plan.checkRemapTarget(change.Kind, change.Code, target, storedAccounts); err != nil {
+			return nil, err
+		}
+		plan.Changes[i].RemapTo = target
+	}
+
+	// A remap of a code that stays is most likely a typo
+	for code := range remaps {
+		if !plan.removes(code) {
+			message := fmt.Sprintf("Cannot remap %s: the configuration does not remove it", code)
+			if !prune {
+				message += " (codes missing from the configuration are only removed with --prune)"
+			}
+			return nil, errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidOperation, message)
+		}
+	}
+	return plan, nil
+}
+
+// keepRemoved drops the removals from the plan and keeps the stored items instead
+func (p *ConfigPlan) keepRemoved(accounts []domain.Account, categories []domain.Category, tags []domain.Tag, budgets []domain.Budget) error {
+	kept := make(map[string]bool)
+	changes := p.Changes[:0]
+	for _, change := range p.Changes {
+		if change.Action == ConfigRemoved {
+			kept[change.Kind+" "+change.Code] = true
+			continue
+		}
+		changes = append(changes, change)
+	}
+	p.Changes = changes
+	if len(kept) == 0 {
+		return nil
+	}
+
+	for _, account := range accounts {
+		if kept[ConfigAccount+" "+account.ID] {
+			p.accounts = append(p.accounts, account)
+		}
+	}
+	merged := append([]domain.Category{}, p.categories...)
+	for _, category := range categories {
+		if kept[ConfigCategory+" "+category.Code] {
+			merged = append(merged, category)
+		}
+	}
+	linked, err := domain.LinkCategories(merged)
+	if err != nil {
+		return errors.New(errors.ErrorTypeValidation, errors.CodeInvalidOperation,
+			fmt.Sprintf("Cannot keep the categories missing from the configuration: %v (use --prune to remove them)", err))
+	}
+	p.categories = linked
+
+	merged = merged[:0]
+	for _, tag := range p.tags {
+		merged = append(merged, domain.Category(tag))
+	}
+	for _, tag := range tags {
+		if kept[ConfigTag+" "+tag.Code] {
+			merged = append(merged, domain.Category(tag))
+		}
+	}
+	if linked, err = domain.LinkCategories(merged); err != nil {
+		return errors.New(errors.ErrorTypeValidation, errors.CodeInvalidOperation,
+			fmt.Sprintf("Cannot keep the tags missing from the configuration: %v (use --prune to remove them)", err))
+	}
+	p.tags = make([]domain.Tag, len(linked))
+	for i, tag := range linked {
+		p.tags[i] = domain.Tag(tag)
+	}
+
+	p.budgets = append([]domain.Budget{}, p.budgets...)
+	for _, budget := range budgets {
+		if kept[ConfigBudget+" "+budget.Key()] {
+			p.budgets = append(p.budgets, budget)
+		}
+	}
+	if err := domain.ValidateBudgets(p.budgets); err != nil {
+		return errors.New(errors.ErrorTypeValidation, errors.CodeInvalidOperation,
+			fmt.Sprintf("Cannot keep the budgets missing from the configuration: %v (use --prune to remove them)", err))
+	}
+	return nil
+}
+
+func (p *ConfigPlan) removes(code string) bool {
+	for _, change := range p.Changes {
+		if change.Action == ConfigRemoved && change.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRemapTarget makes sure the references of code can move to target
+func (p *ConfigPlan) checkRemapTarget(kind, code, target string, storedAccounts []domain.Account) error {
+	switch kind {
+	case ConfigAccount:
+		var from *domain.Account
+		for i := range storedAccounts {
+			if storedAccounts[i].ID == code {
+				from = &storedAccounts[i]
+			}
+		}
+		for _, account := range p.accounts {
+			if account.ID == target {
+				if from != nil && from.Currency != account.Currency {
+					return errors.CurrencyMismatch(target, account.Currency, from.Currency)
+				}
+				return nil
+			}
+		}
+		return errors.AccountNotFound(target)
+	case ConfigCategory:
+		for _, category := range p.categories {
+			if category.Code == target {
+				return nil
+			}
+		}
+		return errors.CategoryNotFound(target)
+	default:
+		for _, tag := range p.tags {
+			if tag.Code == target {
+				return nil
+			}
+		}
+		return errors.TagNotFound(target)
+	}
+}
+
+// Apply saves the configured items and remaps the movements and batches of removed codes,
+// in one unit of work
+func (s *ConfigSyncService) Apply(plan *ConfigPlan) error {
+	return s.storage.Atomically(func() error {
+		now := time.Now()
+		for _, change := range plan.Changes {
+			if change.RemapTo == "" {
+				continue
+			}
+			if err := rewriteRules(s.storage, change.Kind, change.Code, change.RemapTo); err != nil {
+				return err
+			}
+			if err := rewriteBatches(s.storage, change.Kind, change.Code, change.RemapTo, now); err != nil {
+				return err
+			}
+			if change.Kind != ConfigCategory {
+				continue
+			}
+			if err := rewriteEnvelopes(s.storage, change.Code, change.RemapTo); err != nil {
+				return err
+			}
+		}
+		if plan.Remapped() > 0 {
+			transactions, err := s.storage.GetTransactions()
+			if err != nil {
+				return errors.StorageReadFailed("transactions", err)
+			}
+			for _, change := range plan.Changes {
+				if change.RemapTo == "" {
+					continue
+				}
+				changed := remapReferences(transactions, change.Kind, change.Code, change.RemapTo, now)
+				details := fmt.Sprintf("%s %s removed from the configuration, movements remapped to %s", change.Kind, change.Code, change.RemapTo)
+				if _, err := recordAudit(s.storage, "config.remap", details, changed); err != nil {
+					return err
+				}
+			}
+			if err := s.storage.SaveTransactions(transactions); err != nil {
+				return errors.StorageWriteFailed("transactions", err)
+			}
+		}
+
+		if err := s.storage.SaveAccounts(plan.accounts); err != nil {
+			return errors.StorageWriteFailed("accounts", err)
+		}
+		if err := s.storage.SaveCategories(plan.categories); err != nil {
+			return errors.StorageWriteFailed("categories", err)
+		}
+		if err := s.storage.SaveTags(plan.tags); err != nil {
+			return errors.StorageWriteFailed("tags", err)
+		}
+		if err := s.storage.SaveBudgets(plan.budgets); err != nil {
+			return errors.StorageWriteFailed("budgets", err)
+		}
+		return nil
+	})
+}
+
+// checkClosable applies the rule of AccountService.CloseAccount to an account closed by the
+// configuration: its balance, with the configured initial balance, must be zero
+func (s *ConfigSyncService) checkClosable(stored, configured domain.Account) error {
+	balance := configured.InitialBalance.WithCurrency(configured.Currency)
+	if stored.Currency == configured.Currency {
+		current, err := s.storage.GetAccountBalance(stored.ID)
+		if err != nil {
+			return errors.StorageReadFailed("accounts", err)
+		}
+		balance = current.Sub(stored.InitialBalance.WithCurrency(stored.Currency)).Add(balance)
+	}
+	if !balance.IsZero() {
+		return errors.New(errors.ErrorTypeBusiness, errors.CodeAccountNotEmpty,
+			fmt.Sprintf("Cannot close account %s: its balance is %s (close it with 'comptes accounts close %s --transfer-to <account>')",
+				stored.ID, balance, stored.ID))
+	}
+	return nil
+}
+
+func accountChanges(stored, configured domain.Account) []string {
+	var fields []string
+	if stored.Name != configured.Name {
+		fields = append(fields, "name")
+	}
+	if stored.Type != configured.Type {
+		fields = append(fields, "type")
+	}
+	if stored.Currency != configured.Currency {
+		fields = append(fields, "currency")
+	}
+	if stored.InitialBalance.WithCurrency(stored.Currency) != configured.InitialBalance.WithCurrency(configured.Currency) {
+		fields = append(fields, "initial_balance")
+	}
+	if stored.IsActive != configured.IsActive {
+		fields = append(fields, "is_active")
+	}
+	return fields
+}
+
+// codeEntry is a category or tag reduced to the fields compared by diffCodes
+type codeEntry struct {
+	code   string
+	fields map[string]string
+}
+
+func categoryEntries(categories []domain.Category) []codeEntry {
+	entries := make([]codeEntry, len(categories))
+	for i, category := range categories {
+		entries[i] = codeEntry{category.Code, map[string]string{
+			"name": category.Name, "description": category.Description, "parent": stringValue(category.Parent),
+		}}
+	}
+	return entries
+}
+
+func tagEntries(tags []domain.Tag) []codeEntry {
+	entries := make([]codeEntry, len(tags))
+	for i, tag := range tags {
+		entries[i] = codeEntry{tag.Code, map[string]string{
+			"name": tag.Name, "description": tag.Description, "parent": stringValue(tag.Parent),
+		}}
+	}
+	return entries
+}
+
+// budgetEntries identifies budgets by category and first day (see domain.Budget.Key)
+func budgetEntries(budgets []domain.Budget) []codeEntry {
+	entries := make([]codeEntry, len(budgets))
+	for i, budget := range budgets {
+		validTo := ""
+		if budget.ValidTo != nil {
+			validTo = budget.ValidTo.Format("2006-01-02")
+		}
+		entries[i] = codeEntry{budget.Key(), map[string]string{
+			"amount": budget.Amount.String(), "period": string(budget.Period), "valid_to": validTo, "description": budget.Description,
+		}}
+	}
+	return entries
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func diffCodes(kind string, stored, configured []codeEntry) []ConfigChange {
+	var changes []ConfigChange
+	storedByCode := make(map[string]codeEntry, len(stored))
+	for _, entry := range stored {
+		storedByCode[entry.code] = entry
+	}
+	for _, entry := range configured {
+		old, exists := storedByCode[entry.code]
+		delete(storedByCode, entry.code)
+		if !exists {
+			changes = append(changes, ConfigChange{Kind: kind, Code: entry.code, Action: ConfigAdded})
+			continue
+		}
+		var fields []string
+		for field, value := range entry.fields {
+			if old.fields[field] != value {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			sort.Strings(fields)
+			changes = append(changes, ConfigChange{Kind: kind, Code: entry.code, Action: ConfigChanged, Fields: fields})
+		}
+	}
+	for _, entry := range stored {
+		if _, removed := storedByCode[entry.code]; removed {
+			changes = append(changes, ConfigChange{Kind: kind, Code: entry.code, Action: ConfigRemoved})
+		}
+	}
+	return changes
+}
+
+// countReferences counts the movements (active or not) using a code
+func countReferences(transactions []domain.Transaction, kind, code string) int {
+	var count int
+	for _, txn := range transactions {
+		if references(txn, kind, code) {
+			count++
+		}
+	}
+	return count
+}
+
+func references(txn domain.Transaction, kind, code string) bool {
+	switch kind {
+	case ConfigAccount:
+		return txn.Account == code
+	case ConfigCategory:
+		if containsCode(txn.Categories, code) {
+			return true
+		}
+		for _, split := range txn.Splits {
+			if containsCode(split.Categories, code) {
+				return true
+			}
+		}
+	case ConfigTag:
+		if containsCode(txn.Tags, code) {
+			return true
+		}
+		for _, split := range txn.Splits {
+			if containsCode(split.Tags, code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remapReferences moves the references to code over to target in every movement and
+// returns the IDs of the movements changed
+func remapReferences(transactions []domain.Transaction, kind, code, target string, now time.Time) []string {
+	var changed []string
+	for i := range transactions {
+		txn := &transactions[i]
+		if !references(*txn, kind, code) {
+			continue
+		}
+		switch kind {
+		case ConfigAccount:
+			txn.Account = target
+		case ConfigCategory:
+			txn.Categories = replaceCode(txn.Categories, code, target)
+			for j := range txn.Splits {
+				txn.Splits[j].Categories = replaceCode(txn.Splits[j].Categories, code, target)
+			}
+		case ConfigTag:
+			txn.Tags = replaceCode(txn.Tags, code, target)
+			for j := range txn.Splits {
+				txn.Splits[j].Tags = replaceCode(txn.Splits[j].Tags, code, target)
+			}
+		}
+		// UpdatedAt of a deleted or edited movement keeps when it was replaced (see RecordedState)
+		if txn.IsActive {
+			txn.UpdatedAt = now
+		}
+		changed = append(changed, txn.ID)
+	}
+	return changed
+}
+
+// replaceCode replaces code with target, without duplicating target
+func replaceCode(codes []string, code, target string) []string {
+	result := make([]string, 0, len(codes))
+	for _, c := range codes {
+		if c == code {
+			c = target
+		}
+		if !containsCode(result, c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
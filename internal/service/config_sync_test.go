@@ -0,0 +1,275 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestConfigSyncService_PlanAndApplyKeepMovements(t *testing.T) {
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockStorage := &MockStorage{
+		accounts:   []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOI", Name: "Loisirs"}},
+		tags:       []domain.Tag{{Code: "URG", Name: "Urgent"}, {Code: "GONE"}},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-1000, "EUR"), Categories: []string{"LOI"}, IsActive: true},
+			{ID: "txn2", Account: "BANQUE", Amount: domain.NewMoney(-2000, "EUR"), Categories: []string{"ALM"}, IsActive: true},
+		},
+		budgets: []domain.Budget{{Category: "ALM", Amount: domain.NewMoney(10000, "EUR"), Period: domain.BudgetMonthly, ValidFrom: january}},
+	}
+	service := NewConfigSyncService(mockStorage)
+
+	accounts := []domain.Account{
+		{ID: "BANQUE", Name: "Compte courant", Currency: "EUR", IsActive: true},
+		{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: true},
+	}
+	categories := []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOI", Name: "Loisirs"}, {Code: "SRT", Name: "Sorties"}}
+	tags := []domain.Tag{{Code: "URG", Name: "Urgent"}, {Code: "OLD", Name: "Ancien"}}
+	budgets := []domain.Budget{
+		{Category: "ALM", Amount: domain.NewMoney(20000, "EUR"), Period: domain.BudgetMonthly, ValidFrom: january},
+		{Category: "SRT", Amount: domain.NewMoney(5000, "EUR"), Period: domain.BudgetMonthly, ValidFrom: january},
+	}
+
+	plan, err := service.Plan(accounts, categories, tags, budgets, nil, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := map[string]string{
+		"account BANQUE": ConfigChanged, "account LIVRET": ConfigAdded,
+		"category SRT": ConfigAdded, "tag OLD": ConfigAdded, "tag GONE": ConfigRemoved,
+		"budget ALM@2024-01-01": ConfigChanged, "budget SRT@2024-01-01": ConfigAdded,
+	}
+	if len(plan.Changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), plan.Changes)
+	}
+	for _, change := range plan.Changes {
+		if want[change.Kind+" "+change.Code] != change.Action {
+			t.Errorf("Unexpected change %+v", change)
+		}
+	}
+
+	if err := service.Apply(plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mockStorage.transactions) != 2 || len(mockStorage.accounts) != 2 || len(mockStorage.categories) != 3 || len(mockStorage.budgets) != 2 {
+		t.Errorf("Expected movements kept and config saved, got %d movements, %d accounts, %d categories",
+			len(mockStorage.transactions), len(mockStorage.accounts), len(mockStorage.categories))
+	}
+}
+
+func TestConfigSyncService_RemovingReferencedCodeNeedsRemap(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts:   []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOI", Name: "Loisirs"}},
+		tags:       []domain.Tag{{Code: "URG", Name: "Urgent"}},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-1000, "EUR"), Categories: []string{"LOI"}, IsActive: true},
+			{ID: "txn2", Account: "BANQUE", Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"ALM", "LOI"}, IsActive: true,
+				Splits: []domain.Split{
+					{Amount: domain.NewMoney(-2000, "EUR"), Categories: []string{"ALM"}},
+					{Amount: domain.NewMoney(-1000, "EUR"), Categories: []string{"LOI"}},
+				}},
+		},
+	}
+	service := NewConfigSyncService(mockStorage)
+	categories := []domain.Category{{Code: "ALM", Name: "Alimentation"}}
+
+	_, err := service.Plan(mockStorage.accounts, categories, mockStorage.tags, nil, nil, true)
+	if !hasErrorCode(err, errors.CodeInvalidOperation) {
+		t.Fatalf("Expected removal of a used category to be refused, got %v", err)
+	}
+
+	if _, err := service.Plan(mockStorage.accounts, categories, mockStorage.tags, nil, map[string]string{"LOI": "XXX"}, true); err == nil {
+		t.Error("Expected a remap to an unknown category to be refused")
+	}
+	if _, err := service.Plan(mockStorage.accounts, categories, mockStorage.tags, nil, map[string]string{"LOI": "ALM", "URG": "ALM"}, true); err == nil {
+		t.Error("Expected a remap of a code that stays to be refused")
+	}
+
+	plan, err := service.Plan(mockStorage.accounts, categories, mockStorage.tags, nil, map[string]string{"LOI": "ALM"}, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plan.Remapped() != 2 {
+		t.Errorf("Expected 2 movements remapped, got %d", plan.Remapped())
+	}
+	if err := service.Apply(plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	txn1, txn2 := mockStorage.transactions[0], mockStorage.transactions[1]
+	if len(txn1.Categories) != 1 || txn1.Categories[0] != "ALM" {
+		t.Errorf("Expected txn1 remapped to ALM, got %v", txn1.Categories)
+	}
+	if len(txn2.Categories) != 1 || txn2.Categories[0] != "ALM" {
+		t.Errorf("Expected txn2 categories deduplicated to ALM, got %v", txn2.Categories)
+	}
+	if txn2.Splits[1].Categories[0] != "ALM" {
+		t.Errorf("Expected split lines remapped, got %v", txn2.Splits[1].Categories)
+	}
+}
+
+func TestConfigSyncService_RemovingEnvelopeCategoryNeedsRemap(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts:   []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOI", Name: "Loisirs"}, {Code: "VAC", Name: "Vacances"}},
+		envelopes:  []domain.EnvelopeAssignment{{ID: "a1", Month: "2024-01", Category: "VAC", Amount: domain.NewMoney(10000, "EUR")}},
+	}
+	service := NewConfigSyncService(mockStorage)
+	categories := []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOI", Name: "Loisirs"}}
+
+	if _, err := service.Plan(mockStorage.accounts, categories, mockStorage.tags, nil, nil, true); err == nil {
+		t.Fatal("Expected removal of a category with envelope assignments to be refused")
+	}
+	plan, err := service.Plan(mockStorage.accounts, categories, mockStorage.tags, nil, map[string]string{"VAC": "LOI"}, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.Apply(plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockStorage.envelopes[0].Category != "LOI" {
+		t.Errorf("Expected the assignment remapped to LOI, got %+v", mockStorage.envelopes)
+	}
+}
+
+func TestConfigSyncService_AccountCurrencyCannotChangeUnderMovements(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts:     []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}},
+		transactions: []domain.Transaction{{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-1000, "EUR"), IsActive: true}},
+	}
+	service := NewConfigSyncService(mockStorage)
+
+	accounts := []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "USD", IsActive: true}}
+	if _, err := service.Plan(accounts, mockStorage.categories, mockStorage.tags, nil, nil, false); err == nil {
+		t.Error("Expected a currency change of an account with movements to be refused")
+	}
+}
+
+func TestConfigSyncService_KeepsStoredCodesWithoutPrune(t *testing.T) {
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	parent := "ALM"
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true},
+			{ID: "PEA", Name: "Plan d'épargne", Currency: "EUR", IsActive: true},
+		},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation", Children: []string{"RES"}}, {Code: "RES", Name: "Restaurants", Parent: &parent}},
+		tags:       []domain.Tag{{Code: "VAC", Name: "Vacances"}},
+		budgets:    []domain.Budget{{Category: "RES", Amount: domain.NewMoney(5000, "EUR"), Period: domain.BudgetMonthly, ValidFrom: january}},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Account: "PEA", Amount: domain.NewMoney(-1000, "EUR"), Categories: []string{"RES"}, Tags: []string{"VAC"}, IsActive: true},
+		},
+	}
+	service := NewConfigSyncService(mockStorage)
+
+	// The configuration predates the codes added with the CLI
+	accounts := []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}}
+	categories := []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOG", Name: "Logement"}}
+	plan, err := service.Plan(accounts, categories, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("Expected codes missing from the configuration to be kept, got %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Code != "LOG" || plan.Changes[0].Action != ConfigAdded {
+		t.Fatalf("Expected only LOG to be added, got %+v", plan.Changes)
+	}
+	if _, err := service.Plan(accounts, categories, nil, nil, map[string]string{"RES": "ALM"}, false); !hasErrorCode(err, errors.CodeInvalidOperation) {
+		t.Errorf("Expected a remap without --prune to be refused, got %v", err)
+	}
+
+	if err := service.Apply(plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mockStorage.accounts) != 2 || len(mockStorage.categories) != 3 || len(mockStorage.tags) != 1 || len(mockStorage.budgets) != 1 {
+		t.Errorf("Expected stored codes kept, got %+v %+v %+v %+v", mockStorage.accounts, mockStorage.categories, mockStorage.tags, mockStorage.budgets)
+	}
+	for _, category := range mockStorage.categories {
+		if category.Code == "ALM" && (len(category.Children) != 1 || category.Children[0] != "RES") {
+			t.Errorf("Expected RES kept under ALM, got %+v", category)
+		}
+	}
+
+	// With prune, the codes still used need a remap
+	if _, err := service.Plan(accounts, categories, nil, nil, nil, true); !hasErrorCode(err, errors.CodeInvalidOperation) {
+		t.Errorf("Expected pruning used codes without a remap to be refused, got %v", err)
+	}
+}
+
+func TestConfigSyncService_RemapsPendingBatches(t *testing.T) {
+	mockStorage := &MockStorageForBatch{
+		MockStorage: &MockStorage{
+			accounts:   []domain.Account{{ID: "BANQUE", Name: "Banque", Currency: "EUR", IsActive: true}},
+			categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "LOI", Name: "Loisirs"}},
+		},
+		pendingBatches: []domain.TransactionBatch{{ID: "batch1", Transactions: []domain.Transaction{
+			{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-1000, "EUR"), Categories: []string{"LOI"}, IsActive: true},
+		}}},
+		rolledBackBatches: []domain.TransactionBatch{{ID: "batch0", Transactions: []domain.Transaction{
+			{ID: "txn0", Account: "BANQUE", Amount: domain.NewMoney(-500, "EUR"), Categories: []string{"LOI"}, IsActive: true},
+		}}},
+	}
+	service := NewConfigSyncService(mockStorage)
+	categories := []domain.Category{{Code: "ALM", Name: "Alimentation"}}
+
+	// Committing the batch later would bring back the removed code
+	if _, err := service.Plan(mockStorage.accounts, categories, nil, nil, nil, true); !hasErrorCode(err, errors.CodeInvalidOperation) {
+		t.Fatalf("Expected removal of a category used by a pending batch to be refused, got %v", err)
+	}
+	plan, err := service.Plan(mockStorage.accounts, categories, nil, nil, map[string]string{"LOI": "ALM"}, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plan.Remapped() != 1 {
+		t.Errorf("Expected the movement of the pending batch counted, got %d", plan.Remapped())
+	}
+	if err := service.Apply(plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if categories := mockStorage.pendingBatches[0].Transactions[0].Categories; len(categories) != 1 || categories[0] != "ALM" {
+		t.Errorf("Expected the pending batch remapped to ALM, got %v", categories)
+	}
+	if categories := mockStorage.rolledBackBatches[0].Transactions[0].Categories; len(categories) != 1 || categories[0] != "ALM" {
+		t.Errorf("Expected the rolled back batch remapped to ALM, got %v", categories)
+	}
+}
+
+func TestConfigSyncService_ClosesAccountsAtZeroBalance(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", InitialBalance: domain.NewMoney(1000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: false},
+		},
+		transactions: []domain.Transaction{{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-400, "EUR"), IsActive: true}},
+	}
+	service := NewConfigSyncService(mockStorage)
+
+	accounts := []domain.Account{
+		{ID: "BANQUE", Name: "Banque", Currency: "EUR", InitialBalance: domain.NewMoney(1000, "EUR"), IsActive: false},
+		{ID: "LIVRET", Name: "Livret", Currency: "EUR", IsActive: true},
+	}
+	if _, err := service.Plan(accounts, nil, nil, nil, nil, false); !hasErrorCode(err, errors.CodeAccountNotEmpty) {
+		t.Fatalf("Expected closing an account with a balance to be refused, got %v", err)
+	}
+
+	// The balance counts with the configured initial balance
+	accounts[0].InitialBalance = domain.NewMoney(400, "EUR")
+	plan, err := service.Plan(accounts, nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(plan.Changes) != 2 {
+		t.Fatalf("Expected BANQUE closed and LIVRET reopened, got %+v", plan.Changes)
+	}
+	if fields := plan.Changes[1].Fields; len(fields) != 1 || fields[0] != "is_active" {
+		t.Errorf("Expected is_active changed, got %v", fields)
+	}
+	if err := service.Apply(plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockStorage.accounts[0].IsActive || !mockStorage.accounts[1].IsActive {
+		t.Errorf("Expected BANQUE closed and LIVRET open, got %+v", mockStorage.accounts)
+	}
+}
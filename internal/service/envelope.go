@@ -0,0 +1,370 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EnvelopeService manages envelope budgeting: each month, money of the pool to be budgeted
+// is assigned to the envelopes of categories, categorised movements draw the matching
+// envelope down, and what is left (or overspent) rolls over to the next month
+type EnvelopeService struct {
+	storage            storage.Storage
+	transactionService *TransactionService
+	exchangeService    *ExchangeRateService
+}
+
+// NewEnvelopeService creates a new envelope service
+func NewEnvelopeService(storage storage.Storage, transactionService *TransactionService, exchangeService *ExchangeRateService) *EnvelopeService {
+	return &EnvelopeService{
+		storage:            storage,
+		transactionService: transactionService,
+		exchangeService:    exchangeService,
+	}
+}
+
+// EnvelopeLine is the state of the envelope of a category over a month
+type EnvelopeLine struct {
+	Code      string       `json:"code"`
+	Name      string       `json:"name"`
+	Parent    string       `json:"parent,omitempty"`
+	Depth     int          `json:"depth"`
+	Carried   domain.Money `json:"carried"` // Available at the end of the previous month, negative when overspent
+	Assigned  domain.Money `json:"assigned"`
+	Activity  domain.Money `json:"activity"` // Net of the movements drawn from the envelope
+	Available domain.Money `json:"available"`
+}
+
+// EnvelopeMonth is the state of every envelope at the end of a month. ToBeBudgeted plus
+// the available amounts of the envelopes always equals the initial balances of the
+// accounts plus every movement up to the end of the month.
+type EnvelopeMonth struct {
+	Month        Period         `json:"month"`
+	Currency     string         `json:"currency"`
+	Lines        []EnvelopeLine `json:"envelopes"`
+	Total        EnvelopeLine   `json:"total"`
+	Inflow       domain.Money   `json:"inflow"`             // Movements of the month outside the envelopes (income less unbudgeted spending)
+	ToBeBudgeted domain.Money   `json:"to_be_budgeted"`     // Negative when more was assigned than there is
+	Excluded     []string       `json:"excluded,omitempty"` // Movements and accounts without an exchange rate to Currency
+}
+
+// Assignments returns the assignments of a month (YYYY-MM), or all of them when month is
+// empty, oldest first
+func (s *EnvelopeService) Assignments(month string) ([]domain.EnvelopeAssignment, error) {
+	assignments, err := s.storage.GetEnvelopeAssignments()
+	if err != nil {
+		return nil, errors.StorageReadFailed("envelopes", err)
+	}
+	var selected []domain.EnvelopeAssignment
+	for _, assignment := range assignments {
+		if month == "" || assignment.Month == month {
+			selected = append(selected, assignment)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Month != selected[j].Month {
+			return selected[i].Month < selected[j].Month
+		}
+		return selected[i].CreatedAt.Before(selected[j].CreatedAt)
+	})
+	return selected, nil
+}
+
+// Assign moves amount from the pool to be budgeted into the envelope of category for month
+// (YYYY-MM); a negative amount gives money back to the pool. All assignments share one
+// currency.
+func (s *EnvelopeService) Assign(category, month string, amount domain.Money, description string) (*domain.EnvelopeAssignment, error) {
+	assignment := domain.EnvelopeAssignment{Month: month, Category: strings.ToUpper(category), Amount: amount, Description: description}
+	var created []domain.EnvelopeAssignment
+	err := s.storage.Atomically(func() error {
+		var err error
+		created, err = s.record(assignment)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created[0], nil
+}
+
+// Move moves amount from the envelope of one category to another's for month (YYYY-MM),
+// recorded as two assignments
+func (s *EnvelopeService) Move(from, to, month string, amount domain.Money, description string) ([]domain.EnvelopeAssignment, error) {
+	if !amount.IsPositive() {
+		return nil, errors.InvalidAmount(amount.String(), fmt.Errorf("the amount to move must be positive"))
+	}
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return nil, errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidOperation, "Cannot move money from an envelope to itself")
+	}
+	if description == "" {
+		description = fmt.Sprintf("Moved from %s to %s", from, to)
+	}
+
+	var created []domain.EnvelopeAssignment
+	err := s.storage.Atomically(func() error {
+		var err error
+		created, err = s.record(
+			domain.EnvelopeAssignment{Month: month, Category: from, Amount: amount.Neg(), Description: description},
+			domain.EnvelopeAssignment{Month: month, Category: to, Amount: amount, Description: description},
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// record validates and saves new assignments; it must run in a unit of work
+func (s *EnvelopeService) record(assignments ...domain.EnvelopeAssignment) ([]domain.EnvelopeAssignment, error) {
+	categories, err := s.storage.GetCategories()
+	if err != nil {
+		return nil, errors.StorageReadFailed("categories", err)
+	}
+	stored, err := s.storage.GetEnvelopeAssignments()
+	if err != nil {
+		return nil, errors.StorageReadFailed("envelopes", err)
+	}
+
+	now := time.Now()
+	for i := range assignments {
+		assignment := &assignments[i]
+		if _, err := MonthPeriod(assignment.Month); err != nil {
+			return nil, err
+		}
+		if findCode(categories, assignment.Category) < 0 {
+			return nil, errors.CategoryNotFound(assignment.Category)
+		}
+		if assignment.Amount.IsZero() {
+			return nil, errors.InvalidAmount(assignment.Amount.String(), fmt.Errorf("an assignment cannot be zero"))
+		}
+		if len(stored) > 0 && stored[0].Amount.Currency != assignment.Amount.Currency {
+			return nil, errors.New(errors.ErrorTypeValidation, errors.CodeCurrencyMismatch,
+				fmt.Sprintf("Envelopes are kept in %s, got an amount in %s", stored[0].Amount.Currency, assignment.Amount.Currency))
+		}
+		assignment.ID = uuid.New().String()
+		assignment.CreatedAt = now
+	}
+
+	if err := s.storage.SaveEnvelopeAssignments(append(stored, assignments...)); err != nil {
+		return nil, errors.StorageWriteFailed("envelopes", err)
+	}
+	return assignments, nil
+}
+
+// Month rebuilds the envelopes from the first month with an assignment up to month
+// (YYYY-MM), in currency. Envelopes start at the first assignment of their category:
+// from then on, the active movements of the category (subcategories included, unless
+// they have an envelope of their own) draw from it. Every other movement, and everything
+// before the first envelope, goes to the pool to be budgeted, with the initial balances of
+// the accounts. Transfers between accounts are left out. Movements are converted at the
+// rate of their date, assignments and initial balances at the first day of their month.
+func (s *EnvelopeService) Month(month, currency string) (*EnvelopeMonth, error) {
+	period, err := MonthPeriod(month)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.Assignments("")
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.storage.GetCategories()
+	if err != nil {
+		return nil, errors.StorageReadFailed("categories", err)
+	}
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return nil, errors.StorageReadFailed("accounts", err)
+	}
+	rates, err := s.exchangeService.GetRates()
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.transactionService.FilterTransactions(TransactionFilter{To: period.To, NoTransfer: true})
+	if err != nil {
+		return nil, err
+	}
+
+	zero := domain.NewMoney(0, currency)
+	result := &EnvelopeMonth{Month: period, Currency: currency, Inflow: zero}
+
+	// Assignments after the month are left out
+	start := period.Label
+	opened := make(map[string]string) // Category -> month of its first assignment
+	assigned := make(map[string]map[string]domain.Money)
+	for _, assignment := range assignments {
+		if assignment.Month > period.Label {
+			continue
+		}
+		monthStart, err := MonthPeriod(assignment.Month)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := convert(rates, assignment.Amount, currency, monthStart.From)
+		if err != nil {
+			return nil, err
+		}
+		if assignment.Month < start {
+			start = assignment.Month
+		}
+		if first, exists := opened[assignment.Category]; !exists || assignment.Month < first {
+			opened[assignment.Category] = assignment.Month
+		}
+		if assigned[assignment.Month] == nil {
+			assigned[assignment.Month] = make(map[string]domain.Money)
+		}
+		assigned[assignment.Month][assignment.Category] = amountOr(assigned[assignment.Month], assignment.Category, zero).Add(amount)
+	}
+	startPeriod, err := MonthPeriod(start)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := zero
+	for _, account := range accounts {
+		balance, err := convert(rates, account.InitialBalance.WithCurrency(account.Currency), currency, startPeriod.From)
+		if err != nil {
+			result.Excluded = append(result.Excluded, account.ID)
+			continue
+		}
+		pool = pool.Add(balance)
+	}
+
+	tree := domain.NewCategoryTree(categories)
+	envelopeOf := func(codes []string, month string) string {
+		for _, code := range codes {
+			for _, c := range append([]string{code}, tree.Ancestors(code)...) {
+				if first, exists := opened[c]; exists && first <= month {
+					return c
+				}
+			}
+		}
+		return ""
+	}
+	inflows := make(map[string]domain.Money)
+	activity := make(map[string]map[string]domain.Money)
+	for _, txn := range transactions {
+		lines, err := convertedLines(rates, txn, currency, RateAtTransactionDate)
+		if err != nil {
+			result.Excluded = append(result.Excluded, txn.ID)
+			continue
+		}
+		month := txn.Date.Format("2006-01")
+		for _, line := range lines {
+			if month < start {
+				pool = pool.Add(line.Amount)
+				continue
+			}
+			code := envelopeOf(line.Categories, month)
+			if code == "" {
+				inflows[month] = amountOr(inflows, month, zero).Add(line.Amount)
+				continue
+			}
+			if activity[month] == nil {
+				activity[month] = make(map[string]domain.Money)
+			}
+			activity[month][code] = amountOr(activity[month], code, zero).Add(line.Amount)
+		}
+	}
+
+	// Roll every envelope and the pool over from month to month
+	available := make(map[string]domain.Money)
+	lines := make(map[string]*EnvelopeLine)
+	for cursor := startPeriod.From; cursor.Before(period.To); cursor = cursor.AddDate(0, 1, 0) {
+		month := cursor.Format("2006-01")
+		last := month == period.Label
+		pool = pool.Add(amountOr(inflows, month, zero))
+		if last {
+			result.Inflow = amountOr(inflows, month, zero)
+		}
+		for code, first := range opened {
+			if first > month {
+				continue
+			}
+			line := EnvelopeLine{Code: code, Carried: amountOr(available, code, zero),
+				Assigned: amountOr(assigned[month], code, zero), Activity: amountOr(activity[month], code, zero)}
+			line.Available = line.Carried.Add(line.Assigned).Add(line.Activity)
+			available[code] = line.Available
+			pool = pool.Sub(line.Assigned)
+			if last {
+				lines[code] = &line
+			}
+		}
+	}
+	result.ToBeBudgeted = pool
+
+	result.Total = EnvelopeLine{Name: "Total", Carried: zero, Assigned: zero, Activity: zero, Available: zero}
+	appendLine := func(line EnvelopeLine) {
+		result.Lines = append(result.Lines, line)
+		result.Total.Carried = result.Total.Carried.Add(line.Carried)
+		result.Total.Assigned = result.Total.Assigned.Add(line.Assigned)
+		result.Total.Activity = result.Total.Activity.Add(line.Activity)
+		result.Total.Available = result.Total.Available.Add(line.Available)
+	}
+	tree.Walk(func(category domain.Category, depth int) {
+		if line, exists := lines[category.Code]; exists {
+			line.Name, line.Parent, line.Depth = category.Name, stringValue(category.Parent), depth
+			appendLine(*line)
+			delete(lines, category.Code)
+		}
+	})
+	var orphans []string
+	for code := range lines {
+		orphans = append(orphans, code)
+	}
+	sort.Strings(orphans)
+	for _, code := range orphans {
+		lines[code].Name = code
+		appendLine(*lines[code])
+	}
+	return result, nil
+}
+
+// amountOr returns amounts[key], or zero when it is missing
+func amountOr(amounts map[string]domain.Money, key string, zero domain.Money) domain.Money {
+	if amount, exists := amounts[key]; exists {
+		return amount
+	}
+	return zero
+}
+
+// rewriteEnvelopes moves the envelope assignments of category code over to target
+func rewriteEnvelopes(store storage.Storage, code, target string) error {
+	assignments, err := store.GetEnvelopeAssignments()
+	if err != nil {
+		return errors.StorageReadFailed("envelopes", err)
+	}
+	changed := false
+	for i := range assignments {
+		if assignments[i].Category == code {
+			assignments[i].Category = target
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := store.SaveEnvelopeAssignments(assignments); err != nil {
+		return errors.StorageWriteFailed("envelopes", err)
+	}
+	return nil
+}
+
+// countEnvelopeAssignments returns how many envelope assignments use category code
+func countEnvelopeAssignments(assignments []domain.EnvelopeAssignment, code string) int {
+	count := 0
+	for _, assignment := range assignments {
+		if assignment.Category == code {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeService_MonthRollsOver(t *testing.T) {
+	parent := "ALM"
+	date := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.UTC) }
+	eur := func(minor int64) domain.Money { return domain.NewMoney(minor, "EUR") }
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{{ID: "BANQUE", Currency: "EUR", InitialBalance: eur(100000), IsActive: true}},
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES", "SUP"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+			{Code: "SUP", Name: "Supermarché", Parent: &parent},
+			{Code: "LOI", Name: "Loisirs"},
+			{Code: "SLR", Name: "Salaire"},
+		},
+		transactions: []domain.Transaction{
+			{ID: "december", Account: "BANQUE", Date: time.Date(2023, 12, 20, 0, 0, 0, 0, time.UTC), Amount: eur(-5000), Categories: []string{"ALM"}, IsActive: true},
+			{ID: "salary", Account: "BANQUE", Date: date(1, 2), Amount: eur(200000), Categories: []string{"SLR"}, IsActive: true},
+			{ID: "groceries", Account: "BANQUE", Date: date(1, 5), Amount: eur(-30000), Categories: []string{"SUP"}, IsActive: true},
+			{ID: "lunch", Account: "BANQUE", Date: date(1, 6), Amount: eur(-8000), Categories: []string{"RES"}, IsActive: true},
+			{ID: "cinema", Account: "BANQUE", Date: date(1, 7), Amount: eur(-2000), Categories: []string{"LOI"}, IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: date(1, 8), Amount: eur(-99900), Categories: []string{"SUP"}, IsActive: false},
+			{ID: "transfer", Account: "BANQUE", Date: date(1, 9), Amount: eur(-50000), TransferID: "t1", IsActive: true},
+			{ID: "dinner", Account: "BANQUE", Date: date(2, 3), Amount: eur(-9000), Categories: []string{"RES"}, IsActive: true},
+		},
+		envelopes: []domain.EnvelopeAssignment{
+			{ID: "a1", Month: "2024-01", Category: "ALM", Amount: eur(40000)},
+			{ID: "a2", Month: "2024-01", Category: "RES", Amount: eur(5000)},
+			{ID: "a3", Month: "2024-03", Category: "RES", Amount: eur(10000)},
+		},
+	}
+	service := NewEnvelopeService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+
+	january, err := service.Month("2024-01", "EUR")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []struct {
+		code                                   string
+		carried, assigned, activity, available string
+	}{
+		{"ALM", "0.00 EUR", "400.00 EUR", "-300.00 EUR", "100.00 EUR"}, // SUP has no envelope of its own
+		{"RES", "0.00 EUR", "50.00 EUR", "-80.00 EUR", "-30.00 EUR"},
+	}
+	if len(january.Lines) != len(expected) {
+		t.Fatalf("Expected %d envelopes, got %+v", len(expected), january.Lines)
+	}
+	for i, want := range expected {
+		line := january.Lines[i]
+		if line.Code != want.code || line.Carried.String() != want.carried || line.Assigned.String() != want.assigned ||
+			line.Activity.String() != want.activity || line.Available.String() != want.available {
+			t.Errorf("January line %d: expected %+v, got %+v", i, want, line)
+		}
+	}
+	// 1000 initial - 50 in December + 2000 salary - 20 cinema, less 450 assigned
+	if january.Inflow.String() != "1980.00 EUR" || january.ToBeBudgeted.String() != "2480.00 EUR" {
+		t.Errorf("Expected an inflow of 1980.00 and 2480.00 to be budgeted, got %s and %s", january.Inflow, january.ToBeBudgeted)
+	}
+	if money := january.ToBeBudgeted.Add(january.Total.Available); money.String() != "2550.00 EUR" {
+		t.Errorf("Expected the pool and the envelopes to add up to the money on the accounts, got %s", money)
+	}
+
+	// Overspending rolls over too; the March assignment does not count yet
+	february, err := service.Month("2024-02", "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := february.Lines[1]
+	if res.Carried.String() != "-30.00 EUR" || res.Assigned.String() != "0.00 EUR" || res.Available.String() != "-120.00 EUR" {
+		t.Errorf("Expected RES to carry -30.00 over and end at -120.00, got %+v", res)
+	}
+	if february.Lines[0].Carried.String() != "100.00 EUR" || february.ToBeBudgeted.String() != "2480.00 EUR" {
+		t.Errorf("Expected ALM to carry 100.00 over and the pool to stay at 2480.00, got %+v, %s", february.Lines[0], february.ToBeBudgeted)
+	}
+
+	march, err := service.Month("2024-03", "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := march.Lines[1]; res.Available.String() != "-20.00 EUR" || march.ToBeBudgeted.String() != "2380.00 EUR" {
+		t.Errorf("Expected RES at -20.00 and 2380.00 to be budgeted in March, got %+v, %s", res, march.ToBeBudgeted)
+	}
+}
+
+func TestEnvelopeService_AssignAndMove(t *testing.T) {
+	parent := "ALM"
+	date := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.UTC) }
+	eur := func(minor int64) domain.Money { return domain.NewMoney(minor, "EUR") }
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{{ID: "BANQUE", Currency: "EUR", InitialBalance: eur(100000), IsActive: true}},
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES", "SUP"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+			{Code: "SUP", Name: "Supermarché", Parent: &parent},
+			{Code: "LOI", Name: "Loisirs"},
+			{Code: "SLR", Name: "Salaire"},
+		},
+		transactions: []domain.Transaction{
+			{ID: "december", Account: "BANQUE", Date: time.Date(2023, 12, 20, 0, 0, 0, 0, time.UTC), Amount: eur(-5000), Categories: []string{"ALM"}, IsActive: true},
+			{ID: "salary", Account: "BANQUE", Date: date(1, 2), Amount: eur(200000), Categories: []string{"SLR"}, IsActive: true},
+			{ID: "groceries", Account: "BANQUE", Date: date(1, 5), Amount: eur(-30000), Categories: []string{"SUP"}, IsActive: true},
+			{ID: "lunch", Account: "BANQUE", Date: date(1, 6), Amount: eur(-8000), Categories: []string{"RES"}, IsActive: true},
+			{ID: "cinema", Account: "BANQUE", Date: date(1, 7), Amount: eur(-2000), Categories: []string{"LOI"}, IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: date(1, 8), Amount: eur(-99900), Categories: []string{"SUP"}, IsActive: false},
+			{ID: "transfer", Account: "BANQUE", Date: date(1, 9), Amount: eur(-50000), TransferID: "t1", IsActive: true},
+			{ID: "dinner", Account: "BANQUE", Date: date(2, 3), Amount: eur(-9000), Categories: []string{"RES"}, IsActive: true},
+		},
+		envelopes: []domain.EnvelopeAssignment{
+			{ID: "a1", Month: "2024-01", Category: "ALM", Amount: eur(40000)},
+			{ID: "a2", Month: "2024-01", Category: "RES", Amount: eur(5000)},
+			{ID: "a3", Month: "2024-03", Category: "RES", Amount: eur(10000)},
+		},
+	}
+	service := NewEnvelopeService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+
+	assignment, err := service.Assign("loi", "2024-01", domain.NewMoney(3000, "EUR"), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if assignment.ID == "" || assignment.Category != "LOI" || assignment.CreatedAt.IsZero() || len(mockStorage.envelopes) != 4 {
+		t.Errorf("Expected a recorded LOI assignment, got %+v", assignment)
+	}
+
+	moved, err := service.Move("ALM", "LOI", "2024-01", domain.NewMoney(1000, "EUR"), "")
+	if err != nil || len(moved) != 2 || moved[0].Amount.String() != "-10.00 EUR" || moved[1].Category != "LOI" {
+		t.Fatalf("Expected a move recorded as two assignments, got %+v, %v", moved, err)
+	}
+	january, err := service.Month("2024-01", "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The cinema now draws from LOI instead of the pool
+	if january.ToBeBudgeted.String() != "2470.00 EUR" || january.Lines[2].Available.String() != "20.00 EUR" {
+		t.Errorf("Expected 2470.00 to be budgeted and LOI at 20.00, got %s, %+v", january.ToBeBudgeted, january.Lines)
+	}
+
+	invalid := []struct {
+		category, month string
+		amount          domain.Money
+		code            string
+	}{
+		{"XXX", "2024-01", domain.NewMoney(100, "EUR"), errors.CodeCategoryNotFound},
+		{"ALM", "2024-13", domain.NewMoney(100, "EUR"), errors.CodeInvalidDate},
+		{"ALM", "2024-01", domain.NewMoney(0, "EUR"), errors.CodeInvalidAmount},
+		{"ALM", "2024-01", domain.NewMoney(100, "USD"), errors.CodeCurrencyMismatch},
+	}
+	for _, test := range invalid {
+		_, err := service.Assign(test.category, test.month, test.amount, "")
+		if !hasErrorCode(err, test.code) {
+			t.Errorf("Assign(%s, %s, %s): expected %s, got %v", test.category, test.month, test.amount, test.code, err)
+		}
+	}
+	if _, err := service.Move("ALM", "ALM", "2024-01", domain.NewMoney(100, "EUR"), ""); err == nil {
+		t.Error("Expected a move to the same envelope to be refused")
+	}
+}
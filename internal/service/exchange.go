@@ -0,0 +1,335 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RateDate selects which exchange rate converts a past movement
+type RateDate string
+
+const (
+	// RateAtTransactionDate converts each movement at the rate of its own date
+	RateAtTransactionDate RateDate = "transaction"
+	// RateAtToday converts everything at the latest known rate
+	RateAtToday RateDate = "today"
+)
+
+// ParseRateDate parses "transaction" or "today"
+func ParseRateDate(value string) (RateDate, error) {
+	switch RateDate(strings.ToLower(value)) {
+	case RateAtTransactionDate:
+		return RateAtTransactionDate, nil
+	case RateAtToday, "":
+		return RateAtToday, nil
+	}
+	return "", errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidExchangeRate,
+		fmt.Sprintf("Unknown rate date %q (expected 'transaction' or 'today')", value))
+}
+
+// at returns the day whose rate converts an amount dated date
+func (mode RateDate) at(date time.Time) time.Time {
+	if mode == RateAtToday {
+		return time.Now()
+	}
+	return date
+}
+
+// ExchangeRateService manages the local exchange-rate table and currency conversions
+type ExchangeRateService struct {
+	storage storage.Storage
+}
+
+// NewExchangeRateService creates a new exchange-rate service
+func NewExchangeRateService(storage storage.Storage) *ExchangeRateService {
+	return &ExchangeRateService{
+		storage: storage,
+	}
+}
+
+// GetRates returns all known rates ordered by date
+func (s *ExchangeRateService) GetRates() ([]domain.ExchangeRate, error) {
+	rates, err := s.storage.GetExchangeRates()
+	if err != nil {
+		return nil, errors.StorageReadFailed("exchange rates", err)
+	}
+	sortRates(rates)
+	return rates, nil
+}
+
+// AddRates records rates, replacing any existing rate for the same pair and day
+func (s *ExchangeRateService) AddRates(newRates ...domain.ExchangeRate) error {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return err
+	}
+	defer s.storage.Unlock()
+
+	rates, err := s.storage.GetExchangeRates()
+	if err != nil {
+		return errors.StorageReadFailed("exchange rates", err)
+	}
+
+	for _, rate := range newRates {
+		rate, err := normalizeRate(rate)
+		if err != nil {
+			return err
+		}
+
+		replaced := false
+		for i, existing := range rates {
+			if sameDay(existing.Date, rate.Date) && existing.From == rate.From && existing.To == rate.To {
+				rates[i] = rate
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			rates = append(rates, rate)
+		}
+	}
+
+	sortRates(rates)
+	if err := s.storage.SaveExchangeRates(rates); err != nil {
+		return errors.StorageWriteFailed("exchange rates", err)
+	}
+	return nil
+}
+
+// ImportCSV records the rates of a CSV file with "date,from,to,rate" columns
+// (header line optional, dates as 2024-01-15) and returns how many were read
+func (s *ExchangeRateService) ImportCSV(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, errors.Wrap(errors.ErrorTypeUserInput, errors.CodeInvalidExchangeRate, "Failed to read exchange-rate CSV", err)
+	}
+
+	var rates []domain.ExchangeRate
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) != 4 {
+			return 0, errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidExchangeRate,
+				fmt.Sprintf("Line %d: expected 4 columns (date,from,to,rate), got %d", i+1, len(record)))
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return 0, errors.Wrap(errors.ErrorTypeUserInput, errors.CodeInvalidDate, fmt.Sprintf("Line %d: invalid date", i+1), err)
+		}
+		rates = append(rates, domain.ExchangeRate{Date: date, From: record[1], To: record[2], Rate: record[3]})
+	}
+
+	if err := s.AddRates(rates...); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// Rate returns the rate converting from into to on the given date
+func (s *ExchangeRateService) Rate(from, to string, date time.Time) (*big.Rat, error) {
+	rates, err := s.GetRates()
+	if err != nil {
+		return nil, err
+	}
+	return findRate(rates, from, to, date)
+}
+
+// Convert converts an amount into currency at the rate of the given date
+func (s *ExchangeRateService) Convert(amount domain.Money, currency string, date time.Time) (domain.Money, error) {
+	rates, err := s.GetRates()
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return convert(rates, amount, currency, date)
+}
+
+// ConvertedBalance returns the balance of an account expressed in currency. With
+// RateAtTransactionDate the initial balance is converted at the account creation date
+// and each active movement at its own date; with RateAtToday the balance is converted at once.
+func (s *ExchangeRateService) ConvertedBalance(accountID, currency string, mode RateDate) (domain.Money, error) {
+	return s.ConvertedBalanceIn(accountID, currency, mode, LedgerView{})
+}
+
+// ConvertedBalanceIn is ConvertedBalance for the balance seen by view; with RateAtToday
+// and an AsOf date, the balance is converted at the rate of that date
+func (s *ExchangeRateService) ConvertedBalanceIn(accountID, currency string, mode RateDate, view LedgerView) (domain.Money, error) {
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return domain.Money{}, errors.StorageReadFailed("accounts", err)
+	}
+	var account *domain.Account
+	for i := range accounts {
+		if accounts[i].ID == accountID {
+			account = &accounts[i]
+			break
+		}
+	}
+	if account == nil {
+		return domain.Money{}, errors.AccountNotFound(accountID)
+	}
+
+	rates, err := s.GetRates()
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	if mode != RateAtTransactionDate && view.IsCurrent() {
+		balance, err := s.storage.GetAccountBalance(accountID)
+		if err != nil {
+			return domain.Money{}, errors.StorageReadFailed("balance", err)
+		}
+		return convert(rates, balance, currency, time.Now())
+	}
+
+	transactions, err := viewMovements(s.storage, storage.TransactionQuery{Account: accountID}, view)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	if mode != RateAtTransactionDate {
+		balance := account.InitialBalance.WithCurrency(account.Currency)
+		for _, txn := range transactions {
+			balance = balance.Add(txn.Amount)
+		}
+		date := view.AsOf
+		if date.IsZero() {
+			date = time.Now()
+		}
+		return convert(rates, balance, currency, date)
+	}
+
+	total, err := convert(rates, account.InitialBalance.WithCurrency(account.Currency), currency, account.CreatedAt)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	for _, txn := range transactions {
+		converted, err := convert(rates, txn.Amount.WithCurrency(account.Currency), currency, txn.Date)
+		if err != nil {
+			return domain.Money{}, err
+		}
+		total = total.Add(converted)
+	}
+	return total, nil
+}
+
+// convert converts an amount using a rate table (amounts already in currency are returned as is)
+func convert(rates []domain.ExchangeRate, amount domain.Money, currency string, date time.Time) (domain.Money, error) {
+	currency = strings.ToUpper(currency)
+	if amount.Currency == "" || amount.Currency == currency {
+		return amount.WithCurrency(currency), nil
+	}
+	rate, err := findRate(rates, amount.Currency, currency, date)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return amount.Convert(rate, currency), nil
+}
+
+// findRate returns the latest rate on or before date for the pair, falling back to the
+// earliest later rate when none is older. Inverse pairs are used when only they are known.
+func findRate(rates []domain.ExchangeRate, from, to string, date time.Time) (*big.Rat, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	day := date.Format("2006-01-02")
+	var best *domain.ExchangeRate
+	inverse := false
+	for i := range rates {
+		rate := &rates[i]
+		var isInverse bool
+		switch {
+		case rate.From == from && rate.To == to:
+			isInverse = false
+		case rate.From == to && rate.To == from:
+			isInverse = true
+		default:
+			continue
+		}
+		if best == nil || betterRate(rate.Date.Format("2006-01-02"), best.Date.Format("2006-01-02"), day) ||
+			// A direct rate wins over an inverse one on the same day
+			(sameDay(rate.Date, best.Date) && inverse && !isInverse) {
+			best = rate
+			inverse = isInverse
+		}
+	}
+
+	if best == nil {
+		return nil, errors.RateNotFound(from, to)
+	}
+	value, err := best.Value()
+	if err != nil {
+		return nil, errors.InvalidExchangeRate(best.Rate, err)
+	}
+	if inverse {
+		value.Inv(value)
+	}
+	return value, nil
+}
+
+// betterRate reports whether a rate dated candidate is closer to day than one dated current
+func betterRate(candidate, current, day string) bool {
+	candidateBefore := candidate <= day
+	currentBefore := current <= day
+	switch {
+	case candidateBefore && !currentBefore:
+		return true
+	case !candidateBefore && currentBefore:
+		return false
+	case candidateBefore:
+		return candidate > current
+	default:
+		return candidate < current
+	}
+}
+
+// normalizeRate validates a rate and normalizes its currencies and date
+func normalizeRate(rate domain.ExchangeRate) (domain.ExchangeRate, error) {
+	rate.From = strings.ToUpper(strings.TrimSpace(rate.From))
+	rate.To = strings.ToUpper(strings.TrimSpace(rate.To))
+	if rate.From == "" || rate.To == "" || rate.From == rate.To {
+		return rate, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidExchangeRate,
+			fmt.Sprintf("Exchange rate needs two different currencies, got %q and %q", rate.From, rate.To))
+	}
+	value, err := domain.ParseRate(rate.Rate)
+	if err != nil {
+		return rate, errors.InvalidExchangeRate(rate.Rate, err)
+	}
+	rate.Rate = domain.FormatRate(value)
+	if rate.Date.IsZero() {
+		rate.Date = time.Now()
+	}
+	rate.Date = time.Date(rate.Date.Year(), rate.Date.Month(), rate.Date.Day(), 0, 0, 0, 0, time.Local)
+	return rate, nil
+}
+
+func sortRates(rates []domain.ExchangeRate) {
+	sort.SliceStable(rates, func(i, j int) bool {
+		if !rates[i].Date.Equal(rates[j].Date) {
+			return rates[i].Date.Before(rates[j].Date)
+		}
+		if rates[i].From != rates[j].From {
+			return rates[i].From < rates[j].From
+		}
+		return rates[i].To < rates[j].To
+	})
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
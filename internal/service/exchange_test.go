@@ -0,0 +1,159 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(value string) time.Time {
+	date, _ := time.ParseInLocation("2006-01-02", value, time.Local)
+	return date
+}
+
+func TestExchangeRateService_ImportCSV(t *testing.T) {
+	mockStorage := &MockStorage{}
+	service := NewExchangeRateService(mockStorage)
+
+	csv := "date,from,to,rate\n2024-01-01,usd,eur,0.90\n2024-02-01,EUR,USD,1.25\n2024-01-01,USD,EUR,0.91\n"
+	count, err := service.ImportCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 rates read, got %d", count)
+	}
+	// The second USD/EUR rate for 2024-01-01 replaces the first one
+	if len(mockStorage.rates) != 2 {
+		t.Fatalf("Expected 2 rates stored, got %d", len(mockStorage.rates))
+	}
+	if mockStorage.rates[0].From != "USD" || mockStorage.rates[0].Rate != "0.91" {
+		t.Errorf("Expected USD/EUR 0.91, got %s/%s %s", mockStorage.rates[0].From, mockStorage.rates[0].To, mockStorage.rates[0].Rate)
+	}
+
+	if _, err := service.ImportCSV(strings.NewReader("2024-01-01,USD,EUR,-1\n")); err == nil {
+		t.Error("Expected error for a negative rate")
+	}
+	if _, err := service.ImportCSV(strings.NewReader("2024-01-01,USD,EUR\n")); err == nil {
+		t.Error("Expected error for a missing column")
+	}
+}
+
+func TestExchangeRateService_Convert(t *testing.T) {
+	mockStorage := &MockStorage{
+		rates: []domain.ExchangeRate{
+			{Date: day("2024-01-01"), From: "USD", To: "EUR", Rate: "0.9"},
+			{Date: day("2024-03-01"), From: "EUR", To: "USD", Rate: "1.25"},
+		},
+	}
+	service := NewExchangeRateService(mockStorage)
+
+	tests := []struct {
+		date     string
+		expected domain.Money
+	}{
+		{"2023-06-01", domain.NewMoney(9000, "EUR")}, // before any rate: earliest rate
+		{"2024-02-15", domain.NewMoney(9000, "EUR")}, // latest rate on or before the date
+		{"2024-03-01", domain.NewMoney(8000, "EUR")}, // inverse pair (1 / 1.25)
+	}
+
+	for _, tt := range tests {
+		got, err := service.Convert(domain.NewMoney(10000, "USD"), "EUR", day(tt.date))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.date, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.date, tt.expected, got)
+		}
+	}
+
+	if _, err := service.Convert(domain.NewMoney(10000, "USD"), "JPY", time.Now()); err == nil {
+		t.Error("Expected error when no rate exists for the pair")
+	}
+}
+
+func TestExchangeRateService_ConvertedBalance(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "US", Currency: "USD", InitialBalance: domain.NewMoney(10000, "USD"), IsActive: true, CreatedAt: day("2024-01-01")},
+		},
+		rates: []domain.ExchangeRate{
+			{Date: day("2024-01-01"), From: "USD", To: "EUR", Rate: "0.9"},
+			{Date: day("2024-06-01"), From: "USD", To: "EUR", Rate: "0.8"},
+		},
+		transactions: []domain.Transaction{
+			{ID: "t1", Account: "US", Date: day("2024-02-01"), Amount: domain.NewMoney(10000, "USD"), IsActive: true},
+		},
+	}
+	service := NewExchangeRateService(mockStorage)
+
+	today, err := service.ConvertedBalance("US", "EUR", RateAtToday)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if today != domain.NewMoney(16000, "EUR") {
+		t.Errorf("Expected 160.00 EUR at today's rate, got %s", today)
+	}
+
+	historical, err := service.ConvertedBalance("US", "EUR", RateAtTransactionDate)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if historical != domain.NewMoney(18000, "EUR") {
+		t.Errorf("Expected 180.00 EUR at transaction-date rates, got %s", historical)
+	}
+}
+
+func TestTransactionService_AddTransfer_CrossCurrency(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true},
+			{ID: "US", Currency: "USD", InitialBalance: domain.NewMoney(10000, "USD"), IsActive: true},
+		},
+		rates: []domain.ExchangeRate{
+			{Date: day("2024-01-01"), From: "EUR", To: "USD", Rate: "1.1"},
+		},
+	}
+	service := NewTransactionService(mockStorage)
+
+	legs, err := service.AddTransfer(TransferRequest{
+		From:   "BANQUE",
+		To:     "US",
+		Amount: domain.NewMoney(10000, ""),
+		Date:   day("2024-02-01"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if legs[0].Amount != domain.NewMoney(-10000, "EUR") || legs[1].Amount != domain.NewMoney(11000, "USD") {
+		t.Errorf("Expected -100.00 EUR / 110.00 USD, got %s / %s", legs[0].Amount, legs[1].Amount)
+	}
+	if legs[0].ExchangeRate != "1.1" || legs[1].ExchangeRate != "1.1" {
+		t.Errorf("Expected rate 1.1 recorded on both legs, got %q / %q", legs[0].ExchangeRate, legs[1].ExchangeRate)
+	}
+
+	// Editing the credited leg converts back with the recorded rate
+	if _, err := service.EditTransaction(legs[1].ID, domain.Transaction{ID: "edited", Amount: domain.NewMoney(22000, "")}, "Montant corrigé"); err != nil {
+		t.Fatalf("Expected no error editing transfer, got %v", err)
+	}
+	banque, _ := service.GetAccountBalance("BANQUE")
+	if banque != domain.NewMoney(80000, "EUR") {
+		t.Errorf("Expected BANQUE balance 800.00 EUR after edit, got %s", banque)
+	}
+
+	// An explicit received amount sets the rate
+	legs, err = service.AddTransfer(TransferRequest{
+		From:     "US",
+		To:       "BANQUE",
+		Amount:   domain.NewMoney(1000, ""),
+		Received: domain.NewMoney(850, ""),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if legs[1].Amount != domain.NewMoney(850, "EUR") || legs[0].ExchangeRate != "0.85" {
+		t.Errorf("Expected 8.50 EUR at rate 0.85, got %s at %s", legs[1].Amount, legs[0].ExchangeRate)
+	}
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expression is a parsed selection of movements, e.g.
+//
+//	account:BANQUE and cat:ALM and amount<-50 and date>=2024-01 and not tag:PRO
+//
+// Comparisons are "field op value" with op among : = != < <= > >=; they combine with
+// and, or, not and parentheses (not binds tighter than and, which binds tighter than or).
+// Values containing spaces or operators are written between double quotes.
+//
+// Fields:
+//   - account: account ID (case-insensitive)
+//   - cat, category: a category of the movement or of a split line, subcategories included
+//   - tag: a tag of the movement or of a split line
+//   - amount: signed amount; "50 USD" only compares with USD movements
+//   - date: YYYY, YYYY-MM or YYYY-MM-DD; date:2024-01 is the whole month, date<=2024-01 up to its end
+//   - desc, description: ':' searches the text (case-insensitive), '=' compares it whole
+//   - currency: currency of the amount
+//   - id: ':' matches an ID prefix, '=' the full ID
+//   - is: transfer, split, active or deleted
+type Expression struct {
+	source string
+	match  predicate
+}
+
+// predicate evaluates a comparison; tree is nil when categories are matched exactly
+type predicate func(t domain.Transaction, tree *domain.CategoryTree) bool
+
+// Matches reports whether a transaction is selected. With a category tree, a category
+// comparison also selects the subcategories.
+func (e *Expression) Matches(t domain.Transaction, tree *domain.CategoryTree) bool {
+	return e.match(t, tree)
+}
+
+// String returns the expression as written
+func (e *Expression) String() string {
+	return e.source
+}
+
+// ParseExpression parses a selection expression; syntax errors carry the column (from 1)
+// of the offending token.
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &expressionParser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, errors.InvalidExpression(1, "empty expression")
+	}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, errors.InvalidExpression(next.column, fmt.Sprintf("expected 'and' or 'or' before %q", next.text))
+	}
+	return &Expression{source: source, match: match}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	column int
+}
+
+// isWordRune reports whether r can appear in an unquoted word
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()"<>=!:`, r)
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		column := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenOpen, "(", column})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenClose, ")", column})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.InvalidExpression(column, "unterminated string")
+			}
+			tokens = append(tokens, token{tokenString, string(runes[i+1 : end]), column})
+			i = end + 1
+		case strings.ContainsRune("<>=!:", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' && r != ':' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, errors.InvalidExpression(column, "unexpected '!' (use != or not)")
+			}
+			tokens = append(tokens, token{tokenOperator, op, column})
+			i += len(op)
+		default:
+			end := i
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[i:end]), column})
+			i = end
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(runes) + 1}), nil
+}
+
+type expressionParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the given keyword, and consumes it if so
+func (p *expressionParser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseOr() (predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(t domain.Transaction, tree *domain.CategoryTree) bool { return l(t, tree) || right(t, tree) }
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (predicate, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(t domain.Transaction, tree *domain.CategoryTree) bool { return l(t, tree) && right(t, tree) }
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseNot() (predicate, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(t domain.Transaction, tree *domain.CategoryTree) bool { return !operand(t, tree) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (predicate, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, errors.InvalidExpression(closing.column, fmt.Sprintf("expected ')' to close the '(' at column %d", t.column))
+		}
+		return inner, nil
+	case tokenWord:
+		return p.parseComparison(t)
+	case tokenEOF:
+		return nil, errors.InvalidExpression(t.column, "unexpected end of expression, expected a comparison")
+	default:
+		return nil, errors.InvalidExpression(t.column, fmt.Sprintf("unexpected %q, expected a comparison such as cat:ALM", t.text))
+	}
+}
+
+func (p *expressionParser) parseComparison(field token) (predicate, error) {
+	op := p.next()
+	if op.kind != tokenOperator {
+		return nil, errors.InvalidExpression(op.column, fmt.Sprintf("expected an operator (: = != < <= > >=) after %q", field.text))
+	}
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, errors.InvalidExpression(value.column, fmt.Sprintf("expected a value after %q", field.text+op.text))
+	}
+
+	switch strings.ToLower(field.text) {
+	case "account":
+		return textComparison(op, value, func(t domain.Transaction) []string { return []string{t.Account} })
+	case "currency":
+		return textComparison(op, value, func(t domain.Transaction) []string { return []string{t.Amount.Currency} })
+	case "cat", "category":
+		return categoryComparison(op, value)
+	case "tag":
+		return textComparison(op, value, transactionTags)
+	case "amount":
+		return amountComparison(op, value)
+	case "date":
+		return dateComparison(op, value)
+	case "desc", "description":
+		return descriptionComparison(op, value)
+	case "id":
+		return idComparison(op, value)
+	case "is":
+		return stateComparison(op, value)
+	default:
+		return nil, errors.InvalidExpression(field.column,
+			fmt.Sprintf("unknown field %q (account, cat, tag, amount, date, desc, currency, id, is)", field.text))
+	}
+}
+
+func unsupportedOperator(op token, field string) error {
+	return errors.InvalidExpression(op.column, fmt.Sprintf("operator %s cannot be used with %s", op.text, field))
+}
+
+// equality turns a match into a predicate for ':' and '=' (match) or '!=' (no match)
+func equality(op token, field string, match predicate) (predicate, error) {
+	switch op.text {
+	case ":", "=":
+		return match, nil
+	case "!=":
+		return func(t domain.Transaction, tree *domain.CategoryTree) bool { return !match(t, tree) }, nil
+	default:
+		return nil, unsupportedOperator(op, field)
+	}
+}
+
+// textComparison matches when one of the values returned by get equals the value, ignoring case
+func textComparison(op, value token, get func(domain.Transaction) []string) (predicate, error) {
+	return equality(op, "text fields", func(t domain.Transaction, _ *domain.CategoryTree) bool {
+		for _, v := range get(t) {
+			if strings.EqualFold(v, value.text) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func categoryComparison(op, value token) (predicate, error) {
+	code := strings.ToUpper(value.text)
+	return equality(op, "cat", func(t domain.Transaction, tree *domain.CategoryTree) bool {
+		wanted := []string{code}
+		if tree != nil {
+			wanted = tree.Descendants(code)
+		}
+		for _, c := range transactionCategories(t) {
+			if containsCode(wanted, c) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func amountComparison(op, value token) (predicate, error) {
+	bound, err := domain.ParseMoney(value.text, "")
+	if err != nil {
+		return nil, errors.InvalidExpression(value.column, fmt.Sprintf("invalid amount %q", value.text))
+	}
+	accept := map[string]func(int) bool{
+		":":  func(c int) bool { return c == 0 },
+		"=":  func(c int) bool { return c == 0 },
+		"!=": func(c int) bool { return c != 0 },
+		"<":  func(c int) bool { return c < 0 },
+		"<=": func(c int) bool { return c <= 0 },
+		">":  func(c int) bool { return c > 0 },
+		">=": func(c int) bool { return c >= 0 },
+	}[op.text]
+	return func(t domain.Transaction, _ *domain.CategoryTree) bool {
+		return t.Amount.SameCurrency(bound) && accept(t.Amount.Cmp(bound))
+	}, nil
+}
+
+func dateComparison(op, value token) (predicate, error) {
+	from, to, ok := parsePeriod(value.text)
+	if !ok {
+		return nil, errors.InvalidExpression(value.column, fmt.Sprintf("invalid date %q (YYYY, YYYY-MM or YYYY-MM-DD)", value.text))
+	}
+	var accept func(day time.Time) bool
+	switch op.text {
+	case ":", "=":
+		accept = func(day time.Time) bool { return !day.Before(from) && day.Before(to) }
+	case "!=":
+		accept = func(day time.Time) bool { return day.Before(from) || !day.Before(to) }
+	case "<":
+		accept = func(day time.Time) bool { return day.Before(from) }
+	case "<=":
+		accept = func(day time.Time) bool { return day.Before(to) }
+	case ">":
+		accept = func(day time.Time) bool { return !day.Before(to) }
+	case ">=":
+		accept = func(day time.Time) bool { return !day.Before(from) }
+	}
+	return func(t domain.Transaction, _ *domain.CategoryTree) bool {
+		return accept(time.Date(t.Date.Year(), t.Date.Month(), t.Date.Day(), 0, 0, 0, 0, time.UTC))
+	}, nil
+}
+
+// parsePeriod returns the days [from, to) covered by a year, a month or a day
+func parsePeriod(text string) (time.Time, time.Time, bool) {
+	layouts := []struct {
+		layout       string
+		years, month int
+		days         int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	}
+	for _, l := range layouts {
+		if from, err := time.Parse(l.layout, text); err == nil {
+			return from, from.AddDate(l.years, l.month, l.days), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func descriptionComparison(op, value token) (predicate, error) {
+	text := strings.ToLower(value.text)
+	if op.text == ":" {
+		return func(t domain.Transaction, _ *domain.CategoryTree) bool { return descriptionContains(t, text) }, nil
+	}
+	return equality(op, "desc", func(t domain.Transaction, _ *domain.CategoryTree) bool {
+		return strings.ToLower(t.Description) == text
+	})
+}
+
+func idComparison(op, value token) (predicate, error) {
+	if op.text == ":" {
+		return func(t domain.Transaction, _ *domain.CategoryTree) bool { return strings.HasPrefix(t.ID, value.text) }, nil
+	}
+	return equality(op, "id", func(t domain.Transaction, _ *domain.CategoryTree) bool { return t.ID == value.text })
+}
+
+func stateComparison(op, value token) (predicate, error) {
+	states := map[string]func(domain.Transaction) bool{
+		"transfer": func(t domain.Transaction) bool { return t.IsTransfer() },
+		"split":    func(t domain.Transaction) bool { return len(t.Splits) > 0 },
+		"active":   func(t domain.Transaction) bool { return t.IsActive },
+		"deleted":  func(t domain.Transaction) bool { return !t.IsActive },
+	}
+	state, exists := states[strings.ToLower(value.text)]
+	if !exists {
+		return nil, errors.InvalidExpression(value.column, fmt.Sprintf("unknown state %q (transfer, split, active, deleted)", value.text))
+	}
+	return equality(op, "is", func(t domain.Transaction, _ *domain.CategoryTree) bool { return state(t) })
+}
+
+// transactionCategories returns the categories of a movement and of its split lines
+func transactionCategories(t domain.Transaction) []string {
+	codes := append([]string(nil), t.Categories...)
+	for _, split := range t.Splits {
+		codes = append(codes, split.Categories...)
+	}
+	return codes
+}
+
+// transactionTags returns the tags of a movement and of its split lines
+func transactionTags(t domain.Transaction) []string {
+	codes := append([]string(nil), t.Tags...)
+	for _, split := range t.Splits {
+		codes = append(codes, split.Tags...)
+	}
+	return codes
+}
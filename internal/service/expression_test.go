@@ -0,0 +1,97 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestParseExpression_Matches(t *testing.T) {
+	parent := "ALM"
+	tree := domain.NewCategoryTree([]domain.Category{
+		{Code: "ALM", Children: []string{"RES"}},
+		{Code: "RES", Parent: &parent},
+	})
+	transactions := []domain.Transaction{
+		{ID: "aaa1", Account: "BANQUE", Date: time.Date(2024, 1, 31, 18, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-8000, "EUR"),
+			Description: "Restaurant Le Zinc", Categories: []string{"RES"}, IsActive: true},
+		{ID: "aaa2", Account: "BANQUE", Date: time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-3000, "EUR"),
+			Description: "Déjeuner client", Categories: []string{"ALM"}, Tags: []string{"PRO"}, IsActive: true},
+		{ID: "bbb3", Account: "LIVRET", Date: time.Date(2023, 12, 24, 0, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-6000, "USD"),
+			Description: "Courses", IsActive: false,
+			Splits: []domain.Split{{Amount: domain.NewMoney(-6000, "USD"), Categories: []string{"RES"}}}},
+		{ID: "ccc4", Account: "BANQUE", Date: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-10000, "EUR"),
+			Description: "Virement épargne", TransferID: "t1", IsActive: true},
+	}
+
+	tests := []struct {
+		expression string
+		expected   []string
+	}{
+		{"account:BANQUE and cat:ALM and amount<-50 and date>=2024-01 and not tag:PRO", []string{"aaa1"}},
+		{"cat:alm", []string{"aaa1", "aaa2", "bbb3"}},
+		{"cat=RES or is:transfer", []string{"aaa1", "bbb3", "ccc4"}},
+		{"date:2024-01", []string{"aaa1"}},
+		{"date<=2024-01 and date>2023", []string{"aaa1"}},
+		{"date!=2024", []string{"bbb3"}},
+		{`desc:"le zinc"`, []string{"aaa1"}},
+		{`desc="DÉJEUNER CLIENT"`, []string{"aaa2"}},
+		{"amount>=-50", []string{"aaa2"}},
+		{`amount<"-50 USD"`, []string{"bbb3"}},
+		{"not (is:active or currency:usd) or id:aaa", []string{"aaa1", "aaa2"}},
+		{"is:split and is:deleted", []string{"bbb3"}},
+		{"tag!=pro and account=banque", []string{"aaa1", "ccc4"}},
+		{"(cat:RES or cat:ALM) and not (amount<-50)", []string{"aaa2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expression, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var got []string
+			for _, txn := range transactions {
+				if expression.Matches(txn, tree) {
+					got = append(got, txn.ID)
+				}
+			}
+			if !equalStrings(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseExpression_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		column     int
+	}{
+		{"", 1},
+		{"cat:ALM and", 12},
+		{"cat:ALM tag:PRO", 9},
+		{"colour:red", 1},
+		{"cat<ALM", 4},
+		{"amount<abc", 8},
+		{"date>=2024-13", 7},
+		{"(cat:ALM or tag:PRO", 20},
+		{`desc:"open`, 6},
+		{"cat ALM", 5},
+		{"amount<", 8},
+		{"is:pending", 4},
+		{"cat!ALM", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := ParseExpression(tt.expression)
+			e, ok := err.(*errors.ComptesError)
+			if !ok || e.Code != errors.CodeInvalidExpression {
+				t.Fatalf("Expected invalid_expression, got %v", err)
+			}
+			if e.Column != tt.column {
+				t.Errorf("Expected column %d, got %d (%s)", tt.column, e.Column, e.Message)
+			}
+		})
+	}
+}
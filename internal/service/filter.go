@@ -0,0 +1,153 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"strings"
+	"time"
+)
+
+// TransactionFilter narrows a listing of movements. Zero-valued fields do not filter and
+// the others combine with AND; within Categories or Tags, any of the codes matches.
+type TransactionFilter struct {
+	Account    string
+	From       time.Time     // Inclusive, by calendar day
+	To         time.Time     // Exclusive, by calendar day
+	Categories []string      // A category also selects its subcategories
+	Tags       []string      // On the transaction or one of its split lines
+	Min        *domain.Money // Signed amount, inclusive; amounts in another currency never match
+	Max        *domain.Money
+	Search     string // Case-insensitive, in the description or a split line description
+	Batch      string // ID or ID prefix of a committed batch
+	History    bool   // Include deleted and edited movements
+	NoTransfer bool
+	Where      *Expression // Ad-hoc selection (see ParseExpression)
+	View       LedgerView  // Movements as of a date or as recorded at an instant
+}
+
+// Query returns the part of the filter that storage backends can apply themselves
+func (f TransactionFilter) Query() storage.TransactionQuery {
+	query := storage.TransactionQuery{
+		Account:    f.Account,
+		From:       f.From,
+		To:         f.To,
+		Categories: f.Categories,
+		Tags:       f.Tags,
+	}
+	if end := f.View.End(); !end.IsZero() && (query.To.IsZero() || end.Before(query.To)) {
+		query.To = end
+	}
+	// The recorded state needs the movements deleted since to rebuild them
+	if !f.History && f.View.RecordedAt.IsZero() {
+		query.Active = storage.ActiveOnly(true)
+	}
+	return query
+}
+
+// Matches reports whether a transaction satisfies the filters not covered by Query
+func (f TransactionFilter) Matches(t domain.Transaction) bool {
+	if f.NoTransfer && t.IsTransfer() {
+		return false
+	}
+	if f.Min != nil && !amountAtLeast(t.Amount, *f.Min) {
+		return false
+	}
+	if f.Max != nil && !amountAtLeast(*f.Max, t.Amount) {
+		return false
+	}
+	if f.Search != "" && !descriptionContains(t, strings.ToLower(f.Search)) {
+		return false
+	}
+	return true
+}
+
+// FilterTransactions returns the movements selected by filter, in storage order
+func (s *TransactionService) FilterTransactions(filter TransactionFilter) ([]domain.Transaction, error) {
+	var batchIDs map[string]bool
+	if filter.Batch != "" {
+		ids, err := s.batchTransactionIDs(filter.Batch)
+		if err != nil {
+			return nil, err
+		}
+		batchIDs = ids
+	}
+
+	var tree *domain.CategoryTree
+	if filter.Where != nil {
+		categories, err := s.storage.GetCategories()
+		if err != nil {
+			return nil, errors.StorageReadFailed("categories", err)
+		}
+		tree = domain.NewCategoryTree(categories)
+	}
+
+	transactions, err := s.QueryTransactions(filter.Query())
+	if err != nil {
+		return nil, err
+	}
+	var filtered []domain.Transaction
+	for _, txn := range filter.View.Apply(transactions) {
+		if !filter.History && !txn.IsActive {
+			continue
+		}
+		if batchIDs != nil && !batchIDs[txn.ID] {
+			continue
+		}
+		if filter.Where != nil && !filter.Where.Matches(txn, tree) {
+			continue
+		}
+		if filter.Matches(txn) {
+			filtered = append(filtered, txn)
+		}
+	}
+	return filtered, nil
+}
+
+// batchTransactionIDs returns the IDs of the movements recorded by a committed batch
+func (s *TransactionService) batchTransactionIDs(batchID string) (map[string]bool, error) {
+	batches, err := s.storage.GetCommittedBatches()
+	if err != nil {
+		return nil, errors.StorageReadFailed("committed_batches", err)
+	}
+
+	var matches []domain.TransactionBatch
+	for _, batch := range batches {
+		if strings.HasPrefix(batch.ID, batchID) {
+			matches = append(matches, batch)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errors.TransactionNotFound(batchID)
+	}
+	if len(matches) > 1 {
+		return nil, errors.AmbiguousID(batchID)
+	}
+
+	ids := make(map[string]bool, len(matches[0].Transactions))
+	for _, txn := range matches[0].Transactions {
+		ids[txn.ID] = true
+	}
+	return ids, nil
+}
+
+// amountAtLeast reports whether amount >= bound; a bound without currency applies to
+// every currency, otherwise amounts in another currency are not comparable
+func amountAtLeast(amount, bound domain.Money) bool {
+	if !amount.SameCurrency(bound) {
+		return false
+	}
+	return amount.Cmp(bound) >= 0
+}
+
+func descriptionContains(t domain.Transaction, search string) bool {
+	if strings.Contains(strings.ToLower(t.Description), search) {
+		return true
+	}
+	for _, split := range t.Splits {
+		if strings.Contains(strings.ToLower(split.Description), search) {
+			return true
+		}
+	}
+	return false
+}
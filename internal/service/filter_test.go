@@ -0,0 +1,81 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func transactionIDs(transactions []domain.Transaction) []string {
+	ids := make([]string, len(transactions))
+	for i, txn := range transactions {
+		ids[i] = txn.ID
+	}
+	return ids
+}
+
+func TestTransactionService_FilterTransactions(t *testing.T) {
+	parent := "ALM"
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorageForBatch{MockStorage: &MockStorage{
+		categories: []domain.Category{
+			{Code: "ALM", Children: []string{"RES"}},
+			{Code: "RES", Parent: &parent},
+		},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Account: "BANQUE", Date: day(1), Amount: domain.NewMoney(-4500, "EUR"), Description: "Pizzeria Napoli", Categories: []string{"RES"}, IsActive: true},
+			{ID: "txn2", Account: "BANQUE", Date: day(5), Amount: domain.NewMoney(-12000, "EUR"), Description: "Supermarché", Categories: []string{"ALM"}, Tags: []string{"FAMILLE"}, IsActive: true},
+			{ID: "txn3", Account: "LIVRET", Date: day(5), Amount: domain.NewMoney(-2000, "EUR"), Description: "Pizza", Categories: []string{"RES"}, IsActive: true},
+			{ID: "txn4", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-3000, "USD"), Description: "Pizza NYC", Categories: []string{"RES"}, IsActive: true},
+			{ID: "txn5", Account: "BANQUE", Date: day(5), Amount: domain.NewMoney(-4500, "EUR"), Description: "Pizzeria", Categories: []string{"RES"}, IsActive: false},
+		},
+	}, committedBatches: []domain.TransactionBatch{
+		{ID: "batch-1234", Transactions: []domain.Transaction{{ID: "txn2"}, {ID: "txn3"}}},
+	}}
+	service := NewTransactionService(mockStorage)
+	min, max := domain.MustParseMoney("-50", ""), domain.MustParseMoney("-40", "EUR")
+
+	tests := []struct {
+		name     string
+		filter   TransactionFilter
+		expected []string
+	}{
+		{"no filter", TransactionFilter{}, []string{"txn1", "txn2", "txn3", "txn4"}},
+		{"history", TransactionFilter{History: true, Search: "pizzeria"}, []string{"txn1", "txn5"}},
+		{"account and search", TransactionFilter{Account: "BANQUE", Search: "PIZZ"}, []string{"txn1", "txn4"}},
+		{"dates", TransactionFilter{From: day(2), To: day(6)}, []string{"txn2", "txn3"}},
+		{"parent category", TransactionFilter{Categories: []string{"ALM"}, Tags: []string{"FAMILLE"}}, []string{"txn2"}},
+		{"amount range", TransactionFilter{Min: &min, Max: &max}, []string{"txn1"}},
+		{"min without currency", TransactionFilter{Min: &min}, []string{"txn1", "txn3", "txn4"}},
+		{"batch prefix", TransactionFilter{Batch: "batch-12", Categories: []string{"RES"}}, []string{"txn3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions, err := service.FilterTransactions(tt.filter)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := transactionIDs(transactions); !equalStrings(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	_, err := service.FilterTransactions(TransactionFilter{Batch: "unknown"})
+	if !hasErrorCode(err, errors.CodeTransactionNotFound) {
+		t.Errorf("Expected an unknown batch to be reported, got %v", err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"time"
+)
+
+// LedgerView selects the state of the ledger that balances and listings show. The zero
+// value is the current ledger; both fields can be combined.
+type LedgerView struct {
+	AsOf       time.Time // When set, only movements dated on or before this day count
+	RecordedAt time.Time // When set, the ledger as it was recorded at this instant (see RecordedState)
+}
+
+// IsCurrent reports whether the view is the current ledger
+func (v LedgerView) IsCurrent() bool {
+	return v.AsOf.IsZero() && v.RecordedAt.IsZero()
+}
+
+// End returns the first day after AsOf, zero without AsOf
+func (v LedgerView) End() time.Time {
+	if v.AsOf.IsZero() {
+		return time.Time{}
+	}
+	return storage.Day(v.AsOf).AddDate(0, 0, 1)
+}
+
+// Apply returns the movements as seen by the view, deleted and edited ones included
+func (v LedgerView) Apply(transactions []domain.Transaction) []domain.Transaction {
+	if !v.RecordedAt.IsZero() {
+		transactions = RecordedState(transactions, v.RecordedAt)
+	}
+	if v.AsOf.IsZero() {
+		return transactions
+	}
+	end := v.End()
+	var dated []domain.Transaction
+	for _, txn := range transactions {
+		if movementDay(txn).Before(end) {
+			dated = append(dated, txn)
+		}
+	}
+	return dated
+}
+
+// HasAccount reports whether an account existed in the view: accounts created after
+// RecordedAt are left out (accounts without creation time always count)
+func (v LedgerView) HasAccount(account domain.Account) bool {
+	return v.RecordedAt.IsZero() || account.CreatedAt.IsZero() || !account.CreatedAt.After(v.RecordedAt)
+}
+
+// RecordedState rebuilds the movements as they stood at instant at, from their CreatedAt
+// and UpdatedAt and the edit chain: movements recorded later (including the new versions
+// made by later edits) are left out, and movements deleted or replaced later are active
+// again. Movements without CreatedAt count as always recorded. Permanently removed
+// movements, undone edits and undone deletions leave no trace and cannot be rebuilt;
+// renamed or merged codes show under their new code.
+func RecordedState(transactions []domain.Transaction, at time.Time) []domain.Transaction {
+	var recorded []domain.Transaction
+	for _, txn := range transactions {
+		if !txn.CreatedAt.IsZero() && txn.CreatedAt.After(at) {
+			continue
+		}
+		if !txn.IsActive && txn.UpdatedAt.After(at) {
+			txn.IsActive = true
+			txn.EditComment = ""
+		}
+		recorded = append(recorded, txn)
+	}
+	return recorded
+}
+
+// viewMovements returns the active movements matching query as seen by view
+func viewMovements(store storage.Storage, query storage.TransactionQuery, view LedgerView) ([]domain.Transaction, error) {
+	if view.RecordedAt.IsZero() {
+		query.Active = storage.ActiveOnly(true)
+	}
+	if end := view.End(); !end.IsZero() && (query.To.IsZero() || end.Before(query.To)) {
+		query.To = end
+	}
+	transactions, err := store.QueryTransactions(query)
+	if err != nil {
+		return nil, errors.StorageReadFailed("transactions", err)
+	}
+	var active []domain.Transaction
+	for _, txn := range view.Apply(transactions) {
+		if txn.IsActive {
+			active = append(active, txn)
+		}
+	}
+	return active, nil
+}
+
+// AccountBalanceIn returns the balance of an account as seen by view: its initial balance
+// plus the movements the view counts
+func (s *TransactionService) AccountBalanceIn(accountID string, view LedgerView) (domain.Money, error) {
+	if view.IsCurrent() {
+		return s.GetAccountBalance(accountID)
+	}
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return domain.Money{}, errors.StorageReadFailed("accounts", err)
+	}
+	account, err := findAccount(accounts, accountID)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	transactions, err := viewMovements(s.storage, storage.TransactionQuery{Account: account.ID}, view)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	balance := account.InitialBalance.WithCurrency(account.Currency)
+	for _, txn := range transactions {
+		balance = balance.Add(txn.Amount)
+	}
+	return balance, nil
+}
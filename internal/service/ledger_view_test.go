@@ -0,0 +1,95 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestTransactionService_LedgerView(t *testing.T) {
+	at := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 9, 0, 0, 0, time.UTC) }
+	// Late evening of January 31st in American Samoa is already February 1st in UTC
+	samoa := time.FixedZone("SST", -11*60*60)
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{
+			{ID: "BANQUE", Name: "Banque", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true, CreatedAt: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: "LATER", Name: "Ouvert en février", Currency: "EUR", IsActive: true, CreatedAt: at(2, 15)},
+			{ID: "SAMOA", Name: "Pago Pago", Currency: "EUR", IsActive: true},
+		},
+		transactions: []domain.Transaction{
+			{ID: "rent", Account: "BANQUE", Date: at(1, 1), Amount: domain.NewMoney(-50000, "EUR"), IsActive: true, CreatedAt: at(1, 1), UpdatedAt: at(1, 1)},
+			// Deleted on January 3rd
+			{ID: "old", Account: "BANQUE", Date: at(1, 2), Amount: domain.NewMoney(-700, "EUR"), IsActive: false, EditComment: "typo", CreatedAt: at(1, 2), UpdatedAt: at(1, 3)},
+			// Recorded on January 5th, edited on February 10th
+			{ID: "groc1", Account: "BANQUE", Date: at(1, 5), Amount: domain.NewMoney(-5000, "EUR"), IsActive: false, EditComment: "wrong amount", CreatedAt: at(1, 5), UpdatedAt: at(2, 10)},
+			// Deleted on March 1st
+			{ID: "gift", Account: "BANQUE", Date: at(1, 20), Amount: domain.NewMoney(10000, "EUR"), IsActive: false, EditComment: "returned", CreatedAt: at(1, 20), UpdatedAt: at(3, 1)},
+			// Dated in the future
+			{ID: "future", Account: "BANQUE", Date: at(12, 31), Amount: domain.NewMoney(-100000, "EUR"), IsActive: true, CreatedAt: at(1, 2), UpdatedAt: at(1, 2)},
+			{ID: "groc2", Account: "BANQUE", Date: at(1, 5), Amount: domain.NewMoney(-8000, "EUR"), IsActive: true, ParentID: "groc1", CreatedAt: at(2, 10), UpdatedAt: at(2, 10)},
+			{ID: "late", Account: "SAMOA", Date: time.Date(2024, 1, 31, 23, 30, 0, 0, samoa), Amount: domain.NewMoney(-1000, "EUR"), IsActive: true, CreatedAt: at(3, 1), UpdatedAt: at(3, 1)},
+		},
+	}
+	service := NewTransactionService(mockStorage)
+	february := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		account  string
+		view     LedgerView
+		expected string
+	}{
+		{"current ledger", "BANQUE", LedgerView{}, "-580.00 EUR"},
+		{"as of a date", "BANQUE", LedgerView{AsOf: time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)}, "420.00 EUR"},
+		{"as of the day of a movement", "BANQUE", LedgerView{AsOf: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)}, "420.00 EUR"},
+		{"as recorded", "BANQUE", LedgerView{RecordedAt: february}, "-450.00 EUR"},
+		{"as recorded and as of", "BANQUE", LedgerView{RecordedAt: february, AsOf: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}, "550.00 EUR"},
+		{"as of the local day of a movement", "SAMOA", LedgerView{AsOf: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}, "-10.00 EUR"},
+		{"as of the day before", "SAMOA", LedgerView{AsOf: time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)}, "0.00 EUR"},
+	}
+	for _, tt := range tests {
+		balance, err := service.AccountBalanceIn(tt.account, tt.view)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.name, err)
+		}
+		if balance.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, balance)
+		}
+	}
+
+	if _, err := service.AccountBalanceIn("NOPE", LedgerView{AsOf: february}); err == nil {
+		t.Error("Expected an unknown account to be refused")
+	}
+	view := LedgerView{RecordedAt: february}
+	if accounts, _ := service.storage.GetAccounts(); view.HasAccount(accounts[1]) || !view.HasAccount(accounts[0]) {
+		t.Error("Expected the account created after the recording instant to be left out")
+	}
+
+	// Movements as recorded on February 1st
+	transactions, err := service.FilterTransactions(TransactionFilter{View: view})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ids := transactionIDs(transactions); !equalStrings(ids, []string{"rent", "groc1", "gift", "future"}) {
+		t.Errorf("Expected the movements active on February 1st, got %v", ids)
+	}
+	if transactions[1].EditComment != "" {
+		t.Errorf("Expected the later edit comment to be cleared, got %q", transactions[1].EditComment)
+	}
+
+	transactions, err = service.FilterTransactions(TransactionFilter{View: view, History: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ids := transactionIDs(transactions); !equalStrings(ids, []string{"rent", "old", "groc1", "gift", "future"}) {
+		t.Errorf("Expected the movements recorded by February 1st, got %v", ids)
+	}
+
+	balances, err := service.RunningBalances(view)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if balances["gift"].String() != "550.00 EUR" {
+		t.Errorf("Expected 550.00 EUR after the gift, got %s", balances["gift"])
+	}
+}
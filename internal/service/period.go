@@ -0,0 +1,65 @@
+package service
+
+import (
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is a range of whole days used by reports. Movements fall in it by the calendar
+// day of their date, as listings show it, whatever its time zone (see storage.Day).
+type Period struct {
+	From  time.Time `json:"from"` // Inclusive
+	To    time.Time `json:"to"`   // Exclusive
+	Label string    `json:"label"`
+}
+
+// Contains reports whether a date falls in the period (by calendar day)
+func (p Period) Contains(date time.Time) bool {
+	day := storage.Day(date)
+	return !day.Before(p.From) && day.Before(p.To)
+}
+
+// MonthPeriod parses a month written YYYY-MM
+func MonthPeriod(text string) (Period, error) {
+	from, err := time.Parse("2006-01", text)
+	if err != nil {
+		return Period{}, errors.InvalidDate(text, fmt.Errorf("expected a month as YYYY-MM"))
+	}
+	return Period{From: from, To: from.AddDate(0, 1, 0), Label: from.Format("2006-01")}, nil
+}
+
+// QuarterPeriod parses a quarter written YYYY-Qn (n from 1 to 4)
+func QuarterPeriod(text string) (Period, error) {
+	year, quarter, found := strings.Cut(strings.ToUpper(text), "-Q")
+	y, yearErr := strconv.Atoi(year)
+	q, quarterErr := strconv.Atoi(quarter)
+	if !found || yearErr != nil || quarterErr != nil || len(year) != 4 || q < 1 || q > 4 {
+		return Period{}, errors.InvalidDate(text, fmt.Errorf("expected a quarter as YYYY-Q1 to YYYY-Q4"))
+	}
+	from := time.Date(y, time.Month(3*(q-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return Period{From: from, To: from.AddDate(0, 3, 0), Label: fmt.Sprintf("%d-Q%d", y, q)}, nil
+}
+
+// YearPeriod parses a year written YYYY
+func YearPeriod(text string) (Period, error) {
+	from, err := time.Parse("2006", text)
+	if err != nil {
+		return Period{}, errors.InvalidDate(text, fmt.Errorf("expected a year as YYYY"))
+	}
+	return Period{From: from, To: from.AddDate(1, 0, 0), Label: from.Format("2006")}, nil
+}
+
+// RangePeriod returns the days from first to last, both included
+func RangePeriod(first, last time.Time) (Period, error) {
+	from := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return Period{}, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidDate,
+			fmt.Sprintf("The period ends (%s) before it starts (%s)", last.Format("2006-01-02"), first.Format("2006-01-02")))
+	}
+	return Period{From: from, To: to, Label: from.Format("2006-01-02") + ".." + last.Format("2006-01-02")}, nil
+}
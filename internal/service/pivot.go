@@ -0,0 +1,318 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PivotDimension is what the rows or the columns of a pivot table stand for
+type PivotDimension string
+
+const (
+	PivotCategory PivotDimension = "category"
+	PivotTag      PivotDimension = "tag"
+	PivotAccount  PivotDimension = "account"
+	PivotMonth    PivotDimension = "month"
+	PivotWeek     PivotDimension = "week"
+)
+
+// ParsePivotDimension validates a dimension given on the command line
+func ParsePivotDimension(value string) (PivotDimension, error) {
+	switch d := PivotDimension(strings.ToLower(value)); d {
+	case PivotCategory, PivotTag, PivotAccount, PivotMonth, PivotWeek:
+		return d, nil
+	}
+	return "", errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand,
+		fmt.Sprintf("Unknown pivot dimension %q (category, tag, account, month or week)", value))
+}
+
+// PivotKey is one row or column of a pivot table
+type PivotKey struct {
+	Key   string `json:"key"` // Empty for movements without category or tag
+	Label string `json:"label"`
+	Depth int    `json:"depth,omitempty"` // Level in the category tree
+}
+
+// PivotTable holds the net amount of movements by two dimensions. A category includes its
+// subcategories and a line counts in each of its tags, so totals count every line once and
+// can be less than the sum of their cells. Averages divide totals by the number of columns
+// (RowAverages) or rows (ColumnAverages); for categories, only top-level ones are counted,
+// since a subcategory is already included in its parent.
+type PivotTable struct {
+	Period         Period           `json:"period"`
+	Currency       string           `json:"currency"`
+	RateDate       RateDate         `json:"rate_date"`
+	Rows           PivotDimension   `json:"rows"`
+	Columns        PivotDimension   `json:"columns"`
+	RowKeys        []PivotKey       `json:"row_keys"`
+	ColumnKeys     []PivotKey       `json:"column_keys"`
+	Cells          [][]domain.Money `json:"cells"` // Cells[row][column]
+	RowTotals      []domain.Money   `json:"row_totals"`
+	RowAverages    []domain.Money   `json:"row_averages"`
+	ColumnTotals   []domain.Money   `json:"column_totals"`
+	ColumnAverages []domain.Money   `json:"column_averages"`
+	Total          domain.Money     `json:"total"`
+	Excluded       []string         `json:"excluded,omitempty"` // Movements without an exchange rate to Currency
+}
+
+// Pivot builds a pivot table of the movements selected and converted like CategoryReport.
+// Month and week columns (or rows) cover the whole period, empty ones included.
+func (s *ReportService) Pivot(filter TransactionFilter, period Period, currency string, rateDate RateDate, rows, columns PivotDimension) (*PivotTable, error) {
+	if rows == columns {
+		return nil, errors.New(errors.ErrorTypeUserInput, errors.CodeInvalidCommand, "Rows and columns of a pivot must differ")
+	}
+	categories, transactions, rates, err := s.load(filter, period)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return nil, errors.StorageReadFailed("accounts", err)
+	}
+	tags, err := s.storage.GetTags()
+	if err != nil {
+		return nil, errors.StorageReadFailed("tags", err)
+	}
+
+	p := &pivot{
+		tree:     domain.NewCategoryTree(categories),
+		currency: currency,
+		cells:    make(map[[2]string]domain.Money),
+		rowSums:  make(map[string]domain.Money),
+		colSums:  make(map[string]domain.Money),
+		total:    domain.NewMoney(0, currency),
+		rowSeen:  make(map[string]bool),
+		colSeen:  make(map[string]bool),
+	}
+	for _, code := range filter.Categories {
+		p.within = append(p.within, p.tree.Descendants(code)...)
+	}
+
+	var excluded []string
+	for _, txn := range transactions {
+		lines, err := convertedLines(rates, txn, currency, rateDate)
+		if err != nil {
+			excluded = append(excluded, txn.ID)
+			continue
+		}
+		for _, line := range lines {
+			if p.within != nil && !anyCodeIn(line.Categories, p.within) {
+				continue
+			}
+			p.add(p.keys(rows, txn, line), p.keys(columns, txn, line), line.Amount)
+		}
+	}
+
+	table := &PivotTable{
+		Period:   period,
+		Currency: currency,
+		RateDate: rateDate,
+		Rows:     rows,
+		Columns:  columns,
+		Total:    p.total,
+		Excluded: excluded,
+	}
+	keysFor := func(d PivotDimension, seen map[string]bool) []PivotKey {
+		switch d {
+		case PivotCategory:
+			return categoryKeys(p.tree, seen)
+		case PivotTag:
+			return tagKeys(tags, seen)
+		case PivotAccount:
+			return accountKeys(accounts, seen)
+		default:
+			return periodKeys(d, period)
+		}
+	}
+	table.RowKeys = keysFor(rows, p.rowSeen)
+	table.ColumnKeys = keysFor(columns, p.colSeen)
+
+	zero := domain.NewMoney(0, currency)
+	table.Cells = make([][]domain.Money, len(table.RowKeys))
+	table.ColumnTotals = make([]domain.Money, len(table.ColumnKeys))
+	table.ColumnAverages = make([]domain.Money, len(table.ColumnKeys))
+	for j, column := range table.ColumnKeys {
+		table.ColumnTotals[j] = p.colSums[column.Key].Add(zero)
+		table.ColumnAverages[j] = average(table.ColumnTotals[j], topLevel(table.RowKeys))
+	}
+	for i, row := range table.RowKeys {
+		table.Cells[i] = make([]domain.Money, len(table.ColumnKeys))
+		for j, column := range table.ColumnKeys {
+			table.Cells[i][j] = p.cells[[2]string{row.Key, column.Key}].Add(zero)
+		}
+		total := p.rowSums[row.Key].Add(zero)
+		table.RowTotals = append(table.RowTotals, total)
+		table.RowAverages = append(table.RowAverages, average(total, topLevel(table.ColumnKeys)))
+	}
+	return table, nil
+}
+
+// pivot accumulates the lines of movements by row and column key
+type pivot struct {
+	tree             *domain.CategoryTree
+	currency         string
+	within           []string // When set, only lines in these categories count
+	cells            map[[2]string]domain.Money
+	rowSums, colSums map[string]domain.Money
+	total            domain.Money
+	rowSeen, colSeen map[string]bool
+}
+
+// add counts an amount once in each of its cells, rows and columns, and in the total
+func (p *pivot) add(rows, columns []string, amount domain.Money) {
+	p.total = p.total.Add(amount)
+	for _, row := range rows {
+		p.rowSeen[row] = true
+		p.rowSums[row] = p.rowSums[row].Add(amount)
+		for _, column := range columns {
+			key := [2]string{row, column}
+			p.cells[key] = p.cells[key].Add(amount)
+		}
+	}
+	for _, column := range columns {
+		p.colSeen[column] = true
+		p.colSums[column] = p.colSums[column].Add(amount)
+	}
+}
+
+// keys returns the keys of a line along a dimension, each once
+func (p *pivot) keys(d PivotDimension, t domain.Transaction, line domain.Split) []string {
+	switch d {
+	case PivotCategory:
+		if len(line.Categories) == 0 {
+			return []string{""}
+		}
+		return rolledCategories(p.tree, line.Categories)
+	case PivotTag:
+		if len(line.Tags) == 0 {
+			return []string{""}
+		}
+		return mergeKeys(line.Tags)
+	case PivotAccount:
+		return []string{t.Account}
+	case PivotWeek:
+		return []string{weekKey(t.Date)}
+	default:
+		return []string{t.Date.Format("2006-01")}
+	}
+}
+
+func mergeKeys(codes []string) []string {
+	var keys []string
+	for _, code := range codes {
+		if !containsCode(keys, code) {
+			keys = append(keys, code)
+		}
+	}
+	return keys
+}
+
+// weekKey returns the ISO week of a date, e.g. 2024-W03
+func weekKey(date time.Time) string {
+	year, week := date.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// categoryKeys returns the categories seen in tree order, then unknown codes and the
+// uncategorised key
+func categoryKeys(tree *domain.CategoryTree, seen map[string]bool) []PivotKey {
+	var keys []PivotKey
+	known := make(map[string]bool)
+	tree.Walk(func(category domain.Category, depth int) {
+		known[category.Code] = true
+		if seen[category.Code] {
+			keys = append(keys, PivotKey{Key: category.Code, Label: category.Name, Depth: depth})
+		}
+	})
+	return append(keys, remainingKeys(seen, known, "Uncategorised")...)
+}
+
+func tagKeys(tags []domain.Tag, seen map[string]bool) []PivotKey {
+	var keys []PivotKey
+	known := make(map[string]bool)
+	sorted := append([]domain.Tag(nil), tags...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Code < sorted[j].Code })
+	for _, tag := range sorted {
+		known[tag.Code] = true
+		if seen[tag.Code] {
+			keys = append(keys, PivotKey{Key: tag.Code, Label: tag.Name})
+		}
+	}
+	return append(keys, remainingKeys(seen, known, "Untagged")...)
+}
+
+func accountKeys(accounts []domain.Account, seen map[string]bool) []PivotKey {
+	var keys []PivotKey
+	known := make(map[string]bool)
+	for _, account := range accounts {
+		known[account.ID] = true
+		if seen[account.ID] {
+			keys = append(keys, PivotKey{Key: account.ID, Label: account.Name})
+		}
+	}
+	return append(keys, remainingKeys(seen, known, "")...)
+}
+
+// remainingKeys returns the keys seen but unknown, sorted and labelled by their code,
+// followed by the empty key labelled empty when seen
+func remainingKeys(seen, known map[string]bool, empty string) []PivotKey {
+	var codes []string
+	for code := range seen {
+		if code != "" && !known[code] {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	keys := make([]PivotKey, 0, len(codes)+1)
+	for _, code := range codes {
+		keys = append(keys, PivotKey{Key: code, Label: code})
+	}
+	if seen[""] {
+		keys = append(keys, PivotKey{Label: empty})
+	}
+	return keys
+}
+
+// periodKeys returns every month or ISO week overlapping the period
+func periodKeys(d PivotDimension, period Period) []PivotKey {
+	var keys []PivotKey
+	start := period.From
+	step := func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	key := func(t time.Time) string { return t.Format("2006-01") }
+	if d == PivotWeek {
+		// Back to the Monday starting the first week
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+		key = weekKey
+	} else {
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	for t := start; t.Before(period.To); t = step(t) {
+		keys = append(keys, PivotKey{Key: key(t), Label: key(t)})
+	}
+	return keys
+}
+
+// average divides a total into n equal parts, rounded to the minor unit
+// topLevel counts the keys that are not below another one (subcategories are)
+func topLevel(keys []PivotKey) int {
+	n := 0
+	for _, key := range keys {
+		if key.Depth == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func average(total domain.Money, n int) domain.Money {
+	if n == 0 {
+		return total
+	}
+	return domain.MoneyFromRat(new(big.Rat).Quo(total.Rat(), big.NewRat(int64(n), 1)), total.Currency)
+}
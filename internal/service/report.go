@@ -0,0 +1,236 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"sort"
+)
+
+// ReportService builds reports on movements, converted into a single currency
+type ReportService struct {
+	storage            storage.Storage
+	transactionService *TransactionService
+	exchangeService    *ExchangeRateService
+}
+
+// NewReportService creates a new report service
+func NewReportService(storage storage.Storage, transactionService *TransactionService, exchangeService *ExchangeRateService) *ReportService {
+	return &ReportService{
+		storage:            storage,
+		transactionService: transactionService,
+		exchangeService:    exchangeService,
+	}
+}
+
+// ReportLine holds the income and expenses of a category, subcategories included
+type ReportLine struct {
+	Code     string       `json:"code"` // Empty for uncategorised amounts
+	Name     string       `json:"name"`
+	Parent   string       `json:"parent,omitempty"`
+	Depth    int          `json:"depth"`
+	Income   domain.Money `json:"income"`
+	Expenses domain.Money `json:"expenses"` // Negative
+	Net      domain.Money `json:"net"`
+}
+
+// CategoryReport is the income, expenses and net of each category over a period. Lines
+// follow the category tree; Total counts every line once, even when it has several categories.
+type CategoryReport struct {
+	Period   Period       `json:"period"`
+	Currency string       `json:"currency"`
+	Lines    []ReportLine `json:"categories"`
+	RateDate RateDate     `json:"rate_date"`
+	Total    ReportLine   `json:"total"`
+	Excluded []string     `json:"excluded,omitempty"` // Movements without an exchange rate to Currency
+}
+
+// CategoryReport totals the active movements of the period selected by filter, except
+// transfers between accounts. Each movement is converted at the rate of its own date, or
+// at today's rate with RateAtToday. When filter names categories, only the lines in them
+// (or below them) are counted.
+func (s *ReportService) CategoryReport(filter TransactionFilter, period Period, currency string, rateDate RateDate) (*CategoryReport, error) {
+	categories, transactions, rates, err := s.load(filter, period)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := domain.NewCategoryTree(categories)
+	r := newRollup(tree, currency, rates, rateDate, filter.Categories)
+	for _, txn := range transactions {
+		r.add(txn)
+	}
+	return &CategoryReport{
+		Period:   period,
+		Currency: currency,
+		RateDate: rateDate,
+		Lines:    r.lines(),
+		Total:    r.total.line(ReportLine{Name: "Total"}),
+		Excluded: r.excluded,
+	}, nil
+}
+
+// load returns the categories, the movements of the period selected by filter (active,
+// transfers left out) and the exchange rates
+func (s *ReportService) load(filter TransactionFilter, period Period) ([]domain.Category, []domain.Transaction, []domain.ExchangeRate, error) {
+	categories, err := s.storage.GetCategories()
+	if err != nil {
+		return nil, nil, nil, errors.StorageReadFailed("categories", err)
+	}
+	filter.From, filter.To = period.From, period.To
+	filter.History = false
+	filter.NoTransfer = true
+	transactions, err := s.transactionService.FilterTransactions(filter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rates, err := s.exchangeService.GetRates()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return categories, transactions, rates, nil
+}
+
+// amounts accumulates income and expenses
+type amounts struct {
+	income, expenses domain.Money
+}
+
+func (a *amounts) add(amount domain.Money) {
+	if amount.IsNegative() {
+		a.expenses = a.expenses.Add(amount)
+	} else {
+		a.income = a.income.Add(amount)
+	}
+}
+
+func (a amounts) line(line ReportLine) ReportLine {
+	line.Income, line.Expenses, line.Net = a.income, a.expenses, a.income.Add(a.expenses)
+	return line
+}
+
+// rollup accumulates the lines of movements by category, each line counting in its
+// categories and their ancestors, once per category
+type rollup struct {
+	tree       *domain.CategoryTree
+	currency   string
+	rates      []domain.ExchangeRate
+	rateDate   RateDate
+	within     []string // When set, only lines in these categories count
+	byCategory map[string]*amounts
+	total      amounts
+	excluded   []string
+}
+
+func newRollup(tree *domain.CategoryTree, currency string, rates []domain.ExchangeRate, rateDate RateDate, categories []string) *rollup {
+	r := &rollup{
+		tree:       tree,
+		currency:   currency,
+		rates:      rates,
+		rateDate:   rateDate,
+		byCategory: make(map[string]*amounts),
+		total:      amounts{domain.NewMoney(0, currency), domain.NewMoney(0, currency)},
+	}
+	for _, code := range categories {
+		r.within = append(r.within, tree.Descendants(code)...)
+	}
+	return r
+}
+
+// convertedLines returns the lines of a movement converted into currency at its date,
+// or at today's rate with RateAtToday
+func convertedLines(rates []domain.ExchangeRate, t domain.Transaction, currency string, rateDate RateDate) ([]domain.Split, error) {
+	lines := t.Lines()
+	for i := range lines {
+		converted, err := convert(rates, lines[i].Amount, currency, rateDate.at(t.Date))
+		if err != nil {
+			return nil, err
+		}
+		lines[i].Amount = converted
+	}
+	return lines, nil
+}
+
+// add counts the lines of a movement; a movement that cannot be converted is excluded whole
+func (r *rollup) add(t domain.Transaction) {
+	lines, err := convertedLines(r.rates, t, r.currency, r.rateDate)
+	if err != nil {
+		r.excluded = append(r.excluded, t.ID)
+		return
+	}
+
+	for _, line := range lines {
+		if r.within != nil && !anyCodeIn(line.Categories, r.within) {
+			continue
+		}
+		r.total.add(line.Amount)
+		if len(line.Categories) == 0 {
+			r.at("").add(line.Amount)
+			continue
+		}
+		for _, code := range rolledCategories(r.tree, line.Categories) {
+			r.at(code).add(line.Amount)
+		}
+	}
+}
+
+// rolledCategories returns codes and their ancestors, each once
+func rolledCategories(tree *domain.CategoryTree, codes []string) []string {
+	var rolled []string
+	for _, code := range codes {
+		for _, c := range append([]string{code}, tree.Ancestors(code)...) {
+			if !containsCode(rolled, c) {
+				rolled = append(rolled, c)
+			}
+		}
+	}
+	return rolled
+}
+
+func (r *rollup) at(code string) *amounts {
+	a, exists := r.byCategory[code]
+	if !exists {
+		a = &amounts{domain.NewMoney(0, r.currency), domain.NewMoney(0, r.currency)}
+		r.byCategory[code] = a
+	}
+	return a
+}
+
+// lines returns the categories with movements in tree order, then codes missing from the
+// categories (sorted) and the uncategorised amounts
+func (r *rollup) lines() []ReportLine {
+	var lines []ReportLine
+	known := make(map[string]bool)
+	r.tree.Walk(func(category domain.Category, depth int) {
+		known[category.Code] = true
+		a, exists := r.byCategory[category.Code]
+		if !exists {
+			return
+		}
+		lines = append(lines, a.line(ReportLine{Code: category.Code, Name: category.Name, Parent: stringValue(category.Parent), Depth: depth}))
+	})
+
+	var orphans []string
+	for code := range r.byCategory {
+		if code != "" && !known[code] {
+			orphans = append(orphans, code)
+		}
+	}
+	sort.Strings(orphans)
+	for _, code := range orphans {
+		lines = append(lines, r.byCategory[code].line(ReportLine{Code: code, Name: code}))
+	}
+	if a, exists := r.byCategory[""]; exists {
+		lines = append(lines, a.line(ReportLine{Name: "Uncategorised"}))
+	}
+	return lines
+}
+
+func anyCodeIn(codes, wanted []string) bool {
+	for _, code := range codes {
+		if containsCode(wanted, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestReportService_CategoryReport(t *testing.T) {
+	parent := "ALM"
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+			{Code: "SLR", Name: "Salaire"},
+			{Code: "VET", Name: "Vêtements"},
+		},
+		rates: []domain.ExchangeRate{{Date: day(1), From: "USD", To: "EUR", Rate: "0.5"}},
+		transactions: []domain.Transaction{
+			{ID: "salary", Account: "BANQUE", Date: day(2), Amount: domain.NewMoney(200000, "EUR"), Categories: []string{"SLR"}, IsActive: true},
+			{ID: "lunch", Account: "BANQUE", Date: day(3), Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "refund", Account: "BANQUE", Date: day(4), Amount: domain.NewMoney(1000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "shop", Account: "BANQUE", Date: day(5), Amount: domain.NewMoney(-12000, "EUR"), IsActive: true, Splits: []domain.Split{
+				{Amount: domain.NewMoney(-9000, "EUR"), Categories: []string{"ALM"}},
+				{Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"VET"}},
+			}},
+			{ID: "nyc", Account: "USDACC", Date: day(6), Amount: domain.NewMoney(-4000, "USD"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "misc", Account: "BANQUE", Date: day(7), Amount: domain.NewMoney(-500, "EUR"), IsActive: true},
+			{ID: "gbp", Account: "GBPACC", Date: day(8), Amount: domain.NewMoney(-700, "GBP"), Categories: []string{"VET"}, IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-99900, "EUR"), Categories: []string{"ALM"}, IsActive: false},
+			{ID: "transfer", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-50000, "EUR"), TransferID: "t1", IsActive: true},
+			{ID: "february", Account: "BANQUE", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-100, "EUR"), Categories: []string{"ALM"}, IsActive: true},
+		},
+	}
+	service := NewReportService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+	period, err := MonthPeriod("2024-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := service.CategoryReport(TransactionFilter{}, period, "EUR", RateAtTransactionDate)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []struct {
+		code                  string
+		depth                 int
+		income, expenses, net string
+	}{
+		{"ALM", 0, "10.00 EUR", "-140.00 EUR", "-130.00 EUR"}, // shop line, lunch, refund and the converted USD lunch
+		{"RES", 1, "10.00 EUR", "-50.00 EUR", "-40.00 EUR"},
+		{"SLR", 0, "2000.00 EUR", "0.00 EUR", "2000.00 EUR"},
+		{"VET", 0, "0.00 EUR", "-30.00 EUR", "-30.00 EUR"},
+		{"", 0, "0.00 EUR", "-5.00 EUR", "-5.00 EUR"},
+	}
+	if len(report.Lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %+v", len(expected), report.Lines)
+	}
+	for i, e := range expected {
+		line := report.Lines[i]
+		if line.Code != e.code || line.Depth != e.depth || line.Income.String() != e.income ||
+			line.Expenses.String() != e.expenses || line.Net.String() != e.net {
+			t.Errorf("Line %d: expected %+v, got %s depth %d %s %s %s", i, e, line.Code, line.Depth, line.Income, line.Expenses, line.Net)
+		}
+	}
+	if report.Total.Net.String() != "1835.00 EUR" {
+		t.Errorf("Expected a total net of 1835.00 EUR, got %s", report.Total.Net)
+	}
+	if len(report.Excluded) != 1 || report.Excluded[0] != "gbp" {
+		t.Errorf("Expected the GBP movement to be excluded for lack of rate, got %v", report.Excluded)
+	}
+}
+
+func TestReportService_CategoryReportWithinCategory(t *testing.T) {
+	parent := "ALM"
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+			{Code: "VET", Name: "Vêtements"},
+		},
+		transactions: []domain.Transaction{
+			{ID: "lunch", Account: "BANQUE", Date: day(3), Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "refund", Account: "BANQUE", Date: day(4), Amount: domain.NewMoney(1000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "shop", Account: "BANQUE", Date: day(5), Amount: domain.NewMoney(-12000, "EUR"), IsActive: true, Splits: []domain.Split{
+				{Amount: domain.NewMoney(-9000, "EUR"), Categories: []string{"ALM"}},
+				{Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"VET"}},
+			}},
+		},
+	}
+	service := NewReportService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+	period, _ := RangePeriod(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC))
+
+	report, err := service.CategoryReport(TransactionFilter{Categories: []string{"ALM"}}, period, "EUR", RateAtTransactionDate)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The VET line of the split receipt is left out
+	if len(report.Lines) != 2 || report.Total.Net.String() != "-110.00 EUR" {
+		t.Errorf("Expected ALM and RES only for -110.00 EUR, got %+v (total %s)", report.Lines, report.Total.Net)
+	}
+}
+
+func TestReportService_CalendarDay(t *testing.T) {
+	// Late evening of October 16th in American Samoa is already October 17th in UTC
+	samoa := time.FixedZone("SST", -11*60*60)
+	mockStorage := &MockStorage{
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}},
+		transactions: []domain.Transaction{
+			{ID: "late", Account: "BANQUE", Date: time.Date(2026, 10, 16, 23, 30, 0, 0, samoa), Amount: domain.NewMoney(-500, "EUR"), Categories: []string{"ALM"}, IsActive: true},
+		},
+	}
+	service := NewReportService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+
+	for day, expected := range map[int]string{16: "-5.00 EUR", 17: "0.00 EUR"} {
+		date := time.Date(2026, 10, day, 0, 0, 0, 0, time.UTC)
+		period, _ := RangePeriod(date, date)
+		report, err := service.CategoryReport(TransactionFilter{}, period, "EUR", RateAtTransactionDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Total.Net.String() != expected {
+			t.Errorf("Expected a net of %s on October %d, got %s", expected, day, report.Total.Net)
+		}
+	}
+}
+
+func TestReportService_RateDate(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		accounts:   []domain.Account{{ID: "USDACC", Currency: "USD", IsActive: true}},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}},
+		budgets:    []domain.Budget{{Category: "ALM", Amount: domain.NewMoney(10000, "USD"), Period: domain.BudgetMonthly, ValidFrom: day(1, 1)}},
+		rates: []domain.ExchangeRate{
+			{Date: day(1, 1), From: "USD", To: "EUR", Rate: "0.5"},
+			{Date: day(6, 1), From: "USD", To: "EUR", Rate: "0.8"},
+		},
+		transactions: []domain.Transaction{
+			{ID: "nyc", Account: "USDACC", Date: day(1, 6), Amount: domain.NewMoney(-4000, "USD"), Categories: []string{"ALM"}, IsActive: true},
+		},
+	}
+	service := NewReportService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+	period, _ := MonthPeriod("2024-01")
+
+	tests := []struct {
+		rateDate                 RateDate
+		net, budgeted, pivotCell string
+	}{
+		{RateAtTransactionDate, "-20.00 EUR", "50.00 EUR", "-20.00 EUR"},
+		{RateAtToday, "-32.00 EUR", "80.00 EUR", "-32.00 EUR"},
+	}
+	for _, test := range tests {
+		report, err := service.CategoryReport(TransactionFilter{}, period, "EUR", test.rateDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Total.Net.String() != test.net || report.RateDate != test.rateDate {
+			t.Errorf("%s: expected a net of %s, got %s", test.rateDate, test.net, report.Total.Net)
+		}
+		table, err := service.Pivot(TransactionFilter{}, period, "EUR", test.rateDate, PivotCategory, PivotMonth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.Total.String() != test.pivotCell {
+			t.Errorf("%s: expected a pivot total of %s, got %s", test.rateDate, test.pivotCell, table.Total)
+		}
+		budget, err := service.BudgetReport(TransactionFilter{}, period, "EUR", test.rateDate, day(1, 31))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if budget.Total.Budgeted.String() != test.budgeted {
+			t.Errorf("%s: expected %s budgeted, got %s", test.rateDate, test.budgeted, budget.Total.Budgeted)
+		}
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	tests := []struct {
+		parse    func(string) (Period, error)
+		text     string
+		from, to string
+	}{
+		{MonthPeriod, "2024-02", "2024-02-01", "2024-03-01"},
+		{QuarterPeriod, "2024-q4", "2024-10-01", "2025-01-01"},
+		{YearPeriod, "2023", "2023-01-01", "2024-01-01"},
+	}
+	for _, tt := range tests {
+		period, err := tt.parse(tt.text)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.text, err)
+		}
+		if period.From.Format("2006-01-02") != tt.from || period.To.Format("2006-01-02") != tt.to {
+			t.Errorf("%s: expected [%s, %s), got [%s, %s)", tt.text, tt.from, tt.to, period.From, period.To)
+		}
+	}
+
+	for _, invalid := range []string{"2024-Q5", "2024Q1", "24-Q1"} {
+		if _, err := QuarterPeriod(invalid); err == nil {
+			t.Errorf("Expected %q to be refused", invalid)
+		}
+	}
+}
+
+func TestReportService_Pivot(t *testing.T) {
+	parent := "ALM"
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{{ID: "BANQUE", Name: "Banque"}, {ID: "USDACC", Name: "Dollars"}},
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+			{Code: "SLR", Name: "Salaire"},
+			{Code: "VET", Name: "Vêtements"},
+		},
+		rates: []domain.ExchangeRate{{Date: day(1), From: "USD", To: "EUR", Rate: "0.5"}},
+		transactions: []domain.Transaction{
+			{ID: "salary", Account: "BANQUE", Date: day(2), Amount: domain.NewMoney(200000, "EUR"), Categories: []string{"SLR"}, IsActive: true},
+			{ID: "lunch", Account: "BANQUE", Date: day(3), Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "refund", Account: "BANQUE", Date: day(4), Amount: domain.NewMoney(1000, "EUR"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "shop", Account: "BANQUE", Date: day(5), Amount: domain.NewMoney(-12000, "EUR"), IsActive: true, Splits: []domain.Split{
+				{Amount: domain.NewMoney(-9000, "EUR"), Categories: []string{"ALM"}},
+				{Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"VET"}},
+			}},
+			{ID: "nyc", Account: "USDACC", Date: day(6), Amount: domain.NewMoney(-4000, "USD"), Categories: []string{"RES"}, IsActive: true},
+			{ID: "misc", Account: "BANQUE", Date: day(7), Amount: domain.NewMoney(-500, "EUR"), IsActive: true},
+			{ID: "gbp", Account: "GBPACC", Date: day(8), Amount: domain.NewMoney(-700, "GBP"), Categories: []string{"VET"}, IsActive: true},
+			{ID: "deleted", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-99900, "EUR"), Categories: []string{"ALM"}, IsActive: false},
+			{ID: "transfer", Account: "BANQUE", Date: day(9), Amount: domain.NewMoney(-50000, "EUR"), TransferID: "t1", IsActive: true},
+			{ID: "february", Account: "BANQUE", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Amount: domain.NewMoney(-100, "EUR"), Categories: []string{"ALM"}, IsActive: true},
+		},
+	}
+	service := NewReportService(mockStorage, NewTransactionService(mockStorage), NewExchangeRateService(mockStorage))
+	period, _ := RangePeriod(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+
+	table, err := service.Pivot(TransactionFilter{}, period, "EUR", RateAtTransactionDate, PivotCategory, PivotMonth)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(table.ColumnKeys) != 2 || table.ColumnKeys[0].Key != "2024-01" || table.ColumnKeys[1].Key != "2024-02" {
+		t.Fatalf("Expected one column per month, got %+v", table.ColumnKeys)
+	}
+	if table.RowKeys[0].Key != "ALM" || table.RowKeys[1].Key != "RES" || table.RowKeys[1].Depth != 1 {
+		t.Fatalf("Expected rows in tree order, got %+v", table.RowKeys)
+	}
+	if table.Cells[0][0].String() != "-130.00 EUR" || table.Cells[0][1].String() != "-1.00 EUR" {
+		t.Errorf("Expected ALM cells -130.00 and -1.00 EUR, got %v", table.Cells[0])
+	}
+	if table.RowTotals[0].String() != "-131.00 EUR" || table.RowAverages[0].String() != "-65.50 EUR" {
+		t.Errorf("Expected ALM total -131.00 and average -65.50 EUR, got %s and %s", table.RowTotals[0], table.RowAverages[0])
+	}
+	// ALM already includes RES: column totals count every line once
+	if table.ColumnTotals[0].String() != "1835.00 EUR" || table.Total.String() != "1834.00 EUR" {
+		t.Errorf("Expected January total 1835.00 and overall 1834.00 EUR, got %s and %s", table.ColumnTotals[0], table.Total)
+	}
+	if last := table.RowKeys[len(table.RowKeys)-1]; last.Key != "" || last.Label != "Uncategorised" {
+		t.Errorf("Expected the uncategorised row last, got %+v", last)
+	}
+	// RES is inside ALM: averages count the top-level rows ALM, SLR, VET and uncategorised
+	if table.ColumnAverages[0].String() != "458.75 EUR" {
+		t.Errorf("Expected a January average of 458.75 EUR over 4 top-level rows, got %s", table.ColumnAverages[0])
+	}
+
+	table, err = service.Pivot(TransactionFilter{}, period, "EUR", RateAtTransactionDate, PivotMonth, PivotCategory)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if table.RowAverages[0].String() != "458.75 EUR" {
+		t.Errorf("Expected a January average of 458.75 EUR over 4 top-level columns, got %s", table.RowAverages[0])
+	}
+
+	table, err = service.Pivot(TransactionFilter{}, period, "EUR", RateAtTransactionDate, PivotAccount, PivotWeek)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// 2024-01-01 is a Monday; 2024-02-29 falls in week 9
+	if len(table.ColumnKeys) != 9 || table.ColumnKeys[0].Key != "2024-W01" {
+		t.Errorf("Expected weeks 1 to 9, got %+v", table.ColumnKeys)
+	}
+	if len(table.RowKeys) != 2 || table.RowKeys[1].Label != "Dollars" || table.RowTotals[1].String() != "-20.00 EUR" {
+		t.Errorf("Expected BANQUE and USDACC rows, got %+v %v", table.RowKeys, table.RowTotals)
+	}
+
+	if _, err := service.Pivot(TransactionFilter{}, period, "EUR", RateAtTransactionDate, PivotTag, PivotTag); err == nil {
+		t.Error("Expected identical rows and columns to be refused")
+	}
+}
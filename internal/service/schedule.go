@@ -0,0 +1,250 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScheduleService manages recurring rules and posts their due occurrences
+type ScheduleService struct {
+	storage            storage.Storage
+	transactionService *TransactionService
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(storage storage.Storage, transactionService *TransactionService) *ScheduleService {
+	return &ScheduleService{
+		storage:            storage,
+		transactionService: transactionService,
+	}
+}
+
+// Rules returns the recurring rules sorted by ID
+func (s *ScheduleService) Rules() ([]domain.RecurringRule, error) {
+	rules, err := s.storage.GetRecurringRules()
+	if err != nil {
+		return nil, errors.StorageReadFailed("recurring", err)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+// AddRule adds a recurring rule. Its ID follows the rules of category codes and cannot be
+// the ID of a removed rule whose movements are kept, as their occurrence keys would match
+// the new rule's. Its movement must be valid as of its first occurrence (account,
+// categories, tags).
+func (s *ScheduleService) AddRule(rule domain.RecurringRule) error {
+	rule.ID = strings.ToUpper(rule.ID)
+	if !codePattern.MatchString(rule.ID) {
+		return errors.InvalidCode(rule.ID)
+	}
+	if err := rule.Validate(); err != nil {
+		return errors.InvalidRule(rule.ID, err)
+	}
+	rule.CreatedAt = time.Now()
+
+	return s.storage.Atomically(func() error {
+		rules, err := s.storage.GetRecurringRules()
+		if err != nil {
+			return errors.StorageReadFailed("recurring", err)
+		}
+		for _, existing := range rules {
+			if existing.ID == rule.ID {
+				return errors.DuplicateCode("recurring rule", rule.ID)
+			}
+		}
+		posted, err := s.postedOccurrences()
+		if err != nil {
+			return err
+		}
+		for key := range posted {
+			if strings.HasPrefix(key, rule.ID+"@") {
+				return errors.RuleIDUsed(rule.ID)
+			}
+		}
+		prepared, err := s.transactionService.PrepareTransaction(ruleMovement(rule, rule.Start))
+		if err != nil {
+			return err
+		}
+		rule.Amount = prepared.Amount
+		if err := s.storage.SaveRecurringRules(append(rules, rule)); err != nil {
+			return errors.StorageWriteFailed("recurring", err)
+		}
+		return nil
+	})
+}
+
+// RemoveRule removes a recurring rule; the movements it posted are kept
+func (s *ScheduleService) RemoveRule(id string) (*domain.RecurringRule, error) {
+	id = strings.ToUpper(id)
+	var removed *domain.RecurringRule
+	err := s.storage.Atomically(func() error {
+		rules, err := s.storage.GetRecurringRules()
+		if err != nil {
+			return errors.StorageReadFailed("recurring", err)
+		}
+		for i, rule := range rules {
+			if rule.ID == id {
+				removed = &rule
+				if err := s.storage.SaveRecurringRules(append(rules[:i], rules[i+1:]...)); err != nil {
+					return errors.StorageWriteFailed("recurring", err)
+				}
+				return nil
+			}
+		}
+		return errors.RuleNotFound(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// Due returns the movements of the occurrences up to through (included) that were never
+// posted, oldest first
+func (s *ScheduleService) Due(through time.Time) ([]domain.Transaction, error) {
+	rules, err := s.storage.GetRecurringRules()
+	if err != nil {
+		return nil, errors.StorageReadFailed("recurring", err)
+	}
+	posted, err := s.postedOccurrences()
+	if err != nil {
+		return nil, err
+	}
+
+	var due []domain.Transaction
+	for _, rule := range rules {
+		for _, date := range rule.Occurrences(through) {
+			if !posted[rule.OccurrenceKey(date)] {
+				due = append(due, ruleMovement(rule, date))
+			}
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if !due[i].Date.Equal(due[j].Date) {
+			return due[i].Date.Before(due[j].Date)
+		}
+		return due[i].Occurrence < due[j].Occurrence
+	})
+	return due, nil
+}
+
+// Run puts the due occurrences up to through (included) into a new pending batch, to be
+// reviewed then committed or rolled back; it returns nil when nothing is due. An
+// occurrence already posted, even deleted since, or waiting in a pending batch is never
+// posted again; one whose batch was rolled back is due again.
+func (s *ScheduleService) Run(through time.Time, description string) (*domain.TransactionBatch, error) {
+	var batch *domain.TransactionBatch
+	err := s.storage.Atomically(func() error {
+		due, err := s.Due(through)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		for i := range due {
+			due[i].ID = uuid.New().String()
+			due[i].CreatedAt, due[i].UpdatedAt = now, now
+		}
+		batch = &domain.TransactionBatch{
+			ID:           uuid.New().String(),
+			Description:  description,
+			CreatedAt:    now,
+			Transactions: due,
+		}
+		pending, err := s.storage.GetPendingBatches()
+		if err != nil {
+			return errors.StorageReadFailed("pending_transactions", err)
+		}
+		if err := s.storage.SavePendingBatches(append(pending, *batch)); err != nil {
+			return errors.StorageWriteFailed("pending_transactions", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// postedOccurrences returns the keys of the occurrences found in the movements (deleted
+// and edited ones included) and in pending batches
+func (s *ScheduleService) postedOccurrences() (map[string]bool, error) {
+	transactions, err := s.storage.GetTransactions()
+	if err != nil {
+		return nil, errors.StorageReadFailed("transactions", err)
+	}
+	pending, err := s.storage.GetPendingBatches()
+	if err != nil {
+		return nil, errors.StorageReadFailed("pending_transactions", err)
+	}
+	for _, batch := range pending {
+		transactions = append(transactions, batch.Transactions...)
+	}
+
+	posted := make(map[string]bool)
+	for _, txn := range transactions {
+		if txn.Occurrence != "" {
+			posted[txn.Occurrence] = true
+		}
+	}
+	return posted, nil
+}
+
+// ruleMovement returns the movement of the occurrence of rule on date
+func ruleMovement(rule domain.RecurringRule, date time.Time) domain.Transaction {
+	description := rule.Description
+	if description == "" {
+		description = rule.ID
+	}
+	return domain.Transaction{
+		Account:     rule.Account,
+		Date:        date,
+		Amount:      rule.Amount,
+		Description: description,
+		Categories:  append([]string{}, rule.Categories...),
+		Tags:        append([]string{}, rule.Tags...),
+		IsActive:    true,
+		Occurrence:  rule.OccurrenceKey(date),
+	}
+}
+
+// rewriteRules moves the references of recurring rules to code over to target
+func rewriteRules(store storage.Storage, kind, code, target string) error {
+	rules, err := store.GetRecurringRules()
+	if err != nil {
+		return errors.StorageReadFailed("recurring", err)
+	}
+	changed := false
+	for i := range rules {
+		movement := []domain.Transaction{ruleMovement(rules[i], rules[i].Start)}
+		if len(remapReferences(movement, kind, code, target, time.Time{})) == 0 {
+			continue
+		}
+		rules[i].Account, rules[i].Categories, rules[i].Tags = movement[0].Account, movement[0].Categories, movement[0].Tags
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	if err := store.SaveRecurringRules(rules); err != nil {
+		return errors.StorageWriteFailed("recurring", err)
+	}
+	return nil
+}
+
+// ruleReferencing returns the first recurring rule that uses code, or "" when none does
+func ruleReferencing(rules []domain.RecurringRule, kind, code string) string {
+	for _, rule := range rules {
+		if references(ruleMovement(rule, rule.Start), kind, code) {
+			return rule.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleService_AddRuleValidates(t *testing.T) {
+	mockStorage := NewMockStorageForBatch()
+	mockStorage.rules = []domain.RecurringRule{{
+		ID: "LOYER", Account: "account1", Amount: domain.NewMoney(-85000, "EUR"), Description: "Loyer",
+		Categories: []string{"ALM"}, Tags: []string{"REC"}, Frequency: domain.Monthly, Day: 5,
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}}
+	service := NewScheduleService(mockStorage, NewTransactionService(mockStorage))
+	rule := domain.RecurringRule{ID: "salaire", Account: "account1", Amount: domain.NewMoney(250000, ""),
+		Categories: []string{"ALM"}, Frequency: domain.Monthly, Start: time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC)}
+
+	if err := service.AddRule(rule); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if added := mockStorage.rules[1]; added.ID != "SALAIRE" || added.Amount.String() != "2500.00 EUR" {
+		t.Errorf("Expected the ID upper-cased and the account currency, got %+v", added)
+	}
+
+	tests := []struct {
+		name string
+		edit func(r *domain.RecurringRule)
+		code string
+	}{
+		{"duplicate", func(r *domain.RecurringRule) {}, errors.CodeDuplicateCode},
+		{"bad code", func(r *domain.RecurringRule) { r.ID = "SAL AIRE" }, errors.CodeInvalidCode},
+		{"bad frequency", func(r *domain.RecurringRule) { r.ID, r.Frequency = "PRIME", "hourly" }, errors.CodeInvalidRule},
+		{"unknown account", func(r *domain.RecurringRule) { r.ID, r.Account = "PRIME", "NOPE" }, errors.CodeAccountNotFound},
+	}
+	for _, test := range tests {
+		invalid := rule
+		test.edit(&invalid)
+		err := service.AddRule(invalid)
+		if !hasErrorCode(err, test.code) {
+			t.Errorf("%s: expected %s, got %v", test.name, test.code, err)
+		}
+	}
+	if len(mockStorage.rules) != 2 {
+		t.Errorf("Expected the invalid rules not to be saved, got %+v", mockStorage.rules)
+	}
+}
+
+func TestScheduleService_RunNeverPostsTwice(t *testing.T) {
+	mockStorage := NewMockStorageForBatch()
+	mockStorage.rules = []domain.RecurringRule{{
+		ID: "LOYER", Account: "account1", Amount: domain.NewMoney(-85000, "EUR"), Description: "Loyer",
+		Categories: []string{"ALM"}, Tags: []string{"REC"}, Frequency: domain.Monthly, Day: 5,
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}}
+	transactionService := NewTransactionService(mockStorage)
+	service := NewScheduleService(mockStorage, transactionService)
+	batchService := NewTransactionBatchService(mockStorage, transactionService)
+	through := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	batch, err := service.Run(through, "Recurring")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if batch == nil || len(batch.Transactions) != 3 || len(mockStorage.pendingBatches) != 1 {
+		t.Fatalf("Expected a pending batch with January to March, got %+v", batch)
+	}
+	if first := batch.Transactions[0]; first.Occurrence != "LOYER@2024-01-05" || first.ID == "" || first.Description != "Loyer" {
+		t.Errorf("Expected the January occurrence first, got %+v", first)
+	}
+
+	// Occurrences waiting in a pending batch are not due
+	if again, err := service.Run(through, "Recurring"); err != nil || again != nil {
+		t.Fatalf("Expected nothing due while the batch is pending, got %+v, %v", again, err)
+	}
+
+	// A rolled back batch makes its occurrences due again
+	if err := batchService.RollbackBatch(batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	if due, _ := service.Due(through); len(due) != 3 {
+		t.Fatalf("Expected 3 occurrences due after the rollback, got %d", len(due))
+	}
+
+	batch, _ = service.Run(through, "Recurring")
+	if err := batchService.CommitBatch(batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transactionService.EditTransaction(batch.Transactions[0].ID, domain.Transaction{Amount: domain.NewMoney(-90000, "EUR")}, "Raise"); err != nil {
+		t.Fatal(err)
+	}
+	if err := transactionService.DeleteTransaction(batch.Transactions[1].ID, "Paid cash"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Edited and deleted occurrences stay posted; only April is due
+	due, err := service.Due(time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].Occurrence != "LOYER@2024-04-05" {
+		t.Errorf("Expected only the April occurrence due, got %+v", due)
+	}
+}
+
+func TestScheduleService_CodesFollowRules(t *testing.T) {
+	mockStorage := NewMockStorageForBatch()
+	mockStorage.rules = []domain.RecurringRule{{
+		ID: "LOYER", Account: "account1", Amount: domain.NewMoney(-85000, "EUR"), Description: "Loyer",
+		Categories: []string{"ALM"}, Tags: []string{"REC"}, Frequency: domain.Monthly, Day: 5,
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}}
+	codes := NewCodeService(mockStorage)
+
+	err := codes.Remove(ConfigCategory, "ALM")
+	if !hasErrorCode(err, errors.CodeCodeInUse) {
+		t.Errorf("Expected code_in_use removing a category used by a rule, got %v", err)
+	}
+	if _, err := codes.Rename(ConfigTag, "REC", "MEN"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tags := mockStorage.rules[0].Tags; len(tags) != 1 || tags[0] != "MEN" {
+		t.Errorf("Expected the rule to follow the renamed tag, got %v", tags)
+	}
+}
+
+func TestScheduleService_RemovedRuleIDNotReused(t *testing.T) {
+	mockStorage := NewMockStorageForBatch()
+	rent := domain.RecurringRule{
+		ID: "LOYER", Account: "account1", Amount: domain.NewMoney(-85000, "EUR"), Description: "Loyer",
+		Categories: []string{"ALM"}, Frequency: domain.Monthly, Day: 5,
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	mockStorage.rules = []domain.RecurringRule{rent}
+	service := NewScheduleService(mockStorage, NewTransactionService(mockStorage))
+
+	if _, err := service.Run(time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), "Recurring"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.RemoveRule("loyer"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The posted occurrences would hide those of a new rule with the same ID
+	rent.Start = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := service.AddRule(rent); !hasErrorCode(err, errors.CodeDuplicateCode) {
+		t.Errorf("Expected the ID of a removed rule with posted movements to be refused, got %v", err)
+	}
+	rent.ID = "LOYER2"
+	if err := service.AddRule(rent); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if due, _ := service.Due(time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)); len(due) != 1 || due[0].Occurrence != "LOYER2@2024-02-05" {
+		t.Errorf("Expected the February occurrence of the new rule due, got %+v", due)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestTransactionService_AddTransaction_Splits(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts:   []domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "VET", Name: "Vêtements"}},
+		tags:       []domain.Tag{{Code: "URG", Name: "Urgent"}},
+	}
+	service := NewTransactionService(mockStorage)
+
+	txn := domain.Transaction{
+		ID:          "split1",
+		Account:     "BANQUE",
+		Date:        time.Now(),
+		Description: "Supermarché",
+		Splits: []domain.Split{
+			{Amount: domain.NewMoney(-9000, ""), Categories: []string{"ALM"}},
+			{Amount: domain.NewMoney(-3000, ""), Categories: []string{"VET"}, Tags: []string{"URG"}},
+		},
+		IsActive: true,
+	}
+
+	if err := service.AddTransaction(txn); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	saved := mockStorage.transactions[0]
+	if saved.Amount != domain.NewMoney(-12000, "EUR") {
+		t.Errorf("Expected amount computed from splits -120.00 EUR, got %s", saved.Amount)
+	}
+	if len(saved.Categories) != 2 || saved.Categories[0] != "ALM" || saved.Categories[1] != "VET" {
+		t.Errorf("Expected categories [ALM VET], got %v", saved.Categories)
+	}
+	if saved.Splits[0].Amount.Currency != "EUR" {
+		t.Errorf("Expected split currency filled from account, got %q", saved.Splits[0].Amount.Currency)
+	}
+
+	amounts := saved.CategoryAmounts()
+	if amounts["ALM"] != domain.NewMoney(-9000, "EUR") || amounts["VET"] != domain.NewMoney(-3000, "EUR") {
+		t.Errorf("Expected ALM -90.00 and VET -30.00, got %v", amounts)
+	}
+}
+
+func TestTransactionService_AddTransaction_InvalidSplits(t *testing.T) {
+	invalid := map[string][]domain.Split{
+		"sum mismatch": {
+			{Amount: domain.NewMoney(-9000, ""), Categories: []string{"ALM"}},
+			{Amount: domain.NewMoney(-2000, ""), Categories: []string{"VET"}},
+		},
+		"unknown category": {
+			{Amount: domain.NewMoney(-12000, ""), Categories: []string{"XXX"}},
+		},
+		"unknown tag": {
+			{Amount: domain.NewMoney(-12000, ""), Categories: []string{"ALM"}, Tags: []string{"XXX"}},
+		},
+		"zero line": {
+			{Amount: domain.NewMoney(-12000, ""), Categories: []string{"ALM"}},
+			{Amount: domain.NewMoney(0, ""), Categories: []string{"VET"}},
+		},
+	}
+
+	for name, splits := range invalid {
+		mockStorage := &MockStorage{
+			accounts:   []domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}},
+			categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "VET", Name: "Vêtements"}},
+			tags:       []domain.Tag{{Code: "URG", Name: "Urgent"}},
+		}
+		service := NewTransactionService(mockStorage)
+
+		err := service.AddTransaction(domain.Transaction{
+			ID:          "split1",
+			Account:     "BANQUE",
+			Amount:      domain.NewMoney(-12000, "EUR"),
+			Date:        time.Now(),
+			Description: "Supermarché",
+			Splits:      splits,
+			IsActive:    true,
+		})
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+		if len(mockStorage.transactions) != 0 {
+			t.Errorf("%s: expected nothing saved", name)
+		}
+	}
+}
+
+func TestTransactionService_EditTransaction_ReplacesSplits(t *testing.T) {
+	mockStorage := &MockStorage{
+		accounts:   []domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{{Code: "ALM", Name: "Alimentation"}, {Code: "VET", Name: "Vêtements"}},
+	}
+	service := NewTransactionService(mockStorage)
+
+	original := domain.Transaction{
+		ID:          "split1",
+		Account:     "BANQUE",
+		Date:        time.Now(),
+		Description: "Supermarché",
+		Splits: []domain.Split{
+			{Amount: domain.NewMoney(-9000, ""), Categories: []string{"ALM"}},
+			{Amount: domain.NewMoney(-3000, ""), Categories: []string{"VET"}},
+		},
+		IsActive: true,
+	}
+	if err := service.AddTransaction(original); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	edited, err := service.EditTransaction("split1", domain.Transaction{
+		ID: "split2",
+		Splits: []domain.Split{
+			{Amount: domain.NewMoney(-10000, ""), Categories: []string{"ALM"}},
+		},
+	}, "Ticket corrigé")
+	if err != nil {
+		t.Fatalf("Expected no error editing splits, got %v", err)
+	}
+
+	if edited.Amount != domain.NewMoney(-10000, "EUR") {
+		t.Errorf("Expected amount recomputed to -100.00 EUR, got %s", edited.Amount)
+	}
+	if len(edited.Categories) != 1 || edited.Categories[0] != "ALM" {
+		t.Errorf("Expected categories [ALM], got %v", edited.Categories)
+	}
+}
+
+func TestTransactionService_AddTransaction_SplitsAcrossParentAndChild(t *testing.T) {
+	parent := "ALM"
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{
+			{Code: "ALM", Name: "Alimentation", Children: []string{"RES"}},
+			{Code: "RES", Name: "Restaurants", Parent: &parent},
+		},
+	}
+	service := NewTransactionService(mockStorage)
+
+	txn := domain.Transaction{
+		ID:      "split1",
+		Account: "BANQUE",
+		Date:    time.Now(),
+		Splits: []domain.Split{
+			{Amount: domain.NewMoney(-9000, ""), Categories: []string{"ALM"}},
+			{Amount: domain.NewMoney(-2500, ""), Categories: []string{"RES"}},
+		},
+		IsActive: true,
+	}
+	if err := service.AddTransaction(txn); err != nil {
+		t.Fatalf("Expected a split across a parent and its child to be accepted, got %v", err)
+	}
+
+	// A single line still cannot hold both
+	txn.ID = "split2"
+	txn.Splits = []domain.Split{
+		{Amount: domain.NewMoney(-9000, ""), Categories: []string{"ALM", "RES"}},
+		{Amount: domain.NewMoney(-2500, ""), Categories: []string{"RES"}},
+	}
+	if err := service.AddTransaction(txn); err == nil {
+		t.Error("Expected a line with a parent and its child to be refused")
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"comptes/internal/domain"
 	"comptes/internal/errors"
 	"comptes/internal/storage"
+	"fmt"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // TransactionService handles transaction operations
@@ -22,8 +25,15 @@ func NewTransactionService(storage storage.Storage) *TransactionService {
 
 // AddTransaction adds a new transaction
 func (s *TransactionService) AddTransaction(transaction domain.Transaction) error {
-	// Validate transaction
-	if err := s.ValidateTransaction(transaction); err != nil {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return err
+	}
+	defer s.storage.Unlock()
+
+	// Validate transaction and set its currency
+	transaction, err := s.PrepareTransaction(transaction)
+	if err != nil {
 		return err
 	}
 
@@ -49,12 +59,39 @@ func (s *TransactionService) GetTransactions() ([]domain.Transaction, error) {
 	return s.storage.GetTransactions()
 }
 
+// QueryTransactions returns the transactions matching query, filtered by the storage.
+// A category also selects the movements of its subcategories.
+func (s *TransactionService) QueryTransactions(query storage.TransactionQuery) ([]domain.Transaction, error) {
+	if len(query.Categories) > 0 {
+		categories, err := s.storage.GetCategories()
+		if err != nil {
+			return nil, errors.StorageReadFailed("categories", err)
+		}
+		tree := domain.NewCategoryTree(categories)
+		var expanded []string
+		for _, code := range query.Categories {
+			for _, descendant := range tree.Descendants(code) {
+				if !containsCode(expanded, descendant) {
+					expanded = append(expanded, descendant)
+				}
+			}
+		}
+		query.Categories = expanded
+	}
+
+	transactions, err := s.storage.QueryTransactions(query)
+	if err != nil {
+		return nil, errors.StorageReadFailed("transactions", err)
+	}
+	return transactions, nil
+}
+
 // GetAccountBalance calculates the current balance for an account
-func (s *TransactionService) GetAccountBalance(accountID string) (float64, error) {
+func (s *TransactionService) GetAccountBalance(accountID string) (domain.Money, error) {
 	// Verify account exists
 	accounts, err := s.storage.GetAccounts()
 	if err != nil {
-		return 0, errors.StorageReadFailed("accounts", err)
+		return domain.Money{}, errors.StorageReadFailed("accounts", err)
 	}
 
 	// Find the account
@@ -67,7 +104,7 @@ func (s *TransactionService) GetAccountBalance(accountID string) (float64, error
 	}
 
 	if !accountFound {
-		return 0, errors.AccountNotFound(accountID)
+		return domain.Money{}, errors.AccountNotFound(accountID)
 	}
 
 	// Delegate balance calculation to storage
@@ -82,17 +119,25 @@ func (s *TransactionService) ValidateTransaction(transaction domain.Transaction)
 		return errors.StorageReadFailed("accounts", err)
 	}
 
-	accountExists := false
-	for _, account := range accounts {
-		if account.ID == transaction.Account {
-			accountExists = true
+	var account *domain.Account
+	for i := range accounts {
+		if accounts[i].ID == transaction.Account {
+			account = &accounts[i]
 			break
 		}
 	}
 
-	if !accountExists {
+	if account == nil {
 		return errors.AccountNotFound(transaction.Account)
 	}
+	if !account.IsActive {
+		return errors.AccountClosed(account.ID)
+	}
+
+	// Amount must be in the account currency (or without currency, filled on save)
+	if transaction.Amount.Currency != "" && account.Currency != "" && transaction.Amount.Currency != account.Currency {
+		return errors.CurrencyMismatch(account.ID, account.Currency, transaction.Amount.Currency)
+	}
 
 	// Check if categories exist
 	categories, err := s.storage.GetCategories()
@@ -110,6 +155,13 @@ func (s *TransactionService) ValidateTransaction(transaction domain.Transaction)
 			return errors.CategoryNotFound(categoryCode)
 		}
 	}
+	// A split transaction's categories are the union of its lines, checked line by line below
+	tree := domain.NewCategoryTree(categories)
+	if len(transaction.Splits) == 0 {
+		if err := checkCategoryBranches(tree, transaction.Categories); err != nil {
+			return err
+		}
+	}
 
 	// Check if tags exist
 	tags, err := s.storage.GetTags()
@@ -128,11 +180,126 @@ func (s *TransactionService) ValidateTransaction(transaction domain.Transaction)
 		}
 	}
 
+	// Check split lines: known codes, account currency, and a total equal to the amount
+	if len(transaction.Splits) > 0 {
+		total := domain.NewMoney(0, account.Currency)
+		for _, split := range transaction.Splits {
+			for _, categoryCode := range split.Categories {
+				if !categoryMap[categoryCode] {
+					return errors.CategoryNotFound(categoryCode)
+				}
+			}
+			if err := checkCategoryBranches(tree, split.Categories); err != nil {
+				return err
+			}
+			for _, tagCode := range split.Tags {
+				if !tagMap[tagCode] {
+					return errors.TagNotFound(tagCode)
+				}
+			}
+			if split.Amount.Currency != "" && account.Currency != "" && split.Amount.Currency != account.Currency {
+				return errors.CurrencyMismatch(account.ID, account.Currency, split.Amount.Currency)
+			}
+			if split.Amount.IsZero() {
+				return errors.New(errors.ErrorTypeValidation, errors.CodeInvalidSplit, "Split lines must have a non-zero amount")
+			}
+			total = total.Add(split.Amount.WithCurrency(account.Currency))
+		}
+		if amount := transaction.Amount.WithCurrency(account.Currency); total != amount {
+			return errors.SplitMismatch(amount.String(), total.String())
+		}
+	}
+
+	return nil
+}
+
+// PrepareTransaction validates a transaction and denominates its amount in the account currency
+// (public for use by batch service)
+func (s *TransactionService) PrepareTransaction(transaction domain.Transaction) (domain.Transaction, error) {
+	// A split transaction without amount takes the total of its lines
+	if len(transaction.Splits) > 0 {
+		if transaction.Amount.IsZero() {
+			total := domain.Money{}
+			for _, split := range transaction.Splits {
+				if !total.SameCurrency(split.Amount) {
+					return transaction, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidSplit, "Split lines use different currencies")
+				}
+				total = total.Add(split.Amount)
+			}
+			transaction.Amount = total
+		}
+		// Keep the transaction categories as the union of its split categories
+		if len(transaction.Categories) == 0 {
+			for _, split := range transaction.Splits {
+				for _, code := range split.Categories {
+					if !containsCode(transaction.Categories, code) {
+						transaction.Categories = append(transaction.Categories, code)
+					}
+				}
+			}
+		}
+	}
+
+	if err := s.ValidateTransaction(transaction); err != nil {
+		return transaction, err
+	}
+
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return transaction, errors.StorageReadFailed("accounts", err)
+	}
+
+	for _, account := range accounts {
+		if account.ID == transaction.Account {
+			transaction.Amount = transaction.Amount.WithCurrency(account.Currency)
+			if len(transaction.Splits) > 0 {
+				splits := make([]domain.Split, len(transaction.Splits))
+				for i, split := range transaction.Splits {
+					splits[i] = split
+					splits[i].Amount = split.Amount.WithCurrency(account.Currency)
+				}
+				transaction.Splits = splits
+			}
+			break
+		}
+	}
+
+	return transaction, nil
+}
+
+// checkCategoryBranches refuses a category together with one of its parents: the
+// movement already counts in the parent through the subcategory
+func checkCategoryBranches(tree *domain.CategoryTree, codes []string) error {
+	for _, code := range codes {
+		for _, ancestor := range tree.Ancestors(code) {
+			if containsCode(codes, ancestor) {
+				return errors.New(errors.ErrorTypeValidation, errors.CodeInvalidCategory,
+					fmt.Sprintf("Categories %s and %s are on the same branch (%s is below %s): use only one of them", ancestor, code, code, ancestor))
+			}
+		}
+	}
 	return nil
 }
 
-// EditTransaction edits a transaction by creating a new one and soft-deleting the old one
+// containsCode reports whether a category or tag code is in the list
+func containsCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// EditTransaction edits a transaction by creating a new one and soft-deleting the old one.
+// Editing one leg of a transfer also edits the other leg (date, amount, description).
 func (s *TransactionService) EditTransaction(transactionID string, modifications domain.Transaction, message string) (*domain.Transaction, error) {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return nil, err
+	}
+	defer s.storage.Unlock()
+
 	// Get all transactions
 	transactions, err := s.storage.GetTransactions()
 	if err != nil {
@@ -144,59 +311,51 @@ func (s *TransactionService) EditTransaction(transactionID string, modifications
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkAccountsOpen(transferLegs(transactions, *oldTransaction)); err != nil {
+		return nil, err
+	}
 
 	// Create new transaction by merging old with modifications
-	newTransaction := domain.Transaction{
-		ID:          modifications.ID, // Should be generated by caller
-		Account:     oldTransaction.Account,
-		Date:        oldTransaction.Date,
-		Amount:      oldTransaction.Amount,
-		Description: oldTransaction.Description,
-		Categories:  oldTransaction.Categories,
-		Tags:        oldTransaction.Tags,
-		IsActive:    true,
-		ParentID:    oldTransaction.ID,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	// Apply modifications
-	if modifications.Account != "" {
-		newTransaction.Account = modifications.Account
-	}
-	if !modifications.Date.IsZero() {
-		newTransaction.Date = modifications.Date
-	}
-	if modifications.Amount != 0 {
-		newTransaction.Amount = modifications.Amount
-	}
-	if modifications.Description != "" {
-		newTransaction.Description = modifications.Description
-	}
-	if len(modifications.Categories) > 0 {
-		newTransaction.Categories = modifications.Categories
-	}
-	if len(modifications.Tags) > 0 {
-		newTransaction.Tags = modifications.Tags
-	}
+	newTransaction := s.applyModifications(*oldTransaction, modifications)
+	newTransaction.ID = modifications.ID // Should be generated by caller
 
 	// Validate the new transaction
-	if err := s.ValidateTransaction(newTransaction); err != nil {
+	newTransaction, err = s.PrepareTransaction(newTransaction)
+	if err != nil {
 		return nil, errors.Wrap(errors.ErrorTypeValidation, "validation_failed", "Validation failed for edited transaction", err)
 	}
 
-	// Soft delete the old transaction with comment
-	for i, txn := range transactions {
-		if txn.ID == oldTransaction.ID {
-			transactions[i].IsActive = false
-			transactions[i].EditComment = message
-			transactions[i].UpdatedAt = time.Now()
-			break
+	replaced := []string{oldTransaction.ID}
+	var mirrored []domain.Transaction
+
+	// Mirror the edit on the other leg of a transfer; both new legs get a new transfer ID
+	if counterpart := findCounterpart(transactions, *oldTransaction); counterpart != nil {
+		newCounterpart, err := s.mirrorTransferLeg(*counterpart, newTransaction)
+		if err != nil {
+			return nil, err
+		}
+		transferID := uuid.New().String()
+		newTransaction.TransferID = transferID
+		newCounterpart.TransferID = transferID
+		mirrored = append(mirrored, newCounterpart)
+		replaced = append(replaced, counterpart.ID)
+	}
+	edited := append([]domain.Transaction{newTransaction}, mirrored...)
+
+	// Soft delete the old transaction(s) with comment
+	for _, id := range replaced {
+		for i, txn := range transactions {
+			if txn.ID == id {
+				transactions[i].IsActive = false
+				transactions[i].EditComment = message
+				transactions[i].UpdatedAt = time.Now()
+				break
+			}
 		}
 	}
 
-	// Add the new transaction
-	transactions = append(transactions, newTransaction)
+	// Add the new transaction(s)
+	transactions = append(transactions, edited...)
 
 	// Save back to storage
 	if err := s.storage.SaveTransactions(transactions); err != nil {
@@ -206,8 +365,14 @@ func (s *TransactionService) EditTransaction(transactionID string, modifications
 	return &newTransaction, nil
 }
 
-// DeleteTransaction soft-deletes a transaction
+// DeleteTransaction soft-deletes a transaction (and the other leg of a transfer)
 func (s *TransactionService) DeleteTransaction(transactionID string, message string) error {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return err
+	}
+	defer s.storage.Unlock()
+
 	// Get all transactions
 	transactions, err := s.storage.GetTransactions()
 	if err != nil {
@@ -224,14 +389,19 @@ func (s *TransactionService) DeleteTransaction(transactionID string, message str
 	if !targetTransaction.IsActive {
 		return errors.TransactionAlreadyDeleted(targetTransaction.ID)
 	}
+	if err := s.checkAccountsOpen(transferLegs(transactions, *targetTransaction)); err != nil {
+		return err
+	}
 
 	// Soft delete with comment
-	for i, txn := range transactions {
-		if txn.ID == targetTransaction.ID {
-			transactions[i].IsActive = false
-			transactions[i].EditComment = message
-			transactions[i].UpdatedAt = time.Now()
-			break
+	for _, leg := range transferLegs(transactions, *targetTransaction) {
+		for i, txn := range transactions {
+			if txn.ID == leg.ID {
+				transactions[i].IsActive = false
+				transactions[i].EditComment = message
+				transactions[i].UpdatedAt = time.Now()
+				break
+			}
 		}
 	}
 
@@ -243,8 +413,14 @@ func (s *TransactionService) DeleteTransaction(transactionID string, message str
 	return nil
 }
 
-// UndoTransaction undoes the last operation on a transaction
+// UndoTransaction undoes the last operation on a transaction (and on the other leg of a transfer)
 func (s *TransactionService) UndoTransaction(transactionID string) error {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return err
+	}
+	defer s.storage.Unlock()
+
 	// Get all transactions
 	transactions, err := s.storage.GetTransactions()
 	if err != nil {
@@ -256,24 +432,43 @@ func (s *TransactionService) UndoTransaction(transactionID string) error {
 	if err != nil {
 		return err
 	}
+	if err := s.checkAccountsOpen(transferLegs(transactions, *targetTransaction)); err != nil {
+		return err
+	}
 
-	// Determine operation type to undo
-	if targetTransaction.ParentID != "" {
-		// This is a transaction created by edit -> undo edit
-		return s.undoEdit(transactions, targetTransaction)
-	} else if !targetTransaction.IsActive && targetTransaction.EditComment != "" {
-		// This is a deleted transaction -> undo delete
-		return s.undoDelete(transactions, targetTransaction)
-	} else if targetTransaction.IsActive && targetTransaction.EditComment == "" {
-		// This is an added transaction -> undo add
-		return s.undoAdd(transactions, targetTransaction)
-	} else {
-		return errors.InvalidOperation(targetTransaction.ID)
+	for _, leg := range transferLegs(transactions, *targetTransaction) {
+		transactions, err = s.undoOperation(transactions, leg)
+		if err != nil {
+			return err
+		}
 	}
+
+	// Save back to storage
+	if err := s.storage.SaveTransactions(transactions); err != nil {
+		return errors.StorageWriteFailed("transactions", err)
+	}
+
+	return nil
 }
 
 // Helper methods
 
+// checkAccountsOpen refuses to change movements of a closed account, whose balance must stay zero
+func (s *TransactionService) checkAccountsOpen(legs []domain.Transaction) error {
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return errors.StorageReadFailed("accounts", err)
+	}
+	for _, leg := range legs {
+		for _, account := range accounts {
+			if account.ID == leg.Account && !account.IsActive {
+				return errors.AccountClosed(account.ID)
+			}
+		}
+	}
+	return nil
+}
+
 // findTransactionByID finds a transaction by ID (supports partial IDs)
 func (s *TransactionService) findTransactionByID(transactions []domain.Transaction, id string) (*domain.Transaction, error) {
 	var matches []domain.Transaction
@@ -295,7 +490,73 @@ func (s *TransactionService) findTransactionByID(transactions []domain.Transacti
 	return &matches[0], nil
 }
 
-func (s *TransactionService) undoEdit(transactions []domain.Transaction, childTransaction *domain.Transaction) error {
+// applyModifications merges the non-empty fields of modifications into a copy of the original
+func (s *TransactionService) applyModifications(original domain.Transaction, modifications domain.Transaction) domain.Transaction {
+	newTransaction := domain.Transaction{
+		Account:      original.Account,
+		Date:         original.Date,
+		Amount:       original.Amount,
+		Description:  original.Description,
+		Categories:   original.Categories,
+		Tags:         original.Tags,
+		Splits:       original.Splits,
+		TransferID:   original.TransferID,
+		ExchangeRate: original.ExchangeRate,
+		Occurrence:   original.Occurrence,
+		IsActive:     true,
+		ParentID:     original.ID,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	if modifications.Account != "" {
+		newTransaction.Account = modifications.Account
+	}
+	if !modifications.Date.IsZero() {
+		newTransaction.Date = modifications.Date
+	}
+	if !modifications.Amount.IsZero() {
+		newTransaction.Amount = modifications.Amount
+	}
+	if modifications.Description != "" {
+		newTransaction.Description = modifications.Description
+	}
+	if len(modifications.Categories) > 0 {
+		newTransaction.Categories = modifications.Categories
+	}
+	if len(modifications.Tags) > 0 {
+		newTransaction.Tags = modifications.Tags
+	}
+	// Splits are replaced as a whole; an empty (non-nil) list removes them
+	if modifications.Splits != nil {
+		newTransaction.Splits = modifications.Splits
+		if len(modifications.Categories) == 0 && len(modifications.Splits) > 0 {
+			newTransaction.Categories = nil
+		}
+		if modifications.Amount.IsZero() && len(modifications.Splits) > 0 {
+			newTransaction.Amount = domain.Money{}
+		}
+	}
+
+	return newTransaction
+}
+
+func (s *TransactionService) undoOperation(transactions []domain.Transaction, target domain.Transaction) ([]domain.Transaction, error) {
+	// Determine operation type to undo
+	if target.ParentID != "" {
+		// This is a transaction created by edit -> undo edit
+		return s.undoEdit(transactions, &target)
+	} else if !target.IsActive && target.EditComment != "" {
+		// This is a deleted transaction -> undo delete
+		return s.undoDelete(transactions, &target), nil
+	} else if target.IsActive && target.EditComment == "" {
+		// This is an added transaction -> undo add
+		return s.undoAdd(transactions, &target), nil
+	}
+	return nil, errors.InvalidOperation(target.ID)
+}
+
+func (s *TransactionService) undoEdit(transactions []domain.Transaction, childTransaction *domain.Transaction) ([]domain.Transaction, error) {
 	// Find the parent transaction
 	var parentTransaction *domain.Transaction
 	for _, txn := range transactions {
@@ -306,7 +567,7 @@ func (s *TransactionService) undoEdit(transactions []domain.Transaction, childTr
 	}
 
 	if parentTransaction == nil {
-		return errors.ParentNotFound(childTransaction.ParentID)
+		return nil, errors.ParentNotFound(childTransaction.ParentID)
 	}
 
 	// Reactivate parent transaction and completely remove child transaction
@@ -325,15 +586,10 @@ func (s *TransactionService) undoEdit(transactions []domain.Transaction, childTr
 		// Child transaction is completely removed (not added to updatedTransactions)
 	}
 
-	// Save back to storage
-	if err := s.storage.SaveTransactions(updatedTransactions); err != nil {
-		return errors.StorageWriteFailed("transactions", err)
-	}
-
-	return nil
+	return updatedTransactions, nil
 }
 
-func (s *TransactionService) undoDelete(transactions []domain.Transaction, deletedTransaction *domain.Transaction) error {
+func (s *TransactionService) undoDelete(transactions []domain.Transaction, deletedTransaction *domain.Transaction) []domain.Transaction {
 	// Set is_active to true and remove comment
 	for i, txn := range transactions {
 		if txn.ID == deletedTransaction.ID {
@@ -344,15 +600,10 @@ func (s *TransactionService) undoDelete(transactions []domain.Transaction, delet
 		}
 	}
 
-	// Save back to storage
-	if err := s.storage.SaveTransactions(transactions); err != nil {
-		return errors.StorageWriteFailed("transactions", err)
-	}
-
-	return nil
+	return transactions
 }
 
-func (s *TransactionService) undoAdd(transactions []domain.Transaction, addedTransaction *domain.Transaction) error {
+func (s *TransactionService) undoAdd(transactions []domain.Transaction, addedTransaction *domain.Transaction) []domain.Transaction {
 	// Set is_active to false with comment
 	for i, txn := range transactions {
 		if txn.ID == addedTransaction.ID {
@@ -363,62 +614,51 @@ func (s *TransactionService) undoAdd(transactions []domain.Transaction, addedTra
 		}
 	}
 
-	// Save back to storage
-	if err := s.storage.SaveTransactions(transactions); err != nil {
-		return errors.StorageWriteFailed("transactions", err)
-	}
-
-	return nil
+	return transactions
 }
 
-// DeleteTransactionHard permanently deletes a transaction from storage
+// DeleteTransactionHard permanently deletes a transaction (and the other leg of a transfer) from storage
 func (s *TransactionService) DeleteTransactionHard(transactionID string, message string) error {
-	// Get all transactions
-	transactions, err := s.storage.GetTransactions()
-	if err != nil {
-		return errors.StorageReadFailed("transactions", err)
-	}
-
-	// Find the transaction to delete
-	targetTransaction, err := s.findTransactionByID(transactions, transactionID)
-	if err != nil {
-		return err
-	}
+	return s.removeTransaction(transactionID)
+}
 
-	// Remove the transaction from the slice
-	var newTransactions []domain.Transaction
-	for _, txn := range transactions {
-		if txn.ID != targetTransaction.ID {
-			newTransactions = append(newTransactions, txn)
-		}
-	}
+// UndoTransactionHard permanently removes a transaction (and the other leg of a transfer) instead of soft delete
+func (s *TransactionService) UndoTransactionHard(transactionID string) error {
+	return s.removeTransaction(transactionID)
+}
 
-	// Save back to storage
-	if err := s.storage.SaveTransactions(newTransactions); err != nil {
-		return errors.StorageWriteFailed("transactions", err)
+// removeTransaction removes a transaction and its transfer counterpart from the slice and saves
+func (s *TransactionService) removeTransaction(transactionID string) error {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return err
 	}
+	defer s.storage.Unlock()
 
-	return nil
-}
-
-// UndoTransactionHard permanently removes a transaction instead of soft delete
-func (s *TransactionService) UndoTransactionHard(transactionID string) error {
 	// Get all transactions
 	transactions, err := s.storage.GetTransactions()
 	if err != nil {
 		return errors.StorageReadFailed("transactions", err)
 	}
 
-	// Find the transaction to undo
+	// Find the transaction to remove
 	targetTransaction, err := s.findTransactionByID(transactions, transactionID)
 	if err != nil {
 		return err
 	}
+	if err := s.checkAccountsOpen(transferLegs(transactions, *targetTransaction)); err != nil {
+		return err
+	}
+
+	removed := make(map[string]bool)
+	for _, leg := range transferLegs(transactions, *targetTransaction) {
+		removed[leg.ID] = true
+	}
 
-	// Remove the transaction from the slice
+	// Remove the transaction(s) from the slice
 	var newTransactions []domain.Transaction
 	for _, txn := range transactions {
-		if txn.ID != targetTransaction.ID {
+		if !removed[txn.ID] {
 			newTransactions = append(newTransactions, txn)
 		}
 	}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"comptes/internal/storage"
 	"fmt"
 	"testing"
 	"time"
@@ -13,6 +15,21 @@ type MockStorage struct {
 	accounts     []domain.Account
 	categories   []domain.Category
 	tags         []domain.Tag
+	rates        []domain.ExchangeRate
+	budgets      []domain.Budget
+	envelopes    []domain.EnvelopeAssignment
+	rules        []domain.RecurringRule
+	audit        []domain.AuditEntry
+}
+
+func (m *MockStorage) Lock() error {
+	return nil
+}
+
+func (m *MockStorage) Unlock() {}
+
+func (m *MockStorage) Atomically(fn func() error) error {
+	return fn()
 }
 
 func (m *MockStorage) GetTransactions() ([]domain.Transaction, error) {
@@ -24,6 +41,10 @@ func (m *MockStorage) SaveTransactions(transactions []domain.Transaction) error
 	return nil
 }
 
+func (m *MockStorage) QueryTransactions(query storage.TransactionQuery) ([]domain.Transaction, error) {
+	return query.Apply(m.transactions), nil
+}
+
 func (m *MockStorage) GetAccounts() ([]domain.Account, error) {
 	return m.accounts, nil
 }
@@ -51,9 +72,54 @@ func (m *MockStorage) SaveTags(tags []domain.Tag) error {
 	return nil
 }
 
-func (m *MockStorage) GetAccountBalance(accountID string) (float64, error) {
+func (m *MockStorage) GetExchangeRates() ([]domain.ExchangeRate, error) {
+	return m.rates, nil
+}
+
+func (m *MockStorage) SaveExchangeRates(rates []domain.ExchangeRate) error {
+	m.rates = rates
+	return nil
+}
+
+func (m *MockStorage) GetBudgets() ([]domain.Budget, error) {
+	return m.budgets, nil
+}
+
+func (m *MockStorage) SaveBudgets(budgets []domain.Budget) error {
+	m.budgets = budgets
+	return nil
+}
+
+func (m *MockStorage) GetEnvelopeAssignments() ([]domain.EnvelopeAssignment, error) {
+	return m.envelopes, nil
+}
+
+func (m *MockStorage) SaveEnvelopeAssignments(assignments []domain.EnvelopeAssignment) error {
+	m.envelopes = assignments
+	return nil
+}
+
+func (m *MockStorage) GetRecurringRules() ([]domain.RecurringRule, error) {
+	return m.rules, nil
+}
+
+func (m *MockStorage) SaveRecurringRules(rules []domain.RecurringRule) error {
+	m.rules = rules
+	return nil
+}
+
+func (m *MockStorage) GetAuditLog() ([]domain.AuditEntry, error) {
+	return m.audit, nil
+}
+
+func (m *MockStorage) SaveAuditLog(entries []domain.AuditEntry) error {
+	m.audit = entries
+	return nil
+}
+
+func (m *MockStorage) GetAccountBalance(accountID string) (domain.Money, error) {
 	// Find the account
-	var initialBalance float64
+	var initialBalance domain.Money
 	var accountFound bool
 	for _, acc := range m.accounts {
 		if acc.ID == accountID {
@@ -64,14 +130,14 @@ func (m *MockStorage) GetAccountBalance(accountID string) (float64, error) {
 	}
 
 	if !accountFound {
-		return 0, fmt.Errorf("account not found: %s", accountID)
+		return domain.Money{}, fmt.Errorf("account not found: %s", accountID)
 	}
 
 	// Calculate balance
 	balance := initialBalance
 	for _, txn := range m.transactions {
 		if txn.Account == accountID && txn.IsActive {
-			balance += txn.Amount
+			balance = balance.Add(txn.Amount)
 		}
 	}
 
@@ -102,6 +168,12 @@ func (m *MockStorage) SaveRolledBackBatches(batches []domain.TransactionBatch) e
 	return nil
 }
 
+// hasErrorCode reports whether err is a ComptesError with code
+func hasErrorCode(err error, code string) bool {
+	e, ok := err.(*errors.ComptesError)
+	return ok && e.Code == code
+}
+
 func TestTransactionService_AddTransaction(t *testing.T) {
 	// Setup
 	mockStorage := &MockStorage{
@@ -112,7 +184,7 @@ func TestTransactionService_AddTransaction(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      time.Now(),
 			},
@@ -139,7 +211,7 @@ func TestTransactionService_AddTransaction(t *testing.T) {
 	transaction := domain.Transaction{
 		ID:          "txn1",
 		Account:     "account1",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test purchase",
 		Categories:  []string{"food"},
 		Tags:        []string{"test"},
@@ -171,7 +243,7 @@ func TestTransactionService_GetAccountBalance(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Purchase",
 				IsActive:    true,
 				CreatedAt:   time.Now(),
@@ -180,7 +252,7 @@ func TestTransactionService_GetAccountBalance(t *testing.T) {
 			{
 				ID:          "txn2",
 				Account:     "account1",
-				Amount:      100.0,
+				Amount:      domain.NewMoney(10000, "EUR"),
 				Description: "Income",
 				IsActive:    true,
 				CreatedAt:   time.Now(),
@@ -189,7 +261,7 @@ func TestTransactionService_GetAccountBalance(t *testing.T) {
 			{
 				ID:          "txn3",
 				Account:     "account1",
-				Amount:      -25.0,
+				Amount:      domain.NewMoney(-2500, "EUR"),
 				Description: "Inactive transaction",
 				IsActive:    false, // This should not be counted
 				CreatedAt:   time.Now(),
@@ -202,7 +274,7 @@ func TestTransactionService_GetAccountBalance(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      time.Now(),
 			},
@@ -221,9 +293,9 @@ func TestTransactionService_GetAccountBalance(t *testing.T) {
 
 	// Expected balance: 1000 (initial) + 100 (income) - 50 (expense) = 1050
 	// Inactive transaction (-25) should not be counted
-	expectedBalance := 1050.0
+	expectedBalance := domain.NewMoney(105000, "EUR")
 	if balance != expectedBalance {
-		t.Errorf("Expected balance %.2f, got %.2f", expectedBalance, balance)
+		t.Errorf("Expected balance %s, got %s", expectedBalance, balance)
 	}
 }
 
@@ -260,7 +332,7 @@ func TestTransactionService_AddTransaction_NonExistentAccount(t *testing.T) {
 	transaction := domain.Transaction{
 		ID:          "txn1",
 		Account:     "nonexistent",
-		Amount:      -50.0,
+		Amount:      domain.NewMoney(-5000, "EUR"),
 		Description: "Test purchase",
 		IsActive:    true,
 		CreatedAt:   time.Now(),
@@ -281,7 +353,7 @@ func TestTransactionService_EditTransaction(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Original purchase",
 				Categories:  []string{"food"},
 				Tags:        []string{"test"},
@@ -296,7 +368,7 @@ func TestTransactionService_EditTransaction(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -321,9 +393,9 @@ func TestTransactionService_EditTransaction(t *testing.T) {
 
 	// Test editing a transaction
 	modifications := domain.Transaction{
-		ID:          "txn2",             // New ID
-		Amount:      -75.0,              // Changed amount
-		Description: "Updated purchase", // Changed description
+		ID:          "txn2",                        // New ID
+		Amount:      domain.NewMoney(-7500, "EUR"), // Changed amount
+		Description: "Updated purchase",            // Changed description
 	}
 
 	newTransaction, err := service.EditTransaction("txn1", modifications, "Price correction")
@@ -336,8 +408,8 @@ func TestTransactionService_EditTransaction(t *testing.T) {
 		t.Errorf("Expected new transaction ID 'txn2', got '%s'", newTransaction.ID)
 	}
 
-	if newTransaction.Amount != -75.0 {
-		t.Errorf("Expected amount -75.0, got %.2f", newTransaction.Amount)
+	if newTransaction.Amount != domain.NewMoney(-7500, "EUR") {
+		t.Errorf("Expected amount -75.00 EUR, got %s", newTransaction.Amount)
 	}
 
 	if newTransaction.Description != "Updated purchase" {
@@ -383,7 +455,7 @@ func TestTransactionService_DeleteTransaction(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Test purchase",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -396,7 +468,7 @@ func TestTransactionService_DeleteTransaction(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -436,7 +508,7 @@ func TestTransactionService_DeleteTransaction_AlreadyDeleted(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Test purchase",
 				IsActive:    false, // Already deleted
 				CreatedAt:   now,
@@ -449,7 +521,7 @@ func TestTransactionService_DeleteTransaction_AlreadyDeleted(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -475,7 +547,7 @@ func TestTransactionService_UndoTransaction_UndoAdd(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Test purchase",
 				IsActive:    true,
 				EditComment: "", // No edit comment = added transaction
@@ -489,7 +561,7 @@ func TestTransactionService_UndoTransaction_UndoAdd(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -529,7 +601,7 @@ func TestTransactionService_UndoTransaction_UndoDelete(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Test purchase",
 				IsActive:    false, // Deleted transaction
 				EditComment: "Mistake",
@@ -543,7 +615,7 @@ func TestTransactionService_UndoTransaction_UndoDelete(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -583,7 +655,7 @@ func TestTransactionService_UndoTransaction_UndoEdit(t *testing.T) {
 			{
 				ID:          "txn1",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Original purchase",
 				IsActive:    false, // Parent transaction (soft-deleted)
 				EditComment: "Price correction",
@@ -593,7 +665,7 @@ func TestTransactionService_UndoTransaction_UndoEdit(t *testing.T) {
 			{
 				ID:          "txn2",
 				Account:     "account1",
-				Amount:      -75.0,
+				Amount:      domain.NewMoney(-7500, "EUR"),
 				Description: "Updated purchase",
 				IsActive:    true,
 				ParentID:    "txn1", // Child transaction
@@ -607,7 +679,7 @@ func TestTransactionService_UndoTransaction_UndoEdit(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -650,7 +722,7 @@ func TestTransactionService_findTransactionByID(t *testing.T) {
 			{
 				ID:          "abc12345",
 				Account:     "account1",
-				Amount:      -50.0,
+				Amount:      domain.NewMoney(-5000, "EUR"),
 				Description: "Transaction 1",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -659,7 +731,7 @@ func TestTransactionService_findTransactionByID(t *testing.T) {
 			{
 				ID:          "abdef67890",
 				Account:     "account1",
-				Amount:      -25.0,
+				Amount:      domain.NewMoney(-2500, "EUR"),
 				Description: "Transaction 2",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -672,7 +744,7 @@ func TestTransactionService_findTransactionByID(t *testing.T) {
 				Name:           "Test Account",
 				Type:           "checking",
 				Currency:       "EUR",
-				InitialBalance: 1000.0,
+				InitialBalance: domain.NewMoney(100000, "EUR"),
 				IsActive:       true,
 				CreatedAt:      now,
 			},
@@ -722,7 +794,7 @@ func TestTransactionService_DeleteTransactionHard(t *testing.T) {
 			{
 				ID:          "test123",
 				Account:     "account1",
-				Amount:      -25.0,
+				Amount:      domain.NewMoney(-2500, "EUR"),
 				Description: "Test transaction",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -731,7 +803,7 @@ func TestTransactionService_DeleteTransactionHard(t *testing.T) {
 			{
 				ID:          "test456",
 				Account:     "account2",
-				Amount:      100.0,
+				Amount:      domain.NewMoney(10000, "EUR"),
 				Description: "Another transaction",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -771,7 +843,7 @@ func TestTransactionService_UndoTransactionHard(t *testing.T) {
 			{
 				ID:          "test123",
 				Account:     "account1",
-				Amount:      -25.0,
+				Amount:      domain.NewMoney(-2500, "EUR"),
 				Description: "Test transaction",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -780,7 +852,7 @@ func TestTransactionService_UndoTransactionHard(t *testing.T) {
 			{
 				ID:          "test456",
 				Account:     "account2",
-				Amount:      100.0,
+				Amount:      domain.NewMoney(10000, "EUR"),
 				Description: "Another transaction",
 				IsActive:    true,
 				CreatedAt:   now,
@@ -812,3 +884,33 @@ func TestTransactionService_UndoTransactionHard(t *testing.T) {
 		t.Error("Expected error for non-existent transaction, got nil")
 	}
 }
+
+func TestTransactionService_CategoryHierarchy(t *testing.T) {
+	parent := "ALM"
+	mockStorage := &MockStorage{
+		accounts: []domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}},
+		categories: []domain.Category{
+			{Code: "ALM", Children: []string{"RES"}},
+			{Code: "RES", Parent: &parent},
+			{Code: "LOI"},
+		},
+		transactions: []domain.Transaction{
+			{ID: "txn1", Account: "BANQUE", Categories: []string{"RES"}, IsActive: true},
+			{ID: "txn2", Account: "BANQUE", Categories: []string{"LOI"}, IsActive: true},
+		},
+	}
+	service := NewTransactionService(mockStorage)
+
+	transactions, err := service.QueryTransactions(storage.TransactionQuery{Categories: []string{"ALM"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].ID != "txn1" {
+		t.Errorf("Expected ALM to select the RES movement, got %+v", transactions)
+	}
+
+	err = service.ValidateTransaction(domain.Transaction{Account: "BANQUE", Amount: domain.NewMoney(-100, "EUR"), Categories: []string{"ALM", "RES"}})
+	if !hasErrorCode(err, errors.CodeInvalidCategory) {
+		t.Errorf("Expected invalid_category for a category with its parent, got %v", err)
+	}
+}
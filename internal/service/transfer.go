@@ -0,0 +1,241 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferRequest describes a movement of money between two accounts
+type TransferRequest struct {
+	From   string
+	To     string
+	Amount domain.Money // Positive amount leaving From and entering To
+	// Received is the amount credited to To when the accounts use different currencies
+	// (optional: by default Amount is converted at the rate of the transfer date)
+	Received    domain.Money
+	Date        time.Time
+	Description string
+	Categories  []string
+	Tags        []string
+}
+
+// NewTransfer builds and validates the two linked legs of a transfer without saving them
+// (the first leg debits From, the second credits To)
+func (s *TransactionService) NewTransfer(request TransferRequest) ([]domain.Transaction, error) {
+	if request.From == "" || request.To == "" {
+		return nil, errors.New(errors.ErrorTypeValidation, errors.CodeMissingField, "Transfer requires a source and a destination account")
+	}
+	if request.From == request.To {
+		return nil, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidTransfer, "Cannot transfer to the same account")
+	}
+	if !request.Amount.IsPositive() {
+		return nil, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidAmount, "Transfer amount must be positive")
+	}
+
+	now := time.Now()
+	date := request.Date
+	if date.IsZero() {
+		date = now
+	}
+	description := request.Description
+	if description == "" {
+		description = fmt.Sprintf("Transfer %s -> %s", request.From, request.To)
+	}
+
+	// Cross-currency transfers credit the converted amount and record the rate on both legs
+	received, rate, err := s.transferReceivedAmount(request, date)
+	if err != nil {
+		return nil, err
+	}
+
+	transferID := uuid.New().String()
+	legs := []domain.Transaction{
+		{
+			ID:           uuid.New().String(),
+			Account:      request.From,
+			Date:         date,
+			Amount:       request.Amount.Neg(),
+			Description:  description,
+			Categories:   request.Categories,
+			Tags:         request.Tags,
+			TransferID:   transferID,
+			ExchangeRate: rate,
+			IsActive:     true,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		{
+			ID:           uuid.New().String(),
+			Account:      request.To,
+			Date:         date,
+			Amount:       received,
+			Description:  description,
+			Categories:   request.Categories,
+			Tags:         request.Tags,
+			TransferID:   transferID,
+			ExchangeRate: rate,
+			IsActive:     true,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+	}
+
+	for i, leg := range legs {
+		prepared, err := s.PrepareTransaction(leg)
+		if err != nil {
+			return nil, err
+		}
+		legs[i] = prepared
+	}
+
+	return legs, nil
+}
+
+// transferReceivedAmount returns the amount credited to the destination account and, for
+// cross-currency transfers, the rate used (units of the destination currency per source unit)
+func (s *TransactionService) transferReceivedAmount(request TransferRequest, date time.Time) (domain.Money, string, error) {
+	accounts, err := s.storage.GetAccounts()
+	if err != nil {
+		return domain.Money{}, "", errors.StorageReadFailed("accounts", err)
+	}
+	var fromCurrency, toCurrency string
+	for _, account := range accounts {
+		switch account.ID {
+		case request.From:
+			fromCurrency = account.Currency
+		case request.To:
+			toCurrency = account.Currency
+		}
+	}
+
+	// Unknown accounts are reported by PrepareTransaction
+	if fromCurrency == "" || toCurrency == "" || fromCurrency == toCurrency {
+		if !request.Received.IsZero() && request.Received != request.Amount {
+			return domain.Money{}, "", errors.New(errors.ErrorTypeValidation, errors.CodeInvalidTransfer,
+				"A received amount can only differ from the sent amount between accounts in different currencies")
+		}
+		return request.Amount, "", nil
+	}
+
+	sent := request.Amount.WithCurrency(fromCurrency)
+	if sent.Currency != fromCurrency {
+		return domain.Money{}, "", errors.CurrencyMismatch(request.From, fromCurrency, sent.Currency)
+	}
+
+	if request.Received.IsZero() {
+		rate, err := NewExchangeRateService(s.storage).Rate(fromCurrency, toCurrency, date)
+		if err != nil {
+			return domain.Money{}, "", err
+		}
+		return sent.Convert(rate, toCurrency), domain.FormatRate(rate), nil
+	}
+
+	received := request.Received.WithCurrency(toCurrency)
+	if received.Currency != toCurrency {
+		return domain.Money{}, "", errors.CurrencyMismatch(request.To, toCurrency, received.Currency)
+	}
+	if !received.IsPositive() {
+		return domain.Money{}, "", errors.New(errors.ErrorTypeValidation, errors.CodeInvalidAmount, "Received amount must be positive")
+	}
+	rate := new(big.Rat).Quo(received.Rat(), sent.Rat())
+	return received, domain.FormatRate(rate), nil
+}
+
+// AddTransfer creates both legs of a transfer in a single save
+func (s *TransactionService) AddTransfer(request TransferRequest) ([]domain.Transaction, error) {
+	// Hold the data directory lock for the whole read-modify-write cycle
+	if err := s.storage.Lock(); err != nil {
+		return nil, err
+	}
+	defer s.storage.Unlock()
+
+	legs, err := s.NewTransfer(request)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.storage.GetTransactions()
+	if err != nil {
+		return nil, errors.StorageReadFailed("transactions", err)
+	}
+
+	transactions = append(transactions, legs...)
+
+	if err := s.storage.SaveTransactions(transactions); err != nil {
+		return nil, errors.StorageWriteFailed("transactions", err)
+	}
+
+	return legs, nil
+}
+
+// findCounterpart returns the other leg of a transfer, or nil for a regular transaction
+func findCounterpart(transactions []domain.Transaction, leg domain.Transaction) *domain.Transaction {
+	if leg.TransferID == "" {
+		return nil
+	}
+	for i := range transactions {
+		if transactions[i].TransferID == leg.TransferID && transactions[i].ID != leg.ID {
+			return &transactions[i]
+		}
+	}
+	return nil
+}
+
+// transferLegs returns the transaction followed by its transfer counterpart, if any
+func transferLegs(transactions []domain.Transaction, transaction domain.Transaction) []domain.Transaction {
+	legs := []domain.Transaction{transaction}
+	if counterpart := findCounterpart(transactions, transaction); counterpart != nil {
+		legs = append(legs, *counterpart)
+	}
+	return legs
+}
+
+// mirrorAmount returns the amount of the other leg of an edited transfer leg, converted
+// at the rate recorded on the transfer when both legs use different currencies
+func mirrorAmount(edited domain.Transaction, counterpart domain.Transaction) (domain.Money, error) {
+	amount := edited.Amount.Neg()
+	if amount.SameCurrency(counterpart.Amount) {
+		return amount, nil
+	}
+
+	rate, err := domain.ParseRate(edited.ExchangeRate)
+	if err != nil {
+		return domain.Money{}, errors.InvalidExchangeRate(edited.ExchangeRate, err)
+	}
+	// The rate goes from the debited leg to the credited leg
+	if edited.Amount.IsPositive() {
+		rate.Inv(rate)
+	}
+	return amount.Convert(rate, counterpart.Amount.Currency), nil
+}
+
+// mirrorTransferLeg builds the new version of the other leg after one leg of a transfer was edited:
+// date and description are shared, the amount is the opposite of the edited leg
+func (s *TransactionService) mirrorTransferLeg(counterpart domain.Transaction, edited domain.Transaction) (domain.Transaction, error) {
+	if edited.Account == counterpart.Account {
+		return domain.Transaction{}, errors.New(errors.ErrorTypeValidation, errors.CodeInvalidTransfer, "Both legs of a transfer cannot use the same account")
+	}
+
+	amount, err := mirrorAmount(edited, counterpart)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	mirrored := s.applyModifications(counterpart, domain.Transaction{
+		Date:        edited.Date,
+		Amount:      amount,
+		Description: edited.Description,
+	})
+	mirrored.ID = uuid.New().String()
+	// Split lines of the other leg no longer add up once its amount changes
+	if mirrored.Amount != counterpart.Amount {
+		mirrored.Splits = nil
+	}
+
+	return s.PrepareTransaction(mirrored)
+}
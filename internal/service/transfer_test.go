@@ -0,0 +1,120 @@
+package service
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestTransactionService_AddTransfer(t *testing.T) {
+	mockStorage := &MockStorage{
+		transactions: []domain.Transaction{},
+		accounts: []domain.Account{
+			{ID: "BANQUE", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Currency: "EUR", InitialBalance: domain.NewMoney(0, "EUR"), IsActive: true},
+		},
+		categories: []domain.Category{},
+		tags:       []domain.Tag{},
+	}
+	service := NewTransactionService(mockStorage)
+
+	legs, err := service.AddTransfer(TransferRequest{
+		From:   "BANQUE",
+		To:     "LIVRET",
+		Amount: domain.NewMoney(10000, "EUR"),
+		Date:   time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(legs) != 2 || len(mockStorage.transactions) != 2 {
+		t.Fatalf("Expected 2 legs saved, got %d legs and %d transactions", len(legs), len(mockStorage.transactions))
+	}
+	if legs[0].TransferID == "" || legs[0].TransferID != legs[1].TransferID {
+		t.Error("Expected both legs to share a transfer ID")
+	}
+
+	banque, _ := service.GetAccountBalance("BANQUE")
+	livret, _ := service.GetAccountBalance("LIVRET")
+	if banque != domain.NewMoney(90000, "EUR") || livret != domain.NewMoney(10000, "EUR") {
+		t.Errorf("Expected balances 900.00/100.00 EUR, got %s/%s", banque, livret)
+	}
+}
+
+func TestTransactionService_AddTransfer_Invalid(t *testing.T) {
+	service := NewTransactionService(&MockStorage{
+		transactions: []domain.Transaction{},
+		accounts: []domain.Account{
+			{ID: "BANQUE", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Currency: "EUR", InitialBalance: domain.NewMoney(0, "EUR"), IsActive: true},
+		},
+		categories: []domain.Category{},
+		tags:       []domain.Tag{},
+	})
+
+	invalid := []TransferRequest{
+		{From: "BANQUE", To: "BANQUE", Amount: domain.NewMoney(100, "EUR")},
+		{From: "BANQUE", To: "LIVRET", Amount: domain.NewMoney(-100, "EUR")},
+		{From: "BANQUE", To: "UNKNOWN", Amount: domain.NewMoney(100, "EUR")},
+	}
+
+	for _, request := range invalid {
+		if _, err := service.AddTransfer(request); err == nil {
+			t.Errorf("Expected error for transfer %s -> %s of %s, got nil", request.From, request.To, request.Amount)
+		}
+	}
+}
+
+func TestTransactionService_TransferLegsFollowEachOther(t *testing.T) {
+	mockStorage := &MockStorage{
+		transactions: []domain.Transaction{},
+		accounts: []domain.Account{
+			{ID: "BANQUE", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true},
+			{ID: "LIVRET", Currency: "EUR", InitialBalance: domain.NewMoney(0, "EUR"), IsActive: true},
+		},
+		categories: []domain.Category{},
+		tags:       []domain.Tag{},
+	}
+	service := NewTransactionService(mockStorage)
+
+	legs, _ := service.AddTransfer(TransferRequest{From: "BANQUE", To: "LIVRET", Amount: domain.NewMoney(10000, "EUR")})
+
+	// Editing the credit leg mirrors the amount on the debit leg
+	edited, err := service.EditTransaction(legs[1].ID, domain.Transaction{ID: "edited", Amount: domain.NewMoney(15000, "EUR")}, "Montant corrigé")
+	if err != nil {
+		t.Fatalf("Expected no error editing transfer, got %v", err)
+	}
+
+	banque, _ := service.GetAccountBalance("BANQUE")
+	if banque != domain.NewMoney(85000, "EUR") {
+		t.Errorf("Expected BANQUE balance 850.00 EUR after edit, got %s", banque)
+	}
+
+	// Undoing the edit restores both legs
+	if err := service.UndoTransaction(edited.ID); err != nil {
+		t.Fatalf("Expected no error undoing edit, got %v", err)
+	}
+	banque, _ = service.GetAccountBalance("BANQUE")
+	if banque != domain.NewMoney(90000, "EUR") || len(mockStorage.transactions) != 2 {
+		t.Errorf("Expected original transfer restored, got balance %s and %d transactions", banque, len(mockStorage.transactions))
+	}
+
+	// Deleting one leg deletes the other
+	if err := service.DeleteTransaction(legs[0].ID, "Annulé"); err != nil {
+		t.Fatalf("Expected no error deleting transfer, got %v", err)
+	}
+	for _, txn := range mockStorage.transactions {
+		if txn.IsActive {
+			t.Errorf("Expected leg %s to be deleted", txn.ID)
+		}
+	}
+
+	// Hard delete removes both legs
+	if err := service.DeleteTransactionHard(legs[1].ID, "Purge"); err != nil {
+		t.Fatalf("Expected no error hard deleting transfer, got %v", err)
+	}
+	if len(mockStorage.transactions) != 0 {
+		t.Errorf("Expected no transactions left, got %d", len(mockStorage.transactions))
+	}
+}
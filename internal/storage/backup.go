@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"comptes/internal/errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// backupFormatVersion is the layout of the archives written by Backup
+	backupFormatVersion = 1
+
+	manifestName = "manifest.json"
+
+	// Directories of the archive
+	archiveDataDir   = "data"
+	archiveConfigDir = "config"
+
+	// autoBackupPrefix names the backups taken before destructive commands, of which
+	// only the latest autoBackupKeep are kept
+	autoBackupPrefix = "auto-"
+	autoBackupKeep   = 10
+)
+
+// BackupManifest describes an archive written by Backup. It is stored first in the
+// archive, unencrypted: counts are only given for a plaintext data directory.
+type BackupManifest struct {
+	FormatVersion int          `json:"format_version"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Reason        string       `json:"reason,omitempty"`
+	SchemaVersion int          `json:"schema_version"`
+	Encrypted     bool         `json:"encrypted"`
+	Accounts      int          `json:"accounts"`
+	Movements     int          `json:"movements"`
+	Files         []BackupFile `json:"files"`
+}
+
+// BackupFile is a file of an archive with its checksum
+type BackupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Size returns the total size of the files of the archive
+func (m BackupManifest) Size() int64 {
+	var size int64
+	for _, file := range m.Files {
+		size += file.Size
+	}
+	return size
+}
+
+// BackupPath returns the default path of a new archive: data/backups/comptes-<date>[-reason].tar.gz
+func BackupPath(dataDir, reason string) string {
+	name := "comptes-" + time.Now().Format("20060102-150405")
+	if reason != "" {
+		name = autoBackupPrefix + name + "-" + reason
+	}
+	return filepath.Join(dataDir, backupDirName, name+".tar.gz")
+}
+
+// Backup writes a compressed archive of the data directory (files as stored, encrypted
+// or not) and of the configuration file, with a manifest of their checksums. The
+// storage is locked meanwhile so that the archive is consistent.
+func Backup(s Storage, dataDir, configPath, target, reason string) (BackupManifest, error) {
+	if err := s.Lock(); err != nil {
+		return BackupManifest{}, err
+	}
+	defer s.Unlock()
+
+	manifest := BackupManifest{
+		FormatVersion: backupFormatVersion,
+		CreatedAt:     time.Now(),
+		Reason:        reason,
+		Encrypted:     IsEncrypted(dataDir),
+	}
+	var err error
+	if manifest.SchemaVersion, err = SchemaVersion(dataDir); err != nil {
+		return manifest, errors.BackupFailed(err)
+	}
+	// Counts are informative: a directory that does not load must still be backed up
+	if !manifest.Encrypted {
+		if accounts, err := s.GetAccounts(); err == nil {
+			manifest.Accounts = len(accounts)
+		}
+		if transactions, err := s.QueryTransactions(TransactionQuery{Active: ActiveOnly(true)}); err == nil {
+			manifest.Movements = len(transactions)
+		}
+	}
+
+	// Read everything first: the manifest goes first in the archive
+	contents := make(map[string][]byte)
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return manifest, errors.BackupFailed(err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == lockFileName || isTempFile(entry.Name()) {
+			continue
+		}
+		if err := addBackupFile(&manifest, contents, path.Join(archiveDataDir, entry.Name()), filepath.Join(dataDir, entry.Name())); err != nil {
+			return manifest, errors.BackupFailed(err)
+		}
+	}
+	if configPath != "" {
+		if _, err := os.Stat(configPath); err == nil {
+			if err := addBackupFile(&manifest, contents, path.Join(archiveConfigDir, filepath.Base(configPath)), configPath); err != nil {
+				return manifest, errors.BackupFailed(err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, manifest, contents); err != nil {
+		return manifest, errors.BackupFailed(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return manifest, errors.BackupFailed(err)
+	}
+	if err := writeFileAtomic(target, buf.Bytes(), 0600); err != nil {
+		return manifest, errors.BackupFailed(err)
+	}
+
+	if reason != "" {
+		pruneAutoBackups(filepath.Dir(target))
+	}
+	return manifest, nil
+}
+
+func addBackupFile(manifest *BackupManifest, contents map[string][]byte, name, source string) error {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	manifest.Files = append(manifest.Files, BackupFile{Path: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+	contents[name] = data
+	return nil
+}
+
+func writeArchive(w io.Writer, manifest BackupManifest, contents map[string][]byte) error {
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: manifest.CreatedAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := write(manifestName, manifestData); err != nil {
+		return err
+	}
+	for _, file := range manifest.Files {
+		if err := write(file.Path, contents[file.Path]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// pruneAutoBackups removes the oldest automatic backups beyond autoBackupKeep
+func pruneAutoBackups(dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, autoBackupPrefix+"*.tar.gz"))
+	if err != nil || len(matches) <= autoBackupKeep {
+		return
+	}
+	// Names start with the date: lexical order is chronological
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-autoBackupKeep] {
+		os.Remove(old)
+	}
+}
+
+// BackupArchive is an archive read and verified by ReadBackup
+type BackupArchive struct {
+	Manifest BackupManifest
+	files    map[string][]byte
+}
+
+// ReadBackup reads an archive and checks it against its manifest: every file listed
+// must be present with its size and checksum, and nothing else may be there
+func ReadBackup(archive string) (*BackupArchive, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, errors.InvalidBackup(archive, err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.InvalidBackup(archive, err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifestData []byte
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.InvalidBackup(archive, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, errors.InvalidBackup(archive, fmt.Errorf("unexpected entry %s", header.Name))
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, errors.InvalidBackup(archive, err)
+		}
+		if header.Name == manifestName {
+			manifestData = data
+			continue
+		}
+		if !validArchivePath(header.Name) {
+			return nil, errors.InvalidBackup(archive, fmt.Errorf("unexpected entry %s", header.Name))
+		}
+		files[header.Name] = data
+	}
+
+	if manifestData == nil {
+		return nil, errors.InvalidBackup(archive, fmt.Errorf("no %s", manifestName))
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, errors.InvalidBackup(archive, fmt.Errorf("failed to parse %s: %w", manifestName, err))
+	}
+	if manifest.FormatVersion != backupFormatVersion {
+		return nil, errors.InvalidBackup(archive, fmt.Errorf("unsupported format version %d", manifest.FormatVersion))
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		data, exists := files[entry.Path]
+		if !exists {
+			return nil, errors.InvalidBackup(archive, fmt.Errorf("%s is missing", entry.Path))
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != entry.Size || hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return nil, errors.InvalidBackup(archive, fmt.Errorf("checksum mismatch for %s", entry.Path))
+		}
+		listed[entry.Path] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, errors.InvalidBackup(archive, fmt.Errorf("%s is not in the manifest", name))
+		}
+	}
+
+	return &BackupArchive{Manifest: manifest, files: files}, nil
+}
+
+// isTempFile tells the temporary files of writeFileAtomic
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".tmp")
+}
+
+// validArchivePath accepts the files Backup writes: data/<file> and config/<file>
+func validArchivePath(name string) bool {
+	dir, file := path.Split(name)
+	return (dir == archiveDataDir+"/" || dir == archiveConfigDir+"/") && file != "" && file != "." && file != ".."
+}
+
+// Restore replaces the data directory (and the configuration file, when the archive
+// has one) with the content of a verified archive. Data files missing from the archive
+// are removed. The replacement goes through a commit intent, like a unit of work.
+func Restore(s Storage, dataDir, configPath string, archive *BackupArchive) error {
+	if archive.Manifest.SchemaVersion > CurrentSchemaVersion {
+		return errors.SchemaTooNew(dataDir, archive.Manifest.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	var intent []intentFile
+	restored := make(map[string]bool)
+	var configData []byte
+	for _, entry := range archive.Manifest.Files {
+		dir, name := path.Split(entry.Path)
+		if dir == archiveConfigDir+"/" {
+			configData = archive.files[entry.Path]
+			continue
+		}
+		intent = append(intent, newIntentFile(name, archive.files[entry.Path]))
+		restored[name] = true
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return errors.StorageReadFailed(dataDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && name != lockFileName && name != intentFileName && !restored[name] && !isTempFile(name) {
+			intent = append(intent, intentFile{Name: name, Remove: true})
+		}
+	}
+
+	if err := writeIntent(dataDir, intent); err != nil {
+		return errors.StorageWriteFailed(dataDir, err)
+	}
+	if configData != nil && configPath != "" {
+		if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			return errors.StorageWriteFailed(configPath, err)
+		}
+		if err := writeFileAtomic(configPath, configData, 0644); err != nil {
+			return errors.StorageWriteFailed(configPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup_RestoreRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	dataDir := filepath.Join(tempDir, "data")
+	configPath := filepath.Join(tempDir, "config", "config.yaml")
+	os.MkdirAll(dataDir, 0755)
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte("accounts: []\n"), 0644)
+
+	storage, err := New(BackendJSON, dataDir)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	if err := storage.SaveAccounts([]domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveTransactions(testTransactions()); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(tempDir, "backup.tar.gz")
+	manifest, err := Backup(storage, dataDir, configPath, archivePath, "")
+	if err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+	if manifest.Accounts != 1 || manifest.Movements != 2 {
+		t.Errorf("Expected 1 account and 2 movements in the manifest, got %d and %d", manifest.Accounts, manifest.Movements)
+	}
+
+	// Change everything after the backup
+	if err := storage.SaveTransactions(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveTags([]domain.Tag{{Code: "NEW"}}); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(configPath, []byte("changed\n"), 0644)
+
+	archive, err := ReadBackup(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if err := Restore(storage, dataDir, configPath, archive); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+
+	if loaded, _ := storage.GetTransactions(); len(loaded) != 2 {
+		t.Errorf("Expected 2 restored transactions, got %d", len(loaded))
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "tags.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected tags.json, written after the backup, to be removed")
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != "accounts: []\n" {
+		t.Errorf("Expected config.yaml to be restored, got %q", data)
+	}
+}
+
+func TestReadBackup_RejectsAlteredArchives(t *testing.T) {
+	tempDir := t.TempDir()
+	movements := []byte(`[]`)
+	sum := "4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945" // SHA-256 of "[]"
+
+	cases := []struct {
+		name     string
+		files    []BackupFile
+		contents map[string][]byte
+	}{
+		{"checksum mismatch", []BackupFile{{Path: "data/movements.json", Size: 2, SHA256: "0000"}}, map[string][]byte{"data/movements.json": movements}},
+		{"missing file", []BackupFile{{Path: "data/movements.json", Size: 2, SHA256: sum}}, map[string][]byte{}},
+		{"unexpected path", []BackupFile{{Path: "../evil.json", Size: 2, SHA256: sum}}, map[string][]byte{"../evil.json": movements}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			manifest := BackupManifest{FormatVersion: backupFormatVersion, Files: tc.files}
+			if err := writeArchive(&buf, manifest, tc.contents); err != nil {
+				t.Fatal(err)
+			}
+			archivePath := filepath.Join(tempDir, "archive.tar.gz")
+			os.WriteFile(archivePath, buf.Bytes(), 0644)
+
+			_, err := ReadBackup(archivePath)
+			if !hasErrorCode(err, errors.CodeInvalidBackup) {
+				t.Errorf("Expected invalid_backup, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBackup_PrunesAutomaticBackups(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewJSONStorage(dataDir)
+	backupDir := filepath.Join(dataDir, backupDirName)
+	os.MkdirAll(backupDir, 0755)
+	for i := 0; i < autoBackupKeep+3; i++ {
+		os.WriteFile(filepath.Join(backupDir, fmt.Sprintf("%scomptes-20240101-0000%02d-init.tar.gz", autoBackupPrefix, i)), nil, 0644)
+	}
+	manual := filepath.Join(backupDir, "comptes-20230101-000000.tar.gz")
+	os.WriteFile(manual, nil, 0644)
+
+	if _, err := Backup(storage, dataDir, "", BackupPath(dataDir, "init"), "init"); err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(backupDir, autoBackupPrefix+"*"))
+	if len(matches) != autoBackupKeep {
+		t.Errorf("Expected %d automatic backups kept, got %d", autoBackupKeep, len(matches))
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, autoBackupPrefix+"comptes-20240101-000000-init.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest automatic backup to be removed")
+	}
+	if _, err := os.Stat(manual); err != nil {
+		t.Errorf("Expected manual backups to be kept: %v", err)
+	}
+}
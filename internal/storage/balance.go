@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"fmt"
+	"time"
+)
+
+// snapshotStore is what balance snapshots need from a storage backend
+type snapshotStore interface {
+	Lock() error
+	Unlock()
+	GetAccounts() ([]domain.Account, error)
+	GetTransactions() ([]domain.Transaction, error)
+	QueryTransactions(query TransactionQuery) ([]domain.Transaction, error)
+	GetBalanceSnapshots() ([]domain.BalanceSnapshot, error)
+	SaveBalanceSnapshots(snapshots []domain.BalanceSnapshot) error
+}
+
+// snapshotBalance computes the balance of an account from its latest valid snapshot and
+// the movements dated after it: only those are read. Snapshots are taken at the start of
+// each month: when the current month has none yet, it is recorded on the way.
+func snapshotBalance(s snapshotStore, accountID string) (domain.Money, error) {
+	// The snapshot written must match the movements read
+	if err := s.Lock(); err != nil {
+		return domain.Money{}, err
+	}
+	defer s.Unlock()
+
+	accounts, err := s.GetAccounts()
+	if err != nil {
+		return domain.Money{}, err
+	}
+	snapshots, err := s.GetBalanceSnapshots()
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	account := findAccount(accounts, accountID)
+	if account == nil {
+		return domain.Money{}, fmt.Errorf("account not found: %s", accountID)
+	}
+
+	// Latest snapshot still consistent with the movements; inconsistent ones are dropped
+	kept := snapshots[:0:0]
+	var latest *domain.BalanceSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.AccountID != accountID {
+			kept = append(kept, snapshot)
+			continue
+		}
+		valid, err := snapshotValid(s, snapshot, *account)
+		if err != nil {
+			return domain.Money{}, err
+		}
+		if !valid {
+			continue
+		}
+		kept = append(kept, snapshot)
+		if latest == nil || snapshot.Date.After(latest.Date) {
+			found := snapshot
+			latest = &found
+		}
+	}
+	changed := len(kept) != len(snapshots)
+
+	balance := account.InitialBalance.WithCurrency(account.Currency)
+	query := TransactionQuery{Account: accountID, Active: ActiveOnly(true)}
+	if latest != nil {
+		balance = latest.Balance
+		query.From = latest.Date
+	}
+	transactions, err := s.QueryTransactions(query)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	// Replay the movements after the snapshot, and record one at the start of this month
+	cutoff := monthStart(time.Now())
+	next := domain.BalanceSnapshot{AccountID: accountID, Balance: balance, Date: cutoff}
+	var lastDate time.Time
+	var covered int
+	takeSnapshot := latest == nil || Day(latest.Date).Before(cutoff)
+	for _, txn := range transactions {
+		if !balance.SameCurrency(txn.Amount) {
+			return domain.Money{}, fmt.Errorf("transaction %s is in %s but account %s uses %s", txn.ID, txn.Amount.Currency, accountID, account.Currency)
+		}
+		balance = balance.Add(txn.Amount)
+
+		if takeSnapshot && Day(txn.Date).Before(cutoff) {
+			covered++
+			next.Balance = next.Balance.Add(txn.Amount)
+			if !txn.Date.Before(lastDate) {
+				lastDate = txn.Date
+				next.LastTxnID = txn.ID
+			}
+		}
+	}
+
+	if takeSnapshot && covered > 0 {
+		kept = append(kept, next)
+		changed = true
+	}
+
+	if changed {
+		if err := s.SaveBalanceSnapshots(kept); err != nil {
+			return domain.Money{}, err
+		}
+	}
+	return balance, nil
+}
+
+func findAccount(accounts []domain.Account, accountID string) *domain.Account {
+	for i := range accounts {
+		if accounts[i].ID == accountID {
+			return &accounts[i]
+		}
+	}
+	return nil
+}
+
+// snapshotValid guards against changes made behind the back of the invalidation (older
+// binaries, hand edits): the snapshot currency must match the account, and its last
+// movement must still be an active movement of the account dated before the snapshot
+func snapshotValid(s snapshotStore, snapshot domain.BalanceSnapshot, account domain.Account) (bool, error) {
+	if snapshot.Balance.Currency != account.Currency {
+		return false, nil
+	}
+	if snapshot.LastTxnID == "" {
+		return true, nil
+	}
+	candidates, err := s.QueryTransactions(TransactionQuery{Account: account.ID, IDPrefix: snapshot.LastTxnID})
+	if err != nil {
+		return false, err
+	}
+	for _, txn := range candidates {
+		if txn.ID == snapshot.LastTxnID {
+			return txn.IsActive && Day(txn.Date).Before(Day(snapshot.Date)), nil
+		}
+	}
+	return false, nil
+}
+
+// invalidateSnapshots drops the snapshots that include a movement added, changed or
+// removed between previous and current (e.g. a back-dated add, edit, delete or undo)
+func invalidateSnapshots(snapshots []domain.BalanceSnapshot, previous, current []domain.Transaction) []domain.BalanceSnapshot {
+	// Earliest date touched per account
+	earliest := make(map[string]time.Time)
+	touch := func(txn domain.Transaction) {
+		if date, exists := earliest[txn.Account]; !exists || Day(txn.Date).Before(date) {
+			earliest[txn.Account] = Day(txn.Date)
+		}
+	}
+
+	before := make(map[string]domain.Transaction, len(previous))
+	for _, txn := range previous {
+		before[txn.ID] = txn
+	}
+	for _, txn := range current {
+		old, exists := before[txn.ID]
+		delete(before, txn.ID)
+		if exists && sameBalanceEffect(old, txn) {
+			continue
+		}
+		touch(txn)
+		if exists {
+			touch(old)
+		}
+	}
+	for _, removed := range before {
+		touch(removed)
+	}
+
+	kept := snapshots[:0:0]
+	for _, snapshot := range snapshots {
+		if date, touched := earliest[snapshot.AccountID]; touched && date.Before(Day(snapshot.Date)) {
+			continue
+		}
+		kept = append(kept, snapshot)
+	}
+	return kept
+}
+
+// invalidateAccountSnapshots drops the snapshots of accounts whose initial balance or currency changed
+func invalidateAccountSnapshots(snapshots []domain.BalanceSnapshot, previous, current []domain.Account) []domain.BalanceSnapshot {
+	kept := snapshots[:0:0]
+	for _, snapshot := range snapshots {
+		old := findAccount(previous, snapshot.AccountID)
+		updated := findAccount(current, snapshot.AccountID)
+		if old == nil || updated == nil || old.Currency != updated.Currency ||
+			old.InitialBalance.WithCurrency(old.Currency) != updated.InitialBalance.WithCurrency(updated.Currency) {
+			continue
+		}
+		kept = append(kept, snapshot)
+	}
+	return kept
+}
+
+func sameBalanceEffect(a, b domain.Transaction) bool {
+	return a.Account == b.Account && a.Amount == b.Amount && a.IsActive == b.IsActive && a.Date.Equal(b.Date)
+}
+
+// monthStart returns the first day of the month of t, as a calendar day (see Day)
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// invalidateForTransactions drops the snapshots that saving transactions makes stale.
+// It runs before the save: a crash in between only loses snapshots, never leaves a wrong one.
+func invalidateForTransactions(s snapshotStore, transactions []domain.Transaction) error {
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	snapshots, err := s.GetBalanceSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		return err
+	}
+	previous, err := s.GetTransactions()
+	if err != nil {
+		return err
+	}
+	if kept := invalidateSnapshots(snapshots, previous, transactions); len(kept) != len(snapshots) {
+		return s.SaveBalanceSnapshots(kept)
+	}
+	return nil
+}
+
+// invalidateForAccounts drops the snapshots that saving accounts makes stale
+func invalidateForAccounts(s snapshotStore, accounts []domain.Account) error {
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	snapshots, err := s.GetBalanceSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		return err
+	}
+	previous, err := s.GetAccounts()
+	if err != nil {
+		return err
+	}
+	if kept := invalidateAccountSnapshots(snapshots, previous, accounts); len(kept) != len(snapshots) {
+		return s.SaveBalanceSnapshots(kept)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func snapshotFixture(t *testing.T) (*JSONStorage, []domain.Transaction) {
+	storage := NewJSONStorage(t.TempDir())
+	if err := storage.SaveAccounts([]domain.Account{{ID: "BANQUE", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true}}); err != nil {
+		t.Fatalf("Failed to save accounts: %v", err)
+	}
+
+	lastMonth := monthStart(time.Now()).AddDate(0, -1, 0)
+	transactions := []domain.Transaction{
+		{ID: "old1", Account: "BANQUE", Date: lastMonth, Amount: domain.NewMoney(-5000, "EUR"), IsActive: true},
+		{ID: "old2", Account: "BANQUE", Date: lastMonth.AddDate(0, 0, 10), Amount: domain.NewMoney(-2000, "EUR"), IsActive: true},
+		{ID: "new1", Account: "BANQUE", Date: time.Now(), Amount: domain.NewMoney(-1000, "EUR"), IsActive: true},
+	}
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	return storage, transactions
+}
+
+func expectBalance(t *testing.T, storage *JSONStorage, expected int64) {
+	t.Helper()
+	balance, err := storage.GetAccountBalance("BANQUE")
+	if err != nil {
+		t.Fatalf("Failed to compute balance: %v", err)
+	}
+	if balance != domain.NewMoney(expected, "EUR") {
+		t.Errorf("Expected balance %s, got %s", domain.NewMoney(expected, "EUR"), balance)
+	}
+}
+
+func expectSnapshots(t *testing.T, storage *JSONStorage, expected int) []domain.BalanceSnapshot {
+	t.Helper()
+	snapshots, err := storage.GetBalanceSnapshots()
+	if err != nil {
+		t.Fatalf("Failed to read snapshots: %v", err)
+	}
+	if len(snapshots) != expected {
+		t.Fatalf("Expected %d snapshots, got %+v", expected, snapshots)
+	}
+	return snapshots
+}
+
+func TestSnapshotBalance_TakesMonthlySnapshot(t *testing.T) {
+	storage, _ := snapshotFixture(t)
+
+	expectBalance(t, storage, 92000)
+	snapshots := expectSnapshots(t, storage, 1)
+	if snapshots[0].Balance != domain.NewMoney(93000, "EUR") || snapshots[0].LastTxnID != "old2" || !snapshots[0].Date.Equal(monthStart(time.Now())) {
+		t.Errorf("Unexpected snapshot: %+v", snapshots[0])
+	}
+
+	// Later balances start from the snapshot
+	snapshots[0].Balance = domain.NewMoney(50000, "EUR")
+	if err := storage.SaveBalanceSnapshots(snapshots); err != nil {
+		t.Fatalf("Failed to save snapshots: %v", err)
+	}
+	expectBalance(t, storage, 49000)
+}
+
+func TestSnapshotBalance_Invalidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func([]domain.Transaction) []domain.Transaction
+		kept     bool
+		expected int64
+	}{
+		{"edit after snapshot", func(txns []domain.Transaction) []domain.Transaction {
+			txns[2].Amount = domain.NewMoney(-3000, "EUR")
+			return txns
+		}, true, 90000},
+		{"description only", func(txns []domain.Transaction) []domain.Transaction {
+			txns[0].Description = "Renommé"
+			return txns
+		}, true, 92000},
+		{"back-dated add", func(txns []domain.Transaction) []domain.Transaction {
+			return append(txns, domain.Transaction{ID: "late", Account: "BANQUE", Date: txns[0].Date, Amount: domain.NewMoney(-500, "EUR"), IsActive: true})
+		}, false, 91500},
+		{"soft delete before snapshot", func(txns []domain.Transaction) []domain.Transaction {
+			txns[0].IsActive = false
+			return txns
+		}, false, 97000},
+		{"hard delete before snapshot", func(txns []domain.Transaction) []domain.Transaction {
+			return txns[1:]
+		}, false, 97000},
+		{"moved after snapshot", func(txns []domain.Transaction) []domain.Transaction {
+			txns[1].Date = time.Now()
+			return txns
+		}, false, 92000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, transactions := snapshotFixture(t)
+			expectBalance(t, storage, 92000)
+			expectSnapshots(t, storage, 1)
+
+			if err := storage.SaveTransactions(tt.change(transactions)); err != nil {
+				t.Fatalf("Failed to save transactions: %v", err)
+			}
+			if tt.kept {
+				expectSnapshots(t, storage, 1)
+			} else {
+				expectSnapshots(t, storage, 0)
+			}
+			expectBalance(t, storage, tt.expected)
+		})
+	}
+}
+
+func TestSnapshotBalance_InitialBalanceChange(t *testing.T) {
+	storage, _ := snapshotFixture(t)
+	expectBalance(t, storage, 92000)
+
+	accounts, _ := storage.GetAccounts()
+	accounts[0].InitialBalance = domain.NewMoney(200000, "EUR")
+	if err := storage.SaveAccounts(accounts); err != nil {
+		t.Fatalf("Failed to save accounts: %v", err)
+	}
+	expectSnapshots(t, storage, 0)
+	expectBalance(t, storage, 192000)
+}
+
+func TestSnapshotBalance_IgnoresInconsistentSnapshot(t *testing.T) {
+	storage, _ := snapshotFixture(t)
+	// A snapshot whose last movement no longer exists (e.g. written by hand)
+	stale := domain.BalanceSnapshot{AccountID: "BANQUE", Balance: domain.NewMoney(1, "EUR"), Date: monthStart(time.Now()), LastTxnID: "missing"}
+	if err := storage.SaveBalanceSnapshots([]domain.BalanceSnapshot{stale}); err != nil {
+		t.Fatalf("Failed to save snapshots: %v", err)
+	}
+
+	expectBalance(t, storage, 92000)
+	snapshots := expectSnapshots(t, storage, 1)
+	if snapshots[0].LastTxnID != "old2" {
+		t.Errorf("Expected the stale snapshot to be replaced, got %+v", snapshots[0])
+	}
+}
+
+// queryRecorder records the movement queries made through a storage
+type queryRecorder struct {
+	*JSONStorage
+	queries []TransactionQuery
+}
+
+func (r *queryRecorder) QueryTransactions(query TransactionQuery) ([]domain.Transaction, error) {
+	r.queries = append(r.queries, query)
+	return r.JSONStorage.QueryTransactions(query)
+}
+
+func TestSnapshotBalance_ReadsOnlyMovementsAfterSnapshot(t *testing.T) {
+	storage, _ := snapshotFixture(t)
+	expectBalance(t, storage, 92000)
+	expectSnapshots(t, storage, 1)
+
+	recorder := &queryRecorder{JSONStorage: storage}
+	balance, err := snapshotBalance(recorder, "BANQUE")
+	if err != nil || balance != domain.NewMoney(92000, "EUR") {
+		t.Fatalf("Expected 920.00 EUR, got %s (%v)", balance, err)
+	}
+	for _, query := range recorder.queries {
+		if query.IDPrefix == "" && !query.From.Equal(monthStart(time.Now())) {
+			t.Errorf("Expected the movements to be read from the snapshot on, got %+v", query)
+		}
+	}
+}
+
+func TestSnapshotBalance_MonthOfMovementDay(t *testing.T) {
+	storage := NewJSONStorage(t.TempDir())
+	if err := storage.SaveAccounts([]domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}}); err != nil {
+		t.Fatalf("Failed to save accounts: %v", err)
+	}
+	// Last day of the previous month in American Samoa, already this month in UTC
+	samoa := time.FixedZone("SST", -11*60*60)
+	lastDay := monthStart(time.Now()).AddDate(0, 0, -1)
+	late := domain.Transaction{ID: "late", Account: "BANQUE", Date: time.Date(lastDay.Year(), lastDay.Month(), lastDay.Day(), 23, 30, 0, 0, samoa), Amount: domain.NewMoney(-500, "EUR"), IsActive: true}
+	if err := storage.SaveTransactions([]domain.Transaction{late}); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	expectBalance(t, storage, -500)
+	snapshots := expectSnapshots(t, storage, 1)
+	if snapshots[0].LastTxnID != "late" || snapshots[0].Balance != domain.NewMoney(-500, "EUR") {
+		t.Errorf("Expected the movement in the snapshot of its own month, got %+v", snapshots[0])
+	}
+}
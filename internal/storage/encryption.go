@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"bytes"
+	"comptes/internal/errors"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	encryptionFileName = "encryption.json"
+
+	// kdfPBKDF2 derives the key with PBKDF2-HMAC-SHA256
+	kdfPBKDF2 = "pbkdf2-sha256"
+)
+
+// sealedMagic starts every encrypted file and journal line
+var sealedMagic = []byte("CENC1")
+
+// passphraseCheck is sealed in encryption.json to tell a wrong passphrase from corrupted data
+var passphraseCheck = []byte("comptes")
+
+// kdfIterations is the PBKDF2 cost for new keys (lowered by tests)
+var kdfIterations = 600000
+
+// encryptionParams is the content of encryption.json: how to derive the key from the
+// passphrase, never the key itself
+type encryptionParams struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Check      []byte `json:"check"`
+}
+
+// codec turns the bytes of a data file into what is stored on disk, and back. name is
+// the file the bytes belong to: a file sealed under one name does not open under another.
+type codec interface {
+	seal(name string, plaintext []byte) ([]byte, error)
+	open(name string, stored []byte) ([]byte, error)
+}
+
+// plainCodec stores files as they are
+type plainCodec struct{}
+
+func (plainCodec) seal(name string, plaintext []byte) ([]byte, error) {
+	return plaintext, nil
+}
+
+func (plainCodec) open(name string, stored []byte) ([]byte, error) {
+	if bytes.HasPrefix(stored, sealedMagic) {
+		return nil, fmt.Errorf("file is encrypted")
+	}
+	return stored, nil
+}
+
+// cipherCodec seals files with AES-256-GCM: magic, random nonce, then ciphertext and tag.
+// The magic and the file name are authenticated as additional data, so that swapping two
+// encrypted files is detected.
+type cipherCodec struct {
+	aead cipher.AEAD
+}
+
+func newCipherCodec(key []byte) (*cipherCodec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cipherCodec{aead: aead}, nil
+}
+
+// additionalData binds a sealed file to its name
+func additionalData(name string) []byte {
+	return append(append([]byte{}, sealedMagic...), name...)
+}
+
+func (c *cipherCodec) seal(name string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := append(append([]byte{}, sealedMagic...), nonce...)
+	return c.aead.Seal(sealed, nonce, plaintext, additionalData(name)), nil
+}
+
+func (c *cipherCodec) open(name string, stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, sealedMagic) {
+		return nil, fmt.Errorf("file is not encrypted")
+	}
+	stored = stored[len(sealedMagic):]
+	if len(stored) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	nonce, ciphertext := stored[:c.aead.NonceSize()], stored[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, additionalData(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: wrong key or altered file")
+	}
+	return plaintext, nil
+}
+
+// encodeLine stores the journal line starting at offset in the file: as is in plaintext,
+// base64 of the sealed bytes otherwise. Sealing the line under its offset detects lines
+// removed, reordered or duplicated, not only altered.
+func encodeLine(c codec, line []byte, offset int64) ([]byte, error) {
+	if _, plain := c.(plainCodec); plain {
+		return line, nil
+	}
+	sealed, err := c.seal(journalLineName(offset), line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// decodeLine reverses encodeLine (line without its newline)
+func decodeLine(c codec, line []byte, offset int64) ([]byte, error) {
+	if _, plain := c.(plainCodec); plain {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return nil, err
+	}
+	return c.open(journalLineName(offset), sealed)
+}
+
+// journalLineName is the name a journal line is sealed under
+func journalLineName(offset int64) string {
+	return fmt.Sprintf("%s@%d", journalFileName, offset)
+}
+
+// IsEncrypted reports whether a data directory is encrypted
+func IsEncrypted(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(dataDir, encryptionFileName))
+	return err == nil
+}
+
+// unlockDataDir derives the key of an encrypted data directory from its passphrase
+func unlockDataDir(dataDir, passphrase string) (codec, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, encryptionFileName))
+	if err != nil {
+		return nil, errors.StorageReadFailed(encryptionFileName, err)
+	}
+	var params encryptionParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, errors.StorageReadFailed(encryptionFileName, err)
+	}
+	if params.KDF != kdfPBKDF2 || params.Iterations <= 0 || len(params.Salt) == 0 {
+		return nil, errors.StorageReadFailed(encryptionFileName, fmt.Errorf("unsupported key derivation %q", params.KDF))
+	}
+
+	c, err := newCipherCodec(pbkdf2SHA256([]byte(passphrase), params.Salt, params.Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	if check, err := c.open(encryptionFileName, params.Check); err != nil || !bytes.Equal(check, passphraseCheck) {
+		return nil, errors.WrongPassphrase()
+	}
+	return c, nil
+}
+
+// newEncryptionParams draws a salt and derives a new key from passphrase
+func newEncryptionParams(passphrase string) (encryptionParams, codec, error) {
+	if passphrase == "" {
+		return encryptionParams{}, nil, errors.PassphraseRequired()
+	}
+	params := encryptionParams{Version: 1, KDF: kdfPBKDF2, Iterations: kdfIterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return params, nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	c, err := newCipherCodec(pbkdf2SHA256([]byte(passphrase), params.Salt, params.Iterations, 32))
+	if err != nil {
+		return params, nil, err
+	}
+	if params.Check, err = c.seal(encryptionFileName, passphraseCheck); err != nil {
+		return params, nil, err
+	}
+	return params, c, nil
+}
+
+// EnableEncryption encrypts every data file of a plaintext data directory with a key
+// derived from passphrase
+func EnableEncryption(dataDir, passphrase string) error {
+	if IsEncrypted(dataDir) {
+		return errors.AlreadyEncrypted(dataDir)
+	}
+	params, to, err := newEncryptionParams(passphrase)
+	if err != nil {
+		return err
+	}
+	return transformDataDir(dataDir, plainCodec{}, to, &params)
+}
+
+// DisableEncryption decrypts every data file of an encrypted data directory
+func DisableEncryption(dataDir, passphrase string) error {
+	if !IsEncrypted(dataDir) {
+		return errors.NotEncrypted(dataDir)
+	}
+	from, err := unlockDataDir(dataDir, passphrase)
+	if err != nil {
+		return err
+	}
+	return transformDataDir(dataDir, from, plainCodec{}, nil)
+}
+
+// RotatePassphrase re-encrypts every data file with a key derived from a new passphrase
+func RotatePassphrase(dataDir, oldPassphrase, newPassphrase string) error {
+	if !IsEncrypted(dataDir) {
+		return errors.NotEncrypted(dataDir)
+	}
+	from, err := unlockDataDir(dataDir, oldPassphrase)
+	if err != nil {
+		return err
+	}
+	params, to, err := newEncryptionParams(newPassphrase)
+	if err != nil {
+		return err
+	}
+	return transformDataDir(dataDir, from, to, &params)
+}
+
+// transformDataDir re-encodes every data file from one codec to another, and writes
+// (or removes, when params is nil) encryption.json. All new contents go through a
+// commit intent: a crash leaves the old or the new directory once recovered, never a mix.
+func transformDataDir(dataDir string, from, to codec, params *encryptionParams) error {
+	lock := &dirLock{dataDir: dataDir}
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	// Finish an interrupted unit of work first, it was written with the old codec
+	if _, err := recoverIntent(dataDir); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return errors.StorageReadFailed(dataDir, err)
+	}
+
+	var intent []intentFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !isDataFile(name) {
+			continue
+		}
+		stored, err := os.ReadFile(filepath.Join(dataDir, name))
+		if err != nil {
+			return errors.StorageReadFailed(name, err)
+		}
+
+		var content []byte
+		if name == journalFileName {
+			content, err = transformLines(stored, from, to)
+		} else {
+			content, err = transformFile(name, stored, from, to)
+		}
+		if err != nil {
+			return errors.StorageReadFailed(name, err)
+		}
+		intent = append(intent, newIntentFile(name, content))
+	}
+
+	if params != nil {
+		data, err := json.MarshalIndent(params, "", "  ")
+		if err != nil {
+			return err
+		}
+		intent = append(intent, newIntentFile(encryptionFileName, data))
+	} else {
+		intent = append(intent, intentFile{Name: encryptionFileName, Remove: true})
+	}
+
+	return writeIntent(dataDir, intent)
+}
+
+// isDataFile tells the files holding data from the bookkeeping files of the directory
+func isDataFile(name string) bool {
+	switch name {
+	case lockFileName, schemaFileName, encryptionFileName, intentFileName:
+		return false
+	}
+	return filepath.Ext(name) == ".json" || filepath.Ext(name) == ".jsonl"
+}
+
+func transformFile(name string, stored []byte, from, to codec) ([]byte, error) {
+	if len(stored) == 0 {
+		return stored, nil
+	}
+	plaintext, err := from.open(name, stored)
+	if err != nil {
+		return nil, err
+	}
+	return to.seal(name, plaintext)
+}
+
+// transformLines re-encodes the journal line by line, each under its new offset. A last
+// line without its newline is a write torn by a crash, dropped on replay anyway: it is
+// dropped here too. Any other unreadable line is an error, never skipped.
+func transformLines(stored []byte, from, to codec) ([]byte, error) {
+	var buf bytes.Buffer
+	var offset int64
+	for lineNumber, line := range bytes.SplitAfter(stored, []byte("\n")) {
+		start := offset
+		offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 || !bytes.HasSuffix(line, []byte("\n")) {
+			continue
+		}
+		plaintext, err := decodeLine(from, bytes.TrimSuffix(line, []byte("\n")), start)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber+1, err)
+		}
+		encoded, err := encodeLine(to, plaintext, int64(buf.Len()))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// pbkdf2SHA256 derives a key as specified by RFC 8018 (PBKDF2) with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	counter := make([]byte, 4)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter)
+		u = prf.Sum(u[:0])
+
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
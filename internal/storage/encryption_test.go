@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bytes"
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	// Keep key derivation fast in tests
+	kdfIterations = 1000
+}
+
+func passphrase(secret string) func() (string, error) {
+	return func() (string, error) { return secret, nil }
+}
+
+func TestPBKDF2SHA256_RFC7914Vector(t *testing.T) {
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestEncryption_EnableRotateDisable(t *testing.T) {
+	for _, backend := range []string{BackendJSON, BackendJournal} {
+		t.Run(backend, func(t *testing.T) {
+			tempDir := t.TempDir()
+			plain, err := New(backend, tempDir)
+			if err != nil {
+				t.Fatalf("Failed to open storage: %v", err)
+			}
+			if err := plain.SaveTransactions(testTransactions()); err != nil {
+				t.Fatalf("Failed to save transactions: %v", err)
+			}
+
+			if err := EnableEncryption(tempDir, "secret"); err != nil {
+				t.Fatalf("Failed to enable encryption: %v", err)
+			}
+			assertNoPlaintext(t, tempDir, "Courses")
+
+			if _, err := New(backend, tempDir); !hasErrorCode(err, errors.CodePassphraseRequired) {
+				t.Errorf("Expected passphrase_required without a passphrase, got %v", err)
+			}
+			if _, err := Open(backend, tempDir, passphrase("wrong")); !hasErrorCode(err, errors.CodeWrongPassphrase) {
+				t.Errorf("Expected wrong_passphrase, got %v", err)
+			}
+
+			// Writes through an unlocked storage stay encrypted
+			encrypted, err := Open(backend, tempDir, passphrase("secret"))
+			if err != nil {
+				t.Fatalf("Failed to unlock: %v", err)
+			}
+			transactions, err := encrypted.GetTransactions()
+			if err != nil || len(transactions) != 2 {
+				t.Fatalf("Expected 2 transactions, got %d (%v)", len(transactions), err)
+			}
+			transactions = append(transactions, domain.Transaction{ID: "txn3", Account: "BANQUE", Amount: domain.NewMoney(-700, "EUR"), Description: "Boulangerie", IsActive: true})
+			if err := encrypted.SaveTransactions(transactions); err != nil {
+				t.Fatalf("Failed to save transactions: %v", err)
+			}
+			assertNoPlaintext(t, tempDir, "Boulangerie")
+
+			if err := RotatePassphrase(tempDir, "wrong", "new secret"); !hasErrorCode(err, errors.CodeWrongPassphrase) {
+				t.Errorf("Expected rotation to require the current passphrase, got %v", err)
+			}
+			if err := RotatePassphrase(tempDir, "secret", "new secret"); err != nil {
+				t.Fatalf("Failed to rotate passphrase: %v", err)
+			}
+			if _, err := Open(backend, tempDir, passphrase("secret")); !hasErrorCode(err, errors.CodeWrongPassphrase) {
+				t.Errorf("Expected the old passphrase to be rejected, got %v", err)
+			}
+
+			if err := DisableEncryption(tempDir, "new secret"); err != nil {
+				t.Fatalf("Failed to disable encryption: %v", err)
+			}
+			reopened, err := New(backend, tempDir)
+			if err != nil {
+				t.Fatalf("Failed to open decrypted storage: %v", err)
+			}
+			if loaded, err := reopened.GetTransactions(); err != nil || len(loaded) != 3 {
+				t.Errorf("Expected 3 transactions after decryption, got %d (%v)", len(loaded), err)
+			}
+		})
+	}
+}
+
+func TestEncryption_RecoversInterruptedUnitOfWork(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := EnableEncryption(tempDir, "secret"); err != nil {
+		t.Fatalf("Failed to enable encryption: %v", err)
+	}
+	storage, err := Open(BackendJSON, tempDir, passphrase("secret"))
+	if err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+
+	// Crash after the intent was written: the intent only holds encrypted files
+	files := map[string]interface{}{
+		"movements.json":              testTransactions(),
+		"committed_transactions.json": []domain.TransactionBatch{{ID: "batch1"}},
+	}
+	var intent []intentFile
+	for name, v := range files {
+		data, _ := json.Marshal(v)
+		sealed, err := storage.(*JSONStorage).codec.seal(name, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intent = append(intent, newIntentFile(name, sealed))
+	}
+	data, _ := json.Marshal(intent)
+	if err := writeFileAtomic(filepath.Join(tempDir, intentFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertNoPlaintext(t, tempDir, "Courses")
+
+	reopened, err := Open(BackendJSON, tempDir, passphrase("secret"))
+	if err != nil {
+		t.Fatalf("Failed to reopen: %v", err)
+	}
+	if loaded, err := reopened.GetTransactions(); err != nil || len(loaded) != 2 {
+		t.Errorf("Expected the interrupted unit of work to be applied, got %d (%v)", len(loaded), err)
+	}
+}
+
+func TestEncryption_RejectsSwappedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	plain := NewJSONStorage(tempDir)
+	if err := plain.SaveTransactions(testTransactions()); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.SaveTags([]domain.Tag{{Code: "REC", Name: "Récurrent"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnableEncryption(tempDir, "secret"); err != nil {
+		t.Fatalf("Failed to enable encryption: %v", err)
+	}
+
+	// Both files are sealed with the same key, but each under its own name
+	movements, tags := filepath.Join(tempDir, "movements.json"), filepath.Join(tempDir, "tags.json")
+	swapped := filepath.Join(tempDir, "swapped")
+	for _, rename := range [][2]string{{movements, swapped}, {tags, movements}, {swapped, tags}} {
+		if err := os.Rename(rename[0], rename[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage, err := Open(BackendJSON, tempDir, passphrase("secret"))
+	if err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+	if transactions, err := storage.GetTransactions(); err == nil {
+		t.Errorf("Expected the tags moved into movements.json to be rejected, got %+v", transactions)
+	}
+	if tags, err := storage.GetTags(); err == nil {
+		t.Errorf("Expected the movements moved into tags.json to be rejected, got %+v", tags)
+	}
+}
+
+func TestEncryption_DetectsJournalTampering(t *testing.T) {
+	journalDir := func(t *testing.T) (string, [][]byte) {
+		tempDir := t.TempDir()
+		if err := EnableEncryption(tempDir, "secret"); err != nil {
+			t.Fatalf("Failed to enable encryption: %v", err)
+		}
+		storage, err := Open(BackendJournal, tempDir, passphrase("secret"))
+		if err != nil {
+			t.Fatalf("Failed to unlock: %v", err)
+		}
+		// Each save appends one line per movement added or removed
+		for i := 1; i <= 3; i++ {
+			if err := storage.SaveTransactions(testTransactions()[:1+i%2]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(tempDir, journalFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tempDir, bytes.SplitAfter(data, []byte("\n"))
+	}
+	rewrite := func(t *testing.T, dir string, lines ...[]byte) {
+		if err := os.WriteFile(filepath.Join(dir, journalFileName), bytes.Join(lines, nil), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+	}{
+		{"reordered", func(lines [][]byte) [][]byte { return [][]byte{lines[1], lines[0], lines[2]} }},
+		{"duplicated", func(lines [][]byte) [][]byte { return [][]byte{lines[0], lines[0], lines[1], lines[2]} }},
+		{"removed", func(lines [][]byte) [][]byte { return [][]byte{lines[0], lines[2]} }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir, lines := journalDir(t)
+			rewrite(t, tempDir, test.tamper(lines)...)
+			storage, err := Open(BackendJournal, tempDir, passphrase("secret"))
+			if err != nil {
+				t.Fatalf("Failed to unlock: %v", err)
+			}
+			if transactions, err := storage.GetTransactions(); err == nil {
+				t.Errorf("Expected the tampered journal to be rejected, got %+v", transactions)
+			}
+			if err := DisableEncryption(tempDir, "secret"); err == nil {
+				t.Error("Expected decryption to refuse the tampered journal rather than drop lines")
+			}
+			if !IsEncrypted(tempDir) {
+				t.Error("Expected the directory to stay encrypted")
+			}
+		})
+	}
+
+	// A write torn by a crash at the end of the journal is dropped
+	tempDir, lines := journalDir(t)
+	rewrite(t, tempDir, lines[0], lines[1], lines[2][:10])
+	if err := DisableEncryption(tempDir, "secret"); err != nil {
+		t.Fatalf("Expected a torn last line to be dropped, got %v", err)
+	}
+	reopened, err := New(BackendJournal, tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transactions, err := reopened.GetTransactions(); err != nil || len(transactions) != 2 {
+		t.Errorf("Expected the 2 movements of the complete lines, got %d (%v)", len(transactions), err)
+	}
+}
+
+// assertNoPlaintext fails if text appears in any file of the data directory
+func assertNoPlaintext(t *testing.T, dataDir, text string) {
+	t.Helper()
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dataDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(text)) {
+			t.Errorf("Expected %s to be encrypted, found %q in it", entry.Name(), text)
+		}
+	}
+}
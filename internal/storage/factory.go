@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"comptes/internal/errors"
+	"fmt"
+	"strings"
+)
+
+// Storage backends selectable through configuration
+const (
+	BackendJSON    = "json"
+	BackendJournal = "journal"
+)
+
+// New creates the storage backend with the given name (JSON files by default), and
+// finishes any unit of work a crash left half-applied. An encrypted data directory
+// needs Open and its passphrase.
+func New(backend string, dataDir string) (Storage, error) {
+	return Open(backend, dataDir, nil)
+}
+
+// Open is New for a data directory that may be encrypted: passphrase is called, only
+// if it is, to unlock it. The backend then reads and writes its files encrypted.
+func Open(backend string, dataDir string, passphrase func() (string, error)) (Storage, error) {
+	var c codec = plainCodec{}
+	if IsEncrypted(dataDir) {
+		if passphrase == nil {
+			return nil, errors.PassphraseRequired()
+		}
+		secret, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if c, err = unlockDataDir(dataDir, secret); err != nil {
+			return nil, err
+		}
+	}
+
+	var storage Storage
+	switch strings.ToLower(backend) {
+	case "", BackendJSON:
+		json := NewJSONStorage(dataDir)
+		json.codec = c
+		storage = json
+	case BackendJournal:
+		// Uncommitted events are dropped when the journal is replayed
+		journal := NewJournalStorage(dataDir)
+		journal.codec = c
+		storage = journal
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (expected %q or %q)", backend, BackendJSON, BackendJournal)
+	}
+
+	// Commit intents are also left by encrypt and decrypt, whatever the backend
+	if _, err := recoverStorage(storage, dataDir); err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
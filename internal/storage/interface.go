@@ -6,14 +6,27 @@ import (
 
 // Storage defines the interface for data persistence
 type Storage interface {
+	// Locking: Lock takes an exclusive advisory lock on the data directory for a whole
+	// read-modify-write cycle (reentrant); it fails with a storage error when another
+	// process holds it. Unlock releases it.
+	Lock() error
+	Unlock()
+
+	// Atomically runs fn as a unit of work: the saves it makes are applied all together,
+	// or not at all if fn fails or the process crashes (repaired on next open)
+	Atomically(fn func() error) error
+
 	// Accounts
 	GetAccounts() ([]domain.Account, error)
 	SaveAccounts(accounts []domain.Account) error
-	GetAccountBalance(accountID string) (float64, error) // ← Nouveau !
+	GetAccountBalance(accountID string) (domain.Money, error)
 
 	// Transactions
 	GetTransactions() ([]domain.Transaction, error)
 	SaveTransactions(transactions []domain.Transaction) error
+	// QueryTransactions returns the movements matching query, letting backends filter
+	// with their own indexes instead of loading everything
+	QueryTransactions(query TransactionQuery) ([]domain.Transaction, error)
 
 	// Categories
 	GetCategories() ([]domain.Category, error)
@@ -23,6 +36,26 @@ type Storage interface {
 	GetTags() ([]domain.Tag, error)
 	SaveTags(tags []domain.Tag) error
 
+	// Exchange rates
+	GetExchangeRates() ([]domain.ExchangeRate, error)
+	SaveExchangeRates(rates []domain.ExchangeRate) error
+
+	// Budgets
+	GetBudgets() ([]domain.Budget, error)
+	SaveBudgets(budgets []domain.Budget) error
+
+	// Envelope assignments
+	GetEnvelopeAssignments() ([]domain.EnvelopeAssignment, error)
+	SaveEnvelopeAssignments(assignments []domain.EnvelopeAssignment) error
+
+	// Recurring rules
+	GetRecurringRules() ([]domain.RecurringRule, error)
+	SaveRecurringRules(rules []domain.RecurringRule) error
+
+	// Audit log of changes that rewrote stored data in place
+	GetAuditLog() ([]domain.AuditEntry, error)
+	SaveAuditLog(entries []domain.AuditEntry) error
+
 	// Transaction Batches
 	GetPendingBatches() ([]domain.TransactionBatch, error)
 	SavePendingBatches(batches []domain.TransactionBatch) error
@@ -0,0 +1,863 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"comptes/internal/domain"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	journalFileName    = "journal.jsonl"
+	checkpointFileName = "journal.checkpoint.json"
+
+	// DefaultCheckpointEvery is the number of journal events after which the state is checkpointed
+	DefaultCheckpointEvery = 500
+)
+
+// Journal collections
+const (
+	collectionAccounts          = "accounts"
+	collectionTransactions      = "transactions"
+	collectionCategories        = "categories"
+	collectionTags              = "tags"
+	collectionExchangeRates     = "exchange_rates"
+	collectionBudgets           = "budgets"
+	collectionEnvelopes         = "envelopes"
+	collectionRecurringRules    = "recurring"
+	collectionAuditLog          = "audit"
+	collectionBalanceSnapshots  = "balance_snapshots"
+	collectionPendingBatches    = "pending_batches"
+	collectionCommittedBatches  = "committed_batches"
+	collectionRolledBackBatches = "rolled_back_batches"
+)
+
+// Journal operations
+const (
+	journalOpAdd    = "add"
+	journalOpUpdate = "update"
+	journalOpRemove = "remove"
+	journalOpCommit = "commit" // Closes the events of a unit of work written by Atomically
+)
+
+// journalEvent is one line of the append-only journal. Adding a movement, editing or
+// deleting it (a soft delete is an update) and committing a batch (an add to
+// committed_batches) each append events instead of rewriting whole files.
+type journalEvent struct {
+	Seq        int64           `json:"seq"`
+	Time       time.Time       `json:"time"`
+	Op         string          `json:"op"`
+	Collection string          `json:"collection"`
+	Key        string          `json:"key"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Tx         int64           `json:"tx,omitempty"` // Unit of work the event belongs to, applied only once committed
+}
+
+// journalItem is an entry of a collection in a checkpoint
+type journalItem struct {
+	Key  string          `json:"key"`
+	Data json.RawMessage `json:"data"`
+}
+
+// journalCheckpoint is the full state up to (and including) event Seq
+type journalCheckpoint struct {
+	Seq         int64                    `json:"seq"`
+	Collections map[string][]journalItem `json:"collections"`
+}
+
+// journalCollection keeps the encoded items of a collection in order
+type journalCollection struct {
+	order []string
+	items map[string]json.RawMessage
+}
+
+func newJournalCollection() *journalCollection {
+	return &journalCollection{items: make(map[string]json.RawMessage)}
+}
+
+func (c *journalCollection) apply(event journalEvent) {
+	switch event.Op {
+	case journalOpAdd, journalOpUpdate:
+		if _, exists := c.items[event.Key]; !exists {
+			c.order = append(c.order, event.Key)
+		}
+		c.items[event.Key] = event.Data
+	case journalOpRemove:
+		if _, exists := c.items[event.Key]; !exists {
+			return
+		}
+		delete(c.items, event.Key)
+		for i, key := range c.order {
+			if key == event.Key {
+				c.order = append(c.order[:i], c.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// JournalStorage implements Storage interface with an append-only event journal.
+// The state is rebuilt in memory on open from the latest checkpoint plus the events
+// written after it; saves append only the differences with the current state.
+type JournalStorage struct {
+	dataDir         string
+	checkpointEvery int
+	lock            *dirLock
+	codec           codec // Encryption of the checkpoint and of each journal line
+
+	mu              sync.Mutex
+	loaded          bool
+	seq             int64
+	offset          int64     // Size of the journal file when it was last read or written
+	checkpointTime  time.Time // Modification time of the checkpoint that was loaded or written
+	sinceCheckpoint int
+	collections     map[string]*journalCollection
+	tx              *journalUnitOfWork // Events buffered by Atomically, nil outside a unit of work
+}
+
+// NewJournalStorage creates a new journal storage instance
+func NewJournalStorage(dataDir string) *JournalStorage {
+	return &JournalStorage{
+		dataDir:         dataDir,
+		checkpointEvery: DefaultCheckpointEvery,
+		lock:            &dirLock{dataDir: dataDir},
+		codec:           plainCodec{},
+	}
+}
+
+// Lock takes the advisory lock on the data directory
+func (s *JournalStorage) Lock() error {
+	return s.lock.Lock()
+}
+
+// Unlock releases the advisory lock on the data directory
+func (s *JournalStorage) Unlock() {
+	s.lock.Unlock()
+}
+
+// GetAccounts returns accounts from the journal
+func (s *JournalStorage) GetAccounts() ([]domain.Account, error) {
+	var accounts []domain.Account
+	if err := s.getCollection(collectionAccounts, &accounts); err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		accounts[i].InitialBalance = accounts[i].InitialBalance.WithCurrency(accounts[i].Currency)
+	}
+	return accounts, nil
+}
+
+// SaveAccounts records account changes in the journal
+func (s *JournalStorage) SaveAccounts(accounts []domain.Account) error {
+	if err := invalidateForAccounts(s, accounts); err != nil {
+		return err
+	}
+	return saveCollection(s, collectionAccounts, accounts, accountKey)
+}
+
+// GetAccountBalance calculates the current balance for an account from its latest balance snapshot
+func (s *JournalStorage) GetAccountBalance(accountID string) (domain.Money, error) {
+	return snapshotBalance(s, accountID)
+}
+
+// GetTransactions returns transactions (movements) from the journal
+func (s *JournalStorage) GetTransactions() ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	return transactions, s.getCollection(collectionTransactions, &transactions)
+}
+
+// QueryTransactions filters the in-memory state of the journal
+func (s *JournalStorage) QueryTransactions(query TransactionQuery) ([]domain.Transaction, error) {
+	transactions, err := s.GetTransactions()
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(transactions), nil
+}
+
+// SaveTransactions records added, edited and removed transactions in the journal
+func (s *JournalStorage) SaveTransactions(transactions []domain.Transaction) error {
+	if err := invalidateForTransactions(s, transactions); err != nil {
+		return err
+	}
+	return saveCollection(s, collectionTransactions, transactions, transactionKey)
+}
+
+// GetCategories returns categories from the journal
+func (s *JournalStorage) GetCategories() ([]domain.Category, error) {
+	var categories []domain.Category
+	return categories, s.getCollection(collectionCategories, &categories)
+}
+
+// SaveCategories records category changes in the journal
+func (s *JournalStorage) SaveCategories(categories []domain.Category) error {
+	return saveCollection(s, collectionCategories, categories, categoryKey)
+}
+
+// GetTags returns tags from the journal
+func (s *JournalStorage) GetTags() ([]domain.Tag, error) {
+	var tags []domain.Tag
+	return tags, s.getCollection(collectionTags, &tags)
+}
+
+// SaveTags records tag changes in the journal
+func (s *JournalStorage) SaveTags(tags []domain.Tag) error {
+	return saveCollection(s, collectionTags, tags, tagKey)
+}
+
+// GetExchangeRates returns exchange rates from the journal
+func (s *JournalStorage) GetExchangeRates() ([]domain.ExchangeRate, error) {
+	var rates []domain.ExchangeRate
+	return rates, s.getCollection(collectionExchangeRates, &rates)
+}
+
+// SaveExchangeRates records exchange-rate changes in the journal
+func (s *JournalStorage) SaveExchangeRates(rates []domain.ExchangeRate) error {
+	return saveCollection(s, collectionExchangeRates, rates, exchangeRateKey)
+}
+
+// GetBudgets returns budgets from the journal
+func (s *JournalStorage) GetBudgets() ([]domain.Budget, error) {
+	var budgets []domain.Budget
+	return budgets, s.getCollection(collectionBudgets, &budgets)
+}
+
+// SaveBudgets records budget changes in the journal
+func (s *JournalStorage) SaveBudgets(budgets []domain.Budget) error {
+	return saveCollection(s, collectionBudgets, budgets, budgetKey)
+}
+
+// GetEnvelopeAssignments returns envelope assignments from the journal
+func (s *JournalStorage) GetEnvelopeAssignments() ([]domain.EnvelopeAssignment, error) {
+	var assignments []domain.EnvelopeAssignment
+	return assignments, s.getCollection(collectionEnvelopes, &assignments)
+}
+
+// SaveEnvelopeAssignments records envelope assignment changes in the journal
+func (s *JournalStorage) SaveEnvelopeAssignments(assignments []domain.EnvelopeAssignment) error {
+	return saveCollection(s, collectionEnvelopes, assignments, envelopeKey)
+}
+
+// GetRecurringRules returns recurring rules from the journal
+func (s *JournalStorage) GetRecurringRules() ([]domain.RecurringRule, error) {
+	var rules []domain.RecurringRule
+	return rules, s.getCollection(collectionRecurringRules, &rules)
+}
+
+// SaveRecurringRules records recurring rule changes in the journal
+func (s *JournalStorage) SaveRecurringRules(rules []domain.RecurringRule) error {
+	return saveCollection(s, collectionRecurringRules, rules, recurringRuleKey)
+}
+
+// GetAuditLog returns the audit log from the journal
+func (s *JournalStorage) GetAuditLog() ([]domain.AuditEntry, error) {
+	var entries []domain.AuditEntry
+	return entries, s.getCollection(collectionAuditLog, &entries)
+}
+
+// SaveAuditLog records audit log changes in the journal
+func (s *JournalStorage) SaveAuditLog(entries []domain.AuditEntry) error {
+	return saveCollection(s, collectionAuditLog, entries, auditKey)
+}
+
+// GetBalanceSnapshots returns balance snapshots from the journal
+func (s *JournalStorage) GetBalanceSnapshots() ([]domain.BalanceSnapshot, error) {
+	var snapshots []domain.BalanceSnapshot
+	return snapshots, s.getCollection(collectionBalanceSnapshots, &snapshots)
+}
+
+// SaveBalanceSnapshots records balance snapshot changes in the journal
+func (s *JournalStorage) SaveBalanceSnapshots(snapshots []domain.BalanceSnapshot) error {
+	return saveCollection(s, collectionBalanceSnapshots, snapshots, snapshotKey)
+}
+
+// GetPendingBatches returns pending transaction batches from the journal
+func (s *JournalStorage) GetPendingBatches() ([]domain.TransactionBatch, error) {
+	var batches []domain.TransactionBatch
+	return batches, s.getCollection(collectionPendingBatches, &batches)
+}
+
+// SavePendingBatches records pending batch changes in the journal
+func (s *JournalStorage) SavePendingBatches(batches []domain.TransactionBatch) error {
+	return saveCollection(s, collectionPendingBatches, batches, batchKey)
+}
+
+// GetCommittedBatches returns committed transaction batches from the journal
+func (s *JournalStorage) GetCommittedBatches() ([]domain.TransactionBatch, error) {
+	var batches []domain.TransactionBatch
+	return batches, s.getCollection(collectionCommittedBatches, &batches)
+}
+
+// SaveCommittedBatches records batch commits in the journal
+func (s *JournalStorage) SaveCommittedBatches(batches []domain.TransactionBatch) error {
+	return saveCollection(s, collectionCommittedBatches, batches, batchKey)
+}
+
+// GetRolledBackBatches returns rolled back transaction batches from the journal
+func (s *JournalStorage) GetRolledBackBatches() ([]domain.TransactionBatch, error) {
+	var batches []domain.TransactionBatch
+	return batches, s.getCollection(collectionRolledBackBatches, &batches)
+}
+
+// SaveRolledBackBatches records batch rollbacks in the journal
+func (s *JournalStorage) SaveRolledBackBatches(batches []domain.TransactionBatch) error {
+	return saveCollection(s, collectionRolledBackBatches, batches, batchKey)
+}
+
+// Checkpoint writes the current state to the checkpoint file and empties the journal
+func (s *JournalStorage) Checkpoint() error {
+	if err := s.lock.Lock(); err != nil {
+		return err
+	}
+	defer s.lock.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.openLocked(); err != nil {
+		return err
+	}
+	return s.checkpoint()
+}
+
+// Keys identifying the items of each collection in journal events
+
+func accountKey(a domain.Account) string             { return a.ID }
+func transactionKey(t domain.Transaction) string     { return t.ID }
+func categoryKey(c domain.Category) string           { return c.Code }
+func tagKey(t domain.Tag) string                     { return t.Code }
+func batchKey(b domain.TransactionBatch) string      { return b.ID }
+func auditKey(e domain.AuditEntry) string            { return e.ID }
+func budgetKey(b domain.Budget) string               { return b.Key() }
+func envelopeKey(a domain.EnvelopeAssignment) string { return a.ID }
+func recurringRuleKey(r domain.RecurringRule) string { return r.ID }
+func snapshotKey(b domain.BalanceSnapshot) string {
+	return b.AccountID + "/" + b.Date.Format("2006-01-02")
+}
+func exchangeRateKey(r domain.ExchangeRate) string {
+	return r.Date.Format("2006-01-02") + "/" + r.From + "/" + r.To
+}
+
+// getCollection decodes a collection into v (a pointer to a slice)
+func (s *JournalStorage) getCollection(name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	collection := s.collection(name)
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, key := range collection.order {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(collection.items[key])
+	}
+	buf.WriteByte(']')
+
+	if err := json.Unmarshal(buf.Bytes(), v); err != nil {
+		return fmt.Errorf("failed to decode %s from journal: %w", name, err)
+	}
+	return nil
+}
+
+// saveCollection appends the events turning the stored collection into items
+func saveCollection[T any](s *JournalStorage, name string, items []T, key func(T) string) error {
+	// The diff must be computed against the latest state written by any process
+	if err := s.lock.Lock(); err != nil {
+		return err
+	}
+	defer s.lock.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.openLocked(); err != nil {
+		return err
+	}
+
+	collection := s.collection(name)
+	var events []journalEvent
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		k := uniqueKey(key(item), seen)
+		seen[k] = true
+
+		existing, exists := collection.items[k]
+		switch {
+		case !exists:
+			events = append(events, journalEvent{Op: journalOpAdd, Collection: name, Key: k, Data: data})
+		case !bytes.Equal(existing, data):
+			events = append(events, journalEvent{Op: journalOpUpdate, Collection: name, Key: k, Data: data})
+		}
+	}
+	for _, k := range collection.order {
+		if !seen[k] {
+			events = append(events, journalEvent{Op: journalOpRemove, Collection: name, Key: k})
+		}
+	}
+
+	if s.tx != nil {
+		return s.tx.add(s, events)
+	}
+	return s.append(events)
+}
+
+// uniqueKey disambiguates items sharing the same key so that none is lost
+func uniqueKey(key string, seen map[string]bool) string {
+	if !seen[key] {
+		return key
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s#%d", key, n)
+		if !seen[candidate] {
+			return candidate
+		}
+	}
+}
+
+func (s *JournalStorage) collection(name string) *journalCollection {
+	collection, exists := s.collections[name]
+	if !exists {
+		collection = newJournalCollection()
+		s.collections[name] = collection
+	}
+	return collection
+}
+
+// append writes events to the journal, synced to disk, then applies them in memory
+func (s *JournalStorage) append(events []journalEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := s.encode(events, &buf); err != nil {
+		return err
+	}
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		s.collection(event.Collection).apply(event)
+	}
+	s.seq += int64(len(events))
+	s.offset += int64(buf.Len())
+	s.sinceCheckpoint += len(events)
+
+	if s.checkpointEvery > 0 && s.sinceCheckpoint >= s.checkpointEvery {
+		return s.checkpoint()
+	}
+	return nil
+}
+
+// encode numbers events after the current sequence and writes them as journal lines.
+// buf holds the lines to append at the end of the journal (at s.offset).
+func (s *JournalStorage) encode(events []journalEvent, buf *bytes.Buffer) error {
+	now := time.Now()
+	for i := range events {
+		events[i].Seq = s.seq + int64(i) + 1
+		events[i].Time = now
+		line, err := json.Marshal(events[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal journal event: %w", err)
+		}
+		if line, err = encodeLine(s.codec, line, s.offset+int64(buf.Len())); err != nil {
+			return fmt.Errorf("failed to encrypt journal event: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// write appends encoded lines to the journal file and syncs it to disk
+func (s *JournalStorage) write(lines []byte) error {
+	file, err := os.OpenFile(s.path(journalFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", journalFileName, err)
+	}
+	if _, err := file.Write(lines); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", journalFileName, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", journalFileName, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", journalFileName, err)
+	}
+	return nil
+}
+
+// open loads the state on first use, and reloads it when another process changed the journal
+func (s *JournalStorage) open() error {
+	if s.loaded && s.upToDate() {
+		return nil
+	}
+
+	empty, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	// First use on a data directory written by the JSON backend: import its files. The
+	// import writes the checkpoint, so it runs under the directory lock, and only if no
+	// other process imported them in the meantime.
+	if empty && hasJSONFiles(s.dataDir) {
+		if err := s.lock.Lock(); err != nil {
+			return err
+		}
+		defer s.lock.Unlock()
+		if empty, err = s.load(); err != nil {
+			return err
+		}
+		if empty {
+			if err := s.importJSONFiles(); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.loaded = true
+	return nil
+}
+
+// load rebuilds the state from the checkpoint and the journal, and reports whether
+// there is neither
+func (s *JournalStorage) load() (bool, error) {
+	s.loaded = false
+	s.seq = 0
+	s.offset = 0
+	s.sinceCheckpoint = 0
+	s.checkpointTime = time.Time{}
+	s.collections = make(map[string]*journalCollection)
+
+	hasCheckpoint, err := s.loadCheckpoint()
+	if err != nil {
+		return false, err
+	}
+	hasJournal, err := s.replay()
+	if err != nil {
+		return false, err
+	}
+	return !hasCheckpoint && !hasJournal, nil
+}
+
+// hasJSONFiles reports whether dataDir holds data files of the JSON backend
+func hasJSONFiles(dataDir string) bool {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && isDataFile(name) && name != journalFileName && name != checkpointFileName {
+			return true
+		}
+	}
+	return false
+}
+
+// openLocked opens the state, then drops what a crash left unfinished at the end of the
+// journal. The caller holds the directory lock, so no other process is appending: the
+// bytes after the last committed event are a torn line or a unit of work whose commit
+// marker will never be written.
+func (s *JournalStorage) openLocked() error {
+	if err := s.open(); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.path(journalFileName))
+	if err != nil || info.Size() <= s.offset {
+		return nil
+	}
+	if err := os.Truncate(s.path(journalFileName), s.offset); err != nil {
+		return fmt.Errorf("failed to repair %s: %w", journalFileName, err)
+	}
+	return nil
+}
+
+// upToDate reports whether neither the journal nor the checkpoint changed since they were read
+func (s *JournalStorage) upToDate() bool {
+	var checkpointTime time.Time
+	if info, err := os.Stat(s.path(checkpointFileName)); err == nil {
+		checkpointTime = info.ModTime()
+	}
+	if !checkpointTime.Equal(s.checkpointTime) {
+		return false
+	}
+
+	info, err := os.Stat(s.path(journalFileName))
+	if os.IsNotExist(err) {
+		return s.offset == 0
+	}
+	return err == nil && info.Size() == s.offset
+}
+
+func (s *JournalStorage) loadCheckpoint() (bool, error) {
+	data, err := os.ReadFile(s.path(checkpointFileName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", checkpointFileName, err)
+	}
+	if data, err = s.codec.open(checkpointFileName, data); err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", checkpointFileName, err)
+	}
+
+	var checkpoint journalCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", checkpointFileName, err)
+	}
+	for name, items := range checkpoint.Collections {
+		collection := s.collection(name)
+		for _, item := range items {
+			collection.apply(journalEvent{Op: journalOpAdd, Key: item.Key, Data: item.Data})
+		}
+	}
+	s.seq = checkpoint.Seq
+	if info, err := os.Stat(s.path(checkpointFileName)); err == nil {
+		s.checkpointTime = info.ModTime()
+	}
+	return true, nil
+}
+
+// replay applies the journal events written after the checkpoint. A last line cut
+// short, and the events of a unit of work without its commit marker, are ignored: they
+// may be an append of another process still in progress. Only openLocked removes them.
+// Any other unreadable line is an error.
+func (s *JournalStorage) replay() (bool, error) {
+	file, err := os.Open(s.path(journalFileName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", journalFileName, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	lineNumber := 0
+
+	// Events of the unit of work being read, and where it starts in the file
+	var group []journalEvent
+	var groupOffset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return false, fmt.Errorf("failed to read %s: %w", journalFileName, readErr)
+		}
+		if len(line) == 0 {
+			break
+		}
+		lineNumber++
+
+		var event journalEvent
+		if len(bytes.TrimSpace(line)) > 0 {
+			decoded, err := decodeLine(s.codec, bytes.TrimSuffix(line, []byte("\n")), offset)
+			if err == nil {
+				err = json.Unmarshal(decoded, &event)
+			}
+			if err != nil || line[len(line)-1] != '\n' {
+				if readErr == io.EOF {
+					// Torn or unfinished write at the end of the journal: ignore it
+					break
+				}
+				return false, fmt.Errorf("failed to parse %s line %d: %v", journalFileName, lineNumber, err)
+			}
+
+			switch {
+			case event.Op == journalOpCommit:
+				for _, grouped := range group {
+					s.replayEvent(grouped)
+				}
+				s.replayEvent(event)
+				group = nil
+			case event.Tx != 0:
+				if group == nil {
+					groupOffset = offset
+				}
+				group = append(group, event)
+			default:
+				s.replayEvent(event)
+			}
+		}
+
+		offset += int64(len(line))
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	// The state covers the journal up to the last committed event
+	s.offset = offset
+	if group != nil {
+		s.offset = groupOffset
+	}
+	return true, nil
+}
+
+// replayEvent applies an event read from the journal unless the checkpoint already covers it
+func (s *JournalStorage) replayEvent(event journalEvent) {
+	if event.Seq <= s.seq {
+		return
+	}
+	if event.Op != journalOpCommit {
+		s.collection(event.Collection).apply(event)
+	}
+	s.seq = event.Seq
+	s.sinceCheckpoint++
+}
+
+// checkpoint writes the full state atomically, then empties the journal. Events already
+// covered by the checkpoint are skipped on replay if the journal could not be emptied.
+func (s *JournalStorage) checkpoint() error {
+	checkpoint := journalCheckpoint{Seq: s.seq, Collections: make(map[string][]journalItem)}
+	for name, collection := range s.collections {
+		items := make([]journalItem, 0, len(collection.order))
+		for _, key := range collection.order {
+			items = append(items, journalItem{Key: key, Data: collection.items[key]})
+		}
+		checkpoint.Collections[name] = items
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if data, err = s.codec.seal(checkpointFileName, data); err != nil {
+		return fmt.Errorf("failed to encrypt checkpoint: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path(checkpointFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := os.Truncate(s.path(journalFileName), 0); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to truncate %s: %w", journalFileName, err)
+	}
+	s.offset = 0
+	s.sinceCheckpoint = 0
+	if info, err := os.Stat(s.path(checkpointFileName)); err == nil {
+		s.checkpointTime = info.ModTime()
+	}
+	return nil
+}
+
+// importJSONFiles seeds an empty journal from the files of the JSON backend, if any.
+// The caller holds the directory lock.
+func (s *JournalStorage) importJSONFiles() error {
+	source := NewJSONStorage(s.dataDir)
+	source.codec = s.codec
+
+	accounts, err := source.GetAccounts()
+	if err != nil {
+		return err
+	}
+	transactions, err := source.GetTransactions()
+	if err != nil {
+		return err
+	}
+	categories, err := source.GetCategories()
+	if err != nil {
+		return err
+	}
+	tags, err := source.GetTags()
+	if err != nil {
+		return err
+	}
+	rates, err := source.GetExchangeRates()
+	if err != nil {
+		return err
+	}
+	budgets, err := source.GetBudgets()
+	if err != nil {
+		return err
+	}
+	envelopes, err := source.GetEnvelopeAssignments()
+	if err != nil {
+		return err
+	}
+	rules, err := source.GetRecurringRules()
+	if err != nil {
+		return err
+	}
+	audit, err := source.GetAuditLog()
+	if err != nil {
+		return err
+	}
+	pending, err := source.GetPendingBatches()
+	if err != nil {
+		return err
+	}
+	committed, err := source.GetCommittedBatches()
+	if err != nil {
+		return err
+	}
+	rolledBack, err := source.GetRolledBackBatches()
+	if err != nil {
+		return err
+	}
+
+	if len(accounts)+len(transactions)+len(categories)+len(tags)+len(rates)+len(budgets)+len(envelopes)+len(rules)+len(audit)+len(pending)+len(committed)+len(rolledBack) == 0 {
+		return nil
+	}
+
+	imports := []error{
+		appendAll(s, collectionAccounts, accounts, accountKey),
+		appendAll(s, collectionTransactions, transactions, transactionKey),
+		appendAll(s, collectionCategories, categories, categoryKey),
+		appendAll(s, collectionTags, tags, tagKey),
+		appendAll(s, collectionExchangeRates, rates, exchangeRateKey),
+		appendAll(s, collectionBudgets, budgets, budgetKey),
+		appendAll(s, collectionEnvelopes, envelopes, envelopeKey),
+		appendAll(s, collectionRecurringRules, rules, recurringRuleKey),
+		appendAll(s, collectionAuditLog, audit, auditKey),
+		appendAll(s, collectionPendingBatches, pending, batchKey),
+		appendAll(s, collectionCommittedBatches, committed, batchKey),
+		appendAll(s, collectionRolledBackBatches, rolledBack, batchKey),
+	}
+	for _, err := range imports {
+		if err != nil {
+			return err
+		}
+	}
+	return s.checkpoint()
+}
+
+// appendAll adds items to an empty in-memory collection without writing journal events
+func appendAll[T any](s *JournalStorage, name string, items []T, key func(T) string) error {
+	collection := s.collection(name)
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		k := uniqueKey(key(item), seen)
+		seen[k] = true
+		collection.apply(journalEvent{Op: journalOpAdd, Key: k, Data: data})
+	}
+	return nil
+}
+
+func (s *JournalStorage) path(name string) string {
+	return filepath.Join(s.dataDir, name)
+}
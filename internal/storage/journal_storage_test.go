@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"bytes"
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func journalLines(t *testing.T, dir string) int {
+	data, err := os.ReadFile(filepath.Join(dir, journalFileName))
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func testTransactions() []domain.Transaction {
+	return []domain.Transaction{
+		{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-5000, "EUR"), Description: "Courses", IsActive: true, CreatedAt: time.Now()},
+		{ID: "txn2", Account: "BANQUE", Amount: domain.NewMoney(10000, "EUR"), Description: "Salaire", IsActive: true, CreatedAt: time.Now()},
+	}
+}
+
+func TestJournalStorage_SaveAndReplay(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJournalStorage(tempDir)
+
+	accounts := []domain.Account{{ID: "BANQUE", Currency: "EUR", InitialBalance: domain.NewMoney(100000, "EUR"), IsActive: true}}
+	if err := storage.SaveAccounts(accounts); err != nil {
+		t.Fatalf("Failed to save accounts: %v", err)
+	}
+	transactions := testTransactions()
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	// Soft delete one movement: only an update event is appended
+	before := journalLines(t, tempDir)
+	transactions[0].IsActive = false
+	transactions[0].EditComment = "Erreur"
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	if after := journalLines(t, tempDir); after != before+1 {
+		t.Errorf("Expected 1 event appended for an edit, got %d", after-before)
+	}
+
+	// A new instance replays the journal
+	reopened := NewJournalStorage(tempDir)
+	loaded, err := reopened.GetTransactions()
+	if err != nil {
+		t.Fatalf("Failed to load transactions: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].ID != "txn1" || loaded[0].IsActive || loaded[0].EditComment != "Erreur" {
+		t.Errorf("Unexpected replayed transactions: %+v", loaded)
+	}
+
+	balance, err := reopened.GetAccountBalance("BANQUE")
+	if err != nil {
+		t.Fatalf("Failed to compute balance: %v", err)
+	}
+	if balance != domain.NewMoney(110000, "EUR") {
+		t.Errorf("Expected balance 1100.00 EUR, got %s", balance)
+	}
+
+	// Hard delete appends a remove event
+	if err := reopened.SaveTransactions(loaded[1:]); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	loaded, _ = NewJournalStorage(tempDir).GetTransactions()
+	if len(loaded) != 1 || loaded[0].ID != "txn2" {
+		t.Errorf("Expected only txn2 after removal, got %+v", loaded)
+	}
+}
+
+func TestJournalStorage_ReturnedSlicesAreCopies(t *testing.T) {
+	storage := NewJournalStorage(t.TempDir())
+	if err := storage.SaveTransactions(testTransactions()); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	loaded, _ := storage.GetTransactions()
+	loaded[0].Description = "Modifié sans sauvegarde"
+
+	again, _ := storage.GetTransactions()
+	if again[0].Description != "Courses" {
+		t.Errorf("Expected stored state to be unchanged, got %q", again[0].Description)
+	}
+}
+
+func TestJournalStorage_Checkpoint(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJournalStorage(tempDir)
+	storage.checkpointEvery = 3
+
+	transactions := testTransactions()
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	transactions = append(transactions, domain.Transaction{ID: "txn3", Account: "BANQUE", Amount: domain.NewMoney(-100, "EUR"), IsActive: true})
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	// Third event triggers a checkpoint and empties the journal
+	if _, err := os.Stat(filepath.Join(tempDir, checkpointFileName)); err != nil {
+		t.Fatalf("Expected checkpoint file, got %v", err)
+	}
+	if lines := journalLines(t, tempDir); lines != 0 {
+		t.Errorf("Expected empty journal after checkpoint, got %d lines", lines)
+	}
+
+	transactions[2].Description = "Après checkpoint"
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	loaded, err := NewJournalStorage(tempDir).GetTransactions()
+	if err != nil {
+		t.Fatalf("Failed to load transactions: %v", err)
+	}
+	if len(loaded) != 3 || loaded[2].Description != "Après checkpoint" {
+		t.Errorf("Unexpected state after checkpoint and replay: %+v", loaded)
+	}
+}
+
+func TestJournalStorage_DropsTornLastLine(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJournalStorage(tempDir)
+	if err := storage.SaveTransactions(testTransactions()); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	// Simulate a crash in the middle of an append
+	file, err := os.OpenFile(filepath.Join(tempDir, journalFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	file.WriteString(`{"seq":3,"op":"add","collection":"transactions","key":"txn3","data":{"id":`)
+	file.Close()
+
+	reopened := NewJournalStorage(tempDir)
+	loaded, err := reopened.GetTransactions()
+	if err != nil {
+		t.Fatalf("Expected torn line to be dropped, got %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("Expected 2 transactions, got %d", len(loaded))
+	}
+
+	// The journal is usable again after the repair
+	if err := reopened.SaveTransactions(append(loaded, domain.Transaction{ID: "txn3", Account: "BANQUE", IsActive: true})); err != nil {
+		t.Fatalf("Failed to save after repair: %v", err)
+	}
+	loaded, err = NewJournalStorage(tempDir).GetTransactions()
+	if err != nil || len(loaded) != 3 {
+		t.Errorf("Expected 3 transactions after repair, got %d (%v)", len(loaded), err)
+	}
+}
+
+func TestJournalStorage_ImportsJSONFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	jsonStorage := NewJSONStorage(tempDir)
+	if err := jsonStorage.SaveAccounts([]domain.Account{{ID: "BANQUE", Currency: "EUR", IsActive: true}}); err != nil {
+		t.Fatalf("Failed to save accounts: %v", err)
+	}
+	if err := jsonStorage.SaveTransactions(testTransactions()); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	// The import writes the checkpoint: it waits for another process holding the lock
+	previous := lockTimeout
+	lockTimeout = 100 * time.Millisecond
+	defer func() { lockTimeout = previous }()
+	other := NewJSONStorage(tempDir)
+	if err := other.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	storage := NewJournalStorage(tempDir)
+	if _, err := storage.GetTransactions(); !hasErrorCode(err, errors.CodeStorageLocked) {
+		t.Errorf("Expected storage_locked while another process holds the lock, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, checkpointFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected no checkpoint written without the lock, got %v", err)
+	}
+	other.Unlock()
+
+	transactions, err := storage.GetTransactions()
+	if err != nil {
+		t.Fatalf("Failed to load transactions: %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Errorf("Expected 2 imported transactions, got %d", len(transactions))
+	}
+	accounts, _ := storage.GetAccounts()
+	if len(accounts) != 1 || accounts[0].ID != "BANQUE" {
+		t.Errorf("Expected imported account BANQUE, got %+v", accounts)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, checkpointFileName)); err != nil {
+		t.Errorf("Expected import to write a checkpoint, got %v", err)
+	}
+}
+
+func TestNew_UnknownBackend(t *testing.T) {
+	if _, err := New("sqlite", t.TempDir()); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+	if storage, err := New(BackendJournal, t.TempDir()); err != nil {
+		t.Errorf("Expected journal backend, got %v", err)
+	} else if _, ok := storage.(*JournalStorage); !ok {
+		t.Errorf("Expected *JournalStorage, got %T", storage)
+	}
+}
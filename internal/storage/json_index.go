@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// transactionIndex keeps movements.json in memory with indexes on the fields queries
+// filter on. Positions in each index are ascending so that results keep the file order.
+// It is rebuilt when the file changes on disk (size or modification time).
+type transactionIndex struct {
+	modTime time.Time
+	size    int64
+
+	transactions []domain.Transaction
+	byAccount    map[string][]int
+	byCategory   map[string][]int
+	byTag        map[string][]int
+	byDate       []int // Positions sorted by calendar day
+	byID         []int // Positions sorted by ID, for prefix lookups
+}
+
+func newTransactionIndex(transactions []domain.Transaction, info os.FileInfo) *transactionIndex {
+	index := &transactionIndex{
+		modTime:      info.ModTime(),
+		size:         info.Size(),
+		transactions: transactions,
+		byAccount:    make(map[string][]int),
+		byCategory:   make(map[string][]int),
+		byTag:        make(map[string][]int),
+		byDate:       make([]int, len(transactions)),
+		byID:         make([]int, len(transactions)),
+	}
+
+	for i, t := range transactions {
+		index.byAccount[t.Account] = append(index.byAccount[t.Account], i)
+		addPosition(index.byCategory, transactionCategories(t), i)
+		addPosition(index.byTag, transactionTags(t), i)
+		index.byDate[i] = i
+		index.byID[i] = i
+	}
+	sort.SliceStable(index.byDate, func(a, b int) bool {
+		return Day(transactions[index.byDate[a]].Date).Before(Day(transactions[index.byDate[b]].Date))
+	})
+	sort.SliceStable(index.byID, func(a, b int) bool {
+		return transactions[index.byID[a]].ID < transactions[index.byID[b]].ID
+	})
+	return index
+}
+
+// addPosition records position i under each distinct code
+func addPosition(index map[string][]int, codes []string, i int) {
+	for _, code := range codes {
+		positions := index[code]
+		if len(positions) == 0 || positions[len(positions)-1] != i {
+			index[code] = append(positions, i)
+		}
+	}
+}
+
+// query narrows the candidates with the most selective index, then checks every filter
+func (index *transactionIndex) query(q TransactionQuery) []domain.Transaction {
+	candidates := index.candidates(q)
+
+	var result []domain.Transaction
+	skipped := 0
+	visit := func(t domain.Transaction) bool {
+		if !q.Matches(t) {
+			return true
+		}
+		if skipped < q.Offset {
+			skipped++
+			return true
+		}
+		result = append(result, cloneTransaction(t))
+		return q.Limit <= 0 || len(result) < q.Limit
+	}
+
+	if candidates == nil {
+		for _, t := range index.transactions {
+			if !visit(t) {
+				break
+			}
+		}
+		return result
+	}
+	for _, i := range candidates {
+		if !visit(index.transactions[i]) {
+			break
+		}
+	}
+	return result
+}
+
+// candidates returns the smallest set of positions (ascending) an index can give for the
+// query, or nil when no filter is indexed
+func (index *transactionIndex) candidates(q TransactionQuery) []int {
+	var best []int
+	consider := func(positions []int) {
+		if best == nil || len(positions) < len(best) {
+			best = positions
+		}
+	}
+
+	if q.Account != "" {
+		consider(nonNil(index.byAccount[q.Account]))
+	}
+	if len(q.Categories) > 0 {
+		consider(union(index.byCategory, q.Categories))
+	}
+	if len(q.Tags) > 0 {
+		consider(union(index.byTag, q.Tags))
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		start := 0
+		if !q.From.IsZero() {
+			start = sort.Search(len(index.byDate), func(i int) bool {
+				return !Day(index.transactions[index.byDate[i]].Date).Before(Day(q.From))
+			})
+		}
+		end := len(index.byDate)
+		if !q.To.IsZero() {
+			end = sort.Search(len(index.byDate), func(i int) bool {
+				return !Day(index.transactions[index.byDate[i]].Date).Before(Day(q.To))
+			})
+		}
+		if end < start {
+			end = start
+		}
+		consider(sortedPositions(index.byDate[start:end]))
+	}
+	if q.IDPrefix != "" {
+		start := sort.Search(len(index.byID), func(i int) bool {
+			return index.transactions[index.byID[i]].ID >= q.IDPrefix
+		})
+		end := start
+		for end < len(index.byID) && strings.HasPrefix(index.transactions[index.byID[end]].ID, q.IDPrefix) {
+			end++
+		}
+		consider(sortedPositions(index.byID[start:end]))
+	}
+	return best
+}
+
+// union merges the positions of several codes, ascending and without duplicates
+func union(index map[string][]int, codes []string) []int {
+	seen := make(map[int]bool)
+	var positions []int
+	for _, code := range codes {
+		for _, i := range index[code] {
+			if !seen[i] {
+				seen[i] = true
+				positions = append(positions, i)
+			}
+		}
+	}
+	sort.Ints(positions)
+	return nonNil(positions)
+}
+
+func sortedPositions(positions []int) []int {
+	sorted := append([]int{}, positions...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+// nonNil distinguishes "no match" (empty) from "not indexed" (nil)
+func nonNil(positions []int) []int {
+	if positions == nil {
+		return []int{}
+	}
+	return positions
+}
+
+// cloneTransaction copies the slices of a transaction so callers cannot alter the index
+func cloneTransaction(t domain.Transaction) domain.Transaction {
+	t.Categories = append([]string(nil), t.Categories...)
+	t.Tags = append([]string(nil), t.Tags...)
+	if t.Splits != nil {
+		splits := make([]domain.Split, len(t.Splits))
+		for i, split := range t.Splits {
+			split.Categories = append([]string(nil), split.Categories...)
+			split.Tags = append([]string(nil), split.Tags...)
+			splits[i] = split
+		}
+		t.Splits = splits
+	}
+	return t
+}
+
+// QueryTransactions returns the movements selected by query, using in-memory indexes
+// kept as long as movements.json is unchanged
+func (s *JSONStorage) QueryTransactions(query TransactionQuery) ([]domain.Transaction, error) {
+	// Within a unit of work or before the movements.json migration, scan instead
+	info, err := os.Stat(filepath.Join(s.dataDir, "movements.json"))
+	if _, buffered := s.tx.get("movements.json"); buffered || err != nil {
+		transactions, err := s.GetTransactions()
+		if err != nil {
+			return nil, err
+		}
+		return query.Apply(transactions), nil
+	}
+
+	if s.index == nil || !s.index.modTime.Equal(info.ModTime()) || s.index.size != info.Size() {
+		transactions, err := s.GetTransactions()
+		if err != nil {
+			return nil, err
+		}
+		s.index = newTransactionIndex(transactions, info)
+	}
+	return s.index.query(query), nil
+}
@@ -11,97 +11,69 @@ import (
 // JSONStorage implements Storage interface using JSON files
 type JSONStorage struct {
 	dataDir string
+	lock    *dirLock
+	codec   codec             // Encryption of the files, plainCodec unless the directory is encrypted
+	tx      *jsonUnitOfWork   // Writes buffered (unencrypted) by Atomically, nil outside a unit of work
+	index   *transactionIndex // Movements indexed by QueryTransactions
 }
 
 // NewJSONStorage creates a new JSON storage instance
 func NewJSONStorage(dataDir string) *JSONStorage {
 	return &JSONStorage{
 		dataDir: dataDir,
+		lock:    &dirLock{dataDir: dataDir},
+		codec:   plainCodec{},
 	}
 }
 
+// Lock takes the advisory lock on the data directory
+func (s *JSONStorage) Lock() error {
+	return s.lock.Lock()
+}
+
+// Unlock releases the advisory lock on the data directory
+func (s *JSONStorage) Unlock() {
+	s.lock.Unlock()
+}
+
 // GetAccounts reads accounts from JSON file
 func (s *JSONStorage) GetAccounts() ([]domain.Account, error) {
 	var accounts []domain.Account
-	return accounts, s.readJSONFile("accounts.json", &accounts)
+	if err := s.readJSONFile("accounts.json", &accounts); err != nil {
+		return accounts, err
+	}
+	// Legacy files store initial balances as bare numbers: give them the account currency
+	for i := range accounts {
+		accounts[i].InitialBalance = accounts[i].InitialBalance.WithCurrency(accounts[i].Currency)
+	}
+	return accounts, nil
 }
 
 // SaveAccounts saves accounts to JSON file
 func (s *JSONStorage) SaveAccounts(accounts []domain.Account) error {
+	if err := invalidateForAccounts(s, accounts); err != nil {
+		return err
+	}
 	return s.writeJSONFile("accounts.json", accounts)
 }
 
-// GetAccountBalance calculates the current balance for an account
-func (s *JSONStorage) GetAccountBalance(accountID string) (float64, error) {
-	// Get accounts to find initial balance
-	accounts, err := s.GetAccounts()
-	if err != nil {
-		return 0, err
-	}
-
-	// Find the account
-	var initialBalance float64
-	var accountFound bool
-	for _, acc := range accounts {
-		if acc.ID == accountID {
-			initialBalance = acc.InitialBalance
-			accountFound = true
-			break
-		}
-	}
-
-	if !accountFound {
-		return 0, fmt.Errorf("account not found: %s", accountID)
-	}
-
-	// Get transactions
-	transactions, err := s.GetTransactions()
-	if err != nil {
-		return 0, err
-	}
-
-	// Calculate balance
-	balance := initialBalance
-	for _, txn := range transactions {
-		if txn.Account == accountID && txn.IsActive {
-			balance += txn.Amount
-		}
-	}
-
-	return balance, nil
+// GetAccountBalance calculates the current balance for an account from its latest balance snapshot
+func (s *JSONStorage) GetAccountBalance(accountID string) (domain.Money, error) {
+	return snapshotBalance(s, accountID)
 }
 
 // GetTransactions reads transactions (movements) from JSON file
 func (s *JSONStorage) GetTransactions() ([]domain.Transaction, error) {
 	var transactions []domain.Transaction
-	// Try movements.json first (new format), fallback to transactions.json for migration
-	movementsPath := filepath.Join(s.dataDir, "movements.json")
-	transactionsPath := filepath.Join(s.dataDir, "transactions.json")
-
-	// Check if movements.json exists
-	if _, err := os.Stat(movementsPath); err == nil {
-		return transactions, s.readJSONFile("movements.json", &transactions)
-	}
-
-	// Check if old transactions.json exists (migration)
-	if _, err := os.Stat(transactionsPath); err == nil {
-		err := s.readJSONFile("transactions.json", &transactions)
-		if err != nil {
-			return transactions, err
-		}
-		// Migrate: rename file to movements.json
-		if err := os.Rename(transactionsPath, movementsPath); err != nil {
-			return transactions, fmt.Errorf("failed to migrate transactions.json to movements.json: %w", err)
-		}
-		return transactions, nil
-	}
-
-	// Neither file exists, return empty
-	return transactions, nil
+	return transactions, s.readJSONFile("movements.json", &transactions)
 }
 
 // SaveTransactions saves transactions (movements) to JSON file
 func (s *JSONStorage) SaveTransactions(transactions []domain.Transaction) error {
+	if err := invalidateForTransactions(s, transactions); err != nil {
+		return err
+	}
+	s.index = nil
 	return s.writeJSONFile("movements.json", transactions)
 }
 
@@ -127,6 +99,72 @@ func (s *JSONStorage) SaveTags(tags []domain.Tag) error {
 	return s.writeJSONFile("tags.json", tags)
 }
 
+// GetExchangeRates reads exchange rates from JSON file
+func (s *JSONStorage) GetExchangeRates() ([]domain.ExchangeRate, error) {
+	var rates []domain.ExchangeRate
+	return rates, s.readJSONFile("exchange_rates.json", &rates)
+}
+
+// SaveExchangeRates saves exchange rates to JSON file
+func (s *JSONStorage) SaveExchangeRates(rates []domain.ExchangeRate) error {
+	return s.writeJSONFile("exchange_rates.json", rates)
+}
+
+// GetBudgets reads budgets from JSON file
+func (s *JSONStorage) GetBudgets() ([]domain.Budget, error) {
+	var budgets []domain.Budget
+	return budgets, s.readJSONFile("budgets.json", &budgets)
+}
+
+// SaveBudgets saves budgets to JSON file
+func (s *JSONStorage) SaveBudgets(budgets []domain.Budget) error {
+	return s.writeJSONFile("budgets.json", budgets)
+}
+
+// GetEnvelopeAssignments reads envelope assignments from JSON file
+func (s *JSONStorage) GetEnvelopeAssignments() ([]domain.EnvelopeAssignment, error) {
+	var assignments []domain.EnvelopeAssignment
+	return assignments, s.readJSONFile("envelopes.json", &assignments)
+}
+
+// SaveEnvelopeAssignments saves envelope assignments to JSON file
+func (s *JSONStorage) SaveEnvelopeAssignments(assignments []domain.EnvelopeAssignment) error {
+	return s.writeJSONFile("envelopes.json", assignments)
+}
+
+// GetRecurringRules reads recurring rules from JSON file
+func (s *JSONStorage) GetRecurringRules() ([]domain.RecurringRule, error) {
+	var rules []domain.RecurringRule
+	return rules, s.readJSONFile("recurring.json", &rules)
+}
+
+// SaveRecurringRules saves recurring rules to JSON file
+func (s *JSONStorage) SaveRecurringRules(rules []domain.RecurringRule) error {
+	return s.writeJSONFile("recurring.json", rules)
+}
+
+// GetAuditLog reads the audit log from JSON file
+func (s *JSONStorage) GetAuditLog() ([]domain.AuditEntry, error) {
+	var entries []domain.AuditEntry
+	return entries, s.readJSONFile("audit.json", &entries)
+}
+
+// SaveAuditLog saves the audit log to JSON file
+func (s *JSONStorage) SaveAuditLog(entries []domain.AuditEntry) error {
+	return s.writeJSONFile("audit.json", entries)
+}
+
+// GetBalanceSnapshots reads balance snapshots from JSON file
+func (s *JSONStorage) GetBalanceSnapshots() ([]domain.BalanceSnapshot, error) {
+	var snapshots []domain.BalanceSnapshot
+	return snapshots, s.readJSONFile("balance_snapshots.json", &snapshots)
+}
+
+// SaveBalanceSnapshots saves balance snapshots to JSON file
+func (s *JSONStorage) SaveBalanceSnapshots(snapshots []domain.BalanceSnapshot) error {
+	return s.writeJSONFile("balance_snapshots.json", snapshots)
+}
+
 // GetPendingBatches reads pending transaction batches from JSON file
 func (s *JSONStorage) GetPendingBatches() ([]domain.TransactionBatch, error) {
 	var batches []domain.TransactionBatch
@@ -163,6 +201,14 @@ func (s *JSONStorage) SaveRolledBackBatches(batches []domain.TransactionBatch) e
 // Helper methods
 
 func (s *JSONStorage) readJSONFile(filename string, v interface{}) error {
+	// Inside a unit of work, read back what it already wrote
+	if data, buffered := s.tx.get(filename); buffered {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		return nil
+	}
+
 	filepath := filepath.Join(s.dataDir, filename)
 
 	// If file doesn't exist, return empty slice
@@ -179,6 +225,10 @@ func (s *JSONStorage) readJSONFile(filename string, v interface{}) error {
 		return nil
 	}
 
+	if data, err = s.codec.open(filename, data); err != nil {
+		return fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
 	if err := json.Unmarshal(data, v); err != nil {
 		return fmt.Errorf("failed to parse %s: %w", filename, err)
 	}
@@ -194,7 +244,16 @@ func (s *JSONStorage) writeJSONFile(filename string, v interface{}) error {
 		return fmt.Errorf("failed to marshal %s: %w", filename, err)
 	}
 
-	if err := os.WriteFile(filepath, data, 0644); err != nil {
+	if s.tx != nil {
+		s.tx.put(filename, data)
+		return nil
+	}
+
+	if data, err = s.codec.seal(filename, data); err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", filename, err)
+	}
+
+	if err := writeFileAtomic(filepath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write %s: %w", filename, err)
 	}
 
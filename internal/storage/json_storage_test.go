@@ -2,12 +2,20 @@ package storage
 
 import (
 	"comptes/internal/domain"
+	"comptes/internal/errors"
+	stderrors "errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 )
 
+// hasErrorCode reports whether err is, or wraps, a ComptesError with code
+func hasErrorCode(err error, code string) bool {
+	var e *errors.ComptesError
+	return stderrors.As(err, &e) && e.Code == code
+}
+
 func TestJSONStorage_SaveAndLoadTransactions(t *testing.T) {
 	// Create temporary directory
 	tempDir := t.TempDir()
@@ -18,7 +26,7 @@ func TestJSONStorage_SaveAndLoadTransactions(t *testing.T) {
 		{
 			ID:          "txn1",
 			Account:     "account1",
-			Amount:      -50.0,
+			Amount:      domain.NewMoney(-5000, "EUR"),
 			Description: "Test purchase",
 			Categories:  []string{"food"},
 			Tags:        []string{"test"},
@@ -29,7 +37,7 @@ func TestJSONStorage_SaveAndLoadTransactions(t *testing.T) {
 		{
 			ID:          "txn2",
 			Account:     "account1",
-			Amount:      100.0,
+			Amount:      domain.NewMoney(10000, "EUR"),
 			Description: "Test income",
 			Categories:  []string{"salary"},
 			Tags:        []string{"recurring"},
@@ -66,8 +74,8 @@ func TestJSONStorage_SaveAndLoadTransactions(t *testing.T) {
 		t.Errorf("Expected first transaction ID 'txn1', got '%s'", loadedTransactions[0].ID)
 	}
 
-	if loadedTransactions[1].Amount != 100.0 {
-		t.Errorf("Expected second transaction amount 100.0, got %.2f", loadedTransactions[1].Amount)
+	if loadedTransactions[1].Amount != domain.NewMoney(10000, "EUR") {
+		t.Errorf("Expected second transaction amount 100.00 EUR, got %s", loadedTransactions[1].Amount)
 	}
 }
 
@@ -83,7 +91,7 @@ func TestJSONStorage_SaveAndLoadAccounts(t *testing.T) {
 			Name:           "Test Account",
 			Type:           "checking",
 			Currency:       "EUR",
-			InitialBalance: 1500.0,
+			InitialBalance: domain.NewMoney(150000, "EUR"),
 			IsActive:       true,
 			CreatedAt:      time.Now(),
 		},
@@ -92,7 +100,7 @@ func TestJSONStorage_SaveAndLoadAccounts(t *testing.T) {
 			Name:           "Savings Account",
 			Type:           "savings",
 			Currency:       "EUR",
-			InitialBalance: 5000.0,
+			InitialBalance: domain.NewMoney(500000, "EUR"),
 			IsActive:       true,
 			CreatedAt:      time.Now(),
 		},
@@ -125,8 +133,8 @@ func TestJSONStorage_SaveAndLoadAccounts(t *testing.T) {
 		t.Errorf("Expected first account name 'Test Account', got '%s'", loadedAccounts[0].Name)
 	}
 
-	if loadedAccounts[1].InitialBalance != 5000.0 {
-		t.Errorf("Expected second account initial balance 5000.0, got %.2f", loadedAccounts[1].InitialBalance)
+	if loadedAccounts[1].InitialBalance != domain.NewMoney(500000, "EUR") {
+		t.Errorf("Expected second account initial balance 5000.00 EUR, got %s", loadedAccounts[1].InitialBalance)
 	}
 }
 
@@ -191,7 +199,7 @@ func TestJSONStorage_SaveAndLoadPendingBatches(t *testing.T) {
 				{
 					ID:          "txn1",
 					Account:     "account1",
-					Amount:      -50.0,
+					Amount:      domain.NewMoney(-5000, "EUR"),
 					Description: "Transaction 1",
 					IsActive:    true,
 					CreatedAt:   time.Now(),
@@ -259,7 +267,7 @@ func TestJSONStorage_SaveAndLoadCommittedBatches(t *testing.T) {
 				{
 					ID:          "txn1",
 					Account:     "account1",
-					Amount:      -50.0,
+					Amount:      domain.NewMoney(-5000, "EUR"),
 					Description: "Transaction 1",
 					IsActive:    true,
 					CreatedAt:   now,
@@ -316,7 +324,7 @@ func TestJSONStorage_SaveAndLoadRolledBackBatches(t *testing.T) {
 				{
 					ID:          "txn1",
 					Account:     "account1",
-					Amount:      -50.0,
+					Amount:      domain.NewMoney(-5000, "EUR"),
 					Description: "Transaction 1",
 					IsActive:    true,
 					CreatedAt:   now,
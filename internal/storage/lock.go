@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"comptes/internal/errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	lockFileName = ".lock"
+	lockInterval = 50 * time.Millisecond
+)
+
+// lockTimeout is how long Lock waits for another process before giving up
+var lockTimeout = 2 * time.Second
+
+// dirLock is an advisory, reentrant lock on a data directory shared by all processes
+// using it. The OS-specific part (tryLockFile/unlockFile) lives in lock_*.go.
+type dirLock struct {
+	dataDir string
+
+	mu    sync.Mutex
+	depth int
+	file  *os.File
+}
+
+// Lock takes the exclusive lock on the data directory, waiting up to lockTimeout.
+// Nested calls from the same storage only increase a counter.
+func (l *dirLock) Lock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.depth > 0 {
+		l.depth++
+		return nil
+	}
+
+	path := filepath.Join(l.dataDir, lockFileName)
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := tryLockFile(path)
+		if err == nil {
+			l.file = file
+			l.depth = 1
+			return nil
+		}
+		if err != errLocked {
+			return errors.Wrap(errors.ErrorTypeStorage, errors.CodeStorageLocked, "Failed to lock data directory "+l.dataDir, err)
+		}
+		if time.Now().After(deadline) {
+			return errors.StorageLocked(l.dataDir)
+		}
+		time.Sleep(lockInterval)
+	}
+}
+
+// Unlock releases one level of the lock, and the lock itself at the outermost level
+func (l *dirLock) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.depth == 0 {
+		return
+	}
+	l.depth--
+	if l.depth == 0 && l.file != nil {
+		unlockFile(l.file)
+		l.file = nil
+	}
+}
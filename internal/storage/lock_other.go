@@ -0,0 +1,31 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var errLocked = errors.New("data directory is locked")
+
+// tryLockFile creates path exclusively. Without flock, a crashed process leaves the
+// file behind and it has to be removed by hand (its content names the owner PID).
+func tryLockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	fmt.Fprintf(file, "%d\n", os.Getpid())
+	return file, nil
+}
+
+func unlockFile(file *os.File) {
+	name := file.Name()
+	file.Close()
+	os.Remove(name)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONStorage_LockIsExclusiveAndReentrant(t *testing.T) {
+	previous := lockTimeout
+	lockTimeout = 100 * time.Millisecond
+	defer func() { lockTimeout = previous }()
+
+	tempDir := t.TempDir()
+	first := NewJSONStorage(tempDir)
+	second := NewJSONStorage(tempDir)
+
+	if err := first.Lock(); err != nil {
+		t.Fatalf("Failed to take lock: %v", err)
+	}
+	// Nested lock from the same storage
+	if err := first.Lock(); err != nil {
+		t.Fatalf("Expected reentrant lock, got %v", err)
+	}
+
+	err := second.Lock()
+	var comptesErr *errors.ComptesError
+	if !stderrors.As(err, &comptesErr) || comptesErr.Type != errors.ErrorTypeStorage || comptesErr.Code != errors.CodeStorageLocked {
+		t.Fatalf("Expected storage_locked error, got %v", err)
+	}
+
+	// Still held after releasing the nested level
+	first.Unlock()
+	if err := second.Lock(); err == nil {
+		t.Fatal("Expected lock to be held until the outermost Unlock")
+	}
+
+	first.Unlock()
+	if err := second.Lock(); err != nil {
+		t.Fatalf("Expected lock to be free, got %v", err)
+	}
+	second.Unlock()
+}
+
+func TestJSONStorage_WritesAreAtomic(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJSONStorage(tempDir)
+
+	transactions := []domain.Transaction{{ID: "txn1", Account: "BANQUE", Amount: domain.NewMoney(-100, "EUR"), IsActive: true}}
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	transactions = append(transactions, domain.Transaction{ID: "txn2", Account: "BANQUE", Amount: domain.NewMoney(-200, "EUR"), IsActive: true})
+	if err := storage.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read data dir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("Temporary file left behind: %s", entry.Name())
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(tempDir, "movements.json"))
+	if err != nil {
+		t.Fatalf("Expected movements.json, got %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := storage.GetTransactions()
+	if err != nil || len(loaded) != 2 {
+		t.Errorf("Expected 2 transactions, got %d (%v)", len(loaded), err)
+	}
+}
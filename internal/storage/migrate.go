@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CurrentSchemaVersion is the data directory layout this binary reads and writes
+const CurrentSchemaVersion = 2
+
+// backupDirName holds the copies of the data directory taken before each migration
+const backupDirName = "backups"
+
+// Migration upgrades a data directory from Version-1 to Version
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(dataDir string) error
+}
+
+// migrations are applied in order; each one must leave a directory the next can read
+var migrations = []Migration{
+	{Version: 1, Description: "rename transactions.json to movements.json", Apply: renameTransactionsFile},
+	{Version: 2, Description: "store amounts as exact decimals with their currency", Apply: migrateLegacyAmounts},
+}
+
+// MigrationStep is a migration run by Migrate, with the backup taken before it
+type MigrationStep struct {
+	Migration
+	Backup string
+}
+
+// PendingMigrations returns the migrations a data directory still needs
+func PendingMigrations(dataDir string) ([]Migration, error) {
+	version, err := SchemaVersion(dataDir)
+	if err != nil {
+		return nil, errors.StorageReadFailed(schemaFileName, err)
+	}
+	if version > CurrentSchemaVersion {
+		return nil, errors.SchemaTooNew(dataDir, version, CurrentSchemaVersion)
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate brings a data directory to the current schema version. The pending migrations
+// are first run on a copy of the directory, whose result must load (the dry-run check);
+// then each is applied after a backup of the directory, and the version is recorded.
+// With dryRun, only the check runs and the directory is left untouched.
+func Migrate(dataDir string, dryRun bool) ([]MigrationStep, error) {
+	lock := &dirLock{dataDir: dataDir}
+	if err := lock.Lock(); err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	pending, err := PendingMigrations(dataDir)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	// Migrations rewrite the files as plain JSON
+	if IsEncrypted(dataDir) {
+		return nil, errors.MigrationFailed(pending[0].Version, pending[0].Description,
+			fmt.Errorf("the data directory is encrypted: run 'comptes encryption disable' first"))
+	}
+
+	if err := checkMigrations(dataDir, pending); err != nil {
+		return nil, err
+	}
+
+	steps := make([]MigrationStep, 0, len(pending))
+	for _, migration := range pending {
+		step := MigrationStep{Migration: migration}
+		if !dryRun {
+			name := fmt.Sprintf("%s-schema-v%d", time.Now().Format("20060102-150405"), migration.Version-1)
+			if step.Backup, err = backupDataDir(dataDir, name); err != nil {
+				return steps, errors.MigrationFailed(migration.Version, migration.Description, err)
+			}
+			if err := migration.Apply(dataDir); err != nil {
+				return steps, errors.MigrationFailed(migration.Version, migration.Description, err)
+			}
+			if err := WriteSchemaVersion(dataDir, migration.Version); err != nil {
+				return steps, errors.MigrationFailed(migration.Version, migration.Description, err)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// checkMigrations runs migrations on a scratch copy of the data directory and loads the result
+func checkMigrations(dataDir string, pending []Migration) error {
+	scratch, err := os.MkdirTemp("", "comptes-migrate-")
+	if err != nil {
+		return errors.Wrap(errors.ErrorTypeSystem, errors.CodeMigrationFailed, "Failed to prepare the migration check", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	if err := copyDataDir(dataDir, scratch); err != nil {
+		return errors.Wrap(errors.ErrorTypeSystem, errors.CodeMigrationFailed, "Failed to prepare the migration check", err)
+	}
+	for _, migration := range pending {
+		if err := migration.Apply(scratch); err != nil {
+			return errors.MigrationFailed(migration.Version, migration.Description, fmt.Errorf("dry run: %w", err))
+		}
+		if err := verifyDataDir(scratch); err != nil {
+			return errors.MigrationFailed(migration.Version, migration.Description, fmt.Errorf("dry run left unreadable data: %w", err))
+		}
+	}
+	return nil
+}
+
+// verifyDataDir loads every collection of a data directory
+func verifyDataDir(dataDir string) error {
+	var backends []Storage
+	backends = append(backends, NewJSONStorage(dataDir))
+	for _, name := range []string{journalFileName, checkpointFileName} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err == nil {
+			backends = append(backends, NewJournalStorage(dataDir))
+			break
+		}
+	}
+
+	for _, storage := range backends {
+		if _, err := storage.GetAccounts(); err != nil {
+			return err
+		}
+		if _, err := storage.GetTransactions(); err != nil {
+			return err
+		}
+		if _, err := storage.GetCategories(); err != nil {
+			return err
+		}
+		if _, err := storage.GetTags(); err != nil {
+			return err
+		}
+		if _, err := storage.GetExchangeRates(); err != nil {
+			return err
+		}
+		if _, err := storage.GetBudgets(); err != nil {
+			return err
+		}
+		if _, err := storage.GetEnvelopeAssignments(); err != nil {
+			return err
+		}
+		if _, err := storage.GetRecurringRules(); err != nil {
+			return err
+		}
+		if _, err := storage.GetAuditLog(); err != nil {
+			return err
+		}
+		if _, err := storage.GetPendingBatches(); err != nil {
+			return err
+		}
+		if _, err := storage.GetCommittedBatches(); err != nil {
+			return err
+		}
+		if _, err := storage.GetRolledBackBatches(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupDataDir copies the data directory into backups/<name> and returns its path
+func backupDataDir(dataDir, name string) (string, error) {
+	target := filepath.Join(dataDir, backupDirName, name)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := copyDataDir(dataDir, target); err != nil {
+		return "", fmt.Errorf("failed to back up data directory: %w", err)
+	}
+	return target, nil
+}
+
+// copyDataDir copies the regular files of a data directory, leaving out backups and the lock
+func copyDataDir(source, target string) error {
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == lockFileName {
+			continue
+		}
+		if err := copyFile(filepath.Join(source, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Migrations
+
+// renameTransactionsFile moves movements from transactions.json (first versions) to movements.json
+func renameTransactionsFile(dataDir string) error {
+	oldPath := filepath.Join(dataDir, "transactions.json")
+	newPath := filepath.Join(dataDir, "movements.json")
+	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		// Already renamed by an earlier version: movements.json has always taken precedence
+		return nil
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename transactions.json to movements.json: %w", err)
+	}
+	syncDir(dataDir)
+	return nil
+}
+
+// migrateLegacyAmounts converts movements (batches included) and initial balances written as float64
+// numbers (no currency) to exact amounts in their account currency
+func migrateLegacyAmounts(dataDir string) error {
+	storage := NewJSONStorage(dataDir)
+
+	// GetAccounts already reads bare numbers in the account currency
+	accounts, err := storage.GetAccounts()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "accounts.json")); err == nil {
+		if err := storage.writeJSONFile("accounts.json", accounts); err != nil {
+			return err
+		}
+	}
+
+	currencies := make(map[string]string)
+	for _, acc := range accounts {
+		currencies[acc.ID] = acc.Currency
+	}
+
+	var transactions []domain.Transaction
+	if err := storage.readJSONFile("movements.json", &transactions); err != nil {
+		return err
+	}
+	changed, err := withAccountCurrency(transactions, currencies)
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := storage.writeJSONFile("movements.json", transactions); err != nil {
+			return err
+		}
+	}
+
+	// Batches hold movements too, with the same bare amounts
+	for _, name := range []string{"pending_transactions.json", "committed_transactions.json", "rolled_back_transactions.json"} {
+		var batches []domain.TransactionBatch
+		if err := storage.readJSONFile(name, &batches); err != nil {
+			return err
+		}
+		changed := false
+		for _, batch := range batches {
+			batchChanged, err := withAccountCurrency(batch.Transactions, currencies)
+			if err != nil {
+				return fmt.Errorf("batch %s: %w", batch.ID, err)
+			}
+			changed = changed || batchChanged
+		}
+		if changed {
+			if err := storage.writeJSONFile(name, batches); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withAccountCurrency gives the amounts stored without a currency the currency of their
+// account, and reports whether any was changed
+func withAccountCurrency(transactions []domain.Transaction, currencies map[string]string) (bool, error) {
+	var changed bool
+	for i, txn := range transactions {
+		currency := currencies[txn.Account]
+		if txn.Amount.Currency != "" {
+			continue
+		}
+		if currency == "" {
+			return false, fmt.Errorf("transaction %s has no currency and account %q is unknown", txn.ID, txn.Account)
+		}
+		transactions[i].Amount = txn.Amount.WithCurrency(currency)
+		for j, split := range txn.Splits {
+			transactions[i].Splits[j].Amount = split.Amount.WithCurrency(currency)
+		}
+		changed = true
+	}
+	return changed, nil
+}
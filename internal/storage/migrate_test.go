@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"comptes/internal/errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLegacyDataDir(t *testing.T, movements string) string {
+	tempDir := t.TempDir()
+	// Files written by the first versions: transactions.json, bare numbers, no currency
+	legacyAccounts := `[{"id": "account1", "name": "Test", "currency": "EUR", "initial_balance": 1000.1, "is_active": true}]`
+	if err := os.WriteFile(filepath.Join(tempDir, "accounts.json"), []byte(legacyAccounts), 0644); err != nil {
+		t.Fatalf("Failed to write accounts: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "transactions.json"), []byte(movements), 0644); err != nil {
+		t.Fatalf("Failed to write transactions: %v", err)
+	}
+	return tempDir
+}
+
+func TestMigrate_LegacyDataDir(t *testing.T) {
+	tempDir := writeLegacyDataDir(t, `[
+		{"id": "txn1", "account": "account1", "amount": 0.1, "is_active": true},
+		{"id": "txn2", "account": "account1", "amount": 0.2, "is_active": true}
+	]`)
+
+	if version, _ := SchemaVersion(tempDir); version != 0 {
+		t.Errorf("Expected schema version 0, got %d", version)
+	}
+	if err := CheckSchema(tempDir); !hasErrorCode(err, errors.CodeSchemaOutdated) {
+		t.Fatalf("Expected schema_outdated, got %v", err)
+	}
+
+	// Dry run: checked, nothing changed
+	steps, err := Migrate(tempDir, true)
+	if err != nil {
+		t.Fatalf("Expected dry run to pass, got %v", err)
+	}
+	if len(steps) != CurrentSchemaVersion {
+		t.Errorf("Expected %d pending migrations, got %d", CurrentSchemaVersion, len(steps))
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "transactions.json")); err != nil {
+		t.Errorf("Expected dry run to leave transactions.json, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, backupDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup on dry run, got %v", err)
+	}
+
+	steps, err = Migrate(tempDir, false)
+	if err != nil {
+		t.Fatalf("Expected migration to succeed, got %v", err)
+	}
+	if err := CheckSchema(tempDir); err != nil {
+		t.Errorf("Expected current schema after migration, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(steps[0].Backup, "transactions.json")); err != nil {
+		t.Errorf("Expected the first backup to hold transactions.json, got %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "movements.json"))
+	if err != nil {
+		t.Fatalf("Failed to read movements: %v", err)
+	}
+	if !strings.Contains(string(data), `"0.10 EUR"`) {
+		t.Errorf("Expected migrated amount \"0.10 EUR\" in movements.json, got %s", data)
+	}
+	balance, err := NewJSONStorage(tempDir).GetAccountBalance("account1")
+	if err != nil || balance != domain.NewMoney(100040, "EUR") {
+		t.Errorf("Expected balance 1000.40 EUR, got %s (%v)", balance, err)
+	}
+
+	// Nothing left to do
+	if steps, err := Migrate(tempDir, false); err != nil || len(steps) != 0 {
+		t.Errorf("Expected no pending migration, got %d (%v)", len(steps), err)
+	}
+}
+
+func TestMigrate_LegacyBatches(t *testing.T) {
+	tempDir := writeLegacyDataDir(t, `[]`)
+	pending := `[{"id": "batch1", "transactions": [
+		{"id": "txn1", "account": "account1", "amount": -12.5, "is_active": true, "splits": [
+			{"amount": -10, "categories": ["ALM"]},
+			{"amount": -2.5, "categories": ["LOI"]}
+		]}
+	]}]`
+	if err := os.WriteFile(filepath.Join(tempDir, "pending_transactions.json"), []byte(pending), 0644); err != nil {
+		t.Fatalf("Failed to write pending batches: %v", err)
+	}
+	committed := `[{"id": "batch0", "transactions": [{"id": "txn0", "account": "account1", "amount": 3, "is_active": true}]}]`
+	if err := os.WriteFile(filepath.Join(tempDir, "committed_transactions.json"), []byte(committed), 0644); err != nil {
+		t.Fatalf("Failed to write committed batches: %v", err)
+	}
+
+	if _, err := Migrate(tempDir, false); err != nil {
+		t.Fatalf("Expected migration to succeed, got %v", err)
+	}
+
+	storage := NewJSONStorage(tempDir)
+	batches, err := storage.GetPendingBatches()
+	if err != nil || len(batches) != 1 {
+		t.Fatalf("Expected the pending batch, got %+v (%v)", batches, err)
+	}
+	txn := batches[0].Transactions[0]
+	if txn.Amount != domain.NewMoney(-1250, "EUR") || txn.Splits[1].Amount != domain.NewMoney(-250, "EUR") {
+		t.Errorf("Expected the pending batch amounts in EUR, got %+v", txn)
+	}
+	batches, err = storage.GetCommittedBatches()
+	if err != nil || len(batches) != 1 || batches[0].Transactions[0].Amount != domain.NewMoney(300, "EUR") {
+		t.Errorf("Expected the committed batch amounts in EUR, got %+v (%v)", batches, err)
+	}
+}
+
+func TestMigrate_FailedCheckLeavesDataUntouched(t *testing.T) {
+	tempDir := writeLegacyDataDir(t, `[{"id": "txn1", "account": "unknown", "amount": 0.1, "is_active": true}]`)
+
+	if _, err := Migrate(tempDir, false); !hasErrorCode(err, errors.CodeMigrationFailed) {
+		t.Fatalf("Expected migration_failed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "transactions.json")); err != nil {
+		t.Errorf("Expected transactions.json to be left in place, got %v", err)
+	}
+	if version, _ := SchemaVersion(tempDir); version != 0 {
+		t.Errorf("Expected schema version to stay 0, got %d", version)
+	}
+}
+
+func TestSchema_NewerDataDirIsRefused(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := WriteSchemaVersion(tempDir, CurrentSchemaVersion+1); err != nil {
+		t.Fatalf("Failed to write schema version: %v", err)
+	}
+	if err := CheckSchema(tempDir); !hasErrorCode(err, errors.CodeSchemaTooNew) {
+		t.Fatalf("Expected schema_too_new from CheckSchema, got %v", err)
+	}
+	if _, err := Migrate(tempDir, false); !hasErrorCode(err, errors.CodeSchemaTooNew) {
+		t.Fatalf("Expected schema_too_new from Migrate, got %v", err)
+	}
+}
+
+func TestSchema_EmptyDataDirIsCurrent(t *testing.T) {
+	if err := CheckSchema(t.TempDir()); err != nil {
+		t.Errorf("Expected an empty data directory to be current, got %v", err)
+	}
+}
+
+func TestMigrations_AreRegisteredInOrder(t *testing.T) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 || migration.Apply == nil || migration.Description == "" {
+			t.Errorf("Migration %d is out of order or incomplete: %+v", i, migration)
+		}
+	}
+	if len(migrations) != CurrentSchemaVersion {
+		t.Errorf("Expected %d migrations for schema version %d, got %d", CurrentSchemaVersion, CurrentSchemaVersion, len(migrations))
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"strings"
+	"time"
+)
+
+// TransactionQuery selects movements. Zero-valued fields do not filter; results keep
+// the storage order, then Offset and Limit (if positive) page through them.
+type TransactionQuery struct {
+	Account    string
+	From       time.Time // Inclusive, by calendar day (see Day)
+	To         time.Time // Exclusive, by calendar day
+	Categories []string  // Any of these, on the transaction or one of its split lines
+	Tags       []string  // Any of these, on the transaction or one of its split lines
+	Active     *bool
+	IDPrefix   string
+	Offset     int
+	Limit      int
+}
+
+// ActiveOnly returns a pointer for TransactionQuery.Active
+func ActiveOnly(active bool) *bool {
+	return &active
+}
+
+// Day returns the calendar day of t in its own location, at midnight UTC. Movements are
+// selected by the day listings show for them, whatever time zone their date was taken in.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Matches reports whether a transaction satisfies every filter of the query
+func (q TransactionQuery) Matches(t domain.Transaction) bool {
+	if q.Account != "" && t.Account != q.Account {
+		return false
+	}
+	if !q.From.IsZero() && Day(t.Date).Before(Day(q.From)) {
+		return false
+	}
+	if !q.To.IsZero() && !Day(t.Date).Before(Day(q.To)) {
+		return false
+	}
+	if q.Active != nil && t.IsActive != *q.Active {
+		return false
+	}
+	if q.IDPrefix != "" && !strings.HasPrefix(t.ID, q.IDPrefix) {
+		return false
+	}
+	if len(q.Categories) > 0 && !anyCode(q.Categories, transactionCategories(t)) {
+		return false
+	}
+	if len(q.Tags) > 0 && !anyCode(q.Tags, transactionTags(t)) {
+		return false
+	}
+	return true
+}
+
+// Apply filters and pages transactions held in memory, for backends without indexes
+func (q TransactionQuery) Apply(transactions []domain.Transaction) []domain.Transaction {
+	var result []domain.Transaction
+	skipped := 0
+	for _, t := range transactions {
+		if !q.Matches(t) {
+			continue
+		}
+		if skipped < q.Offset {
+			skipped++
+			continue
+		}
+		result = append(result, t)
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// transactionCategories returns the categories of a transaction and of its split lines
+func transactionCategories(t domain.Transaction) []string {
+	codes := append([]string(nil), t.Categories...)
+	for _, split := range t.Splits {
+		codes = append(codes, split.Categories...)
+	}
+	return codes
+}
+
+// transactionTags returns the tags of a transaction and of its split lines
+func transactionTags(t domain.Transaction) []string {
+	codes := append([]string(nil), t.Tags...)
+	for _, split := range t.Splits {
+		codes = append(codes, split.Tags...)
+	}
+	return codes
+}
+
+func anyCode(wanted, codes []string) bool {
+	for _, code := range codes {
+		for _, w := range wanted {
+			if code == w {
+				return true
+			}
+		}
+	}
+	return false
+}
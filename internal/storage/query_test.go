@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"testing"
+	"time"
+)
+
+func queryFixture() []domain.Transaction {
+	date := func(day int) time.Time { return time.Date(2024, 3, day, 0, 0, 0, 0, time.Local) }
+	return []domain.Transaction{
+		{ID: "a1", Account: "BANQUE", Date: date(5), Amount: domain.NewMoney(-5000, "EUR"), Categories: []string{"ALM"}, Tags: []string{"REC"}, IsActive: true},
+		{ID: "a2", Account: "BANQUE", Date: date(1), Amount: domain.NewMoney(-2000, "EUR"), Categories: []string{"TRP"}, IsActive: false},
+		{ID: "b1", Account: "EPARGNE", Date: date(10), Amount: domain.NewMoney(10000, "EUR"), Categories: []string{"SAL"}, IsActive: true},
+		{ID: "a3", Account: "BANQUE", Date: date(20), Amount: domain.NewMoney(-9000, "EUR"), IsActive: true, Splits: []domain.Split{
+			{Amount: domain.NewMoney(-6000, "EUR"), Categories: []string{"ALM"}},
+			{Amount: domain.NewMoney(-3000, "EUR"), Categories: []string{"HAB"}, Tags: []string{"PRO"}},
+		}},
+		{ID: "b2", Account: "EPARGNE", Date: date(15), Amount: domain.NewMoney(-100, "EUR"), Tags: []string{"PRO"}, IsActive: true},
+	}
+}
+
+func ids(transactions []domain.Transaction) []string {
+	result := []string{}
+	for _, t := range transactions {
+		result = append(result, t.ID)
+	}
+	return result
+}
+
+func TestQueryTransactions(t *testing.T) {
+	march := func(day int) time.Time { return time.Date(2024, 3, day, 0, 0, 0, 0, time.Local) }
+	tests := []struct {
+		name     string
+		query    TransactionQuery
+		expected []string
+	}{
+		{"all", TransactionQuery{}, []string{"a1", "a2", "b1", "a3", "b2"}},
+		{"account", TransactionQuery{Account: "BANQUE"}, []string{"a1", "a2", "a3"}},
+		{"unknown account", TransactionQuery{Account: "NONE"}, []string{}},
+		{"active", TransactionQuery{Account: "BANQUE", Active: ActiveOnly(true)}, []string{"a1", "a3"}},
+		{"inactive", TransactionQuery{Active: ActiveOnly(false)}, []string{"a2"}},
+		{"date range", TransactionQuery{From: march(5), To: march(15)}, []string{"a1", "b1"}},
+		{"category on split line", TransactionQuery{Categories: []string{"HAB", "SAL"}}, []string{"b1", "a3"}},
+		{"tag", TransactionQuery{Tags: []string{"PRO"}}, []string{"a3", "b2"}},
+		{"id prefix", TransactionQuery{IDPrefix: "b"}, []string{"b1", "b2"}},
+		{"combined", TransactionQuery{Account: "BANQUE", Categories: []string{"ALM"}, From: march(10)}, []string{"a3"}},
+		{"page", TransactionQuery{Offset: 1, Limit: 2}, []string{"a2", "b1"}},
+		{"page past the end", TransactionQuery{Account: "EPARGNE", Offset: 5}, []string{}},
+	}
+
+	backends := map[string]func(dir string) Storage{
+		"json":    func(dir string) Storage { return NewJSONStorage(dir) },
+		"journal": func(dir string) Storage { return NewJournalStorage(dir) },
+	}
+	for backend, open := range backends {
+		storage := open(t.TempDir())
+		if err := storage.SaveTransactions(queryFixture()); err != nil {
+			t.Fatalf("%s: failed to save transactions: %v", backend, err)
+		}
+		for _, tt := range tests {
+			result, err := storage.QueryTransactions(tt.query)
+			if err != nil {
+				t.Errorf("%s/%s: unexpected error %v", backend, tt.name, err)
+				continue
+			}
+			if got := ids(result); !equalStrings(got, tt.expected) {
+				t.Errorf("%s/%s: expected %v, got %v", backend, tt.name, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestQueryTransactions_CalendarDay(t *testing.T) {
+	// Late evening of October 16th in American Samoa is already October 17th in UTC
+	samoa := time.FixedZone("SST", -11*60*60)
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	transactions := []domain.Transaction{
+		{ID: "late", Account: "BANQUE", Date: time.Date(2026, 10, 16, 23, 30, 0, 0, samoa), Amount: domain.NewMoney(-500, "EUR"), IsActive: true},
+		{ID: "next", Account: "BANQUE", Date: time.Date(2026, 10, 17, 8, 0, 0, 0, samoa), Amount: domain.NewMoney(-700, "EUR"), IsActive: true},
+	}
+	tests := []struct {
+		name     string
+		query    TransactionQuery
+		expected []string
+	}{
+		{"its own day", TransactionQuery{From: day(16), To: day(17)}, []string{"late"}},
+		{"the next day", TransactionQuery{From: day(17), To: day(18)}, []string{"next"}},
+		{"bounds in another zone", TransactionQuery{From: time.Date(2026, 10, 16, 0, 0, 0, 0, samoa), To: time.Date(2026, 10, 17, 0, 0, 0, 0, samoa)}, []string{"late"}},
+	}
+
+	backends := map[string]func(dir string) Storage{
+		"json":    func(dir string) Storage { return NewJSONStorage(dir) },
+		"journal": func(dir string) Storage { return NewJournalStorage(dir) },
+	}
+	for backend, open := range backends {
+		storage := open(t.TempDir())
+		if err := storage.SaveTransactions(transactions); err != nil {
+			t.Fatalf("%s: failed to save transactions: %v", backend, err)
+		}
+		for _, tt := range tests {
+			result, err := storage.QueryTransactions(tt.query)
+			if err != nil {
+				t.Errorf("%s/%s: unexpected error %v", backend, tt.name, err)
+				continue
+			}
+			if got := ids(result); !equalStrings(got, tt.expected) {
+				t.Errorf("%s/%s: expected %v, got %v", backend, tt.name, tt.expected, got)
+			}
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJSONStorage_QueryIndexFollowsChanges(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJSONStorage(tempDir)
+	if err := storage.SaveTransactions(queryFixture()); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	result, _ := storage.QueryTransactions(TransactionQuery{Account: "EPARGNE"})
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(result))
+	}
+	// Results are copies: altering them leaves the index intact
+	result[0].Categories[0] = "MODIFIÉ"
+	if again, _ := storage.QueryTransactions(TransactionQuery{Categories: []string{"SAL"}}); len(again) != 1 || again[0].Categories[0] != "SAL" {
+		t.Errorf("Expected the index to be unchanged, got %+v", again)
+	}
+
+	// Another process adds a movement: the index is rebuilt
+	other := NewJSONStorage(tempDir)
+	transactions := append(queryFixture(), domain.Transaction{ID: "b3", Account: "EPARGNE", Amount: domain.NewMoney(500, "EUR"), IsActive: true})
+	if err := other.SaveTransactions(transactions); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	if result, _ := storage.QueryTransactions(TransactionQuery{Account: "EPARGNE"}); len(result) != 3 {
+		t.Errorf("Expected 3 transactions after an external change, got %d", len(result))
+	}
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"comptes/internal/errors"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const schemaFileName = "schema.json"
+
+// schemaMarker records the layout version of a data directory
+type schemaMarker struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// dataFiles are the files whose presence shows a data directory is in use
+var dataFiles = []string{"accounts.json", "movements.json", "transactions.json", journalFileName, checkpointFileName}
+
+// SchemaVersion returns the schema version of a data directory. A directory without
+// marker is at version 0 if it holds data, and at the current version if it is empty.
+func SchemaVersion(dataDir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, schemaFileName))
+	if os.IsNotExist(err) {
+		if HasData(dataDir) {
+			return 0, nil
+		}
+		return CurrentSchemaVersion, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", schemaFileName, err)
+	}
+
+	var marker schemaMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", schemaFileName, err)
+	}
+	return marker.Version, nil
+}
+
+// HasData reports whether a data directory holds data (accounts or movements)
+func HasData(dataDir string) bool {
+	for _, name := range dataFiles {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteSchemaVersion records the schema version of a data directory
+func WriteSchemaVersion(dataDir string, version int) error {
+	data, err := json.MarshalIndent(schemaMarker{Version: version, UpdatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", schemaFileName, err)
+	}
+	if err := writeFileAtomic(filepath.Join(dataDir, schemaFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", schemaFileName, err)
+	}
+	return nil
+}
+
+// CheckSchema refuses data directories this binary cannot use: written by a newer
+// version, or still waiting for `comptes migrate`
+func CheckSchema(dataDir string) error {
+	version, err := SchemaVersion(dataDir)
+	if err != nil {
+		return errors.StorageReadFailed(schemaFileName, err)
+	}
+	if version > CurrentSchemaVersion {
+		return errors.SchemaTooNew(dataDir, version, CurrentSchemaVersion)
+	}
+	if version < CurrentSchemaVersion {
+		return errors.SchemaOutdated(dataDir, version, CurrentSchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// intentFileName holds the files of a unit of work being applied. It is written
+// (atomically) before any data file is replaced and removed once they all are, so a
+// crash in between is repaired on next open by applying it again.
+const intentFileName = ".commit-intent.json"
+
+// jsonUnitOfWork buffers the files written during Atomically
+type jsonUnitOfWork struct {
+	order []string
+	files map[string][]byte
+}
+
+// intentFile is a file of a commit intent with its content as stored on disk: JSON
+// as is, anything else (encrypted files) as base64 bytes
+type intentFile struct {
+	Name   string          `json:"name"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Raw    []byte          `json:"raw,omitempty"`
+	Remove bool            `json:"remove,omitempty"`
+}
+
+func newIntentFile(name string, content []byte) intentFile {
+	if json.Valid(content) {
+		return intentFile{Name: name, Data: content}
+	}
+	return intentFile{Name: name, Raw: content}
+}
+
+func (f intentFile) content() []byte {
+	if f.Raw != nil {
+		return f.Raw
+	}
+	return f.Data
+}
+
+func (u *jsonUnitOfWork) get(filename string) ([]byte, bool) {
+	if u == nil {
+		return nil, false
+	}
+	data, ok := u.files[filename]
+	return data, ok
+}
+
+func (u *jsonUnitOfWork) put(filename string, data []byte) {
+	if _, exists := u.files[filename]; !exists {
+		u.order = append(u.order, filename)
+	}
+	u.files[filename] = data
+}
+
+// Atomically runs fn with all JSON writes buffered, then applies them together.
+// Nested calls join the outer unit of work.
+func (s *JSONStorage) Atomically(fn func() error) error {
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	if s.tx != nil {
+		return fn()
+	}
+
+	s.tx = &jsonUnitOfWork{files: make(map[string][]byte)}
+	err := fn()
+	tx := s.tx
+	s.tx = nil
+	if err != nil {
+		return err
+	}
+	return s.apply(tx)
+}
+
+// apply writes the files of a unit of work, through the intent file when there are several
+func (s *JSONStorage) apply(tx *jsonUnitOfWork) error {
+	intent := make([]intentFile, 0, len(tx.order))
+	for _, name := range tx.order {
+		sealed, err := s.codec.seal(name, tx.files[name])
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", name, err)
+		}
+		intent = append(intent, newIntentFile(name, sealed))
+	}
+
+	switch len(intent) {
+	case 0:
+		return nil
+	case 1:
+		return writeFileAtomic(filepath.Join(s.dataDir, intent[0].Name), intent[0].content(), 0644)
+	}
+	return writeIntent(s.dataDir, intent)
+}
+
+// writeIntent records the new content of several files, then applies it. The caller holds the lock.
+func writeIntent(dataDir string, intent []intentFile) error {
+	data, err := json.Marshal(intent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal commit intent: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dataDir, intentFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write commit intent: %w", err)
+	}
+	return applyIntent(dataDir, intent)
+}
+
+func applyIntent(dataDir string, intent []intentFile) error {
+	for _, file := range intent {
+		if filepath.Base(file.Name) != file.Name {
+			return fmt.Errorf("invalid file name in commit intent: %q", file.Name)
+		}
+		path := filepath.Join(dataDir, file.Name)
+		if file.Remove {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", file.Name, err)
+			}
+			continue
+		}
+		if err := writeFileAtomic(path, file.content(), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dataDir, intentFileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove commit intent: %w", err)
+	}
+	syncDir(dataDir)
+	return nil
+}
+
+// Recover finishes a unit of work interrupted by a crash, if any, and reports whether it did
+func (s *JSONStorage) Recover() (bool, error) {
+	return recoverStorage(s, s.dataDir)
+}
+
+// recoverStorage applies the commit intent left in dataDir by a crash, under the lock of s.
+// Intents hold files as stored on disk, so no key is needed to finish them.
+func recoverStorage(s Storage, dataDir string) (bool, error) {
+	// Cheap check first: most runs have nothing to recover and need no lock
+	if _, err := os.Stat(filepath.Join(dataDir, intentFileName)); os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := s.Lock(); err != nil {
+		return false, err
+	}
+	defer s.Unlock()
+
+	return recoverIntent(dataDir)
+}
+
+// recoverIntent applies a leftover commit intent. The caller holds the lock.
+func recoverIntent(dataDir string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, intentFileName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read commit intent: %w", err)
+	}
+
+	var intent []intentFile
+	if err := json.Unmarshal(data, &intent); err != nil {
+		return false, fmt.Errorf("failed to parse commit intent: %w", err)
+	}
+	if err := applyIntent(dataDir, intent); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// journalUnitOfWork holds the encoded events of Atomically until they are committed.
+// They are applied in memory as they are saved so that fn reads its own writes.
+type journalUnitOfWork struct {
+	id     int64
+	events int
+	lines  bytes.Buffer
+}
+
+func (u *journalUnitOfWork) add(s *JournalStorage, events []journalEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for i := range events {
+		events[i].Tx = u.id
+	}
+	if err := s.encode(events, &u.lines); err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.collection(event.Collection).apply(event)
+	}
+	s.seq += int64(len(events))
+	u.events += len(events)
+	return nil
+}
+
+// Atomically runs fn with all journal events held back, then appends them followed by
+// a commit marker in a single synced write. Replay ignores events without their marker.
+// Nested calls join the outer unit of work.
+func (s *JournalStorage) Atomically(fn func() error) error {
+	if err := s.lock.Lock(); err != nil {
+		return err
+	}
+	defer s.lock.Unlock()
+
+	s.mu.Lock()
+	if s.tx != nil {
+		s.mu.Unlock()
+		return fn()
+	}
+	if err := s.openLocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.tx = &journalUnitOfWork{id: s.seq + 1}
+	s.mu.Unlock()
+
+	err := fn()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.tx
+	s.tx = nil
+	if err == nil {
+		err = s.commit(tx)
+	}
+	if err != nil {
+		// The in-memory state holds uncommitted events: reload it from disk
+		s.loaded = false
+	}
+	return err
+}
+
+func (s *JournalStorage) commit(tx *journalUnitOfWork) error {
+	if tx.events == 0 {
+		return nil
+	}
+
+	marker := []journalEvent{{Op: journalOpCommit, Tx: tx.id}}
+	if err := s.encode(marker, &tx.lines); err != nil {
+		return err
+	}
+	if err := s.write(tx.lines.Bytes()); err != nil {
+		return err
+	}
+
+	s.seq++
+	s.offset += int64(tx.lines.Len())
+	s.sinceCheckpoint += tx.events + 1
+
+	if s.checkpointEvery > 0 && s.sinceCheckpoint >= s.checkpointEvery {
+		return s.checkpoint()
+	}
+	return nil
+}
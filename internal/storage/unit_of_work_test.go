@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"comptes/internal/domain"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONStorage_AtomicallyAppliesAllOrNothing(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJSONStorage(tempDir)
+	batches := []domain.TransactionBatch{{ID: "batch1"}}
+
+	err := storage.Atomically(func() error {
+		if err := storage.SaveTransactions(testTransactions()); err != nil {
+			return err
+		}
+		// Reads see the writes of the unit of work
+		if loaded, _ := storage.GetTransactions(); len(loaded) != 2 {
+			t.Errorf("Expected buffered transactions to be readable, got %d", len(loaded))
+		}
+		return fmt.Errorf("validation failed")
+	})
+	if err == nil {
+		t.Fatal("Expected the error of fn to be returned")
+	}
+	if loaded, _ := NewJSONStorage(tempDir).GetTransactions(); len(loaded) != 0 {
+		t.Errorf("Expected no transactions after a failed unit of work, got %d", len(loaded))
+	}
+
+	err = storage.Atomically(func() error {
+		if err := storage.SaveTransactions(testTransactions()); err != nil {
+			return err
+		}
+		return storage.SaveCommittedBatches(batches)
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	reopened := NewJSONStorage(tempDir)
+	if loaded, _ := reopened.GetTransactions(); len(loaded) != 2 {
+		t.Errorf("Expected 2 transactions, got %d", len(loaded))
+	}
+	if committed, _ := reopened.GetCommittedBatches(); len(committed) != 1 {
+		t.Errorf("Expected 1 committed batch, got %d", len(committed))
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, intentFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected commit intent to be removed, got %v", err)
+	}
+}
+
+func TestJSONStorage_RecoverAppliesCommitIntent(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJSONStorage(tempDir)
+	if err := storage.SavePendingBatches([]domain.TransactionBatch{{ID: "batch1"}}); err != nil {
+		t.Fatalf("Failed to save pending batches: %v", err)
+	}
+
+	// Simulate a crash after the intent was written but before the data files were replaced
+	transactions, _ := json.Marshal(testTransactions())
+	intent, _ := json.Marshal([]intentFile{
+		{Name: "movements.json", Data: transactions},
+		{Name: "pending_transactions.json", Data: json.RawMessage("[]")},
+	})
+	if err := os.WriteFile(filepath.Join(tempDir, intentFileName), intent, 0644); err != nil {
+		t.Fatalf("Failed to write intent: %v", err)
+	}
+
+	recovered, err := New(BackendJSON, tempDir)
+	if err != nil {
+		t.Fatalf("Expected recovery to succeed, got %v", err)
+	}
+	if loaded, _ := recovered.GetTransactions(); len(loaded) != 2 {
+		t.Errorf("Expected 2 recovered transactions, got %d", len(loaded))
+	}
+	if pending, _ := recovered.GetPendingBatches(); len(pending) != 0 {
+		t.Errorf("Expected no pending batch after recovery, got %d", len(pending))
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, intentFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected commit intent to be removed, got %v", err)
+	}
+}
+
+func TestJournalStorage_AtomicallyAppliesAllOrNothing(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJournalStorage(tempDir)
+
+	err := storage.Atomically(func() error {
+		if err := storage.SaveTransactions(testTransactions()); err != nil {
+			return err
+		}
+		return fmt.Errorf("validation failed")
+	})
+	if err == nil {
+		t.Fatal("Expected the error of fn to be returned")
+	}
+	if loaded, _ := storage.GetTransactions(); len(loaded) != 0 {
+		t.Errorf("Expected no transactions after a failed unit of work, got %d", len(loaded))
+	}
+	if lines := journalLines(t, tempDir); lines != 0 {
+		t.Errorf("Expected nothing written to the journal, got %d lines", lines)
+	}
+
+	err = storage.Atomically(func() error {
+		if err := storage.SaveTransactions(testTransactions()); err != nil {
+			return err
+		}
+		return storage.SaveCommittedBatches([]domain.TransactionBatch{{ID: "batch1"}})
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Two adds, one batch and the commit marker
+	if lines := journalLines(t, tempDir); lines != 4 {
+		t.Errorf("Expected 4 journal lines, got %d", lines)
+	}
+	reopened := NewJournalStorage(tempDir)
+	if loaded, _ := reopened.GetTransactions(); len(loaded) != 2 {
+		t.Errorf("Expected 2 transactions, got %d", len(loaded))
+	}
+	if committed, _ := reopened.GetCommittedBatches(); len(committed) != 1 {
+		t.Errorf("Expected 1 committed batch, got %d", len(committed))
+	}
+}
+
+func TestJournalStorage_DropsUncommittedUnitOfWork(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJournalStorage(tempDir)
+	if err := storage.SaveTransactions(testTransactions()[:1]); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	before := journalLines(t, tempDir)
+
+	// Simulate a crash after part of a unit of work reached the disk, without its commit marker
+	file, err := os.OpenFile(filepath.Join(tempDir, journalFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	file.WriteString(`{"seq":2,"op":"add","collection":"transactions","key":"txn2","data":{"id":"txn2","account":"BANQUE"},"tx":2}` + "\n")
+	file.WriteString(`{"seq":3,"op":"add","collection":"committed_batches","key":"b`)
+	file.Close()
+
+	reopened := NewJournalStorage(tempDir)
+	loaded, err := reopened.GetTransactions()
+	if err != nil {
+		t.Fatalf("Expected uncommitted events to be dropped, got %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].ID != "txn1" {
+		t.Errorf("Expected only txn1, got %+v", loaded)
+	}
+
+	// A reader does not hold the lock: the tail may be another process's append in progress
+	if lines := journalLines(t, tempDir); lines != before+1 {
+		t.Errorf("Expected a read to leave the journal as is, got %d lines", lines)
+	}
+
+	// A writer holds the lock: it drops the tail before appending
+	if err := reopened.SaveTransactions(append(loaded, testTransactions()[1])); err != nil {
+		t.Fatalf("Failed to save after the crash: %v", err)
+	}
+	if lines := journalLines(t, tempDir); lines != before+1 {
+		t.Errorf("Expected the unfinished unit of work replaced by one event, got %d lines", lines-before)
+	}
+	if loaded, err := NewJournalStorage(tempDir).GetTransactions(); err != nil || len(loaded) != 2 {
+		t.Errorf("Expected 2 transactions after the repair, got %d (%v)", len(loaded), err)
+	}
+}
+
+func TestJournalStorage_ReaderKeepsAppendInProgress(t *testing.T) {
+	tempDir := t.TempDir()
+	storage := NewJournalStorage(tempDir)
+	if err := storage.SaveTransactions(testTransactions()[:1]); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+
+	// Another process has written the events of a unit of work, not yet its commit marker
+	path := filepath.Join(tempDir, journalFileName)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	file.WriteString(`{"seq":2,"op":"add","collection":"transactions","key":"txn2","data":{"id":"txn2","account":"BANQUE"},"tx":2}` + "\n")
+
+	reader := NewJournalStorage(tempDir)
+	if loaded, _ := reader.GetTransactions(); len(loaded) != 1 {
+		t.Errorf("Expected the uncommitted event to be ignored, got %d transactions", len(loaded))
+	}
+
+	// Its commit marker lands after the read: nothing was lost
+	file.WriteString(`{"seq":3,"op":"commit","tx":2}` + "\n")
+	file.Close()
+	if loaded, _ := reader.GetTransactions(); len(loaded) != 2 {
+		t.Errorf("Expected the committed unit of work to be read, got %d transactions", len(loaded))
+	}
+}